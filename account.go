@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/joho/godotenv"
+)
+
+// loginProviders are the identity providers offered on the sign-in screen,
+// in Tab order.
+var loginProviders = []string{"github", "google", "oidc"}
+
+// deviceAuth is the server's answer to a device-code request (RFC 8628).
+type deviceAuth struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	Interval        int    `json:"interval"`
+	ExpiresIn       int    `json:"expires_in"`
+	// expiresAt is when the code expires, worked out from ExpiresIn as it
+	// arrives; zero if the server gave no lifetime.
+	expiresAt time.Time
+}
+
+// deviceAuthMsg starts the device-code sign-in on the client side.
+type deviceAuthMsg struct {
+	device deviceAuth
+}
+
+// deviceTokenMsg is the result of one poll of the token endpoint.
+type deviceTokenMsg struct {
+	deviceCode string
+	account    string
+	pending    bool
+	slowDown   bool
+	err        error
+}
+
+// signedOutMsg reports that the stored account credentials were removed.
+type signedOutMsg struct{}
+
+// handleLoginInput handles input on the provider picker.
+func handleLoginInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "tab":
+		for i, provider := range loginProviders {
+			if provider == m.provider {
+				m.provider = loginProviders[(i+1)%len(loginProviders)]
+				break
+			}
+		}
+	case "enter":
+		return m, requestDeviceCode(m.provider)
+	case "esc":
+		m.goTo(stateMenu)
+	}
+	return m, nil
+}
+
+// handleDeviceAuthInput handles input while waiting for the user to approve
+// the sign-in in their browser.
+func handleDeviceAuthInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.String() == "esc" {
+		m.goTo(stateMenu)
+	}
+	return m, nil
+}
+
+// handleDeviceToken applies a poll result, scheduling the next poll while
+// the sign-in is still pending.
+func handleDeviceToken(m *Model, msg deviceTokenMsg) (tea.Model, tea.Cmd) {
+	// Ignore polls for a sign-in the user has since cancelled.
+	if m.state != stateDeviceAuth || msg.deviceCode != m.device.DeviceCode {
+		return m, nil
+	}
+
+	switch {
+	case msg.err != nil:
+		m.goTo(stateMenu)
+		m.status = notice(msg.err.Error())
+	case msg.slowDown:
+		m.device.Interval += 5
+		return m, pollDeviceToken(m.device, m.device.Interval)
+	case msg.pending:
+		return m, pollDeviceToken(m.device, m.device.Interval)
+	default:
+		m.account = msg.account
+		m.goTo(stateMenu)
+		m.status = success("Signed in as " + msg.account)
+	}
+	return m, nil
+}
+
+// renderProviderPicker renders the provider choices, highlighting the
+// selected one.
+func renderProviderPicker(selected string) string {
+	var providers []string
+	for _, provider := range loginProviders {
+		if provider == selected {
+			providers = append(providers, selectedStyle.Render("["+provider+"]"))
+		} else {
+			providers = append(providers, " "+provider+" ")
+		}
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, providers...)
+}
+
+// requestDeviceCode starts a device-code sign-in with the given provider.
+func requestDeviceCode(provider string) tea.Cmd {
+	return func() tea.Msg {
+		jsonData, err := json.Marshal(map[string]string{"provider": provider})
+		if err != nil {
+			return fmt.Errorf("error preparing request: %v", err)
+		}
+
+		call, err := newAPICall(opMetadata, "POST", serverURL+"/auth/device/code", bytes.NewBuffer(jsonData))
+		if err != nil {
+			return fmt.Errorf("error creating request: %v", err)
+		}
+		defer call.close()
+		call.req.Header.Set("Content-Type", "application/json")
+
+		resp, err := call.do()
+		if err != nil {
+			return fmt.Errorf("error connecting to server: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return call.fail(resp, "failed to start sign-in")
+		}
+
+		var device deviceAuth
+		if err := json.NewDecoder(resp.Body).Decode(&device); err != nil {
+			return fmt.Errorf("error parsing response: %v", err)
+		}
+		if device.Interval <= 0 {
+			device.Interval = 5
+		}
+		if device.ExpiresIn > 0 {
+			device.expiresAt = time.Now().Add(time.Duration(device.ExpiresIn) * time.Second)
+		}
+		return deviceAuthMsg{device: device}
+	}
+}
+
+// pollDeviceToken waits interval seconds and then asks whether the user has
+// approved the sign-in, giving up once the code has expired. An approved
+// sign-in is saved to .env.
+func pollDeviceToken(device deviceAuth, interval int) tea.Cmd {
+	return tea.Tick(time.Duration(interval)*time.Second, func(now time.Time) tea.Msg {
+		result := deviceTokenMsg{deviceCode: device.DeviceCode}
+		if !device.expiresAt.IsZero() && now.After(device.expiresAt) {
+			result.err = fmt.Errorf("sign-in code expired, please try again")
+			return result
+		}
+
+		jsonData, err := json.Marshal(map[string]string{"device_code": device.DeviceCode})
+		if err != nil {
+			result.err = fmt.Errorf("error preparing request: %v", err)
+			return result
+		}
+
+		call, err := newAPICall(opMetadata, "POST", serverURL+"/auth/device/token", bytes.NewBuffer(jsonData))
+		if err != nil {
+			result.err = fmt.Errorf("error creating request: %v", err)
+			return result
+		}
+		defer call.close()
+		call.req.Header.Set("Content-Type", "application/json")
+
+		resp, err := call.do()
+		if err != nil {
+			// A dropped poll is retried on the next tick.
+			result.pending = true
+			return result
+		}
+		defer resp.Body.Close()
+
+		var body struct {
+			AccessToken string `json:"access_token"`
+			AccountName string `json:"account_name"`
+			Error       string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			result.err = fmt.Errorf("error parsing response: %v", err)
+			return result
+		}
+
+		switch body.Error {
+		case "":
+		case "authorization_pending":
+			result.pending = true
+			return result
+		case "slow_down":
+			result.slowDown = true
+			return result
+		case "expired_token":
+			result.err = fmt.Errorf("sign-in code expired, please try again")
+			return result
+		case "access_denied":
+			result.err = fmt.Errorf("sign-in was denied")
+			return result
+		default:
+			result.err = fmt.Errorf("sign-in failed: %s", body.Error)
+			return result
+		}
+
+		if err := saveEnvValue("account_token", body.AccessToken); err != nil {
+			result.err = fmt.Errorf("error saving account token: %v", err)
+			return result
+		}
+		if err := saveEnvValue("account_name", body.AccountName); err != nil {
+			result.err = fmt.Errorf("error saving account name: %v", err)
+			return result
+		}
+		accountLocked.Store(false)
+		result.account = body.AccountName
+		return result
+	})
+}
+
+// signOut forgets the stored account credentials.
+func signOut() tea.Msg {
+	for _, key := range []string{"account_token", "account_name"} {
+		if err := saveEnvValue(key, ""); err != nil {
+			return fmt.Errorf("error signing out: %v", err)
+		}
+	}
+	return signedOutMsg{}
+}
+
+// envMu serializes changes to .env, which commands make concurrently.
+var envMu sync.Mutex
+
+// saveEnvValue sets key in .env, keeping the other values, and in the
+// process environment. An empty value removes the key. A .env that exists
+// but can't be read is left alone rather than replaced.
+func saveEnvValue(key, value string) error {
+	envMu.Lock()
+	defer envMu.Unlock()
+	env, err := godotenv.Read()
+	if errors.Is(err, fs.ErrNotExist) {
+		env = map[string]string{}
+	} else if err != nil {
+		return fmt.Errorf("error reading .env: %v", err)
+	}
+	if value == "" {
+		delete(env, key)
+		os.Unsetenv(key)
+	} else {
+		env[key] = value
+		os.Setenv(key, value)
+	}
+	return godotenv.Write(env, ".env")
+}
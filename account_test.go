@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSaveEnvValue(t *testing.T) {
+	inTempDir(t)
+	t.Setenv("account_name", "")
+	if err := os.WriteFile(".env", []byte("auth_token=abc\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveEnvValue("account_name", "me"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(".env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "auth_token") || !strings.Contains(string(data), "account_name") {
+		t.Errorf(".env lost a value: %q", data)
+	}
+	if got := os.Getenv("account_name"); got != "me" {
+		t.Errorf("account_name = %q in the environment", got)
+	}
+}
+
+func TestSaveEnvValueKeepsUnreadableFile(t *testing.T) {
+	inTempDir(t)
+	// A hand edit gone wrong: the file no longer parses.
+	const content = "auth_token=abc\nfoo bar\n"
+	if err := os.WriteFile(".env", []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveEnvValue("account_name", "me"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if data, _ := os.ReadFile(".env"); string(data) != content {
+		t.Errorf(".env was rewritten to %q", data)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// runAliasCommand implements `cshare alias [list | add <alias> <site> | rm <alias>]`.
+func runAliasCommand(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 || args[0] == "list" {
+		names := make([]string, 0, len(cfg.Aliases))
+		for name := range cfg.Aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%-20s %s\n", name, cfg.Aliases[name])
+		}
+		return nil
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: cshare alias add <alias> <site>")
+		}
+		if cfg.Aliases == nil {
+			cfg.Aliases = make(map[string]string)
+		}
+		cfg.Aliases[args[1]] = args[2]
+	case "rm":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: cshare alias rm <alias>")
+		}
+		if _, ok := cfg.Aliases[args[1]]; !ok {
+			return fmt.Errorf("no alias named %q", args[1])
+		}
+		delete(cfg.Aliases, args[1])
+	default:
+		return fmt.Errorf("unknown alias command %q", args[0])
+	}
+	return saveConfig(cfg)
+}
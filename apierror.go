@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// requestIDHeader carries the ID cshare gives each request, so a failure can
+// be matched with the server's logs. Servers that assign their own ID send
+// it back in the same header.
+const requestIDHeader = "X-Request-ID"
+
+// maxErrorBody caps how much of a server response an apiError keeps, and
+// maxShownBody how much of that fits on the error screen.
+const (
+	maxErrorBody = 4000
+	maxShownBody = 600
+)
+
+// redactedParams are query parameters whose values never leave the error
+// screen; passwords and two-factor codes are still sent in the URL to
+// servers that don't take them in headers.
+var redactedParams = []string{"password", "totp", "token", "auth_token", "secret", "code", "X-Amz-Credential", "X-Amz-Signature", "X-Amz-Security-Token"}
+
+// apiErrorMsg carries the failure of a command to Update. Commands return
+// plain errors, which guardCmd wraps, so failures stand apart from the
+// other messages by type.
+type apiErrorMsg struct {
+	err error
+}
+
+// apiError is a request the server answered with an error status. It keeps
+// everything the detailed error screen shows.
+type apiError struct {
+	what      string // e.g. "failed to download file"; empty to show just the body
+	status    int
+	body      string
+	method    string
+	url       string // with secrets redacted
+	requestID string
+}
+
+func (e *apiError) Error() string {
+	if e.what == "" {
+		return e.body
+	}
+	return e.what + ": " + e.body
+}
+
+// newRequestID returns a random ID for the X-Request-ID header.
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// fail reads an error response and describes it as an apiError.
+func (c *apiCall) fail(resp *http.Response, what string) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return c.failWith(resp, body, what)
+}
+
+// failWith is fail for a response whose body was already read.
+func (c *apiCall) failWith(resp *http.Response, body []byte, what string) error {
+	id := resp.Header.Get(requestIDHeader)
+	if id == "" {
+		id = c.req.Header.Get(requestIDHeader)
+	}
+	return &apiError{
+		what:      what,
+		status:    resp.StatusCode,
+		body:      strings.TrimSpace(string(body)),
+		method:    c.req.Method,
+		url:       redactURL(c.req.URL),
+		requestID: id,
+	}
+}
+
+// redactURL renders u with the values of secret query parameters hidden.
+func redactURL(u *url.URL) string {
+	redacted := *u
+	query := redacted.Query()
+	for _, param := range redactedParams {
+		if query.Has(param) {
+			query.Set(param, "REDACTED")
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	redacted.User = nil
+	return redacted.String()
+}
+
+// describe renders the error as plain text for the screen and clipboard.
+func (e *apiError) describe() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Error:      %s\n", e.what)
+	fmt.Fprintf(&b, "Status:     %d %s\n", e.status, http.StatusText(e.status))
+	fmt.Fprintf(&b, "Request:    %s %s\n", e.method, e.url)
+	fmt.Fprintf(&b, "Request ID: %s\n", e.requestID)
+	fmt.Fprintf(&b, "\nServer response:\n%s\n", e.body)
+	return b.String()
+}
+
+// handleErrorDetailInput handles input on the detailed error screen.
+func handleErrorDetailInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "c", "C":
+		if err := clipboard.WriteAll(m.lastError.describe()); err != nil {
+			m.status = notice(fmt.Sprintf("Error copying to clipboard: %v", err))
+		} else {
+			m.status = success("Error details copied to clipboard")
+		}
+	case "esc", "enter":
+		m.goTo(stateMenu)
+	}
+	return m, nil
+}
+
+// renderErrorDetail renders the detailed error screen.
+func renderErrorDetail(m Model) string {
+	e := m.lastError
+	body := e.body
+	if body == "" {
+		body = "(empty)"
+	}
+	if r := []rune(body); len(r) > maxShownBody {
+		body = string(r[:maxShownBody]) + "… (truncated; C copies all of it)"
+	}
+	return lipgloss.JoinVertical(lipgloss.Left,
+		errorStyle.UnsetPadding().Render(e.what),
+		"",
+		fmt.Sprintf("Status:     %d %s", e.status, http.StatusText(e.status)),
+		"Request:    "+e.method+" "+e.url,
+		"Request ID: "+highlightStyle.Render(e.requestID),
+		"",
+		"Server response:",
+		body,
+	)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// apiVersionHeader carries the API version: on requests, the newest
+	// one cshare speaks; on responses, the one the server answered in.
+	apiVersionHeader = "X-Cshare-API-Version"
+	// clientAPIVersion is the newest API version cshare understands.
+	// Version 1 servers ignore the header and never send it back.
+	clientAPIVersion = 2
+)
+
+// responseAPIVersion returns the API version a response is written in.
+// Responses without the header come from version 1 servers.
+func responseAPIVersion(resp *http.Response) int {
+	v, err := strconv.Atoi(resp.Header.Get(apiVersionHeader))
+	if err != nil || v < 1 {
+		return 1
+	}
+	return v
+}
+
+// checkAPIVersion refuses a response in a version newer than cshare knows,
+// which a server should only send when it can't speak an older one.
+func checkAPIVersion(version int) error {
+	if version > clientAPIVersion {
+		return fmt.Errorf("the server answered with API version %d but this cshare only understands up to %d; please update cshare", version, clientAPIVersion)
+	}
+	return nil
+}
+
+// fileV2 is a file as version 2 servers describe it: the name is split
+// from the folder it is in, and size, modification time and free-form
+// metadata come along.
+type fileV2 struct {
+	ID          int               `json:"id"`
+	Name        string            `json:"name"`
+	Folder      string            `json:"folder"`
+	ContentType string            `json:"content_type"`
+	Size        int64             `json:"size"`
+	ModifiedAt  time.Time         `json:"modified_at"`
+	Tags        []string          `json:"tags"`
+	Metadata    map[string]string `json:"metadata"`
+}
+
+func (f fileV2) fileInfo() FileInfo {
+	return FileInfo{
+		ID:          f.ID,
+		FileName:    f.Name,
+		ContentType: f.ContentType,
+		Tags:        f.Tags,
+		Folder:      f.Folder,
+		Size:        f.Size,
+		ModifiedAt:  f.ModifiedAt,
+		Metadata:    f.Metadata,
+	}
+}
+
+// decodeFile reads one file of a listing written in version.
+func decodeFile(version int, data []byte) (FileInfo, error) {
+	if err := checkAPIVersion(version); err != nil {
+		return FileInfo{}, err
+	}
+	if version == 1 {
+		var file FileInfo
+		err := json.Unmarshal(data, &file)
+		return file, err
+	}
+	var file fileV2
+	if err := json.Unmarshal(data, &file); err != nil {
+		return FileInfo{}, err
+	}
+	return file.fileInfo(), nil
+}
+
+// decodeFiles reads the files of a listing written in version. A missing
+// list is an empty site.
+func decodeFiles(version int, data json.RawMessage) ([]FileInfo, error) {
+	if err := checkAPIVersion(version); err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	files := make([]FileInfo, 0, len(raw))
+	for _, item := range raw {
+		file, err := decodeFile(version, item)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Archive formats that can be inspected.
+const (
+	archiveZip    = "zip"
+	archiveTarGz  = "tar.gz"
+	archiveTarZst = "tar.zst"
+	archiveTar    = "tar"
+)
+
+// errNoRanges means the server sends whole files only, so a zip archive
+// has to be downloaded before its contents can be listed.
+var errNoRanges = errors.New("server does not support range requests")
+
+// archiveEntry is a file or folder inside an archive.
+type archiveEntry struct {
+	name  string
+	size  int64
+	isDir bool
+}
+
+// archiveListedMsg carries the contents of an archive on the site.
+type archiveListedMsg struct {
+	file    FileInfo
+	entries []archiveEntry
+	err     error
+}
+
+// archiveFormat returns the format of an archive by its name, or "".
+func archiveFormat(fileName string) string {
+	name := strings.ToLower(fileName)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return archiveZip
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return archiveTarGz
+	case strings.HasSuffix(name, ".tar.zst"), strings.HasSuffix(name, ".tzst"):
+		return archiveTarZst
+	case strings.HasSuffix(name, ".tar"):
+		return archiveTar
+	}
+	return ""
+}
+
+// rangeChunk is the least a remoteFile fetches per request. The zip
+// reader reads in small pieces; fetching ahead saves round trips.
+const rangeChunk = 64 << 10
+
+// remoteFile reads a file on the server with range requests, so a zip
+// archive's directory can be read without downloading the whole archive.
+// It keeps the last chunk fetched.
+type remoteFile struct {
+	fileID int
+	size   int64
+	buf    []byte
+	bufOff int64
+}
+
+// openRemoteFile learns a file's size from a one-byte range request.
+func openRemoteFile(fileID int) (*remoteFile, error) {
+	_, size, err := fetchRange(fileID, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteFile{fileID: fileID, size: size}, nil
+}
+
+func (f *remoteFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= f.size {
+		return 0, io.EOF
+	}
+	end := min(off+int64(len(p)), f.size)
+	if off < f.bufOff || end > f.bufOff+int64(len(f.buf)) {
+		data, _, err := fetchRange(f.fileID, off, min(max(end-off, rangeChunk), f.size-off))
+		if err != nil {
+			return 0, err
+		}
+		f.buf, f.bufOff = data, off
+	}
+	copied := copy(p, f.buf[off-f.bufOff:])
+	if copied < len(p) {
+		return copied, io.EOF
+	}
+	return copied, nil
+}
+
+// fetchRange fetches n bytes of a file from off and returns them with the
+// file's total size. It returns errNoRanges if the server sends the whole
+// file instead.
+func fetchRange(fileID int, off, n int64) ([]byte, int64, error) {
+	authToken, err := loadAuthToken()
+	if err != nil {
+		return nil, 0, err
+	}
+	call, err := newAPICall(opTransfer, "GET", fmt.Sprintf("%s/getfile/%d", serverURL, fileID), nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+	call.req.Header.Set("Authorization", authToken.reveal())
+	call.req.Header.Set("Accept", downloadAccept)
+	// Offsets refer to the file itself, not a compressed encoding of it.
+	call.req.Header.Set("Accept-Encoding", "identity")
+	call.req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+n-1))
+
+	resp, err := call.do()
+	if err != nil {
+		return nil, 0, fmt.Errorf("error connecting to server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		return nil, 0, errNoRanges
+	default:
+		return nil, 0, call.fail(resp, "failed to read archive")
+	}
+	// Content-Range: bytes 0-0/12345
+	_, total, _ := strings.Cut(resp.Header.Get("Content-Range"), "/")
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return nil, 0, errNoRanges
+	}
+	data, err := io.ReadAll(io.LimitReader(call.watch(resp.Body), n))
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading archive: %v", err)
+	}
+	return data, size, nil
+}
+
+// listArchive lists the contents of an archive on the site. Zip archives
+// are read with range requests where the server supports them; tar
+// archives have no index and are streamed until the end.
+func listArchive(siteName string, file FileInfo) tea.Cmd {
+	return func() tea.Msg {
+		msg := archiveListedMsg{file: file}
+		if archiveFormat(file.FileName) == archiveZip {
+			rf, err := openRemoteFile(file.ID)
+			if err == nil {
+				msg.entries, msg.err = zipEntries(rf, rf.size)
+				return msg
+			}
+			if !errors.Is(err, errNoRanges) {
+				msg.err = err
+				return msg
+			}
+		}
+		msg.err = withArchive(siteName, file, func(r io.ReaderAt, size int64, tr *tar.Reader) error {
+			var err error
+			if tr == nil {
+				msg.entries, err = zipEntries(r, size)
+				return err
+			}
+			for {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return fmt.Errorf("error reading archive: %v", err)
+				}
+				msg.entries = append(msg.entries, archiveEntry{name: hdr.Name, size: hdr.Size, isDir: hdr.Typeflag == tar.TypeDir})
+			}
+		})
+		return msg
+	}
+}
+
+// zipEntries reads the directory of a zip archive.
+func zipEntries(r io.ReaderAt, size int64) ([]archiveEntry, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("error reading archive: %v", err)
+	}
+	entries := make([]archiveEntry, len(zr.File))
+	for i, f := range zr.File {
+		entries[i] = archiveEntry{name: f.Name, size: int64(f.UncompressedSize64), isDir: f.FileInfo().IsDir()}
+	}
+	return entries, nil
+}
+
+// withArchive downloads an archive and opens it: zip archives through a
+// temporary file passed as r, tar archives as a stream passed as tr.
+func withArchive(siteName string, file FileInfo, use func(r io.ReaderAt, size int64, tr *tar.Reader) error) error {
+	if archiveFormat(file.FileName) == archiveZip {
+		tmp, err := os.CreateTemp("", "cshare-archive-*.zip")
+		if err != nil {
+			return fmt.Errorf("error creating temporary file: %v", err)
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		size, err := fetchFile(siteName, file.ID, file.FileName, tmp)
+		if err != nil {
+			return err
+		}
+		return use(tmp, size, nil)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := fetchFile(siteName, file.ID, file.FileName, pw)
+		pw.CloseWithError(err)
+	}()
+	// Stop the download if use returns before reading everything.
+	defer pr.Close()
+
+	tr, stop, err := tarReader(archiveFormat(file.FileName), pr)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	return use(nil, 0, tr)
+}
+
+// extractMember downloads one file of an archive on the site to dest.
+func extractMember(siteName string, file FileInfo, member, dest string) tea.Cmd {
+	return func() tea.Msg {
+		copyMember := func(r io.Reader) error {
+			return saveFile(dest, func(w io.Writer) (int64, error) { return io.Copy(w, r) })
+		}
+		fromZip := func(r io.ReaderAt, size int64) error {
+			zr, err := zip.NewReader(r, size)
+			if err != nil {
+				return fmt.Errorf("error reading archive: %v", err)
+			}
+			for _, f := range zr.File {
+				if f.Name != member {
+					continue
+				}
+				rc, err := f.Open()
+				if err != nil {
+					return fmt.Errorf("error reading %s: %v", member, err)
+				}
+				defer rc.Close()
+				return copyMember(rc)
+			}
+			return fmt.Errorf("%s not found in %s", member, file.FileName)
+		}
+
+		err := errNoRanges
+		if archiveFormat(file.FileName) == archiveZip {
+			var rf *remoteFile
+			if rf, err = openRemoteFile(file.ID); err == nil {
+				err = fromZip(rf, rf.size)
+			}
+		}
+		if errors.Is(err, errNoRanges) {
+			err = withArchive(siteName, file, func(r io.ReaderAt, size int64, tr *tar.Reader) error {
+				if tr == nil {
+					return fromZip(r, size)
+				}
+				for {
+					hdr, err := tr.Next()
+					if err == io.EOF {
+						return fmt.Errorf("%s not found in %s", member, file.FileName)
+					}
+					if err != nil {
+						return fmt.Errorf("error reading archive: %v", err)
+					}
+					if hdr.Name == member {
+						return copyMember(tr)
+					}
+				}
+			})
+		}
+		if err != nil {
+			return err
+		}
+		return downloadDoneMsg{
+			path:   dest,
+			status: success(fmt.Sprintf("Extracted %s to %s (o - Open • O - Show in Folder)", path.Base(member), dest)),
+		}
+	}
+}
+
+// openArchive lists the selected archive.
+func openArchive(m *Model) tea.Cmd {
+	file, ok := selectedFile(*m)
+	if !ok {
+		return nil
+	}
+	if archiveFormat(file.FileName) == "" {
+		m.status = notice("Only .zip, .tar, .tar.gz and .tar.zst archives can be inspected")
+		return nil
+	}
+	m.archiveFile = file
+	m.archiveEntries = nil
+	m.archiveIdx = 0
+	m.archiveErr = nil
+	m.goTo(stateArchive)
+	return trackTransfer(m, listArchive(m.siteName, file))
+}
+
+// handleArchiveListed shows the contents of the archive.
+func handleArchiveListed(m *Model, msg archiveListedMsg) (tea.Model, tea.Cmd) {
+	if m.state != stateArchive || msg.file.ID != m.archiveFile.ID {
+		return m, nil
+	}
+	m.archiveEntries = msg.entries
+	m.archiveErr = msg.err
+	if msg.err == nil && len(msg.entries) == 0 {
+		m.archiveErr = fmt.Errorf("the archive is empty")
+	}
+	return m, nil
+}
+
+// handleArchiveInput moves through the archive's contents; Enter downloads
+// the selected file on its own.
+func handleArchiveInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up":
+		if m.archiveIdx > 0 {
+			m.archiveIdx--
+		}
+	case "down":
+		if m.archiveIdx < len(m.archiveEntries)-1 {
+			m.archiveIdx++
+		}
+	case "enter":
+		if m.archiveIdx >= len(m.archiveEntries) || m.archiveEntries[m.archiveIdx].isDir {
+			return m, nil
+		}
+		member := m.archiveEntries[m.archiveIdx].name
+		dest := downloadDest(m.siteName, pathElement(path.Base(member)))
+		if m.dryRun {
+			return m, showPlan(m, "Dry run: extract", []plannedAction{{action: "download", path: dest, size: m.archiveEntries[m.archiveIdx].size, reason: "from " + m.archiveFile.FileName}})
+		}
+		start := func(m *Model) tea.Cmd {
+			return trackTransfer(m, extractMember(m.siteName, m.archiveFile, member, dest))
+		}
+		if _, err := os.Stat(dest); err == nil {
+			askConfirm(m, "Overwrite "+path.Base(member)+"?", dest+" already exists.", start)
+			return m, nil
+		}
+		return m, start(m)
+	case "esc":
+		m.goTo(stateViewFiles)
+	}
+	return m, nil
+}
+
+// renderArchive renders the visible entries of the archive.
+func renderArchive(m Model) string {
+	switch {
+	case m.archiveErr != nil:
+		return errorStyle.UnsetPadding().Render(m.archiveErr.Error())
+	case m.archiveEntries == nil:
+		return mutedStyle.Render("Reading the archive...")
+	}
+	offset := max(0, m.archiveIdx-visibleFiles+1)
+	end := min(offset+visibleFiles, len(m.archiveEntries))
+	var lines []string
+	var total int64
+	for _, e := range m.archiveEntries {
+		total += e.size
+	}
+	for i := offset; i < end; i++ {
+		e := m.archiveEntries[i]
+		size := formatBytes(e.size)
+		if e.isDir {
+			size = "-"
+		}
+		line := fmt.Sprintf("%s %10s", padName(e.name, 44), size)
+		if i == m.archiveIdx {
+			lines = append(lines, selectedStyle.Render("➜  "+line))
+		} else {
+			lines = append(lines, "   "+line)
+		}
+	}
+	lines = append(lines, "", mutedStyle.Render(fmt.Sprintf("%d entries, %s unpacked", len(m.archiveEntries), formatBytes(total))))
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuditEvent is one entry of a site's server-side audit trail: an upload,
+// download, deletion or sign-in, who did it and from where.
+type AuditEvent struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	User   string    `json:"user,omitempty"`
+	File   string    `json:"file,omitempty"`
+	IP     string    `json:"ip,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// parseSince reads an audit --since: an age such as "7d", "12h" or "30m",
+// or a date as YYYY-MM-DD.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if age, err := time.ParseDuration(value); err == nil && age >= 0 {
+		return now.Add(-age), nil
+	}
+	if day, err := time.ParseInLocation(historyDateLayout, value, time.Local); err == nil {
+		return day, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: use an age such as 7d or 12h, or a date as YYYY-MM-DD", value)
+}
+
+// fetchAuditLog fetches a site's audit trail from since on, oldest first.
+// Only the site's owner can read it.
+func fetchAuditLog(siteName string, since time.Time) ([]AuditEvent, error) {
+	authToken, err := loadAuthToken()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := fmt.Sprintf("%s/site/%s/audit", serverURL, url.PathEscape(siteName))
+	if !since.IsZero() {
+		endpoint += "?since=" + url.QueryEscape(since.UTC().Format(time.RFC3339))
+	}
+	call, err := newAPICall(opMetadata, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+	call.req.Header.Set("Authorization", authToken.reveal())
+
+	resp, err := call.do()
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusNotImplemented:
+		return nil, fmt.Errorf("the server keeps no audit log for %s", siteName)
+	default:
+		return nil, call.fail(resp, "failed to fetch the audit log")
+	}
+
+	var result struct {
+		Events []AuditEvent `json:"events"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+	return result.Events, nil
+}
+
+// formatAuditEvent renders an event as a line for `cshare audit`.
+func formatAuditEvent(event AuditEvent) string {
+	line := fmt.Sprintf("%s %-10s %-16s %-32s %s", event.Time.Local().Format("2006-01-02 15:04:05"),
+		event.Action, cmp.Or(event.User, "-"), cmp.Or(event.File, "-"), cmp.Or(event.IP, "-"))
+	if event.Detail != "" {
+		line += "  " + event.Detail
+	}
+	return strings.TrimRight(line, " ")
+}
+
+// runAuditCommand implements
+// `cshare audit [--site <site>] [--since <age or date>] [--json]`, which
+// prints a site's audit trail, or writes it as JSON for archiving.
+func runAuditCommand(args []string) error {
+	const usage = "usage: cshare audit [--site <site>] [--since 7d|YYYY-MM-DD] [--json]"
+	var (
+		site   string
+		since  time.Time
+		asJSON bool
+	)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--json" {
+			asJSON = true
+			continue
+		}
+		if i+1 == len(args) {
+			return fmt.Errorf("%s", usage)
+		}
+		i++
+		switch arg {
+		case "--site":
+			site = resolveSite(args[i])
+		case "--since":
+			var err error
+			if since, err = parseSince(args[i], time.Now()); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s", usage)
+		}
+	}
+	if site == "" {
+		cached, ok := cachedSite()
+		if !ok {
+			return fmt.Errorf("no site given and none logged in: use --site, or open a site first")
+		}
+		site = cached
+	}
+
+	events, err := fetchAuditLog(site, since)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if events == nil {
+			events = []AuditEvent{}
+		}
+		return enc.Encode(events)
+	}
+	if len(events) == 0 {
+		fmt.Println("No audit events found.")
+		return nil
+	}
+	for _, event := range events {
+		fmt.Println(formatAuditEvent(event))
+	}
+	return nil
+}
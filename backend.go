@@ -0,0 +1,147 @@
+package main
+
+import (
+	"hash/fnv"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// backend is what the core flows (create a site, open it, upload, download,
+// delete) need from the server. The UI reaches the server through api, so a
+// test can swap in its own implementation, or keep httpBackend and point it
+// at a fake server (see newFakeServer).
+type backend interface {
+	createSite(siteName string, password secret, enableTOTP bool, ttl time.Duration) tea.Cmd
+	openSite(siteName string, password, totpCode secret) tea.Cmd
+	upload(siteName string, password secret, path, name string) (uploadedMsg, error)
+	download(siteName string, fileID int, fileName, dest string) tea.Cmd
+	deleteFile(siteName string, fileID int, fileName string) tea.Cmd
+}
+
+// api is the backend in use.
+var api backend = siteBackends{}
+
+// siteBackends sends each site's calls to the backend serving it: the
+// store cshare.json puts it in (see storeFor), else cshare's servers.
+type siteBackends struct{}
+
+func backendFor(siteName string) backend {
+	if store, ok := storeFor(siteName); ok {
+		return store
+	}
+	return httpBackend{}
+}
+
+// fileStore is a backend that keeps a site somewhere other than cshare's
+// servers. Listing and fetching files also happen outside the backend's
+// flows (background refreshes, backups, signatures), so it does both on
+// its own.
+type fileStore interface {
+	backend
+	list(siteName string) ([]FileInfo, error)
+	fetch(siteName string, fileID int, fileName string, w io.Writer) (int64, error)
+}
+
+// storeFor returns the store that keeps a site, when cshare.json puts the
+// site in one.
+func storeFor(siteName string) (fileStore, bool) {
+	cfg := currentConfig()
+	if b, ok := cfg.Buckets[siteName]; ok {
+		return bucketBackend{bucket: b}, true
+	}
+	if server, ok := cfg.SFTP[siteName]; ok {
+		return sftpBackend{server: server}, true
+	}
+	if share, ok := cfg.WebDAV[siteName]; ok {
+		return webdavBackend{share: share}, true
+	}
+	return nil, false
+}
+
+func (siteBackends) createSite(siteName string, password secret, enableTOTP bool, ttl time.Duration) tea.Cmd {
+	return backendFor(siteName).createSite(siteName, password, enableTOTP, ttl)
+}
+
+func (siteBackends) openSite(siteName string, password, totpCode secret) tea.Cmd {
+	return backendFor(siteName).openSite(siteName, password, totpCode)
+}
+
+func (siteBackends) upload(siteName string, password secret, path, name string) (uploadedMsg, error) {
+	return backendFor(siteName).upload(siteName, password, path, name)
+}
+
+func (siteBackends) download(siteName string, fileID int, fileName, dest string) tea.Cmd {
+	return backendFor(siteName).download(siteName, fileID, fileName, dest)
+}
+
+func (siteBackends) deleteFile(siteName string, fileID int, fileName string) tea.Cmd {
+	return backendFor(siteName).deleteFile(siteName, fileID, fileName)
+}
+
+// httpBackend talks to cshare's servers over HTTP.
+type httpBackend struct{}
+
+func (httpBackend) createSite(siteName string, password secret, enableTOTP bool, ttl time.Duration) tea.Cmd {
+	return createSite(siteName, password, enableTOTP, ttl)
+}
+
+func (httpBackend) openSite(siteName string, password, totpCode secret) tea.Cmd {
+	return fetchFiles(siteName, password, totpCode)
+}
+
+func (httpBackend) upload(siteName string, password secret, path, name string) (uploadedMsg, error) {
+	return uploadAndVerify(siteName, password, path, name)
+}
+
+func (httpBackend) download(siteName string, fileID int, fileName, dest string) tea.Cmd {
+	return downloadFile(siteName, fileID, fileName, dest)
+}
+
+func (httpBackend) deleteFile(siteName string, fileID int, fileName string) tea.Cmd {
+	return deleteFile(siteName, fileID, fileName)
+}
+
+// remoteFiles maps the IDs the file list uses to the paths of files in
+// stores, which have no IDs of their own. IDs are hashed from the paths so
+// they stay the same between runs, for pins and the download cache.
+var remoteFiles struct {
+	sync.Mutex
+	keys map[int]string // ID -> site + "\x00" + key
+}
+
+// remoteFileID returns the ID of a file of a site stored at key.
+func remoteFileID(siteName, key string) int {
+	entry := siteName + "\x00" + key
+	h := fnv.New32a()
+	h.Write([]byte(entry))
+	id := int(h.Sum32()&0x7fffffff) + 1
+
+	remoteFiles.Lock()
+	defer remoteFiles.Unlock()
+	if remoteFiles.keys == nil {
+		remoteFiles.keys = make(map[int]string)
+	}
+	for {
+		known, ok := remoteFiles.keys[id]
+		if !ok {
+			remoteFiles.keys[id] = entry
+			return id
+		}
+		if known == entry {
+			return id
+		}
+		id++ // a hash collision
+	}
+}
+
+// remoteKey returns the key of the file of a site with the given ID, as
+// last listed.
+func remoteKey(siteName string, fileID int) (string, bool) {
+	remoteFiles.Lock()
+	defer remoteFiles.Unlock()
+	return strings.CutPrefix(remoteFiles.keys[fileID], siteName+"\x00")
+}
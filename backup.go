@@ -0,0 +1,240 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// backupTimeLayout stamps backup archives, in UTC so they sort by name.
+const backupTimeLayout = "20060102-150405"
+
+// backupArchive is a backup on a site: "<folder>-backup-<stamp>.zip".
+type backupArchive struct {
+	file  FileInfo
+	taken time.Time
+}
+
+// backupName names the archive of a backup of folder taken at t.
+func backupName(folder string, t time.Time) string {
+	return pathElement(filepath.Base(folder)) + "-backup-" + t.UTC().Format(backupTimeLayout) + ".zip"
+}
+
+// siteBackups lists the backups of folder among a site's files, newest
+// first.
+func siteBackups(files []FileInfo, folder string) []backupArchive {
+	prefix := pathElement(filepath.Base(folder)) + "-backup-"
+	var backups []backupArchive
+	for _, file := range files {
+		stamp, ok := strings.CutPrefix(file.FileName, prefix)
+		if !ok {
+			continue
+		}
+		taken, err := time.Parse(backupTimeLayout, strings.TrimSuffix(stamp, ".zip"))
+		if err != nil || !strings.HasSuffix(stamp, ".zip") {
+			continue
+		}
+		backups = append(backups, backupArchive{file: file, taken: taken})
+	}
+	slices.SortFunc(backups, func(a, b backupArchive) int { return b.taken.Compare(a.taken) })
+	return backups
+}
+
+// writeBackup zips the files under folder that aren't excluded (see
+// collectFiles) into archive, with paths relative to folder. It returns
+// how many files went in.
+func writeBackup(folder string, excludes []string, archive string) (int, error) {
+	paths, err := collectFiles(folder, excludes)
+	if err != nil {
+		return 0, fmt.Errorf("error reading %s: %v", folder, err)
+	}
+	out, err := os.Create(archive)
+	if err != nil {
+		return 0, fmt.Errorf("error creating archive: %v", err)
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	for _, path := range paths {
+		rel, err := filepath.Rel(folder, path)
+		if err != nil {
+			return 0, fmt.Errorf("error reading %s: %v", path, err)
+		}
+		if err := addToZip(zw, path, filepath.ToSlash(rel)); err != nil {
+			return 0, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return 0, fmt.Errorf("error writing archive: %v", err)
+	}
+	return len(paths), out.Close()
+}
+
+// addToZip copies one file into the archive, keeping its modification time.
+func addToZip(zw *zip.Writer, path, name string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %v", path, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", path, err)
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", path, err)
+	}
+	header.Name, header.Method = name, zip.Deflate
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("error writing archive: %v", err)
+	}
+	if _, err := io.Copy(w, file); err != nil {
+		return fmt.Errorf("error archiving %s: %v", path, err)
+	}
+	return nil
+}
+
+// sitePassword returns the password of a site for a command run outside
+// the TUI: CSHARE_PASSWORD when set, or else asked for on the terminal
+// without echoing it. Sites kept in another store (see storeFor) need
+// none.
+func sitePassword(siteName string) (secret, error) {
+	if _, ok := storeFor(siteName); ok {
+		return "", nil
+	}
+	if password := os.Getenv("CSHARE_PASSWORD"); password != "" {
+		return secret(password), nil
+	}
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return "", fmt.Errorf("the password of %s is needed: set CSHARE_PASSWORD", siteName)
+	}
+	fmt.Printf("Password for %s: ", siteName)
+	password, err := term.ReadPassword(os.Stdin.Fd())
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("error reading password: %v", err)
+	}
+	return secret(password), nil
+}
+
+// runBackupCommand implements
+// `cshare backup <folder> [--site <site>] [--keep <n>]`: it uploads a
+// timestamped zip of the folder and then deletes all but the newest n
+// backups of it on the site. --exclude and .cshareignore files leave files
+// out as for uploads; --dry-run only says what would happen.
+func runBackupCommand(args, excludes []string, dryRun bool) error {
+	const usage = "usage: cshare backup <folder> [--site <site>] [--keep <n>]"
+	var folder, site string
+	keep := 0
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			if folder != "" {
+				return fmt.Errorf("%s", usage)
+			}
+			folder = arg
+			continue
+		}
+		if i+1 == len(args) {
+			return fmt.Errorf("%s", usage)
+		}
+		i++
+		switch arg {
+		case "--site":
+			site = resolveSite(args[i])
+		case "--keep":
+			n, err := strconv.Atoi(args[i])
+			if err != nil || n < 1 {
+				return fmt.Errorf("--keep needs the number of backups to keep, 1 or more")
+			}
+			keep = n
+		default:
+			return fmt.Errorf("%s", usage)
+		}
+	}
+	if folder == "" {
+		return fmt.Errorf("%s", usage)
+	}
+	if info, err := os.Stat(folder); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s is not a folder", folder)
+	}
+	if abs, err := filepath.Abs(folder); err == nil {
+		folder = abs // so that "." is named after the folder it is
+	}
+	if site == "" {
+		cached, ok := cachedSite()
+		if !ok {
+			return fmt.Errorf("no site given and none logged in: use --site, or open a site first")
+		}
+		site = cached
+	}
+	password, err := sitePassword(site)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	name := backupName(folder, now)
+	if dryRun {
+		files, err := fetchFilesDirectly(site, password)
+		if err != nil {
+			return err
+		}
+		paths, err := collectFiles(folder, excludes)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", folder, err)
+		}
+		fmt.Printf("Would upload %s (%d files) to %s\n", name, len(paths), site)
+		backups := append([]backupArchive{{file: FileInfo{FileName: name}, taken: now}}, siteBackups(files, folder)...)
+		for _, old := range prunable(backups, keep) {
+			fmt.Printf("Would delete %s\n", old.file.FileName)
+		}
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", "cshare-backup-*")
+	if err != nil {
+		return fmt.Errorf("error creating archive: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	archive := filepath.Join(dir, name)
+	count, err := writeBackup(folder, excludes, archive)
+	if err != nil {
+		return err
+	}
+	result, err := api.upload(site, password, archive, name)
+	if err != nil {
+		return err
+	}
+	if !result.verified {
+		return fmt.Errorf("%s: %s", name, result.status.text)
+	}
+	fmt.Printf("Backed up %d files of %s to %s as %s\n", count, folder, site, name)
+
+	for _, old := range prunable(siteBackups(result.files, folder), keep) {
+		if err, ok := api.deleteFile(site, old.file.ID, old.file.FileName)().(error); ok {
+			return err
+		}
+		fmt.Printf("Deleted %s\n", old.file.FileName)
+	}
+	return nil
+}
+
+// prunable returns the backups beyond the newest keep, which the retention
+// policy deletes. A keep of 0 keeps them all.
+func prunable(backups []backupArchive, keep int) []backupArchive {
+	if keep == 0 || len(backups) <= keep {
+		return nil
+	}
+	return backups[keep:]
+}
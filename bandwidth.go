@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// transferPriority orders transfers competing for the bandwidth limit.
+type transferPriority int32
+
+const (
+	priorityLow transferPriority = iota
+	priorityNormal
+	priorityHigh
+)
+
+var priorityNames = [...]string{"low", "normal", "high"}
+
+func (p transferPriority) String() string {
+	return priorityNames[p]
+}
+
+const (
+	// limitChunk caps each read of a limited transfer, so the bucket hands
+	// out bandwidth in small, fair slices.
+	limitChunk = 16 << 10
+	// transfersWidth fits a priority label next to a progress row.
+	transfersWidth = 82
+	// maxLimitDelay caps a single wait, so a limit lifted or a priority
+	// changed from the transfers screen takes effect quickly.
+	maxLimitDelay = 100 * time.Millisecond
+)
+
+// bandwidthLimiter is a token bucket shared by all transfers. Tokens are
+// bytes, refilled at rate per second up to one second's worth. A transfer
+// only takes tokens while no higher-priority transfer is waiting for them.
+type bandwidthLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // bytes per second; 0 means unlimited
+	tokens  float64
+	last    time.Time
+	waiting [len(priorityNames)]int
+}
+
+// bandwidth is the limiter every counted transfer goes through.
+var bandwidth bandwidthLimiter
+
+// prioritizeDownloads gives new downloads high and new uploads low
+// priority, as set by CSHARE_PRIORITIZE_DOWNLOADS or toggled on the
+// transfers screen.
+var prioritizeDownloads atomic.Bool
+
+// initBandwidth reads CSHARE_BANDWIDTH_LIMIT and CSHARE_PRIORITIZE_DOWNLOADS
+// once the environment is loaded.
+func initBandwidth() {
+	if value := os.Getenv("CSHARE_BANDWIDTH_LIMIT"); value != "" {
+		rate, err := parseRate(value)
+		if err != nil {
+			fmt.Printf("Ignoring CSHARE_BANDWIDTH_LIMIT: %v\n", err)
+		}
+		bandwidth.setRate(rate)
+	}
+	if envOn("CSHARE_PRIORITIZE_DOWNLOADS") {
+		prioritizeDownloads.Store(true)
+	}
+}
+
+// parseRate reads a bandwidth such as "500K", "2M" or "1.5MB" (per second,
+// binary units) or a plain number of bytes per second. "unlimited", like
+// 0, means no limit.
+func parseRate(value string) (float64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	if s == "UNLIMITED" {
+		return 0, nil
+	}
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "/S"), "B")
+	s = strings.TrimSuffix(s, "I")
+	mult := 1.0
+	if s != "" {
+		if i := strings.IndexByte("KMG", s[len(s)-1]); i >= 0 {
+			mult = float64(int64(1) << (10 * (i + 1)))
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid bandwidth %q (use e.g. 500K or 2M)", value)
+	}
+	return n * mult, nil
+}
+
+// setRate changes the limit; 0 lifts it.
+func (l *bandwidthLimiter) setRate(rate float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.tokens = 0
+	l.last = time.Now()
+}
+
+// limit returns the current limit in bytes per second, 0 if unlimited.
+func (l *bandwidthLimiter) limit() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// refill adds the tokens earned since the last refill. l.mu must be held.
+func (l *bandwidthLimiter) refill() {
+	now := time.Now()
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.rate)
+	l.last = now
+}
+
+// outranked reports whether a transfer of a higher priority than p is
+// waiting. l.mu must be held.
+func (l *bandwidthLimiter) outranked(p transferPriority) bool {
+	for q := int(p) + 1; q < len(l.waiting); q++ {
+		if l.waiting[q] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// wait blocks until n bytes at priority p fit the limit, or ctx ends. The
+// bucket may go into debt by one read; later readers wait it off.
+func (l *bandwidthLimiter) wait(ctx context.Context, n int, p transferPriority) error {
+	queued := false
+	defer func() {
+		if queued {
+			l.mu.Lock()
+			l.waiting[p]--
+			l.mu.Unlock()
+		}
+	}()
+	for {
+		l.mu.Lock()
+		if l.rate <= 0 {
+			l.mu.Unlock()
+			return nil
+		}
+		l.refill()
+		if l.tokens > 0 && !l.outranked(p) {
+			l.tokens -= float64(n)
+			l.mu.Unlock()
+			return nil
+		}
+		if !queued {
+			l.waiting[p]++
+			queued = true
+		}
+		delay := maxLimitDelay
+		if l.tokens <= 0 {
+			delay = min(delay, time.Duration(-l.tokens/l.rate*float64(time.Second))+time.Millisecond)
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-time.After(delay):
+		}
+	}
+}
+
+// defaultPriority is the priority a new transfer starts with.
+func defaultPriority(direction string) transferPriority {
+	if !prioritizeDownloads.Load() {
+		return priorityNormal
+	}
+	if direction == directionDownload {
+		return priorityHigh
+	}
+	return priorityLow
+}
+
+// openTransfers shows the transfers screen on top of the current one.
+func openTransfers(m *Model) {
+	m.transfersReturn = m.state
+	m.transferIdx = 0
+	m.goTo(stateTransfers)
+}
+
+// handleTransfersInput handles input on the transfers screen: selecting a
+// running transfer, changing its priority and toggling downloads first.
+func handleTransfersInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	rows := runningTransfers()
+	m.transferIdx = max(0, min(m.transferIdx, len(rows)-1))
+	switch msg.String() {
+	case "up":
+		if m.transferIdx > 0 {
+			m.transferIdx--
+		}
+	case "down":
+		if m.transferIdx < len(rows)-1 {
+			m.transferIdx++
+		}
+	case "+", "=", "right":
+		if m.transferIdx < len(rows) {
+			setTransferPriority(rows[m.transferIdx].id, +1)
+		}
+	case "-", "left":
+		if m.transferIdx < len(rows) {
+			setTransferPriority(rows[m.transferIdx].id, -1)
+		}
+	case "p", "P":
+		on := !prioritizeDownloads.Load()
+		prioritizeDownloads.Store(on)
+		resetTransferPriorities()
+		if on {
+			m.status = success("Downloads now go before uploads")
+		} else {
+			m.status = success("Downloads and uploads now share bandwidth equally")
+		}
+	case "esc", keyBindings["transfers"]:
+		m.goTo(m.transfersReturn)
+	}
+	return m, nil
+}
+
+// runningTransfers lists the transfers that haven't finished.
+func runningTransfers() []transferRow {
+	var rows []transferRow
+	for _, row := range transferSnapshot() {
+		if row.ended.IsZero() {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// setTransferPriority raises (by > 0) or lowers a running transfer's
+// priority by one step.
+func setTransferPriority(id, by int) {
+	liveTransfers.Lock()
+	defer liveTransfers.Unlock()
+	for _, t := range liveTransfers.list {
+		if t.id == id {
+			p := transferPriority(t.priority.Load()) + transferPriority(by)
+			t.priority.Store(int32(max(priorityLow, min(priorityHigh, p))))
+		}
+	}
+}
+
+// resetTransferPriorities applies the default priorities to the running
+// transfers after downloads first is toggled.
+func resetTransferPriorities() {
+	liveTransfers.Lock()
+	defer liveTransfers.Unlock()
+	for _, t := range liveTransfers.list {
+		t.priority.Store(int32(defaultPriority(t.direction)))
+	}
+}
+
+// renderTransfersScreen lists the running transfers with their priorities
+// and the limit they share.
+func renderTransfersScreen(m Model) string {
+	limit := "unlimited (set CSHARE_BANDWIDTH_LIMIT, e.g. 2M)"
+	if rate := bandwidth.limit(); rate > 0 {
+		limit = formatBytes(int64(rate)) + "/s shared by all transfers"
+	}
+	order := "downloads and uploads share equally"
+	if prioritizeDownloads.Load() {
+		order = "downloads first"
+	}
+	lines := []string{
+		"Bandwidth: " + limit,
+		"Priority:  " + order,
+		"",
+	}
+
+	rows := runningTransfers()
+	if len(rows) == 0 {
+		lines = append(lines, mutedStyle.Render("No transfers running."))
+	}
+	for i, row := range rows {
+		text := fmt.Sprintf("%-6s %s", row.priority, renderTransferRow(row, m.rates[row.id]))
+		if i == m.transferIdx {
+			lines = append(lines, selectedStyle.Render("➜ "+text))
+		} else {
+			lines = append(lines, "  "+text)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
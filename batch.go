@@ -0,0 +1,581 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// hashedFile is a file whose SHA-256 has been computed ahead of upload.
+type hashedFile struct {
+	path string
+	sum  string
+	err  error
+}
+
+// folderSelectMsg carries the folder picked for a batch upload.
+type folderSelectMsg struct {
+	path string
+	err  error
+}
+
+// uploadStartedMsg opens the progress view of a batch upload.
+type uploadStartedMsg struct {
+	stream <-chan uploadProgressMsg
+	stop   func()
+	total  int
+}
+
+// uploadProgressMsg reports batch upload progress: the counts so far, the
+// files being uploaded and the ones that failed. The last one has done set
+// and carries the refreshed listing and a summary.
+type uploadProgressMsg struct {
+	stream   <-chan uploadProgressMsg
+	hashed   int
+	uploaded int
+	sent     string // the local file uploaded since the last report, if any
+	active   []string
+	failed   []string
+	done     bool
+	files    []FileInfo
+	status   statusLine
+}
+
+// defaultUploadWorkers is how many files of a batch are uploaded at once
+// unless CSHARE_UPLOAD_WORKERS says otherwise.
+const (
+	defaultUploadWorkers = 3
+	maxUploadWorkers     = 16
+)
+
+// hashWorkers bounds how many files are hashed at once.
+func hashWorkers() int {
+	n := runtime.NumCPU()
+	if n > 8 {
+		n = 8
+	}
+	return n
+}
+
+// uploadWorkers reads CSHARE_UPLOAD_WORKERS, the number of files of a
+// batch uploaded in parallel.
+func uploadWorkers() int {
+	n, err := strconv.Atoi(os.Getenv("CSHARE_UPLOAD_WORKERS"))
+	if err != nil || n < 1 {
+		return defaultUploadWorkers
+	}
+	return min(n, maxUploadWorkers)
+}
+
+// collectFiles lists the regular files under root, leaving out what the
+// exclude patterns and the .cshareignore files along the way rule out. A
+// root that is a file itself is always kept.
+func collectFiles(root string, excludes []string) ([]string, error) {
+	var paths []string
+	err := walkUpload(root, excludes, func(path string, d fs.DirEntry, excluded *ignoreRule) {
+		if excluded == nil && d.Type().IsRegular() {
+			paths = append(paths, path)
+		}
+	})
+	return paths, err
+}
+
+// walkUpload walks root like collectFiles, calling visit for every file
+// and for every folder that is left out as a whole. excluded is the rule
+// leaving the entry out, or nil if it is uploaded.
+func walkUpload(root string, excludes []string, visit func(path string, d fs.DirEntry, excluded *ignoreRule)) error {
+	rules := make(map[string]ignoreList)
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			if d.IsDir() {
+				rules[path], err = excludeRules(excludes).load(path, ".")
+				return err
+			}
+			visit(path, d, nil)
+			return nil
+		}
+
+		parent := rules[filepath.Dir(path)]
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		rule, ignored := parent.ignored(rel, d.IsDir())
+		switch {
+		case d.IsDir() && ignored:
+			visit(path, d, &rule)
+			return filepath.SkipDir
+		case d.IsDir():
+			rules[path], err = parent.load(path, rel)
+			return err
+		case d.Name() == ignoreFile:
+		case ignored:
+			visit(path, d, &rule)
+		default:
+			visit(path, d, nil)
+		}
+		return nil
+	})
+}
+
+// hashPipeline hashes paths on a bounded pool of workers and emits each
+// file as soon as it is hashed, so uploads can start before the whole batch
+// has been read.
+func hashPipeline(ctx context.Context, paths []string, workers int) <-chan hashedFile {
+	jobs := make(chan string)
+	results := make(chan hashedFile)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer recoverCrash()
+			defer wg.Done()
+			for path := range jobs {
+				sum, err := hashFile(path)
+				select {
+				case results <- hashedFile{path: path, sum: sum, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer recoverCrash()
+		defer close(jobs)
+		for _, path := range paths {
+			select {
+			case jobs <- path:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		defer recoverCrash()
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// hashFile returns the hex SHA-256 of a file's contents.
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("error reading file: %v", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// uploadBatch uploads every file under folder that isn't excluded.
+func uploadBatch(siteName string, password secret, folder string, excludes []string, taken map[string]bool, policy conflictPolicy, sent map[string]bool) tea.Cmd {
+	return uploadPaths(siteName, password, []string{folder}, excludes, taken, policy, sent)
+}
+
+// uploadPaths uploads the given files and every file under the given
+// folders that isn't excluded (see collectFiles). Files are hashed in parallel and handed to a pool of
+// uploadWorkers as soon as they are hashed, then the batch is verified
+// against the server's checksums with a single listing refresh. Names
+// already taken on the site are settled with policy (see remoteNames).
+// Files in sent were uploaded by an interrupted run of the batch and are
+// left out.
+func uploadPaths(siteName string, password secret, targets, excludes []string, taken map[string]bool, policy conflictPolicy, sent map[string]bool) tea.Cmd {
+	return func() tea.Msg {
+		var paths []string
+		for _, target := range targets {
+			found, err := collectFiles(target, excludes)
+			if err != nil {
+				return fmt.Errorf("error reading %s: %v", target, err)
+			}
+			paths = append(paths, found...)
+		}
+		before := len(paths)
+		paths = slices.DeleteFunc(paths, func(path string) bool { return sent[path] })
+		resumed := before - len(paths)
+		if len(paths) == 0 && resumed > 0 {
+			return fmt.Errorf("all %d file(s) were uploaded before the restart; nothing is left to upload", resumed)
+		}
+		names, skipped, err := remoteNames(paths, taken, policy)
+		if err != nil {
+			return err
+		}
+		paths = slices.DeleteFunc(paths, func(path string) bool { return names[path] == "" })
+		if len(paths) == 0 && len(skipped) > 0 {
+			return fmt.Errorf("all %d file(s) are already on the site; nothing was uploaded", len(skipped))
+		}
+		if len(paths) == 0 {
+			return fmt.Errorf("no files to upload")
+		}
+		if err := checkUploads(paths, names); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stream := make(chan uploadProgressMsg)
+		send := func(msg uploadProgressMsg) bool {
+			select {
+			case stream <- msg:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		go func() {
+			defer recoverCrash()
+			defer cancel()
+			defer close(stream)
+
+			uploads := make(chan hashedFile)
+			results := uploadPool(ctx, siteName, names, uploads, uploadWorkers())
+			hashedFiles := hashPipeline(ctx, paths, hashWorkers())
+
+			sums := make(map[string]string)
+			var queue []hashedFile
+			var active, failed []string
+			hashed, uploaded := 0, 0
+			for hashedFiles != nil || len(queue) > 0 || len(active) > 0 {
+				// Only offer a file to the pool when one is waiting.
+				var feed chan<- hashedFile
+				var next hashedFile
+				done := ""
+				if len(queue) > 0 {
+					feed, next = uploads, queue[0]
+				}
+
+				select {
+				case file, ok := <-hashedFiles:
+					if !ok {
+						hashedFiles = nil
+						continue
+					}
+					hashed++
+					if file.err != nil {
+						failed = append(failed, filepath.Base(file.path))
+					} else {
+						queue = append(queue, file)
+					}
+				case feed <- next:
+					queue = queue[1:]
+					active = append(active, names[next.path])
+				case result := <-results:
+					name := names[result.file.path]
+					if i := slices.Index(active, name); i >= 0 {
+						active = slices.Delete(active, i, i+1)
+					}
+					if result.err != nil {
+						failed = append(failed, name)
+					} else {
+						sums[name] = result.file.sum
+						uploaded++
+						done = result.file.path
+					}
+				case <-ctx.Done():
+					close(uploads)
+					return
+				}
+				progress := uploadProgressMsg{hashed: hashed, uploaded: uploaded, sent: done,
+					active: slices.Clone(active), failed: slices.Clone(failed)}
+				if !send(progress) {
+					close(uploads)
+					return
+				}
+			}
+			close(uploads)
+
+			files, err := fetchFilesDirectly(siteName, password)
+			if err != nil {
+				send(uploadProgressMsg{hashed: hashed, uploaded: uploaded, failed: failed, done: true,
+					status: notice(fmt.Sprintf("Uploaded %d of %d files, but error refreshing list: %v", uploaded, len(paths), err))})
+				return
+			}
+			verified := 0
+			for name, sum := range sums {
+				if id, ok := findUploadedFile(files, name); ok {
+					if remote, err := fetchRemoteChecksum(id); err == nil && remote == sum {
+						verified++
+					}
+				}
+			}
+
+			status := success(fmt.Sprintf("Uploaded %d of %d files, %d verified", uploaded, len(paths), verified))
+			if len(failed) > 0 {
+				status = notice(fmt.Sprintf("Uploaded %d of %d files, %d verified; failed: %s", uploaded, len(paths), verified, strings.Join(failed, ", ")))
+			}
+			if len(skipped) > 0 {
+				status.text += fmt.Sprintf("; skipped %d already on the site", len(skipped))
+			}
+			if resumed > 0 {
+				status.text += fmt.Sprintf("; %d uploaded before the restart", resumed)
+			}
+			send(uploadProgressMsg{hashed: hashed, uploaded: uploaded, failed: failed, done: true, files: files, status: status})
+		}()
+
+		return uploadStartedMsg{stream: stream, stop: cancel, total: len(paths)}
+	}
+}
+
+// uploadResult is the outcome of uploading one file of a batch.
+type uploadResult struct {
+	file hashedFile
+	err  error
+}
+
+// uploadPool uploads the files sent on uploads with a bounded number of
+// workers and reports each outcome. The results channel is closed once
+// uploads is closed and the workers are done.
+func uploadPool(ctx context.Context, siteName string, names map[string]string, uploads <-chan hashedFile, workers int) <-chan uploadResult {
+	results := make(chan uploadResult)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer recoverCrash()
+			defer wg.Done()
+			for file := range uploads {
+				err := uploadSigned(siteName, file.path, names[file.path])
+				select {
+				case results <- uploadResult{file: file, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer recoverCrash()
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// uploadSigned uploads a file of a batch and, when uploads are signed, its
+// signature next to it.
+func uploadSigned(siteName, path, name string) error {
+	sig, cleanup, err := signUpload(path)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	if err := postFile(siteName, path, name, io.Discard, 0); err != nil {
+		return err
+	}
+	return postSignature(siteName, sig, name)
+}
+
+// waitBatch waits for the next progress report of a batch upload.
+func waitBatch(stream <-chan uploadProgressMsg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-stream
+		if !ok {
+			return nil
+		}
+		msg.stream = stream
+		return msg
+	}
+}
+
+// handleBatchProgress records batch progress and, when the batch is done,
+// returns to the refreshed file list.
+func handleBatchProgress(m *Model, msg uploadProgressMsg) (tea.Model, tea.Cmd) {
+	if msg.stream != m.batchStream {
+		return m, nil
+	}
+	m.batchHashed = msg.hashed
+	m.batchUploaded = msg.uploaded
+	if msg.sent != "" {
+		m.batchSent = append(m.batchSent, msg.sent)
+	}
+	m.batchActive = msg.active
+	m.batchFailed = msg.failed
+	if !msg.done {
+		return m, waitBatch(msg.stream)
+	}
+
+	m.batchStream = nil
+	m.batchStop = nil
+	m.folderToUpload = ""
+	if msg.files != nil {
+		m.files = msg.files
+	}
+	m.goTo(stateViewFiles)
+	m.status = msg.status
+	return m, nil
+}
+
+// renderBatchProgress renders hashing and upload progress bars, the files
+// being uploaded and the ones that failed.
+func renderBatchProgress(m Model) string {
+	lines := []string{
+		fmt.Sprintf("Hashed   %s %d/%d", progressBar(m.batchHashed, m.batchTotal), m.batchHashed, m.batchTotal),
+		fmt.Sprintf("Uploaded %s %d/%d", progressBar(m.batchUploaded, m.batchTotal), m.batchUploaded, m.batchTotal),
+	}
+	for _, name := range m.batchActive {
+		lines = append(lines, "  ↑ "+name)
+	}
+	if len(m.batchFailed) > 0 {
+		lines = append(lines, errorStyle.UnsetPadding().Render(fmt.Sprintf("  ✗ %d failed: %s", len(m.batchFailed), strings.Join(m.batchFailed, ", "))))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// progressBar renders a fixed-width bar for done out of total.
+func progressBar(done, total int) string {
+	const width = 30
+	filled := 0
+	if total > 0 {
+		filled = done * width / total
+	}
+	bar := make([]rune, width)
+	for i := range bar {
+		if i < filled {
+			bar[i] = '█'
+		} else {
+			bar[i] = '░'
+		}
+	}
+	return string(bar)
+}
+
+// renderUploadProgress renders batch progress while a batch is running,
+// the rows of running and just finished transfers and the uploads pending
+// until the server is reachable.
+func renderUploadProgress(m Model) string {
+	var out string
+	if m.batchStream != nil {
+		out = "\n" + renderBatchProgress(m)
+	}
+	if rows := renderTransfers(m); rows != "" {
+		out += "\n" + rows
+	}
+	if pending := renderOfflineQueue(m); pending != "" {
+		out += "\n" + pending
+	}
+	return out
+}
+
+// expandGlobs replaces arguments holding glob patterns, such as *.pdf, with
+// the paths they match. Shells usually do this already, but not all of them
+// (cmd.exe, quoted arguments). Patterns that match nothing are kept as is.
+func expandGlobs(args []string) []string {
+	var expanded []string
+	for _, arg := range args {
+		if strings.ContainsAny(arg, "*?[") {
+			if matches, err := filepath.Glob(arg); err == nil && len(matches) > 0 {
+				expanded = append(expanded, matches...)
+				continue
+			}
+		}
+		expanded = append(expanded, arg)
+	}
+	return expanded
+}
+
+// selectUpload adds a picked file or folder to the upload selection. A
+// single file keeps the verified single-file upload; picking more turns the
+// selection into a batch.
+func selectUpload(m *Model, path string, folder bool) {
+	m.uploadAs = ""
+	if m.fileToUpload == "" && m.folderToUpload == "" && len(m.pendingUploads) == 0 {
+		if folder {
+			m.folderToUpload = path
+		} else {
+			m.fileToUpload = path
+		}
+		return
+	}
+	for _, picked := range []string{m.fileToUpload, m.folderToUpload} {
+		if picked != "" {
+			m.pendingUploads = append(m.pendingUploads, picked)
+		}
+	}
+	m.fileToUpload, m.folderToUpload = "", ""
+	if !slices.Contains(m.pendingUploads, path) {
+		m.pendingUploads = append(m.pendingUploads, path)
+	}
+}
+
+// unselectUpload removes the last picked file or folder.
+func unselectUpload(m *Model) {
+	m.uploadAs = ""
+	switch {
+	case len(m.pendingUploads) > 0:
+		m.pendingUploads = m.pendingUploads[:len(m.pendingUploads)-1]
+	default:
+		m.fileToUpload, m.folderToUpload = "", ""
+	}
+}
+
+// pathArgs reports whether every argument names an existing file or folder,
+// meaning cshare was started to upload them.
+func pathArgs(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	for _, arg := range args {
+		if _, err := os.Stat(arg); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// cachedSite returns CSHARE_SITE, or else the most recently opened site,
+// when an auth token is stored, so uploads from the command line can skip
+// the login.
+func cachedSite() (string, bool) {
+	if _, err := loadAuthToken(); err != nil {
+		return "", false
+	}
+	if site := os.Getenv("CSHARE_SITE"); site != "" {
+		return resolveSite(site), true
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return "", false
+	}
+	for _, item := range cfg.Recent {
+		if item.Kind == quickSite {
+			return item.Site, true
+		}
+	}
+	return "", false
+}
+
+// renderPendingUploads lists the files passed on the command line.
+func renderPendingUploads(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(paths))
+	for _, path := range paths {
+		names = append(names, filepath.Base(path))
+	}
+	return fmt.Sprintf("%d item(s): %s", len(paths), strings.Join(names, ", "))
+}
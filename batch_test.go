@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestUploadCommandOwnsTargets checks that the batch upload command keeps
+// its own copy of the selection: Update goes on editing pendingUploads
+// while the command reads it, which `go test -race` reports otherwise.
+func TestUploadCommandOwnsTargets(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	m := &Model{siteName: "notes"}
+	// Room to grow, so editing the selection reuses its array.
+	m.pendingUploads = make([]string, 0, 8)
+	selectUpload(m, paths[0], false)
+	selectUpload(m, paths[1], false)
+	// Everything counts as sent already, so the command stops after
+	// reading the selection instead of uploading.
+	m.batchSent = paths
+
+	cmd := startUpload(m, conflictSkip)
+	done := make(chan any)
+	go func() { done <- cmd() }()
+	unselectUpload(m)
+	selectUpload(m, paths[2], false)
+
+	if _, ok := (<-done).(error); !ok {
+		t.Fatal("expected the command to find nothing left to upload")
+	}
+	if got := m.pendingUploads; len(got) != 2 || got[1] != paths[2] {
+		t.Errorf("selection = %v", got)
+	}
+}
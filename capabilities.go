@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Optional server features, as named in the /capabilities descriptor.
+const (
+	featureMultipart  = "multipart_upload"
+	featureURLUpload  = "url_upload"
+	featureShareLinks = "share_links"
+	featureMembers    = "members"
+	featureTags       = "tags"
+	featureComments   = "comments"
+	featureChat       = "chat"
+	featureDelta      = "delta_upload"
+)
+
+// featureLabels names the features in messages to the user.
+var featureLabels = map[string]string{
+	featureMultipart:  "multipart uploads",
+	featureURLUpload:  "fetching URLs",
+	featureShareLinks: "share links",
+	featureMembers:    "members",
+	featureTags:       "tags",
+	featureComments:   "comments",
+	featureChat:       "chat",
+	featureDelta:      "delta uploads",
+}
+
+// capabilities is what the server says it supports. Servers without a
+// descriptor are assumed to support everything, as before they could say.
+type capabilities struct {
+	known    bool
+	features map[string]bool
+	limits   uploadLimits
+}
+
+// serverCaps holds the last descriptor fetched from serverURL. Uploads
+// running in the background read it too.
+var serverCaps atomic.Pointer[capabilities]
+
+// hostCaps holds the descriptor of each server asked so far, by host, as
+// the login server need not be serverURL nor have its features.
+var hostCaps sync.Map // host -> *capabilities
+
+// capabilitiesOf returns the descriptor of the server at base, fetching it
+// the first time that server is asked.
+func capabilitiesOf(base string) *capabilities {
+	host := capsHost(base)
+	if caps, ok := hostCaps.Load(host); ok {
+		return caps.(*capabilities)
+	}
+	msg := fetchCapabilitiesFrom(base)
+	caps, _ := hostCaps.LoadOrStore(host, &msg.caps)
+	return caps.(*capabilities)
+}
+
+// capsHost returns the key of base in hostCaps.
+func capsHost(base string) string {
+	if u, err := url.Parse(base); err == nil {
+		return strings.ToLower(u.Host)
+	}
+	return base
+}
+
+// supports reports whether the server has a feature.
+func supports(feature string) bool {
+	caps := serverCaps.Load()
+	return caps == nil || !caps.known || caps.features[feature]
+}
+
+// advertises reports whether the server listed a feature in its
+// descriptor. Unlike supports, a server that hasn't said doesn't have it,
+// for features that are only tried when the server asks for them.
+func advertises(feature string) bool {
+	caps := serverCaps.Load()
+	return caps != nil && caps.known && caps.features[feature]
+}
+
+// capabilitiesMsg carries the server's descriptor.
+type capabilitiesMsg struct {
+	caps capabilities
+}
+
+// fetchCapabilities asks the server which optional features it has. A
+// server that doesn't answer, or has no /capabilities endpoint, keeps
+// every feature available, so failures surface where they happen.
+func fetchCapabilities() tea.Msg {
+	return fetchCapabilitiesFrom(serverURL)
+}
+
+// fetchCapabilitiesFrom asks the server at base for its descriptor.
+func fetchCapabilitiesFrom(base string) capabilitiesMsg {
+	call, err := newAPICall(opMetadata, "GET", base+"/capabilities", nil)
+	if err != nil {
+		return capabilitiesMsg{}
+	}
+	defer call.close()
+
+	resp, err := call.do()
+	if err != nil {
+		return capabilitiesMsg{}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return capabilitiesMsg{}
+	}
+
+	var descriptor struct {
+		Features []string     `json:"features"`
+		Limits   uploadLimits `json:"limits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&descriptor); err != nil {
+		return capabilitiesMsg{}
+	}
+	caps := capabilities{known: true, features: make(map[string]bool), limits: descriptor.Limits}
+	for _, feature := range descriptor.Features {
+		caps.features[strings.ToLower(feature)] = true
+	}
+	return capabilitiesMsg{caps: caps}
+}
+
+// handleCapabilities keeps the descriptor. One that failed to load doesn't
+// replace a good one from earlier.
+func handleCapabilities(m *Model, msg capabilitiesMsg) (tea.Model, tea.Cmd) {
+	if msg.caps.known || serverCaps.Load() == nil {
+		serverCaps.Store(&msg.caps)
+	}
+	if msg.caps.known {
+		hostCaps.Store(capsHost(serverURL), &msg.caps)
+	}
+	return m, nil
+}
+
+// fileKeyFeatures maps the file list keys to the feature they need.
+var fileKeyFeatures = map[string]string{
+	"m": featureMembers, "M": featureMembers,
+	"s": featureShareLinks, "S": featureShareLinks,
+	"#": featureTags,
+	"c": featureComments, "C": featureComments,
+	"k": featureChat, "K": featureChat,
+}
+
+// unsupported returns the message for a key whose feature the server lacks,
+// or "" if the key is available.
+func unsupported(key string) string {
+	feature, ok := fileKeyFeatures[key]
+	if !ok || supports(feature) {
+		return ""
+	}
+	return "This server doesn't support " + featureLabels[feature]
+}
+
+// fileListHints are the file list's key hints, in order. Hints with a
+// feature are left out when the server lacks it.
+var fileListHints = []struct {
+	hint    string
+	feature string
+}{
+	{"U - Upload", ""},
+	{"M - Members", featureMembers},
+	{"S - Share", featureShareLinks},
+	{"P - Pin", ""},
+	{"X - Delete", ""},
+	{"G - Settings", ""},
+	{"F - Filter", ""},
+	{"V - Group", ""},
+	{"I - Details", ""},
+	{"# - Tags", featureTags},
+	{"C - Comments", featureComments},
+	{"K - Chat", featureChat},
+	{"E - Edit", ""},
+	{"H - Hex", ""},
+	{"Y - Copy Info", ""},
+	{"Z - Archive", ""},
+	{"T - Two-pane", ""},
+	{"R - Refresh", ""},
+	{"Enter - Download", ""},
+	{"A - Download All", ""},
+	{"Esc - Back", ""},
+}
+
+// renderFileListHints joins the hints for the features the server has.
+func renderFileListHints() string {
+	var hints []string
+	for _, h := range fileListHints {
+		if h.feature == "" || supports(h.feature) {
+			hints = append(hints, h.hint)
+		}
+	}
+	return strings.Join(hints, " • ")
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// chatInterval is how often an open site checks for new chat messages.
+	chatInterval = 3 * time.Second
+	// maxChatLength keeps chat messages to one line or two.
+	maxChatLength = 300
+	// keptChatMessages caps the chat history held in memory.
+	keptChatMessages = 200
+	// visibleChat is how many chat messages are shown at once.
+	visibleChat = 12
+)
+
+// chatMessage is one message of a site's chat.
+type chatMessage struct {
+	ID     int       `json:"id"`
+	Author string    `json:"author"`
+	Text   string    `json:"text"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// chatTickMsg fires every chatInterval.
+type chatTickMsg struct{}
+
+// chatPolledMsg carries the messages posted to a site since the last poll
+// and who is viewing the site now.
+type chatPolledMsg struct {
+	site     string
+	messages []chatMessage
+	online   []string
+	err      error
+}
+
+// chatSentMsg reports that a chat message was posted.
+type chatSentMsg struct {
+	site string
+	err  error
+}
+
+// chatTick schedules the next chat poll.
+func chatTick() tea.Cmd {
+	return tea.Tick(chatInterval, func(time.Time) tea.Msg {
+		return chatTickMsg{}
+	})
+}
+
+// handleChatTick polls the open site's chat, unless a poll is still
+// running, and schedules the next tick.
+func handleChatTick(m *Model) (tea.Model, tea.Cmd) {
+	if !inSite(m.state) || m.chatPolling || !supports(featureChat) {
+		return m, chatTick()
+	}
+	m.chatPolling = true
+	return m, tea.Batch(pollChat(m.siteName, lastChatID(m)), chatTick())
+}
+
+// pollChat fetches the chat messages of a site newer than after. The first
+// poll for a site, with after 0, returns the recent history.
+func pollChat(siteName string, after int) tea.Cmd {
+	return func() tea.Msg {
+		messages, online, err := fetchChat(siteName, after)
+		return chatPolledMsg{site: siteName, messages: messages, online: online, err: err}
+	}
+}
+
+func chatEndpoint(siteName string) string {
+	return fmt.Sprintf("%s/site/%s/chat", serverURL, url.PathEscape(siteName))
+}
+
+// fetchChat fetches a site's chat messages newer than after, oldest first,
+// and the users viewing the site. Polling the chat is what marks a user as
+// viewing it, so the server reports everyone who polled recently.
+func fetchChat(siteName string, after int) ([]chatMessage, []string, error) {
+	authToken, err := loadAuthToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	call, err := newAPICall(opMetadata, "GET", fmt.Sprintf("%s?after=%d", chatEndpoint(siteName), after), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+	call.req.Header.Set("Authorization", authToken.reveal())
+
+	resp, err := call.do()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error connecting to server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, call.fail(resp, "failed to fetch chat")
+	}
+
+	var result struct {
+		Messages []chatMessage `json:"messages"`
+		Online   []string      `json:"online"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, nil, fmt.Errorf("error parsing response: %v", err)
+	}
+	return result.Messages, result.Online, nil
+}
+
+// handleChatPolled adds new messages to the chat. Messages from others that
+// arrive while the chat isn't open count as unread; the history loaded when
+// a site opens does not.
+func handleChatPolled(m *Model, msg chatPolledMsg) (tea.Model, tea.Cmd) {
+	m.chatPolling = false
+	if msg.site != m.siteName {
+		return m, nil
+	}
+	m.chatErr = msg.err
+	if msg.err != nil {
+		m.online = nil
+		return m, nil
+	}
+	m.online = msg.online
+	if m.chatSite != msg.site {
+		m.chatSite = msg.site
+		m.chat = msg.messages
+		m.chatUnread = 0
+	} else {
+		for _, message := range msg.messages {
+			if len(m.chat) > 0 && message.ID <= m.chat[len(m.chat)-1].ID {
+				continue
+			}
+			m.chat = append(m.chat, message)
+			if m.state != stateChat && message.Author != m.account {
+				m.chatUnread++
+			}
+		}
+	}
+	if len(m.chat) > keptChatMessages {
+		m.chat = m.chat[len(m.chat)-keptChatMessages:]
+	}
+	return m, nil
+}
+
+// sendChat posts a message to a site's chat.
+func sendChat(siteName, text string) tea.Cmd {
+	return func() tea.Msg {
+		jsonData, err := json.Marshal(map[string]string{"text": text})
+		if err != nil {
+			return chatSentMsg{site: siteName, err: fmt.Errorf("error encoding message: %v", err)}
+		}
+		if err := sendMemberRequest("POST", chatEndpoint(siteName), bytes.NewBuffer(jsonData)); err != nil {
+			return chatSentMsg{site: siteName, err: fmt.Errorf("failed to send message: %w", err)}
+		}
+		return chatSentMsg{site: siteName}
+	}
+}
+
+// handleChatSent clears the input once a message is posted and fetches it
+// back right away rather than on the next tick.
+func handleChatSent(m *Model, msg chatSentMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		m.status = notice(msg.err.Error())
+		return m, nil
+	}
+	m.chatInput = ""
+	m.status = statusLine{}
+	if m.chatPolling || msg.site != m.siteName {
+		return m, nil
+	}
+	m.chatPolling = true
+	return m, pollChat(m.siteName, lastChatID(m))
+}
+
+// lastChatID is the ID of the newest message of the open site's chat, or 0
+// if none has been loaded yet.
+func lastChatID(m *Model) int {
+	if m.chatSite != m.siteName || len(m.chat) == 0 {
+		return 0
+	}
+	return m.chat[len(m.chat)-1].ID
+}
+
+// openChat shows the site's chat and marks its messages as read.
+func openChat(m *Model) {
+	m.chatUnread = 0
+	m.goTo(stateChat)
+}
+
+// handleChatInput edits and sends chat messages.
+func handleChatInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		text := strings.TrimSpace(m.chatInput)
+		if text == "" {
+			return m, nil
+		}
+		return m, sendChat(m.siteName, text)
+	case "esc":
+		m.goTo(stateViewFiles)
+	case "backspace":
+		m.chatInput = deleteLastChar(m.chatInput)
+	default:
+		text := typedText(msg)
+		if len([]rune(m.chatInput+text)) > maxChatLength {
+			m.status = notice(fmt.Sprintf("Chat messages are limited to %d characters", maxChatLength))
+			return m, nil
+		}
+		m.chatInput += text
+	}
+	return m, nil
+}
+
+// renderChat renders the latest messages and the message being typed.
+func renderChat(m Model) string {
+	var lines []string
+	if others := otherViewers(m); len(others) > 0 {
+		lines = append(lines, mutedStyle.Render("Online: "+strings.Join(others, ", ")), "")
+	} else if m.chatErr == nil {
+		lines = append(lines, mutedStyle.Render("Nobody else is viewing this site right now."), "")
+	}
+	messages := m.chat
+	if m.chatSite != m.siteName {
+		messages = nil
+	}
+	switch {
+	case m.chatErr != nil && len(messages) == 0:
+		lines = append(lines, errorStyle.UnsetPadding().Render("Chat unavailable: "+m.chatErr.Error()))
+	case len(messages) == 0:
+		lines = append(lines, mutedStyle.Render("No messages yet. Say hello to the others in this site."))
+	}
+	for _, message := range messages[max(0, len(messages)-visibleChat):] {
+		author := lipgloss.NewStyle().Bold(true).Render(message.Author)
+		if message.Author == m.account {
+			author = successStyle.UnsetPadding().Bold(true).Render(message.Author)
+		}
+		lines = append(lines, mutedStyle.Render(message.SentAt.Local().Format("15:04"))+" "+author+": "+message.Text)
+	}
+	return strings.Join(append(lines, "", "> "+m.chatInput+"█"), "\n")
+}
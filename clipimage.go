@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// clipboardDirPattern names the temporary folders clipboard images are
+// saved to before uploading.
+const clipboardDirPattern = "cshare-clipboard-*"
+
+// clipboardImageMsg reports whether the clipboard holds an image.
+type clipboardImageMsg struct {
+	data []byte // PNG, or nil if the clipboard has no image
+}
+
+// checkClipboardImage looks for an image on the clipboard so the upload
+// screen can offer it. Failures just mean there is nothing to offer.
+func checkClipboardImage() tea.Msg {
+	data, err := readClipboardImage()
+	if err != nil {
+		return clipboardImageMsg{}
+	}
+	return clipboardImageMsg{data: data}
+}
+
+// readClipboardImage returns the clipboard's image as PNG. It relies on
+// the platform's own tools, as the clipboard package only handles text:
+// PowerShell on Windows, AppleScript on macOS and wl-paste or xclip
+// elsewhere.
+func readClipboardImage() ([]byte, error) {
+	var data []byte
+	var err error
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		data, err = clipboardImageViaFile()
+	default:
+		var cmd *exec.Cmd
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			cmd = exec.Command("wl-paste", "--no-newline", "--type", "image/png")
+		} else {
+			cmd = exec.Command("xclip", "-selection", "clipboard", "-target", "image/png", "-out")
+		}
+		data, err = cmd.Output()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no image on the clipboard")
+	}
+	if _, err := png.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("the clipboard doesn't hold a PNG image")
+	}
+	return data, nil
+}
+
+// clipboardImageViaFile has the system save the clipboard's image to a
+// temporary file, for platforms whose tools can't write it to stdout.
+func clipboardImageViaFile() ([]byte, error) {
+	dir, err := os.MkdirTemp("", clipboardDirPattern)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "clipboard.png")
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		script := fmt.Sprintf(`Add-Type -AssemblyName System.Windows.Forms, System.Drawing; `+
+			`$img = [System.Windows.Forms.Clipboard]::GetImage(); `+
+			`if ($img -eq $null) { exit 1 }; `+
+			`$img.Save('%s', [System.Drawing.Imaging.ImageFormat]::Png)`, strings.ReplaceAll(path, "'", "''"))
+		cmd = exec.Command("powershell", "-NoProfile", "-STA", "-Command", script)
+	} else {
+		cmd = exec.Command("osascript",
+			"-e", "set png to (the clipboard as «class PNGf»)",
+			"-e", fmt.Sprintf("set f to open for access POSIX file %q with write permission", path),
+			"-e", "write png to f",
+			"-e", "close access f")
+	}
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// handleClipboardImage remembers a clipboard image for the upload screen,
+// unless the user has left it in the meantime.
+func handleClipboardImage(m *Model, msg clipboardImageMsg) (tea.Model, tea.Cmd) {
+	if m.state == stateUploadFile {
+		m.clipImage = msg.data
+	}
+	return m, nil
+}
+
+// clipboardSavedMsg carries the file a clipboard image was saved to.
+type clipboardSavedMsg struct {
+	path string
+	err  error
+}
+
+// useClipboardImage saves the clipboard image under a generated name to
+// select it for upload.
+func useClipboardImage(m *Model) tea.Cmd {
+	data := m.clipImage
+	if data == nil {
+		m.status = notice("The clipboard has no image. Copy a screenshot first.")
+		return nil
+	}
+	m.clipImage = nil
+	return func() tea.Msg {
+		dir, err := os.MkdirTemp("", clipboardDirPattern)
+		if err != nil {
+			return clipboardSavedMsg{err: err}
+		}
+		path := filepath.Join(dir, time.Now().Format("clipboard-2006-01-02-150405.png"))
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			os.RemoveAll(dir)
+			return clipboardSavedMsg{err: err}
+		}
+		return clipboardSavedMsg{path: path}
+	}
+}
+
+// handleClipboardSaved selects a saved clipboard image for upload.
+func handleClipboardSaved(m *Model, msg clipboardSavedMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		m.status = notice(fmt.Sprintf("Error saving clipboard image: %v", msg.err))
+		return m, nil
+	}
+	m.fileToUpload = msg.path
+	m.folderToUpload = ""
+	m.pendingUploads = nil
+	m.uploadAs = ""
+	m.status = success("Clipboard image saved as " + filepath.Base(msg.path) + ". Press Enter to upload it.")
+	return m, nil
+}
+
+// isClipboardImage reports whether path is a clipboard image saved by
+// useClipboardImage, whose folder can go once it is uploaded.
+func isClipboardImage(path string) bool {
+	dir := filepath.Dir(path)
+	matched, _ := filepath.Match(clipboardDirPattern, filepath.Base(dir))
+	return matched && filepath.Dir(dir) == filepath.Clean(os.TempDir())
+}
+
+// renderClipboardOffer offers the clipboard image on the upload screen,
+// e.g. "📋 Clipboard image (1280×720 PNG, 230.4 KB): press V to upload it".
+func renderClipboardOffer(m Model) string {
+	if m.clipImage == nil {
+		return ""
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(m.clipImage))
+	if err != nil {
+		return ""
+	}
+	return highlightStyle.Render(fmt.Sprintf("📋 Clipboard image (%d×%d PNG, %s): press V to upload it",
+		cfg.Width, cfg.Height, formatBytes(int64(len(m.clipImage)))))
+}
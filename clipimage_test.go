@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFailedClipboardUploadRemovesItsFolder(t *testing.T) {
+	inTempDir(t)
+	_, stop := newFakeServer()
+	defer stop()
+	t.Setenv("auth_token", "token")
+
+	dir, err := os.MkdirTemp("", clipboardDirPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "clipboard.png")
+	if err := os.WriteFile(path, []byte("png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !isClipboardImage(path) {
+		t.Fatalf("%s not taken for a clipboard image", path)
+	}
+
+	// The site doesn't exist, so the upload fails while the server is up.
+	m := &Model{siteName: "missing", fileToUpload: path}
+	if _, failed := run(t, uploadFile(m, "clipboard.png")).(error); !failed {
+		t.Fatal("the upload to a missing site succeeded")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("the clipboard folder is still there after the failed upload (%v)", err)
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+)
+
+// The panes of the two-pane file manager.
+const (
+	paneLocal = iota
+	paneRemote
+)
+
+// paneWidth is the width of each pane of the file manager.
+const paneWidth = 34
+
+// localEntry is a file or folder in the local pane.
+type localEntry struct {
+	name  string
+	isDir bool
+	size  int64
+}
+
+// commanderDoneMsg reports a copy or move between the panes. files is the
+// refreshed remote listing, if the operation changed it.
+type commanderDoneMsg struct {
+	status  statusLine
+	files   []FileInfo
+	deleted int // ID of a remote file that was moved away, or 0
+	err     error
+}
+
+// openCommander shows the two-pane file manager, starting in the working
+// directory.
+func openCommander(m *Model) {
+	if m.localDir == "" {
+		if wd, err := os.Getwd(); err == nil {
+			m.localDir = wd
+		}
+	}
+	readLocalDir(m)
+	m.pane = paneLocal
+	m.remoteIdx = min(m.remoteIdx, max(0, len(m.files)-1))
+	m.goTo(stateCommander)
+}
+
+// readLocalDir lists the local pane's folder: folders first, then files,
+// each sorted by name, with ".." to go up.
+func readLocalDir(m *Model) {
+	entries, err := os.ReadDir(m.localDir)
+	if err != nil {
+		m.status = notice(fmt.Sprintf("Error reading %s: %v", m.localDir, err))
+	}
+	m.localEntries = m.localEntries[:0]
+	if parent := filepath.Dir(m.localDir); parent != m.localDir {
+		m.localEntries = append(m.localEntries, localEntry{name: "..", isDir: true})
+	}
+	var dirs, files []localEntry
+	for _, e := range entries {
+		entry := localEntry{name: e.Name(), isDir: e.IsDir()}
+		if info, err := e.Info(); err == nil && !e.IsDir() {
+			entry.size = info.Size()
+		}
+		if entry.isDir {
+			dirs = append(dirs, entry)
+		} else {
+			files = append(files, entry)
+		}
+	}
+	for _, group := range [][]localEntry{dirs, files} {
+		sort.Slice(group, func(i, j int) bool { return strings.ToLower(group[i].name) < strings.ToLower(group[j].name) })
+		m.localEntries = append(m.localEntries, group...)
+	}
+	m.localIdx = min(m.localIdx, max(0, len(m.localEntries)-1))
+}
+
+// handleCommanderInput handles input in the two-pane file manager. Tab
+// switches panes, F5 (or C) copies the selection to the other side and F6
+// (or M) moves it.
+func handleCommanderInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "tab":
+		m.pane = 1 - m.pane
+	case "up":
+		if m.pane == paneLocal && m.localIdx > 0 {
+			m.localIdx--
+		} else if m.pane == paneRemote && m.remoteIdx > 0 {
+			m.remoteIdx--
+		}
+	case "down":
+		if m.pane == paneLocal && m.localIdx < len(m.localEntries)-1 {
+			m.localIdx++
+		} else if m.pane == paneRemote && m.remoteIdx < len(m.files)-1 {
+			m.remoteIdx++
+		}
+	case "enter":
+		if m.pane == paneLocal && m.localIdx < len(m.localEntries) && m.localEntries[m.localIdx].isDir {
+			enterLocalDir(m, m.localEntries[m.localIdx].name)
+		}
+	case "backspace":
+		if m.pane == paneLocal {
+			enterLocalDir(m, "..")
+		}
+	case "f5", "c", "C":
+		return m, commanderTransfer(m, false)
+	case "f6", "m", "M":
+		return m, commanderTransfer(m, true)
+	case "r", "R":
+		readLocalDir(m)
+	case "esc":
+		m.goTo(stateViewFiles)
+	}
+	return m, nil
+}
+
+// enterLocalDir opens a folder of the local pane, or its parent for "..",
+// keeping the folder just left selected when going up.
+func enterLocalDir(m *Model, name string) {
+	left := filepath.Base(m.localDir)
+	if name == ".." {
+		if parent := filepath.Dir(m.localDir); parent != m.localDir {
+			m.localDir = parent
+		}
+	} else {
+		m.localDir = filepath.Join(m.localDir, name)
+	}
+	m.localIdx = 0
+	readLocalDir(m)
+	if name == ".." {
+		for i, e := range m.localEntries {
+			if e.name == left {
+				m.localIdx = i
+			}
+		}
+	}
+}
+
+// commanderTransfer copies or moves the selection of the focused pane to
+// the other one.
+func commanderTransfer(m *Model, move bool) tea.Cmd {
+	if m.pane == paneLocal {
+		if m.localIdx >= len(m.localEntries) || m.localEntries[m.localIdx].name == ".." {
+			return nil
+		}
+		entry := m.localEntries[m.localIdx]
+		path := filepath.Join(m.localDir, entry.name)
+		if entry.isDir && move {
+			m.status = notice("Only files can be moved; press F5 to copy a folder")
+			return nil
+		}
+		return trackTransfer(m, commanderUpload(m.siteName, m.password, path, entry.isDir, move, m.excludes))
+	}
+
+	if m.remoteIdx >= len(m.files) {
+		return nil
+	}
+	file := m.files[m.remoteIdx]
+	if file.ScanStatus == scanFlagged {
+		m.status = notice(file.FileName + " was flagged by the virus scan; download it from the file list to override")
+		return nil
+	}
+	dest := filepath.Join(m.localDir, pathElement(file.FileName))
+	start := func(m *Model) tea.Cmd {
+		return trackTransfer(m, commanderDownload(m.siteName, file, dest, move))
+	}
+	if _, err := os.Stat(dest); err == nil {
+		askConfirm(m, "Overwrite "+file.FileName+"?", "It already exists in "+m.localDir+".", start)
+		return nil
+	}
+	return start(m)
+}
+
+// commanderUpload uploads a local file or folder to the site, removing the
+// local file afterwards for a move once the upload has been verified.
+func commanderUpload(siteName string, password secret, path string, isDir, move bool, excludes []string) tea.Cmd {
+	return func() tea.Msg {
+		name := filepath.Base(path)
+		if isDir {
+			paths, err := collectFiles(path, excludes)
+			if err != nil {
+				return commanderDoneMsg{err: fmt.Errorf("error reading %s: %v", path, err)}
+			}
+			var failed []string
+			for _, p := range paths {
+				if err := postFile(siteName, p, uploadName(p), io.Discard, 0); err != nil {
+					failed = append(failed, filepath.Base(p))
+				}
+			}
+			files, err := fetchFilesDirectly(siteName, password)
+			if err != nil {
+				return commanderDoneMsg{err: fmt.Errorf("uploaded %s but error refreshing list: %v", name, err)}
+			}
+			status := success(fmt.Sprintf("Copied %s (%d files)", name, len(paths)))
+			if len(failed) > 0 {
+				status = notice(fmt.Sprintf("Copied %d of %d files of %s; failed: %s", len(paths)-len(failed), len(paths), name, strings.Join(failed, ", ")))
+			}
+			return commanderDoneMsg{status: status, files: files}
+		}
+
+		result, err := api.upload(siteName, password, path, uploadName(path))
+		if err != nil {
+			return commanderDoneMsg{err: err}
+		}
+		done := commanderDoneMsg{status: success("Copied " + name), files: result.files}
+		if !result.verified {
+			done.status = result.status
+			if move {
+				done.status.text += "; the local file was kept"
+			}
+			return done
+		}
+		if move {
+			if err := os.Remove(path); err != nil {
+				done.status = notice(fmt.Sprintf("Uploaded %s but could not remove it: %v", name, err))
+				return done
+			}
+			done.status = success("Moved " + name)
+		}
+		return done
+	}
+}
+
+// commanderDownload downloads a remote file into the local pane's folder,
+// deleting it from the site afterwards for a move. A move always fetches
+// from the server, so that a stale cached copy never replaces the original.
+func commanderDownload(siteName string, file FileInfo, dest string, move bool) tea.Cmd {
+	return func() tea.Msg {
+		if !move {
+			if err, ok := api.download(siteName, file.ID, file.FileName, dest)().(error); ok {
+				return commanderDoneMsg{err: err}
+			}
+			return commanderDoneMsg{status: success("Copied " + file.FileName)}
+		}
+
+		err := saveFile(dest, func(w io.Writer) (int64, error) {
+			return fetchFile(siteName, file.ID, file.FileName, w)
+		})
+		if err != nil {
+			return commanderDoneMsg{err: err}
+		}
+		if err, ok := api.deleteFile(siteName, file.ID, file.FileName)().(error); ok {
+			return commanderDoneMsg{status: notice(fmt.Sprintf("Downloaded %s but could not delete it from the site: %v", file.FileName, err))}
+		}
+		return commanderDoneMsg{status: success("Moved " + file.FileName), deleted: file.ID}
+	}
+}
+
+// handleCommanderDone refreshes both panes after a copy or move.
+func handleCommanderDone(m *Model, msg commanderDoneMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		m.status = notice(msg.err.Error())
+		return m, nil
+	}
+	if msg.files != nil {
+		m.files = msg.files
+	}
+	if msg.deleted != 0 {
+		m.files = slicesDeleteFile(m.files, msg.deleted)
+	}
+	m.remoteIdx = min(m.remoteIdx, max(0, len(m.files)-1))
+	if m.state == stateCommander {
+		readLocalDir(m)
+	}
+	m.status = msg.status
+	return m, nil
+}
+
+// slicesDeleteFile removes the file with the given ID from files.
+func slicesDeleteFile(files []FileInfo, id int) []FileInfo {
+	for i, file := range files {
+		if file.ID == id {
+			return append(files[:i:i], files[i+1:]...)
+		}
+	}
+	return files
+}
+
+// renderCommander renders the local and remote panes side by side.
+func renderCommander(m Model) string {
+	local := make([]string, len(m.localEntries))
+	for i, e := range m.localEntries {
+		name := e.name
+		if e.isDir {
+			name += "/"
+		} else {
+			name = fmt.Sprintf("%s %8s", padName(name, paneWidth-16), formatBytes(e.size))
+		}
+		local[i] = name
+	}
+	remote := make([]string, len(m.files))
+	for i, file := range m.files {
+		remote[i] = fitName(file.FileName, paneWidth-6)
+	}
+
+	left := renderPane(truncatePath(m.localDir, paneWidth-6), local, m.localIdx, m.pane == paneLocal)
+	right := renderPane(truncate(m.siteName, paneWidth-6), remote, m.remoteIdx, m.pane == paneRemote)
+	return lipgloss.JoinHorizontal(lipgloss.Top, left, right)
+}
+
+// renderPane renders one pane, scrolled to keep the cursor visible. The
+// focused pane has a highlighted border and cursor.
+func renderPane(title string, rows []string, cursor int, focused bool) string {
+	offset := max(0, cursor-visibleFiles+1)
+	end := min(offset+visibleFiles, len(rows))
+	lines := []string{highlightStyle.Render(title), strings.Repeat("─", paneWidth-6)}
+	if len(rows) == 0 {
+		lines = append(lines, "(empty)")
+	}
+	for i := offset; i < end; i++ {
+		switch {
+		case i == cursor && focused:
+			lines = append(lines, selectedStyle.Render("➜ "+rows[i]))
+		case i == cursor:
+			lines = append(lines, "› "+rows[i])
+		default:
+			lines = append(lines, "  "+rows[i])
+		}
+	}
+	style := fileListStyle.Width(paneWidth).Padding(0, 1)
+	if focused {
+		style = style.BorderForeground(selectedColor)
+	}
+	return style.Render(strings.Join(lines, "\n"))
+}
+
+// truncatePath shortens a path from the left, keeping its last folders.
+func truncatePath(path string, n int) string {
+	if runewidth.StringWidth(path) <= n {
+		return path
+	}
+	return "…" + lastCells(path, n-1)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// maxCommentLength keeps comments to short notes.
+	maxCommentLength = 500
+	// visibleComments is how many comments are shown at once.
+	visibleComments = 6
+	// commentWidth is the width comment text is wrapped to.
+	commentWidth = 56
+)
+
+// Comment is a note a site member left on a file.
+type Comment struct {
+	ID        int       `json:"id"`
+	Author    string    `json:"author"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// commentsMsg carries a file's comments, oldest first, and an optional
+// status line.
+type commentsMsg struct {
+	file     FileInfo
+	comments []Comment
+	status   statusLine
+}
+
+// openComments shows the comments of the selected file.
+func openComments(m *Model) tea.Cmd {
+	file, ok := selectedFile(*m)
+	if !ok {
+		return nil
+	}
+	return fetchComments(m.siteName, file)
+}
+
+// fetchComments loads a file's comments.
+func fetchComments(siteName string, file FileInfo) tea.Cmd {
+	return func() tea.Msg {
+		comments, err := fetchCommentsDirectly(siteName, file.ID)
+		if err != nil {
+			return err
+		}
+		return commentsMsg{file: file, comments: comments}
+	}
+}
+
+// postComment adds a comment to a file and reloads the comments.
+func postComment(siteName string, file FileInfo, text string) tea.Cmd {
+	return func() tea.Msg {
+		jsonData, err := json.Marshal(map[string]string{"text": text})
+		if err != nil {
+			return fmt.Errorf("error encoding comment: %v", err)
+		}
+		if err := sendMemberRequest("POST", commentsEndpoint(siteName, file.ID), bytes.NewBuffer(jsonData)); err != nil {
+			return fmt.Errorf("failed to post comment: %w", err)
+		}
+		comments, err := fetchCommentsDirectly(siteName, file.ID)
+		if err != nil {
+			return err
+		}
+		return commentsMsg{file: file, comments: comments, status: success("Comment added")}
+	}
+}
+
+func commentsEndpoint(siteName string, fileID int) string {
+	return fmt.Sprintf("%s/site/%s/files/%d/comments", serverURL, url.PathEscape(siteName), fileID)
+}
+
+// fetchCommentsDirectly fetches a file's comments synchronously.
+func fetchCommentsDirectly(siteName string, fileID int) ([]Comment, error) {
+	authToken, err := loadAuthToken()
+	if err != nil {
+		return nil, err
+	}
+
+	call, err := newAPICall(opMetadata, "GET", commentsEndpoint(siteName, fileID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+	call.req.Header.Set("Authorization", authToken.reveal())
+
+	resp, err := call.do()
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, call.fail(resp, "failed to fetch comments")
+	}
+
+	var result struct {
+		Comments []Comment `json:"comments"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+	return result.Comments, nil
+}
+
+// handleComments shows freshly loaded comments, scrolled to the newest.
+func handleComments(m *Model, msg commentsMsg) (tea.Model, tea.Cmd) {
+	m.commentFile = msg.file
+	m.comments = msg.comments
+	m.commentOffset = max(0, len(m.comments)-visibleComments)
+	if msg.status.text != "" {
+		m.commentInput = ""
+		m.status = msg.status
+	}
+	if m.state != stateComments {
+		m.goTo(stateComments)
+	}
+	return m, nil
+}
+
+// handleCommentsInput scrolls the comments and edits a new one. Enter
+// posts it.
+func handleCommentsInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up":
+		if m.commentOffset > 0 {
+			m.commentOffset--
+		}
+	case "down":
+		if m.commentOffset < len(m.comments)-visibleComments {
+			m.commentOffset++
+		}
+	case "enter":
+		text := strings.TrimSpace(m.commentInput)
+		if text == "" {
+			m.status = notice("Type a comment first")
+			return m, nil
+		}
+		m.status = statusLine{}
+		return m, postComment(m.siteName, m.commentFile, text)
+	case "esc":
+		m.goTo(stateViewFiles)
+	case "backspace":
+		m.commentInput = deleteLastChar(m.commentInput)
+	default:
+		text := typedText(msg)
+		if len([]rune(m.commentInput+text)) > maxCommentLength {
+			m.status = notice(fmt.Sprintf("Comments are limited to %d characters", maxCommentLength))
+			return m, nil
+		}
+		m.commentInput += text
+	}
+	return m, nil
+}
+
+// renderComments renders the visible comments with their author and time,
+// followed by the new comment being typed.
+func renderComments(m Model) string {
+	var lines []string
+	if len(m.comments) == 0 {
+		lines = append(lines, mutedStyle.Render("No comments yet. Be the first to leave a note."))
+	}
+	end := min(m.commentOffset+visibleComments, len(m.comments))
+	for _, c := range m.comments[m.commentOffset:end] {
+		header := lipgloss.NewStyle().Bold(true).Render(c.Author) + " " +
+			mutedStyle.Render(c.CreatedAt.Local().Format("Jan 2 15:04"))
+		lines = append(lines, header, lipgloss.NewStyle().Width(commentWidth).Render(c.Text), "")
+	}
+	if len(m.comments) > visibleComments {
+		lines = append(lines, mutedStyle.Render(fmt.Sprintf("%d-%d of %d comments", m.commentOffset+1, end, len(m.comments))), "")
+	}
+	lines = append(lines, "New comment:", m.commentInput+"█")
+	return strings.Join(lines, "\n")
+}
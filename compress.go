@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// acceptEncoding is sent with every API call. Setting it by hand turns off
+// net/http's own gzip handling, so do decompresses responses itself.
+const acceptEncoding = "gzip, deflate"
+
+// minCompressedUpload is the smallest upload body worth compressing.
+const minCompressedUpload = 1024
+
+// compressUploads reports whether CSHARE_COMPRESS_UPLOADS turns on gzip for
+// upload bodies. It is off by default because the server has to accept
+// gzip-encoded requests.
+func compressUploads() bool {
+	return envOn("CSHARE_COMPRESS_UPLOADS")
+}
+
+// decompress replaces a compressed response body with its decoded contents.
+// The length headers describe the compressed body, so they are dropped.
+func decompress(resp *http.Response) error {
+	var r io.ReadCloser
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return nil
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return fmt.Errorf("error decompressing response: %v", err)
+		}
+		r = gz
+	case "deflate":
+		// "deflate" is meant to be zlib-wrapped, but some servers send the
+		// raw stream.
+		buffered := bufio.NewReader(resp.Body)
+		if header, err := buffered.Peek(2); err == nil && isZlibHeader(header) {
+			z, err := zlib.NewReader(buffered)
+			if err != nil {
+				return fmt.Errorf("error decompressing response: %v", err)
+			}
+			r = z
+		} else {
+			r = flate.NewReader(buffered)
+		}
+	default:
+		return fmt.Errorf("unsupported response encoding %q", resp.Header.Get("Content-Encoding"))
+	}
+
+	resp.Body = &decodedBody{Reader: r, decoder: r, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// isZlibHeader reports whether b starts a zlib stream (RFC 1950).
+func isZlibHeader(b []byte) bool {
+	return b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
+
+// decodedBody closes both the decoder and the underlying response body.
+type decodedBody struct {
+	io.Reader
+	decoder io.Closer
+	body    io.Closer
+}
+
+func (d *decodedBody) Close() error {
+	d.decoder.Close()
+	return d.body.Close()
+}
+
+// gzipBody compresses an upload body when that is turned on and makes it
+// smaller, returning the body to send and its Content-Encoding ("" if it
+// was left as is).
+func gzipBody(body *bytes.Buffer) (*bytes.Buffer, string) {
+	if !compressUploads() || body.Len() < minCompressedUpload {
+		return body, ""
+	}
+	compressed := &bytes.Buffer{}
+	gz := gzip.NewWriter(compressed)
+	if _, err := gz.Write(body.Bytes()); err != nil {
+		return body, ""
+	}
+	if err := gz.Close(); err != nil || compressed.Len() >= body.Len() {
+		return body, ""
+	}
+	return compressed, "gzip"
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// configPath is the client settings file, kept next to .env.
+const configPath = "cshare.json"
+
+// Config holds client-side settings that are not secrets.
+type Config struct {
+	// Aliases maps short local names to real site names.
+	Aliases map[string]string `json:"aliases,omitempty"`
+	// Recent lists recently opened sites and downloaded files, newest first.
+	Recent []QuickItem `json:"recent,omitempty"`
+	// Pinned lists files the user pinned for quick access.
+	Pinned []QuickItem `json:"pinned,omitempty"`
+	// Hooks are commands run after downloads and uploads.
+	Hooks Hooks `json:"hooks"`
+	// DownloadPath is the template downloads are saved to, such as
+	// "downloads/{site}/{date}/{filename}". Empty means defaultDownloadPath.
+	DownloadPath string `json:"download_path,omitempty"`
+	// Headers are added to every request to cshare's servers, e.g. for an
+	// authenticating reverse proxy. Values may refer to environment
+	// variables as $NAME or ${NAME}.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Pins maps server host names to the SPKI hashes ("sha256/<base64>")
+	// their certificates must carry; connections to a pinned host that
+	// present none of them are refused.
+	Pins map[string][]string `json:"pins,omitempty"`
+	// Buckets maps site names to the S3-compatible buckets that back them
+	// instead of cshare's servers.
+	Buckets map[string]Bucket `json:"buckets,omitempty"`
+	// SFTP maps site names to the folders on SFTP servers that back them.
+	SFTP map[string]SFTPServer `json:"sftp,omitempty"`
+	// WebDAV maps site names to the WebDAV folders that back them.
+	WebDAV map[string]WebDAVShare `json:"webdav,omitempty"`
+}
+
+// loadConfig reads the settings file. A missing file yields empty settings.
+func loadConfig() (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", configPath, err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", configPath, err)
+	}
+	return cfg, nil
+}
+
+// saveConfig writes the settings file.
+func saveConfig(cfg *Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding settings: %v", err)
+	}
+	if err := os.WriteFile(configPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing %s: %v", configPath, err)
+	}
+	// A copy, as the caller may go on changing cfg.
+	saved := &Config{}
+	if json.Unmarshal(data, saved) == nil {
+		settings.Store(saved)
+	}
+	return nil
+}
+
+// settings holds the settings as cshare last read or saved them, so that
+// Update can consult them without reading the file: main loads them at
+// startup and saveConfig replaces them.
+var settings atomic.Pointer[Config]
+
+// currentConfig returns the settings held in memory, loading them on first
+// use. They are shared, so callers must not change them; updateConfig is
+// for that. Settings that can't be read count as empty.
+func currentConfig() *Config {
+	if cfg := settings.Load(); cfg != nil {
+		return cfg
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		cfg = &Config{}
+	}
+	settings.CompareAndSwap(nil, cfg)
+	return settings.Load()
+}
+
+// configMu serializes changes to the settings file, which commands make
+// concurrently.
+var configMu sync.Mutex
+
+// updateConfig loads the settings, lets change edit them and saves them,
+// holding configMu so concurrent changes don't undo each other.
+func updateConfig(change func(cfg *Config)) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	change(cfg)
+	return saveConfig(cfg)
+}
+
+// resolveSite returns the real site name for an alias, or name unchanged
+// when it isn't one.
+func resolveSite(name string) string {
+	if site, ok := currentConfig().Aliases[name]; ok {
+		return site
+	}
+	return name
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// configCheckInterval is how often the TUI looks for edits to config.toml.
+const configCheckInterval = 3 * time.Second
+
+// option is a setting of config.toml. Most can also be given as a CSHARE_*
+// environment variable, which takes precedence so containers, CI and
+// one-off runs can override the file. Settings read from the environment
+// are simply exported; the rest are applied directly.
+type option struct {
+	key   string
+	env   string       // environment variable for the setting, if any
+	apply func(string) // for settings read elsewhere; "" restores the default
+	def   string       // the default, as shown by `cshare config list`
+	live  bool         // picked up by a running TUI when the file changes
+	check func(string) error
+}
+
+// options are the settings config.toml can hold, in the order
+// `cshare config list` shows them.
+var options = []option{
+	{key: "server_url", env: "CSHARE_SERVER", apply: func(v string) { serverURL = cmp.Or(v, defaultServerURL) }, def: defaultServerURL, check: checkURL},
+	{key: "login_server_url", env: "CSHARE_LOGIN_SERVER", apply: func(v string) { loginServerURL = cmp.Or(v, defaultLoginServerURL) }, def: defaultLoginServerURL, check: checkURL},
+	{key: "site", env: "CSHARE_SITE", def: "", check: checkNotEmpty},
+	{key: "theme", env: "CSHARE_THEME", def: "auto", check: checkChoice("auto", "light", "dark")},
+	{key: "truncate_names", env: "CSHARE_TRUNCATE_NAMES", def: "end", live: true, check: checkChoice("end", "middle")},
+	{key: "download_dir", env: "CSHARE_DOWNLOAD_DIR", def: defaultDownloadDir, live: true, check: checkNotEmpty},
+	{key: "download_path", env: "CSHARE_DOWNLOAD_PATH", def: defaultDownloadDir + "/{filename}", live: true, check: validateDownloadPath},
+	{key: "on_conflict", env: "CSHARE_ON_CONFLICT", def: "ask", check: checkChoice("ask", "overwrite", "skip", "rename", "fail")},
+	{key: "upload_workers", env: "CSHARE_UPLOAD_WORKERS", def: strconv.Itoa(defaultUploadWorkers), live: true, check: checkCount(1)},
+	{key: "verify_retries", env: "CSHARE_VERIFY_RETRIES", def: strconv.Itoa(defaultVerifyRetries), live: true, check: checkCount(0)},
+	{key: "bandwidth_limit", env: "CSHARE_BANDWIDTH_LIMIT", def: "unlimited", check: checkRate},
+	{key: "prioritize_downloads", env: "CSHARE_PRIORITIZE_DOWNLOADS", def: "false", check: checkBool},
+	{key: "compress_uploads", env: "CSHARE_COMPRESS_UPLOADS", def: "false", live: true, check: checkBool},
+	{key: "sign", env: "CSHARE_SIGN", def: "off", live: true, check: checkChoice("off", signGPG, signMinisign)},
+	{key: "sign_key", env: "CSHARE_SIGN_KEY", def: "", live: true, check: checkNotEmpty},
+	{key: "trusted_keys", env: "CSHARE_TRUSTED_KEYS", def: "", live: true, check: checkNotEmpty},
+	{key: "auto_extract", env: "CSHARE_AUTO_EXTRACT", def: "false", live: true, check: checkBool},
+	{key: "prefetch_pinned", env: "CSHARE_PREFETCH_PINNED", def: "false", live: true, check: checkBool},
+	{key: "metadata_timeout", env: "CSHARE_METADATA_TIMEOUT", def: defaultMetadataTimeout.String(), live: true, check: checkDuration},
+	{key: "transfer_timeout", env: "CSHARE_TRANSFER_TIMEOUT", def: "0", live: true, check: checkDuration},
+	{key: "stall_timeout", env: "CSHARE_STALL_TIMEOUT", def: defaultStallTimeout.String(), live: true, check: checkDuration},
+	{key: "lock_after", env: "CSHARE_LOCK_AFTER", def: "0", live: true, check: checkDuration},
+	{key: "metrics_addr", env: "CSHARE_METRICS_ADDR", def: "", check: checkNotEmpty},
+	{key: "debug", env: "CSHARE_DEBUG", def: "false", live: true, check: checkBool},
+	{key: "keys.quick_open", apply: bindKey("quick_open"), def: defaultKeys["quick_open"], live: true, check: checkKey},
+	{key: "keys.transfers", apply: bindKey("transfers"), def: defaultKeys["transfers"], live: true, check: checkKey},
+	{key: "keys.session", apply: bindKey("session"), def: defaultKeys["session"], live: true, check: checkKey},
+	{key: "keys.lock", apply: bindKey("lock"), def: defaultKeys["lock"], live: true, check: checkKey},
+}
+
+// defaultKeys are the global shortcuts, which [keys] can rebind.
+var defaultKeys = map[string]string{
+	"quick_open": "ctrl+o",
+	"transfers":  "ctrl+b",
+	"session":    "ctrl+w",
+	"lock":       "ctrl+l",
+}
+
+// keyBindings are the global shortcuts in effect.
+var keyBindings = maps.Clone(defaultKeys)
+
+// userEnv records which of the options' variables were set before
+// config.toml was applied; the file doesn't override those.
+var userEnv = make(map[string]bool)
+
+// fileSettings are the values last applied from config.toml.
+var fileSettings map[string]string
+
+// configModTime is when config.toml was last changed, to spot edits.
+var configModTime time.Time
+
+// configFileMsg carries config.toml after it changed on disk.
+type configFileMsg struct {
+	values  map[string]string
+	modTime time.Time
+	err     error
+}
+
+// configFilePath is where config.toml lives: $XDG_CONFIG_HOME/cshare,
+// %APPDATA%\cshare on Windows, and ~/.config/cshare otherwise.
+func configFilePath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" && runtime.GOOS == "windows" {
+		dir = os.Getenv("APPDATA")
+	}
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("error finding the config directory: %v", err)
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "cshare", "config.toml"), nil
+}
+
+// readConfigFile reads and checks config.toml. A missing file has no
+// settings.
+func readConfigFile() (map[string]string, time.Time, error) {
+	path, err := configFilePath()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return map[string]string{}, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	values, err := parseTOML(data)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	for key, value := range values {
+		opt, ok := findOption(key)
+		if !ok {
+			return nil, time.Time{}, fmt.Errorf("error in %s: unknown setting %s", path, key)
+		}
+		if err := opt.check(value); err != nil {
+			return nil, time.Time{}, fmt.Errorf("error in %s: %s: %v", path, key, err)
+		}
+	}
+	return values, info.ModTime(), nil
+}
+
+// initConfigFile applies config.toml at startup, after .env is loaded.
+func initConfigFile() error {
+	for _, opt := range options {
+		if opt.env == "" {
+			continue
+		}
+		_, userEnv[opt.env] = os.LookupEnv(opt.env)
+		// Nothing else checks the settings applied here.
+		if userEnv[opt.env] && opt.apply != nil {
+			if err := opt.check(os.Getenv(opt.env)); err != nil {
+				return fmt.Errorf("invalid %s: %v", opt.env, err)
+			}
+		}
+	}
+	values, modTime, err := readConfigFile()
+	if err != nil {
+		return err
+	}
+	for _, opt := range options {
+		applyOption(opt, values[opt.key])
+	}
+	fileSettings, configModTime = values, modTime
+	return nil
+}
+
+// applyOption puts a setting from the file into effect, unless the
+// environment overrides it; value is "" when the file doesn't set it.
+func applyOption(opt option, value string) {
+	if userEnv[opt.env] {
+		if opt.apply != nil {
+			opt.apply(os.Getenv(opt.env))
+		}
+		return
+	}
+	switch {
+	case opt.apply != nil:
+		opt.apply(value)
+	case value == "":
+		os.Unsetenv(opt.env)
+	default:
+		os.Setenv(opt.env, value)
+	}
+}
+
+// checkConfigFile schedules the next look at config.toml, reporting it
+// when it changed since modTime.
+func checkConfigFile(modTime time.Time) tea.Cmd {
+	return tea.Tick(configCheckInterval, func(time.Time) tea.Msg {
+		path, err := configFilePath()
+		if err != nil {
+			return configFileMsg{modTime: modTime}
+		}
+		info, err := os.Stat(path)
+		switch {
+		case os.IsNotExist(err) && modTime.IsZero():
+			return configFileMsg{modTime: modTime}
+		case err == nil && info.ModTime().Equal(modTime):
+			return configFileMsg{modTime: modTime}
+		}
+		values, newModTime, err := readConfigFile()
+		if err != nil {
+			// Report a broken file once; the next edit is checked again.
+			if info != nil {
+				modTime = info.ModTime()
+			}
+			return configFileMsg{modTime: modTime, err: err}
+		}
+		return configFileMsg{values: values, modTime: newModTime}
+	})
+}
+
+// handleConfigFile reloads the live settings of an edited config.toml and
+// says which of the changes need a restart.
+func handleConfigFile(m *Model, msg configFileMsg) (tea.Model, tea.Cmd) {
+	if msg.modTime.Equal(configModTime) && msg.err == nil {
+		return m, checkConfigFile(configModTime)
+	}
+	configModTime = msg.modTime
+	if msg.err != nil {
+		m.status = notice(msg.err.Error())
+		return m, checkConfigFile(configModTime)
+	}
+
+	var reloaded, restart []string
+	for _, opt := range options {
+		value := msg.values[opt.key]
+		if value == fileSettings[opt.key] {
+			continue
+		}
+		if !opt.live {
+			restart = append(restart, opt.key)
+			continue
+		}
+		applyOption(opt, value)
+		reloaded = append(reloaded, opt.key)
+	}
+	for _, key := range restart {
+		// Keep the old value so the change is reported until it's applied.
+		if old, ok := fileSettings[key]; ok {
+			msg.values[key] = old
+		} else {
+			delete(msg.values, key)
+		}
+	}
+	fileSettings = msg.values
+
+	switch {
+	case len(restart) > 0 && len(reloaded) > 0:
+		m.status = notice(fmt.Sprintf("Reloaded %s; restart cshare to apply %s", strings.Join(reloaded, ", "), strings.Join(restart, ", ")))
+	case len(restart) > 0:
+		m.status = notice("Restart cshare to apply " + strings.Join(restart, ", "))
+	case len(reloaded) > 0:
+		m.status = success("Reloaded " + strings.Join(reloaded, ", "))
+	}
+	return m, checkConfigFile(configModTime)
+}
+
+// runConfigCommand implements `cshare config list|get|set|unset|path`.
+func runConfigCommand(args []string) error {
+	usage := fmt.Errorf("usage: cshare config list | get <key> | set <key> <value> | unset <key> | path")
+	if len(args) == 0 {
+		return usage
+	}
+	path, err := configFilePath()
+	if err != nil {
+		return err
+	}
+	switch {
+	case args[0] == "path" && len(args) == 1:
+		fmt.Println(path)
+	case args[0] == "list" && len(args) == 1:
+		for _, opt := range options {
+			value, source := effectiveOption(opt)
+			fmt.Printf("%-20s = %-24s (%s)\n", opt.key, formatTOMLValue(value), source)
+		}
+	case args[0] == "get" && len(args) == 2:
+		opt, ok := findOption(args[1])
+		if !ok {
+			return fmt.Errorf("unknown setting %s", args[1])
+		}
+		value, _ := effectiveOption(opt)
+		fmt.Println(value)
+	case args[0] == "set" && len(args) == 3:
+		opt, ok := findOption(args[1])
+		if !ok {
+			return fmt.Errorf("unknown setting %s", args[1])
+		}
+		if err := opt.check(args[2]); err != nil {
+			return fmt.Errorf("invalid %s: %v", opt.key, err)
+		}
+		if err := editConfigFile(path, opt.key, args[2], false); err != nil {
+			return err
+		}
+		if _, source := effectiveOption(opt); userEnv[opt.env] {
+			fmt.Printf("Saved, but %s is set in the environment and takes precedence\n", opt.env)
+		} else if source == configPath {
+			fmt.Printf("Saved, but %s in this folder sets %s and takes precedence\n", configPath, opt.key)
+		}
+	case args[0] == "unset" && len(args) == 2:
+		opt, ok := findOption(args[1])
+		if !ok {
+			return fmt.Errorf("unknown setting %s", args[1])
+		}
+		return editConfigFile(path, opt.key, "", true)
+	default:
+		return usage
+	}
+	return nil
+}
+
+// editConfigFile sets or removes one setting of config.toml, creating the
+// file and its directory as needed.
+func editConfigFile(path, key, value string, remove bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading %s: %v", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, setTOML(data, key, value, remove), 0644); err != nil {
+		return fmt.Errorf("error writing %s: %v", path, err)
+	}
+	return nil
+}
+
+// effectiveOption returns the value a setting has and where it comes from.
+// A download path saved in this folder's cshare.json comes between the
+// environment and the file, as downloadTemplate reads them.
+func effectiveOption(opt option) (value, source string) {
+	if userEnv[opt.env] {
+		return os.Getenv(opt.env), "environment " + opt.env
+	}
+	if path := currentConfig().DownloadPath; opt.key == "download_path" && path != "" {
+		return path, configPath
+	}
+	if value, ok := fileSettings[opt.key]; ok {
+		return value, "config file"
+	}
+	return opt.def, "default"
+}
+
+// findOption looks up a setting by key.
+func findOption(key string) (option, bool) {
+	i := slices.IndexFunc(options, func(opt option) bool { return opt.key == key })
+	if i < 0 {
+		return option{}, false
+	}
+	return options[i], true
+}
+
+// bindKey returns the apply function of a [keys] setting.
+func bindKey(name string) func(string) {
+	return func(v string) { keyBindings[name] = cmp.Or(v, defaultKeys[name]) }
+}
+
+func checkURL(v string) error {
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("expected an http:// or https:// URL")
+	}
+	return nil
+}
+
+// onValues and offValues are what boolean options take. envOn reads the
+// first as on and anything else as off.
+var (
+	onValues  = []string{"true", "1", "yes"}
+	offValues = []string{"false", "0", "no"}
+)
+
+// envOn reports whether a boolean option's variable turns it on.
+func envOn(env string) bool {
+	return slices.Contains(onValues, os.Getenv(env))
+}
+
+func checkBool(v string) error {
+	return checkChoice(slices.Concat(onValues, offValues)...)(v)
+}
+
+func checkChoice(choices ...string) func(string) error {
+	return func(v string) error {
+		if !slices.Contains(choices, v) {
+			return fmt.Errorf("expected one of %s", strings.Join(choices, ", "))
+		}
+		return nil
+	}
+}
+
+func checkCount(least int) func(string) error {
+	return func(v string) error {
+		if n, err := strconv.Atoi(v); err != nil || n < least {
+			return fmt.Errorf("expected a whole number of at least %d", least)
+		}
+		return nil
+	}
+}
+
+func checkDuration(v string) error {
+	if v == "0" {
+		return nil
+	}
+	if d, err := time.ParseDuration(v); err != nil || d < 0 {
+		return fmt.Errorf("expected a duration such as 30s or 15m")
+	}
+	return nil
+}
+
+func checkRate(v string) error {
+	_, err := parseRate(v)
+	return err
+}
+
+func checkNotEmpty(v string) error {
+	if strings.TrimSpace(v) == "" {
+		return fmt.Errorf("expected a value")
+	}
+	return nil
+}
+
+func checkKey(v string) error {
+	if v == "" || strings.ContainsAny(v, " \t") {
+		return fmt.Errorf("expected a key such as ctrl+o")
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestOptionDefaultsPassTheirChecks(t *testing.T) {
+	for _, opt := range options {
+		if opt.def == "" {
+			continue // unset
+		}
+		if err := opt.check(opt.def); err != nil {
+			t.Errorf("`cshare config set %s %s` refuses the default: %v", opt.key, opt.def, err)
+		}
+	}
+}
+
+func TestBoolOptionsAgreeWithReaders(t *testing.T) {
+	for _, value := range []string{"true", "1", "yes", "false", "0", "no"} {
+		if err := checkBool(value); err != nil {
+			t.Errorf("checkBool(%q): %v", value, err)
+		}
+		t.Setenv("CSHARE_AUTO_EXTRACT", value)
+		t.Setenv("CSHARE_DEBUG", value)
+		on := checkChoice(onValues...)(value) == nil
+		if autoExtract() != on || debugEnabled() != on {
+			t.Errorf("%q read as auto-extract %v, debug %v; want %v", value, autoExtract(), debugEnabled(), on)
+		}
+	}
+	if checkBool("maybe") == nil {
+		t.Error("checkBool accepted maybe")
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// confirmation is a dialog shown over the current screen before a
+// destructive or expensive action. While one is open it takes all keys.
+// The first choice is the safe one and Esc always dismisses the dialog
+// without picking anything.
+type confirmation struct {
+	prompt  string
+	detail  string
+	choices []string
+	keys    map[string]int // shortcut key -> choice
+	picked  int
+	onPick  func(m *Model, choice int) tea.Cmd
+	onEsc   func(m *Model) tea.Cmd
+
+	// toggle, when set, adds a checkbox flipped with Tab, such as
+	// "Apply to all"; its state is read from toggled in onPick.
+	toggle  string
+	toggled bool
+}
+
+// transferDoneMsg wraps the result of a tracked upload or download so the
+// model can count transfers still running.
+type transferDoneMsg struct {
+	msg tea.Msg
+}
+
+// askConfirm opens a yes/no dialog; onYes runs only if the user accepts.
+func askConfirm(m *Model, prompt, detail string, onYes func(m *Model) tea.Cmd) {
+	m.confirm = &confirmation{
+		prompt:  prompt,
+		detail:  detail,
+		choices: []string{"No", "Yes"},
+		keys:    map[string]int{"n": 0, "y": 1},
+		onPick: func(m *Model, choice int) tea.Cmd {
+			if choice == 1 {
+				return onYes(m)
+			}
+			return nil
+		},
+	}
+}
+
+// handleConfirmInput handles input while a confirmation dialog is open.
+func handleConfirmInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	c := m.confirm
+	key := msg.String()
+	switch key {
+	case "left":
+		c.picked = (c.picked + len(c.choices) - 1) % len(c.choices)
+	case "right":
+		c.picked = (c.picked + 1) % len(c.choices)
+	case "tab":
+		if c.toggle != "" {
+			c.toggled = !c.toggled
+		} else {
+			c.picked = (c.picked + 1) % len(c.choices)
+		}
+	case "esc":
+		m.confirm = nil
+		if c.onEsc != nil {
+			return m, c.onEsc(m)
+		}
+	case "enter":
+		m.confirm = nil
+		return m, c.onPick(m, c.picked)
+	default:
+		if choice, ok := c.keys[strings.ToLower(key)]; ok {
+			m.confirm = nil
+			return m, c.onPick(m, choice)
+		}
+	}
+	return m, nil
+}
+
+// renderConfirm renders the dialog with its choices.
+func renderConfirm(c confirmation) string {
+	var choices []string
+	help := []string{}
+	for i, choice := range c.choices {
+		if i == c.picked {
+			choices = append(choices, selectedStyle.Render("["+choice+"]"))
+		} else {
+			choices = append(choices, " "+choice+" ")
+		}
+		for key, idx := range c.keys {
+			if idx == i {
+				help = append(help, strings.ToUpper(key)+" - "+choice)
+			}
+		}
+	}
+	help = append(help, "←/→ - Choose", "Enter - Confirm")
+	if c.toggle != "" {
+		help = append(help, "Tab - "+c.toggle)
+	}
+
+	lines := []string{errorStyle.Render(c.prompt)}
+	if c.detail != "" {
+		lines = append(lines, "", c.detail)
+	}
+	lines = append(lines, "", lipgloss.JoinHorizontal(lipgloss.Top, choices...))
+	if c.toggle != "" {
+		box := "[ ] "
+		if c.toggled {
+			box = "[x] "
+		}
+		lines = append(lines, box+c.toggle)
+	}
+	lines = append(lines, "", highlightStyle.Render(strings.Join(help, " • ")))
+	return inputBoxStyle.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+// trackTransfer counts cmd as a running transfer until its result arrives.
+func trackTransfer(m *Model, cmd tea.Cmd) tea.Cmd {
+	m.transfers++
+	return func() tea.Msg {
+		return transferDoneMsg{msg: cmd()}
+	}
+}
+
+// transfersRunning reports whether quitting now would abandon a transfer.
+func transfersRunning(m *Model) bool {
+	return m.transfers > 0 || m.batchStream != nil || len(m.downloads.items) > 0
+}
+
+// confirmQuit quits, asking first while uploads or downloads are running.
+func confirmQuit(m *Model) tea.Cmd {
+	if !transfersRunning(m) {
+		return tea.Quit
+	}
+	running := m.transfers + len(m.downloads.items)
+	if m.batchStream != nil {
+		running++
+	}
+	askConfirm(m, fmt.Sprintf("%d transfer(s) still running. Quit anyway?", running),
+		"Quitting now abandons them; partial uploads are not kept.",
+		func(*Model) tea.Cmd { return tea.Quit })
+	return nil
+}
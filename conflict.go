@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// conflictPolicy decides what a download does when its destination in
+// the download folder already exists, and what an upload does when its
+// name is already on the site.
+type conflictPolicy string
+
+const (
+	conflictAsk       conflictPolicy = ""
+	conflictSkip      conflictPolicy = "skip"
+	conflictRename    conflictPolicy = "rename"
+	conflictOverwrite conflictPolicy = "overwrite"
+	conflictFail      conflictPolicy = "fail"
+)
+
+// conflictChoices are offered in this order, safest first.
+var conflictChoices = []conflictPolicy{conflictSkip, conflictRename, conflictOverwrite}
+
+// conflictLabels name the choices in the conflict dialog.
+var conflictLabels = map[conflictPolicy]string{
+	conflictSkip:      "Skip",
+	conflictRename:    "Keep Both",
+	conflictOverwrite: "Overwrite",
+}
+
+// conflictArgs strips --on-conflict=<policy> (or --on-conflict <policy>)
+// from the command line and returns the policy, which answers every
+// conflict without asking. Without the flag, CSHARE_ON_CONFLICT gives the
+// default; "ask", or neither, asks about each conflict.
+func conflictArgs(args []string) ([]string, conflictPolicy, error) {
+	policy := conflictAsk
+	switch p := conflictPolicy(os.Getenv("CSHARE_ON_CONFLICT")); p {
+	case "", "ask":
+	case conflictOverwrite, conflictSkip, conflictRename, conflictFail:
+		policy = p
+	default:
+		return nil, "", fmt.Errorf("unknown CSHARE_ON_CONFLICT value %q: use ask, overwrite, skip, rename or fail", p)
+	}
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		value, ok := strings.CutPrefix(arg, "--on-conflict=")
+		if !ok && arg == "--on-conflict" {
+			if i+1 == len(args) {
+				return nil, "", fmt.Errorf("--on-conflict needs a value: overwrite, skip, rename or fail")
+			}
+			i++
+			value, ok = args[i], true
+		}
+		if !ok {
+			rest = append(rest, arg)
+			continue
+		}
+		switch p := conflictPolicy(value); p {
+		case conflictOverwrite, conflictSkip, conflictRename, conflictFail:
+			policy = p
+		default:
+			return nil, "", fmt.Errorf("unknown --on-conflict value %q: use overwrite, skip, rename or fail", value)
+		}
+	}
+	return rest, policy, nil
+}
+
+// queuedDownload is one file waiting in the download queue.
+type queuedDownload struct {
+	site string
+	file FileInfo
+}
+
+// downloadQueue runs downloads one at a time so that conflicts can be asked
+// about in order. A single download is a queue of one.
+type downloadQueue struct {
+	items   []queuedDownload
+	current queuedDownload // the running download, when running is set
+	policy  conflictPolicy // from --on-conflict, or chosen with "apply to all"
+	running bool
+	total   int
+	done    int
+	skipped int
+	failed  int
+	badSigs int // downloads whose signature didn't check out
+}
+
+// downloadStepMsg wraps the result of one queued download and, for a file
+// with a signature next to it, how checking it went.
+type downloadStepMsg struct {
+	msg       tea.Msg
+	fileID    int
+	signature *signatureCheck
+}
+
+// queueDownloads adds files to the download queue, starting it if idle.
+// Files flagged by the server's virus scan are asked about first.
+func queueDownloads(m *Model, site string, files []FileInfo) tea.Cmd {
+	if flagged := flaggedFiles(m, site, files); len(flagged) > 0 {
+		askFlaggedDownload(m, site, files, flagged)
+		return nil
+	}
+	return enqueueDownloads(m, site, files)
+}
+
+// enqueueDownloads adds files to the download queue, starting it if idle.
+// While offline, files without a cached copy wait in the offline queue.
+func enqueueDownloads(m *Model, site string, files []FileInfo) tea.Cmd {
+	if workingOffline(m) {
+		if files = deferDownloads(m, site, files); len(files) == 0 {
+			return nil
+		}
+	}
+	q := &m.downloads
+	idle := len(q.items) == 0 && !q.running && m.confirm == nil
+	if idle {
+		*q = downloadQueue{policy: m.onConflict}
+	}
+	for _, file := range files {
+		q.items = append(q.items, queuedDownload{site: site, file: file})
+	}
+	q.total += len(files)
+	if !idle {
+		return nil
+	}
+	return nextDownload(m)
+}
+
+// nextDownload starts the next queued download, first asking what to do if
+// its destination exists and no policy applies yet.
+func nextDownload(m *Model) tea.Cmd {
+	q := &m.downloads
+	for len(q.items) > 0 {
+		item := q.items[0]
+		path := downloadDest(item.site, item.file.FileName)
+		if _, err := os.Stat(path); err == nil {
+			switch q.policy {
+			case conflictAsk:
+				askConflict(m, item, path)
+				return nil
+			case conflictFail:
+				q.failed += len(q.items)
+				q.items = nil
+				finishDownloads(m)
+				m.status = notice(fmt.Sprintf("%s already exists; stopped downloading (--on-conflict=fail)", path))
+				return nil
+			}
+			var skip bool
+			if path, skip = resolveConflict(path, q.policy); skip {
+				q.items = q.items[1:]
+				q.skipped++
+				continue
+			}
+		}
+		q.items = q.items[1:]
+		return startQueued(m, item, path)
+	}
+	finishDownloads(m)
+	return nil
+}
+
+// startQueued downloads a queued file to dest.
+func startQueued(m *Model, item queuedDownload, dest string) tea.Cmd {
+	m.downloads.running = true
+	m.downloads.current = item
+	// Signatures are checked when trusted keys are set and the file's
+	// site, whose listing holds the signature, is the open one.
+	keys := trustedKeys()
+	sigFile, signed := signatureOf(m.files, item.file.FileName)
+	signed = signed && len(keys) > 0 && item.site == m.siteName
+	return trackTransfer(m, func() tea.Msg {
+		step := downloadStepMsg{msg: api.download(item.site, item.file.ID, item.file.FileName, dest)(), fileID: item.file.ID}
+		if _, failed := step.msg.(error); !failed && signed {
+			signer, err := verifySignature(item.site, sigFile, dest, keys)
+			step.signature = &signatureCheck{signer: signer, err: err}
+		}
+		return step
+	})
+}
+
+// handleDownloadStep records a finished download and starts the next one.
+func handleDownloadStep(m *Model, msg downloadStepMsg) (tea.Model, tea.Cmd) {
+	q := &m.downloads
+	q.running = false
+	var cmd tea.Cmd
+	// A failed file is counted and reported on the file list rather than
+	// ending the batch on the menu.
+	if err, failed := msg.msg.(error); failed {
+		q.failed++
+		recordError(m, err)
+	} else {
+		q.done++
+		_, cmd = m.update(msg.msg)
+	}
+	if msg.signature != nil {
+		recordSignature(m, msg.fileID, *msg.signature)
+		if msg.signature.err != nil {
+			q.badSigs++
+		}
+	}
+	return m, tea.Batch(cmd, nextDownload(m))
+}
+
+// finishDownloads replaces the per-file status with a summary once a batch
+// of several downloads is through. A single download keeps its own status.
+func finishDownloads(m *Model) {
+	q := m.downloads
+	if q.total == 0 {
+		return
+	}
+	m.downloads = downloadQueue{}
+	if q.total == 1 {
+		return
+	}
+	status := fmt.Sprintf("Downloaded %d of %d file(s)", q.done, q.total)
+	if q.skipped > 0 {
+		status += fmt.Sprintf(", skipped %d", q.skipped)
+	}
+	if q.badSigs > 0 {
+		status += fmt.Sprintf(", ⚠ %d with a bad signature", q.badSigs)
+	}
+	if q.failed > 0 {
+		m.status = notice(fmt.Sprintf("%s, %d failed", status, q.failed))
+		return
+	}
+	if q.badSigs > 0 {
+		m.status = notice(status)
+		return
+	}
+	m.status = success(status)
+}
+
+// askConflict opens the overwrite / keep both / skip dialog for a download
+// whose destination exists. "Apply to all" is offered only when more
+// downloads are waiting.
+func askConflict(m *Model, item queuedDownload, path string) {
+	var labels []string
+	keys := map[string]int{}
+	for i, policy := range conflictChoices {
+		labels = append(labels, conflictLabels[policy])
+		keys[strings.ToLower(conflictLabels[policy][:1])] = i
+	}
+	c := &confirmation{
+		prompt:  path + " already exists",
+		detail:  "Overwrite it, keep both (the new copy gets a numbered name), or skip this file?",
+		choices: labels,
+		keys:    keys,
+		picked:  1,
+		onEsc: func(m *Model) tea.Cmd {
+			m.downloads.skipped += len(m.downloads.items)
+			m.downloads.items = nil
+			finishDownloads(m)
+			return nil
+		},
+	}
+	c.onPick = func(m *Model, choice int) tea.Cmd {
+		policy := conflictChoices[choice]
+		if c.toggled {
+			m.downloads.policy = policy
+		}
+		m.downloads.items = m.downloads.items[1:]
+		dest, skip := resolveConflict(path, policy)
+		if skip {
+			m.downloads.skipped++
+			if m.downloads.total == 1 {
+				m.status = success("Kept the existing " + path)
+			}
+			return nextDownload(m)
+		}
+		return startQueued(m, item, dest)
+	}
+	if len(m.downloads.items) > 1 {
+		c.toggle = "Apply to all"
+	}
+	m.confirm = c
+}
+
+// resolveConflict returns where to save a download whose destination path
+// exists, or skip if it shouldn't be saved at all.
+func resolveConflict(path string, policy conflictPolicy) (dest string, skip bool) {
+	switch policy {
+	case conflictOverwrite:
+		return path, false
+	case conflictRename:
+		return uniquePath(path), false
+	default:
+		return "", true
+	}
+}
+
+// uniquePath numbers a path as "name (1).ext", "name (2).ext", ... until it
+// names a file that doesn't exist yet.
+func uniquePath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for n := 1; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
+
+// downloadDest is where a downloaded file is saved by default, following
+// the download path template.
+func downloadDest(siteName, fileName string) string {
+	return expandDownloadPath(downloadTemplate(), siteName, fileName, time.Now())
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// fileInfoCopiedMsg reports copying a file's summary to the clipboard.
+// sumErr is set when the checksum couldn't be fetched and was left out.
+type fileInfoCopiedMsg struct {
+	name   string
+	err    error
+	sumErr error
+}
+
+// formatFileInfo renders a file's name, ID, size and checksum as a small
+// block to paste into a chat or ticket. An empty checksum is left out.
+func formatFileInfo(siteName string, file FileInfo, checksum string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "File:    %s\n", file.FileName)
+	fmt.Fprintf(&b, "Site:    %s\n", siteName)
+	fmt.Fprintf(&b, "ID:      %d\n", file.ID)
+	if file.Size > 0 {
+		fmt.Fprintf(&b, "Size:    %s (%d bytes)\n", formatBytes(file.Size), file.Size)
+	}
+	if checksum != "" {
+		fmt.Fprintf(&b, "SHA-256: %s\n", checksum)
+	}
+	return b.String()
+}
+
+// copyFileInfo copies the selected file's summary to the clipboard. The
+// checksum comes from the details pane when it has loaded one, and is
+// fetched otherwise.
+func copyFileInfo(m *Model) tea.Cmd {
+	file, ok := selectedFile(*m)
+	if !ok {
+		return nil
+	}
+	siteName := m.siteName
+	if d := m.details[file.ID]; d != nil && d.checksum != "" {
+		checksum := d.checksum
+		return func() tea.Msg {
+			return fileInfoCopiedMsg{name: file.FileName, err: clipboard.WriteAll(formatFileInfo(siteName, file, checksum))}
+		}
+	}
+	m.status = notice("Fetching the checksum of " + file.FileName + "...")
+	return func() tea.Msg {
+		checksum, sumErr := fetchRemoteChecksum(file.ID)
+		err := clipboard.WriteAll(formatFileInfo(siteName, file, checksum))
+		return fileInfoCopiedMsg{name: file.FileName, err: err, sumErr: sumErr}
+	}
+}
+
+// handleFileInfoCopied reports how copying a file's summary went.
+func handleFileInfoCopied(m *Model, msg fileInfoCopiedMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.err != nil:
+		m.status = notice(fmt.Sprintf("Error copying to clipboard: %v", msg.err))
+	case msg.sumErr != nil:
+		m.status = notice(fmt.Sprintf("Copied the details of %s without its checksum: %v", msg.name, msg.sumErr))
+	default:
+		m.status = success("Copied the details of " + msg.name + " to the clipboard")
+	}
+	return m, nil
+}
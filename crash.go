@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// program is the running TUI, so a panic anywhere can shut it down and
+// restore the terminal.
+var program *tea.Program
+
+// crashState is what the crash report says about the model, as of the
+// latest Update, so a panic on any goroutine can include it.
+var crashState atomic.Pointer[crashSnapshot]
+
+// crash records the first panic; later ones are usually fallout from it.
+var crash struct {
+	sync.Once
+	value  any
+	report string // path of the crash report, empty if it couldn't be written
+}
+
+// recoverCrash is deferred at the top of Update, View, commands and the
+// goroutines cshare starts itself. On a panic it writes a crash report and
+// kills the program, which restores the terminal; main then says where the
+// report is.
+func recoverCrash() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+	crash.Do(func() {
+		crash.value = r
+		crash.report = writeCrashReport(r, stack)
+	})
+	if program != nil {
+		program.Kill()
+	}
+}
+
+// guardCmd makes a command's panics go through recoverCrash and hands its
+// errors to Update as apiErrorMsg. Batched commands are guarded one by one
+// as Bubble Tea unpacks them.
+func guardCmd(cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() (msg tea.Msg) {
+		defer recoverCrash()
+		msg = cmd()
+		switch v := msg.(type) {
+		case tea.BatchMsg:
+			for i := range v {
+				v[i] = guardCmd(v[i])
+			}
+		case error:
+			return apiErrorMsg{err: v}
+		}
+		return msg
+	}
+}
+
+// writeCrashReport saves the panic, stack and model state to a file in the
+// working directory and returns its path.
+func writeCrashReport(r any, stack []byte) string {
+	now := time.Now()
+	path := fmt.Sprintf("cshare-crash-%s.txt", now.Format("20060102-150405"))
+
+	var report strings.Builder
+	fmt.Fprintf(&report, "cshare crash report\n\n")
+	fmt.Fprintf(&report, "Time:    %s\n", now.Format(time.RFC3339))
+	fmt.Fprintf(&report, "Go:      %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&report, "Panic:   %v\n\n", r)
+	fmt.Fprintf(&report, "Stack:\n%s\n", stack)
+	if state := crashState.Load(); state != nil {
+		fmt.Fprintf(&report, "Model:\n%s", state.describe())
+	}
+
+	if err := os.WriteFile(path, []byte(report.String()), 0600); err != nil {
+		return ""
+	}
+	return path
+}
+
+// crashSnapshot is the part of the model a crash report shows: where the
+// user was and how much was loaded or moving. Fields are picked one by one
+// so that passwords, tokens, typed text and file contents never get in.
+type crashSnapshot struct {
+	state       viewState
+	siteOpen    bool
+	signedIn    bool
+	files       int
+	selectedIdx int
+	fileOffset  int
+	listing     bool
+	listLoaded  int
+	listTotal   int
+	pending     int
+	batch       bool
+	batchTotal  int
+	batchDone   int
+	batchFailed int
+	transfers   int
+	downloads   int
+	confirm     bool
+	dryRun      bool
+	showDetails bool
+	groupByType bool
+	lastInput   time.Time
+}
+
+// snapshot copies what a crash report shows of m. Update calls it after
+// every message, so it only copies; describe does the formatting.
+func snapshot(m *Model) *crashSnapshot {
+	return &crashSnapshot{
+		state:       m.state,
+		siteOpen:    m.siteName != "",
+		signedIn:    m.account != "",
+		files:       len(m.files),
+		selectedIdx: m.selectedIdx,
+		fileOffset:  m.fileOffset,
+		listing:     m.listStream != nil,
+		listLoaded:  m.listLoaded,
+		listTotal:   m.listTotal,
+		pending:     len(m.pendingUploads),
+		batch:       m.batchStream != nil,
+		batchTotal:  m.batchTotal,
+		batchDone:   m.batchUploaded,
+		batchFailed: len(m.batchFailed),
+		transfers:   m.transfers,
+		downloads:   len(m.downloads.items),
+		confirm:     m.confirm != nil,
+		dryRun:      m.dryRun,
+		showDetails: m.showDetails,
+		groupByType: m.groupByType,
+		lastInput:   m.lastInput,
+	}
+}
+
+// describe renders the snapshot one field per line.
+func (s *crashSnapshot) describe() string {
+	var b strings.Builder
+	line := func(name string, value any) { fmt.Fprintf(&b, "  %-16s %v\n", name, value) }
+	line("screen", s.state)
+	line("site open", s.siteOpen)
+	line("signed in", s.signedIn)
+	line("files", s.files)
+	line("selected", s.selectedIdx)
+	line("scrolled", s.fileOffset)
+	line("listing", fmt.Sprintf("%v (%d of %d)", s.listing, s.listLoaded, s.listTotal))
+	line("selected uploads", s.pending)
+	line("batch upload", fmt.Sprintf("%v (%d of %d, %d failed)", s.batch, s.batchDone, s.batchTotal, s.batchFailed))
+	line("transfers", s.transfers)
+	line("queued downloads", s.downloads)
+	line("dialog open", s.confirm)
+	line("dry run", s.dryRun)
+	line("details pane", s.showDetails)
+	line("grouped", s.groupByType)
+	line("idle", time.Since(s.lastInput).Round(time.Second))
+	return b.String()
+}
+
+// reportCrash prints what happened after the terminal has been restored.
+func reportCrash() {
+	fmt.Printf("cshare crashed: %v\n", crash.value)
+	if crash.report != "" {
+		fmt.Printf("A crash report was written to %s. Please attach it when reporting the problem.\n", crash.report)
+	}
+	if _, ok := loadSession(); ok {
+		fmt.Println("Run cshare again to restore your session.")
+	}
+}
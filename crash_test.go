@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCrashReportLeavesOutSecrets(t *testing.T) {
+	m := &Model{
+		state:          stateViewFiles,
+		siteName:       "private-site",
+		password:       "hunter2",
+		authToken:      "tok-123",
+		totpCode:       "654321",
+		otpauthURL:     "otpauth://totp/x?secret=JBSWY3DP",
+		account:        "someone",
+		chatInput:      "meet at noon",
+		shareURL:       "https://cshare.example/s/abc",
+		files:          []FileInfo{{ID: 1, FileName: "salaries.xlsx"}},
+		transfers:      2,
+		pendingUploads: []string{"/home/me/a.txt"},
+	}
+	report := snapshot(m).describe()
+	for _, leak := range []string{"private-site", "hunter2", "tok-123", "654321", "JBSWY3DP", "someone", "noon", "cshare.example", "salaries", "/home/me"} {
+		if strings.Contains(report, leak) {
+			t.Errorf("the report shows %q:\n%s", leak, report)
+		}
+	}
+	for _, want := range []string{"viewFiles", "files            1", "transfers        2"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("the report lacks %q:\n%s", want, report)
+		}
+	}
+}
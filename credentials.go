@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+const (
+	// sitePasswordHeader carries a site's password, base64-encoded, to
+	// servers that accept it outside the URL, where proxies and access logs
+	// would record it.
+	sitePasswordHeader = "X-Site-Password"
+	// totpCodeHeader carries the two-factor code alongside it.
+	totpCodeHeader = "X-TOTP-Code"
+	// featurePasswordHeader is the capability of servers that read them.
+	featurePasswordHeader = "password_header"
+)
+
+// tokenOverrides are the variables that supply tokens where there is no
+// .env to log in to, such as containers and CI, and the names cshare keeps
+// the tokens under.
+var tokenOverrides = map[string]string{
+	"CSHARE_TOKEN":         "auth_token",
+	"CSHARE_ACCOUNT_TOKEN": "account_token",
+}
+
+// initTokenOverrides puts tokens given in the environment in place of the
+// ones saved in .env. A later login in the TUI still replaces them.
+func initTokenOverrides() {
+	for from, to := range tokenOverrides {
+		if token := os.Getenv(from); token != "" {
+			os.Setenv(to, token)
+		}
+	}
+}
+
+// passwordInHeader reports whether the server at base takes site
+// passwords in sitePasswordHeader. The server has to say so in its
+// capabilities, since older servers only read the query string and would
+// refuse the login.
+func passwordInHeader(base string) bool {
+	caps := capabilitiesOf(base)
+	return caps.known && caps.features[featurePasswordHeader]
+}
+
+// newSiteCall prepares the request that opens a site on base. The password
+// and two-factor code go in headers when the server supports it, and in
+// the query string, as older servers expect, otherwise.
+func newSiteCall(base, siteName string, password, totpCode secret) (*apiCall, error) {
+	endpoint := fmt.Sprintf("%s/site/%s", base, url.PathEscape(siteName))
+	headers := passwordInHeader(base)
+	if !headers {
+		query := url.Values{"password": {password.reveal()}}
+		if totpCode != "" {
+			query.Set("totp", totpCode.reveal())
+		}
+		endpoint += "?" + query.Encode()
+	}
+	call, err := newAPICall(opMetadata, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if headers {
+		call.req.Header.Set(sitePasswordHeader, base64.StdEncoding.EncodeToString([]byte(password.reveal())))
+		if totpCode != "" {
+			call.req.Header.Set(totpCodeHeader, totpCode.reveal())
+		}
+	}
+	return call, nil
+}
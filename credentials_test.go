@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// capabilityServer serves a descriptor listing features.
+func capabilityServer(features ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/capabilities" {
+			http.NotFound(w, r)
+			return
+		}
+		writeFakeJSON(w, http.StatusOK, map[string]any{"features": features})
+	}))
+}
+
+func TestPasswordInHeaderPerServer(t *testing.T) {
+	login := capabilityServer(featurePasswordHeader)
+	defer login.Close()
+	files := capabilityServer()
+	defer files.Close()
+	old := serverURL
+	serverURL = files.URL
+	defer func() { serverURL = old }()
+
+	// The files server's descriptor is the one the UI fetched; it must not
+	// decide for the login server, nor the other way round.
+	handleCapabilities(&Model{}, fetchCapabilitiesFrom(files.URL))
+	if !passwordInHeader(login.URL) {
+		t.Error("the login server takes passwords in a header, but they went in the URL")
+	}
+	if passwordInHeader(files.URL) {
+		t.Error("the files server doesn't take passwords in a header, but they were sent in one")
+	}
+}
+
+func TestSiteCallRedactsCredentialsInURL(t *testing.T) {
+	files := capabilityServer()
+	defer files.Close()
+	call, err := newSiteCall(files.URL, "notes", "hunter2", "123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer call.close()
+	query := call.req.URL.Query()
+	if query.Get("password") != "hunter2" || query.Get("totp") != "123456" {
+		t.Fatalf("the server without password_header got %s", call.req.URL)
+	}
+	redacted := redactURL(call.req.URL)
+	for _, leaked := range []string{"hunter2", "123456"} {
+		if strings.Contains(redacted, leaked) {
+			t.Errorf("redactURL left %s in %s", leaked, redacted)
+		}
+	}
+}
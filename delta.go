@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+const (
+	// deltaThreshold is the file size from which a re-upload sends only
+	// what changed, when the server can patch its copy.
+	deltaThreshold = 1 << 20
+	// deltaBlockSize is the block size asked for; the server may pick
+	// another.
+	deltaBlockSize = 64 << 10
+	// maxDeltaLiteral caps the new bytes carried by one patch operation.
+	maxDeltaLiteral = 1 << 20
+)
+
+// Operations of a patch, each followed by a big-endian uint32: the index
+// of a block of the server's copy to reuse, or the length of the new bytes
+// that follow.
+const (
+	deltaCopy    = 'C'
+	deltaLiteral = 'L'
+)
+
+// errDeltaUnavailable means the server can't patch the file, because it
+// has no copy yet or no delta endpoint, so the whole file has to go up.
+var errDeltaUnavailable = errors.New("delta upload not available")
+
+// deltaBlock is a block of the server's copy of a file: its rolling
+// checksum (see weakSum) and its SHA-256.
+type deltaBlock struct {
+	Weak   uint32 `json:"weak"`
+	Strong string `json:"strong"`
+}
+
+// deltaSignature describes the server's copy of a file in blocks of
+// BlockSize bytes, the last of which may be shorter.
+type deltaSignature struct {
+	BlockSize int          `json:"block_size"`
+	Size      int64        `json:"size"`
+	SHA256    string       `json:"sha256"`
+	Blocks    []deltaBlock `json:"blocks"`
+}
+
+// blockLen returns the length of block i.
+func (s deltaSignature) blockLen(i int) int {
+	if i == len(s.Blocks)-1 && s.Size%int64(s.BlockSize) != 0 {
+		return int(s.Size % int64(s.BlockSize))
+	}
+	return s.BlockSize
+}
+
+// weakSum is rsync's rolling checksum of a block x of length l: a is the
+// sum of its bytes and b the sum of (l-i)*x[i], both mod 2^16, combined
+// as a | b<<16.
+func weakSum(block []byte) (a, b uint32) {
+	l := uint32(len(block))
+	for i, c := range block {
+		a += uint32(c)
+		b += (l - uint32(i)) * uint32(c)
+	}
+	return a, b
+}
+
+// writeDelta reads the new content of a file from r and writes a patch
+// that rebuilds it from the blocks of sig, rsync-style: a window the size
+// of a block rolls over the content one byte at a time, and wherever its
+// checksums match one of sig's blocks that block is copied instead of
+// sent. It returns how many new bytes the patch carries.
+func writeDelta(r io.Reader, sig deltaSignature, out io.Writer) (int64, error) {
+	bs := sig.BlockSize
+	blocks := make(map[uint32][]int)
+	for i, block := range sig.Blocks {
+		blocks[block.Weak] = append(blocks[block.Weak], i)
+	}
+
+	in := bufio.NewReaderSize(r, 1<<16)
+	var header [5]byte
+	var literal []byte
+	var sent int64
+	emit := func(op byte, n int, data []byte) error {
+		header[0] = op
+		binary.BigEndian.PutUint32(header[1:], uint32(n))
+		if _, err := out.Write(header[:]); err != nil {
+			return err
+		}
+		_, err := out.Write(data)
+		return err
+	}
+	flush := func() error {
+		if len(literal) == 0 {
+			return nil
+		}
+		sent += int64(len(literal))
+		err := emit(deltaLiteral, len(literal), literal)
+		literal = literal[:0]
+		return err
+	}
+
+	// The window is ring[head:] followed by ring[:head], wl bytes long.
+	ring := make([]byte, bs)
+	var head, wl int
+	fill := func() error {
+		n, err := io.ReadFull(in, ring)
+		head, wl = 0, n
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		return err
+	}
+	window := func(fn func([]byte)) {
+		if end := head + wl; end <= bs {
+			fn(ring[head:end])
+		} else {
+			fn(ring[head:])
+			fn(ring[:end-bs])
+		}
+	}
+	match := func(weak uint32) (int, bool) {
+		var strong string
+		for _, i := range blocks[weak] {
+			if sig.blockLen(i) != wl {
+				continue
+			}
+			if strong == "" {
+				h := sha256.New()
+				window(func(p []byte) { h.Write(p) })
+				strong = hex.EncodeToString(h.Sum(nil))
+			}
+			if strong == sig.Blocks[i].Strong {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+
+	if err := fill(); err != nil {
+		return 0, err
+	}
+	a, b := weakSum(ring[:wl])
+	for wl > 0 {
+		if i, ok := match(a&0xffff | (b&0xffff)<<16); ok {
+			if err := flush(); err != nil {
+				return sent, err
+			}
+			if err := emit(deltaCopy, i, nil); err != nil {
+				return sent, err
+			}
+			if err := fill(); err != nil {
+				return sent, err
+			}
+			a, b = weakSum(ring[:wl])
+			continue
+		}
+
+		out := ring[head]
+		literal = append(literal, out)
+		if len(literal) >= maxDeltaLiteral {
+			if err := flush(); err != nil {
+				return sent, err
+			}
+		}
+		c, err := in.ReadByte()
+		switch {
+		case err == io.EOF:
+			// Near the end the window shrinks, to find a short last block.
+			a -= uint32(out)
+			b -= uint32(wl) * uint32(out)
+			head, wl = (head+1)%bs, wl-1
+		case err != nil:
+			return sent, err
+		default:
+			ring[head] = c
+			head = (head + 1) % bs
+			a = a - uint32(out) + uint32(c)
+			b = b - uint32(wl)*uint32(out) + a
+		}
+	}
+	return sent, flush()
+}
+
+// postDelta re-uploads a file the site already has under name by sending
+// only what changed since, when the server can patch its copy: it fetches
+// the signature of the server's copy, then streams a patch (see
+// writeDelta) that the server applies. The file is copied into hash once
+// the server has accepted it. It returns errDeltaUnavailable if the
+// server has no copy to patch or can't patch.
+//
+// The protocol is GET /upload/<site>/delta?name=<name>&block_size=<n>,
+// answered with a deltaSignature, then POST of the patch to the same path
+// with the copy's SHA-256 in X-Delta-Base; a server whose copy changed in
+// between answers 409 Conflict.
+func postDelta(siteName, path, name string, hash io.Writer, retries int) (err error) {
+	authToken, err := loadAuthToken()
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/upload/%s/delta?name=%s", serverURL, url.PathEscape(siteName), url.QueryEscape(name))
+
+	call, err := newAPICall(opMetadata, "GET", fmt.Sprintf("%s&block_size=%d", endpoint, deltaBlockSize), nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	call.req.Header.Set("Authorization", authToken.reveal())
+	resp, err := call.do()
+	if err != nil {
+		call.close()
+		return fmt.Errorf("error connecting to server: %v", err)
+	}
+	var sig deltaSignature
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if decodeErr := json.NewDecoder(resp.Body).Decode(&sig); decodeErr != nil || sig.BlockSize <= 0 {
+			err = errDeltaUnavailable
+		}
+	case http.StatusNotFound, http.StatusNotImplemented:
+		err = errDeltaUnavailable
+	default:
+		err = call.fail(resp, "failed to fetch the file's signature")
+	}
+	resp.Body.Close()
+	call.close()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+
+	// The patch streams out as it is worked out.
+	body, patch := io.Pipe()
+	done := make(chan int64, 1)
+	go func() {
+		defer recoverCrash()
+		literal, err := writeDelta(file, sig, patch)
+		done <- literal
+		patch.CloseWithError(err)
+	}()
+
+	call, err = newAPICall(opTransfer, "POST", endpoint, body)
+	if err != nil {
+		body.Close()
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+	call.req.Header.Set("Authorization", authToken.reveal())
+	call.req.Header.Set("Content-Type", "application/vnd.cshare.delta")
+	call.req.Header.Set("X-Delta-Base", sig.SHA256)
+
+	stat := newTransferStat(directionUpload, endpoint, siteName, name, retries)
+	call.live = stat.live
+	var sent int64
+	defer func() { stat.finish(sent, err) }()
+
+	resp, err = call.do()
+	body.Close() // stops the patch if the request ended early
+	sent = <-done
+	if err != nil {
+		return fmt.Errorf("error uploading file: %v", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusConflict, http.StatusNotFound, http.StatusNotImplemented:
+		return errDeltaUnavailable
+	default:
+		return call.fail(resp, "failed to upload file")
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error reading file: %v", err)
+	}
+	if _, err := io.Copy(hash, file); err != nil {
+		return fmt.Errorf("error reading file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPostFileTriesDeltaOnlyWhenListed(t *testing.T) {
+	dir := inTempDir(t)
+	var deltaAsks atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/upload/docs/delta":
+			deltaAsks.Add(1)
+			http.NotFound(w, r)
+		case r.Method == "POST" && r.URL.Path == "/upload/docs":
+			io.Copy(io.Discard, r.Body)
+			writeFakeJSON(w, http.StatusOK, map[string]any{"message": "File uploaded", "id": 1})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	oldServer, oldCaps := serverURL, serverCaps.Load()
+	serverURL = ts.URL
+	t.Cleanup(func() {
+		serverURL = oldServer
+		serverCaps.Store(oldCaps)
+	})
+	t.Setenv("auth_token", "token")
+	path := filepath.Join(dir, "big.bin")
+	if err := os.WriteFile(path, make([]byte, deltaThreshold), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		caps *capabilities
+		want int32
+	}{
+		{"descriptor not fetched", nil, 0},
+		{"no descriptor", &capabilities{}, 0},
+		{"not listed", &capabilities{known: true, features: map[string]bool{featureMultipart: true}}, 0},
+		{"listed", &capabilities{known: true, features: map[string]bool{featureDelta: true}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deltaAsks.Store(0)
+			serverCaps.Store(tt.caps)
+			if err := postFile("docs", path, "big.bin", io.Discard, 0); err != nil {
+				t.Fatalf("postFile: %v", err)
+			}
+			if got := deltaAsks.Load(); got != tt.want {
+				t.Errorf("asked for the delta signature %d times, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// previewBytes is how much of a file the details pane fetches for its
+	// preview.
+	previewBytes = 1024
+	// previewLines caps the preview's height.
+	previewLines = 6
+	// detailsWidth is the width of the details pane, taken from the file
+	// list while the pane is open.
+	detailsWidth = 32
+)
+
+// fileDetails is what the details pane knows about a file beyond the
+// listing. Fields stay empty until loaded.
+type fileDetails struct {
+	loading  bool
+	checksum string
+	preview  string
+	binary   bool
+	err      error
+}
+
+// detailsMsg carries a file's checksum and preview to the pane.
+type detailsMsg struct {
+	fileID  int
+	details fileDetails
+}
+
+// toggleDetails opens or closes the details pane.
+func toggleDetails(m *Model) tea.Cmd {
+	m.showDetails = !m.showDetails
+	return loadDetails(m)
+}
+
+// loadDetails fetches the selected file's details the first time the pane
+// shows it.
+func loadDetails(m *Model) tea.Cmd {
+	file, ok := selectedFile(*m)
+	if !m.showDetails || !ok {
+		return nil
+	}
+	if _, known := m.details[file.ID]; known {
+		return nil
+	}
+	if m.details == nil {
+		m.details = make(map[int]*fileDetails)
+	}
+	m.details[file.ID] = &fileDetails{loading: true}
+	return fetchDetails(file.ID)
+}
+
+// fetchDetails loads a file's checksum and the start of its contents.
+func fetchDetails(fileID int) tea.Cmd {
+	return func() tea.Msg {
+		var d fileDetails
+		d.checksum, d.err = fetchRemoteChecksum(fileID)
+		head, err := fetchHead(fileID, previewBytes)
+		switch {
+		case err != nil:
+			if d.err == nil {
+				d.err = err
+			}
+		case bytes.IndexByte(head, 0) >= 0 || !utf8.Valid(trimPartialRune(head)):
+			d.binary = true
+		default:
+			d.preview = string(trimPartialRune(head))
+		}
+		return detailsMsg{fileID: fileID, details: d}
+	}
+}
+
+// trimPartialRune drops a UTF-8 sequence cut off at the end of b.
+func trimPartialRune(b []byte) []byte {
+	for i := 0; i < utf8.UTFMax && len(b) > 0; i++ {
+		if r, size := utf8.DecodeLastRune(b); r != utf8.RuneError || size > 1 {
+			return b
+		}
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+// fetchHead fetches the first n bytes of a file. It asks for just that
+// range; servers that send the whole file anyway are cut off once enough
+// has arrived.
+func fetchHead(fileID int, n int) ([]byte, error) {
+	authToken, err := loadAuthToken()
+	if err != nil {
+		return nil, err
+	}
+	call, err := newAPICall(opMetadata, "GET", fmt.Sprintf("%s/getfile/%d", serverURL, fileID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+	call.req.Header.Set("Authorization", authToken.reveal())
+	call.req.Header.Set("Accept", downloadAccept)
+	call.req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", n-1))
+
+	resp, err := call.do()
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return nil, call.fail(resp, "failed to fetch preview")
+	}
+	if isLegacyDownload(resp) {
+		var result struct {
+			File     string `json:"file"`
+			Encoding string `json:"encoding"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return nil, fmt.Errorf("error parsing response: %v", err)
+		}
+		data, err := legacyContent(result.File, result.Encoding)
+		if err != nil {
+			return nil, err
+		}
+		return data[:min(len(data), n)], nil
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(n)))
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %v", err)
+	}
+	return data, nil
+}
+
+// renderDetails renders the details pane for the selected file.
+func renderDetails(m Model) string {
+	file, ok := selectedFile(m)
+	if !ok {
+		return mutedStyle.Render("Select a file to see its details.")
+	}
+	width := detailsWidth - 6
+	lines := []string{
+		lipgloss.NewStyle().Bold(true).Render(fitName(file.FileName, width)),
+		"",
+		"Type:     " + kindNames[kindOf(file.FileName, file.ContentType)],
+	}
+	if file.ContentType != "" {
+		lines = append(lines, "MIME:     "+truncate(file.ContentType, width-10))
+	}
+	lines = append(lines, fmt.Sprintf("ID:       %d", file.ID))
+	if file.Folder != "" {
+		lines = append(lines, "Folder:   "+truncate(file.Folder, width-10))
+	}
+	if file.Size > 0 {
+		lines = append(lines, "Size:     "+formatBytes(file.Size))
+	}
+	if !file.ModifiedAt.IsZero() {
+		lines = append(lines, "Modified: "+file.ModifiedAt.Local().Format("Jan 2 15:04"))
+	}
+	for _, key := range slices.Sorted(maps.Keys(file.Metadata)) {
+		lines = append(lines, truncate(key+": "+file.Metadata[key], width))
+	}
+	if len(file.Tags) > 0 {
+		lines = append(lines, "Tags:     "+truncate("#"+strings.Join(file.Tags, " #"), width-10))
+	}
+	if m.verified[file.ID] {
+		lines = append(lines, "Verified: ✓ matches upload")
+	}
+	if check, ok := m.signatures[file.ID]; ok && check.err == nil {
+		lines = append(lines, "Signed:   🔏 "+truncate(check.signer, width-13))
+	} else if ok {
+		lines = append(lines, "Signed:   ⚠ bad signature")
+	}
+	if label, ok := scanLabels[file.ScanStatus]; ok {
+		lines = append(lines, "Scan:     "+label)
+	}
+	if _, err := os.Stat(downloadDest(m.siteName, file.FileName)); err == nil {
+		lines = append(lines, "Local:    downloaded")
+	}
+
+	d := m.details[file.ID]
+	switch {
+	case d == nil || d.loading:
+		lines = append(lines, "", mutedStyle.Render("Loading details..."))
+	default:
+		if d.checksum != "" {
+			lines = append(lines, "", "SHA-256:", mutedStyle.Render(wrapHex(d.checksum, width)))
+		}
+		if d.err != nil {
+			lines = append(lines, "", errorStyle.UnsetPadding().Render(truncate(d.err.Error(), width)))
+		}
+		switch {
+		case d.binary:
+			lines = append(lines, "", mutedStyle.Render("Binary file: H for hex view"))
+		case d.preview != "":
+			lines = append(lines, "", "Preview:", mutedStyle.Render(previewSnippet(d.preview, width)))
+		}
+	}
+
+	lines = append(lines, "", "Actions:")
+	for _, row := range [][]string{{"Enter Download", "S Share"}, {"P Pin", "# Tags", "C Comments"}, {"E Edit", "X Delete"}} {
+		var actions []string
+		for _, action := range row {
+			if key, _, _ := strings.Cut(action, " "); unsupported(key) == "" {
+				actions = append(actions, action)
+			}
+		}
+		lines = append(lines, strings.Join(actions, " • "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wrapHex breaks a checksum into lines of width characters.
+func wrapHex(s string, width int) string {
+	var lines []string
+	for len(s) > width {
+		lines = append(lines, s[:width])
+		s = s[width:]
+	}
+	return strings.Join(append(lines, s), "\n")
+}
+
+// previewSnippet fits the start of a text file into the pane.
+func previewSnippet(text string, width int) string {
+	text = strings.ReplaceAll(text, "\t", "  ")
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if len(lines) > previewLines {
+		lines = lines[:previewLines]
+	}
+	for i, line := range lines {
+		lines[i] = truncate(line, width)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// diagTimeout bounds each troubleshooting step.
+const diagTimeout = 10 * time.Second
+
+// diagResult is the outcome of one troubleshooting step.
+type diagResult struct {
+	step   int
+	ok     bool
+	detail string
+	fix    string
+}
+
+// diagStep checks one layer of the connection to host.
+type diagStep struct {
+	name string
+	run  func(u *url.URL) diagResult
+}
+
+// diagSteps run in order; each depends on the ones before it, so the
+// wizard stops at the first failure.
+var diagSteps = []diagStep{
+	{"DNS resolution", diagDNS},
+	{"TCP connect", diagTCP},
+	{"TLS handshake", diagTLS},
+	{"HTTP reachability", diagHTTP},
+	{"Auth endpoint", diagAuth},
+}
+
+// startDiagnostics opens the troubleshooting screen and runs the first step.
+func startDiagnostics(m *Model) tea.Cmd {
+	m.goTo(stateDiagnose)
+	m.status = statusLine{}
+	m.diagResults = nil
+	m.diagRunning = true
+	return runDiagStep(0)
+}
+
+// handleDiagnoseInput handles input on the troubleshooting screen.
+func handleDiagnoseInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "r", "R":
+		if !m.diagRunning {
+			return m, startDiagnostics(m)
+		}
+	case "esc":
+		m.goTo(stateMenu)
+	}
+	return m, nil
+}
+
+// handleDiagResult records a step and runs the next one while steps pass.
+func handleDiagResult(m *Model, msg diagResult) (tea.Model, tea.Cmd) {
+	if m.state != stateDiagnose || msg.step != len(m.diagResults) {
+		return m, nil
+	}
+	m.diagResults = append(m.diagResults, msg)
+	if msg.ok && msg.step+1 < len(diagSteps) {
+		return m, runDiagStep(msg.step + 1)
+	}
+	m.diagRunning = false
+	return m, nil
+}
+
+// runDiagStep runs step i against the login server.
+func runDiagStep(i int) tea.Cmd {
+	return func() tea.Msg {
+		u, err := url.Parse(loginServerURL)
+		if err != nil {
+			return diagResult{step: i, detail: err.Error(), fix: "The configured server URL is invalid."}
+		}
+		result := diagSteps[i].run(u)
+		result.step = i
+		return result
+	}
+}
+
+// renderDiagnostics renders each step with its outcome and suggested fix.
+func renderDiagnostics(m Model) string {
+	var out strings.Builder
+	for i, step := range diagSteps {
+		switch {
+		case i < len(m.diagResults) && m.diagResults[i].ok:
+			out.WriteString(successStyle.Render("✔ "+step.name) + "  " + m.diagResults[i].detail + "\n")
+		case i < len(m.diagResults):
+			out.WriteString(errorStyle.Render("✘ "+step.name) + "  " + m.diagResults[i].detail + "\n")
+			out.WriteString(highlightStyle.Render("    → "+m.diagResults[i].fix) + "\n")
+		case i == len(m.diagResults) && m.diagRunning:
+			out.WriteString("  … " + step.name + "\n")
+		default:
+			out.WriteString("  · " + step.name + "\n")
+		}
+	}
+	return out.String()
+}
+
+// hostPort returns the address to dial for u.
+func hostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
+
+func diagDNS(u *url.URL) diagResult {
+	ctx, cancel := context.WithTimeout(context.Background(), diagTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, u.Hostname())
+	if err != nil {
+		return diagResult{detail: err.Error(), fix: "Check your internet connection and DNS settings, or try another network."}
+	}
+	return diagResult{ok: true, detail: strings.Join(addrs, ", ")}
+}
+
+func diagTCP(u *url.URL) diagResult {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", hostPort(u), diagTimeout)
+	if err != nil {
+		return diagResult{detail: err.Error(), fix: "A firewall or proxy may be blocking the connection, or the server is down."}
+	}
+	conn.Close()
+	return diagResult{ok: true, detail: fmt.Sprintf("connected in %s", time.Since(start).Round(time.Millisecond))}
+}
+
+func diagTLS(u *url.URL) diagResult {
+	if u.Scheme != "https" {
+		return diagResult{ok: true, detail: "not used (plain HTTP)"}
+	}
+	dialer := &net.Dialer{Timeout: diagTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", hostPort(u), &tls.Config{ServerName: u.Hostname()})
+	if err != nil {
+		return diagResult{detail: err.Error(), fix: "Check the system clock and that no proxy is intercepting HTTPS traffic."}
+	}
+	defer conn.Close()
+	state := conn.ConnectionState()
+	return diagResult{ok: true, detail: tls.VersionName(state.Version)}
+}
+
+func diagHTTP(u *url.URL) diagResult {
+	client := &http.Client{Timeout: diagTimeout, Transport: httpClient.Transport}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return diagResult{detail: err.Error(), fix: "The server accepts connections but doesn't answer HTTP; it may be restarting."}
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return diagResult{detail: resp.Status, fix: "The server is having problems. Try again later."}
+	}
+	return diagResult{ok: true, detail: resp.Status}
+}
+
+func diagAuth(u *url.URL) diagResult {
+	client := &http.Client{Timeout: diagTimeout, Transport: httpClient.Transport}
+	resp, err := client.Get(u.String() + "/site/cshare-diagnostics?password=")
+	if err != nil {
+		return diagResult{detail: err.Error(), fix: "The login endpoint is unreachable; the server may be partially down."}
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 500:
+		return diagResult{detail: resp.Status, fix: "The login service is failing. Try again later."}
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusOK:
+		return diagResult{ok: true, detail: resp.Status + " (login endpoint responding; check the site name and password)"}
+	default:
+		return diagResult{ok: true, detail: resp.Status}
+	}
+}
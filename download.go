@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// downloadAccept asks for the file as a raw byte stream. Servers that
+// predate streaming ignore it and answer with the legacy JSON envelope.
+const downloadAccept = "application/octet-stream, application/json;q=0.1"
+
+// isLegacyDownload reports whether the server sent the file wrapped in JSON
+// instead of streaming it.
+func isLegacyDownload(resp *http.Response) bool {
+	return strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json")
+}
+
+// dispositionName returns the file name from a Content-Disposition header,
+// or "" if there is none. Only the base name is kept so a server can't
+// point it outside the downloads directory.
+func dispositionName(resp *http.Response) string {
+	_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition"))
+	if err != nil || params["filename"] == "" {
+		return ""
+	}
+	return filepath.Base(filepath.Clean(params["filename"]))
+}
+
+// fetchFile downloads the contents of a file into w and records the
+// transfer's statistics. Streamed responses are copied as they arrive; the
+// legacy JSON envelope has to be read whole first.
+func fetchFile(siteName string, fileID int, fileName string, w io.Writer) (n int64, err error) {
+	if store, ok := storeFor(siteName); ok {
+		return store.fetch(siteName, fileID, fileName, w)
+	}
+	// Load auth token from .env file
+	authToken, err := loadAuthToken()
+	if err != nil {
+		return 0, err
+	}
+
+	// Create the download request
+	url := fmt.Sprintf("%s/getfile/%d", serverURL, fileID)
+	call, err := newAPICall(opTransfer, "GET", url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+
+	// Add authorization token to the request header
+	call.req.Header.Set("Authorization", authToken.reveal())
+	call.req.Header.Set("Accept", downloadAccept)
+
+	stat := newTransferStat(directionDownload, url, siteName, fileName, 0)
+	call.live = stat.live
+	defer func() { stat.finish(n, err) }()
+
+	// Send the request
+	resp, err := call.do()
+	if err != nil {
+		return 0, fmt.Errorf("error downloading file: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, call.fail(resp, "failed to download file")
+	}
+	if name := dispositionName(resp); name != "" {
+		stat.File = name
+	}
+
+	if !isLegacyDownload(resp) {
+		stat.live.setTotal(resp.ContentLength)
+		n, err = io.Copy(w, call.watch(resp.Body))
+		if err != nil {
+			return n, fmt.Errorf("error downloading file: %v", err)
+		}
+		if resp.ContentLength >= 0 && n != resp.ContentLength {
+			return n, fmt.Errorf("error downloading file: got %d of %d bytes", n, resp.ContentLength)
+		}
+		return n, nil
+	}
+
+	var result struct {
+		Message  string `json:"message"`
+		File     string `json:"file"`
+		Encoding string `json:"encoding"`
+	}
+	if err := json.NewDecoder(call.watch(resp.Body)).Decode(&result); err != nil {
+		return 0, fmt.Errorf("error parsing response: %v", err)
+	}
+	data, err := legacyContent(result.File, result.Encoding)
+	if err != nil {
+		return 0, err
+	}
+	written, err := w.Write(data)
+	if err != nil {
+		return int64(written), fmt.Errorf("error saving file: %v", err)
+	}
+	return int64(written), nil
+}
+
+// legacyContent decodes the file from a JSON envelope. Older servers send
+// text as is and flag base64-encoded binary files with "encoding".
+func legacyContent(file, encoding string) ([]byte, error) {
+	switch encoding {
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(file)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding file: %v", err)
+		}
+		return data, nil
+	case "":
+		return []byte(file), nil
+	default:
+		return nil, fmt.Errorf("unsupported file encoding %q", encoding)
+	}
+}
+
+// saveFile writes path through a temporary file in the same directory, so
+// an interrupted download never leaves a truncated file behind.
+func saveFile(path string, fill func(w io.Writer) (int64, error)) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating downloads directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".cshare-download-*")
+	if err != nil {
+		return fmt.Errorf("error saving file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error saving file: %v", err)
+	}
+	_, err = fill(tmp)
+	if closeErr := tmp.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("error saving file: %v", closeErr)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error saving file: %v", err)
+	}
+	return nil
+}
+
+// copyFile saves a copy of src at dst.
+func copyFile(src, dst string) error {
+	return saveFile(dst, func(w io.Writer) (int64, error) {
+		f, err := os.Open(src)
+		if err != nil {
+			return 0, fmt.Errorf("error reading %s: %v", src, err)
+		}
+		defer f.Close()
+		n, err := io.Copy(w, f)
+		if err != nil {
+			return n, fmt.Errorf("error saving file: %v", err)
+		}
+		return n, nil
+	})
+}
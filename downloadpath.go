@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// defaultDownloadDir is the folder downloads go to without a template;
+	// CSHARE_DOWNLOAD_DIR (download_dir in config.toml) replaces it.
+	defaultDownloadDir = "downloads"
+	// defaultDownloadPath keeps downloads in one flat folder, as before
+	// templates existed.
+	defaultDownloadPath = defaultDownloadDir + "/{filename}"
+)
+
+// downloadPlaceholders are the fields a download path template may use.
+var downloadPlaceholders = map[string]string{
+	"site":     "site name",
+	"date":     "download date, e.g. 2006-01-02",
+	"year":     "download year",
+	"month":    "download month, 01-12",
+	"type":     "file type, e.g. images",
+	"filename": "file name with extension",
+	"name":     "file name without extension",
+	"ext":      "extension without the dot",
+}
+
+var placeholderPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// validateDownloadPath checks a template: placeholders must be known and
+// balanced, and the file name must be part of it so that files don't
+// overwrite each other.
+func validateDownloadPath(template string) error {
+	if strings.TrimSpace(template) == "" {
+		return fmt.Errorf("the download path is empty")
+	}
+	named := false
+	for _, match := range placeholderPattern.FindAllStringSubmatch(template, -1) {
+		if _, ok := downloadPlaceholders[match[1]]; !ok {
+			return fmt.Errorf("unknown placeholder {%s}", match[1])
+		}
+		if match[1] == "filename" || match[1] == "name" {
+			named = true
+		}
+	}
+	if rest := placeholderPattern.ReplaceAllString(template, ""); strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("unbalanced { or } in the download path")
+	}
+	if !named {
+		return fmt.Errorf("the download path must contain {filename} or {name}")
+	}
+	for _, part := range strings.FieldsFunc(template, isPathSeparator) {
+		if part == ".." {
+			return fmt.Errorf("the download path must not contain ..")
+		}
+	}
+	return nil
+}
+
+func isPathSeparator(r rune) bool { return r == '/' || r == '\\' }
+
+// expandDownloadPath fills in a template for one file. Values are made safe
+// to use as a single path element, so a site or file name can't add folders.
+func expandDownloadPath(template, siteName, fileName string, now time.Time) string {
+	ext := filepath.Ext(fileName)
+	values := map[string]string{
+		"site":     siteName,
+		"date":     now.Format("2006-01-02"),
+		"year":     now.Format("2006"),
+		"month":    now.Format("01"),
+		"type":     strings.ToLower(kindNames[kindOf(fileName, "")]),
+		"filename": fileName,
+		"name":     strings.TrimSuffix(fileName, ext),
+		"ext":      strings.TrimPrefix(ext, "."),
+	}
+	path := placeholderPattern.ReplaceAllStringFunc(template, func(p string) string {
+		return pathElement(values[strings.Trim(p, "{}")])
+	})
+	return filepath.Clean(filepath.FromSlash(path))
+}
+
+// downloadTemplate returns the configured download path template:
+// CSHARE_DOWNLOAD_PATH from the environment or .env, else the one set for
+// this folder in cshare.json, else download_path from config.toml, else
+// one flat folder, CSHARE_DOWNLOAD_DIR or the default. Invalid templates
+// are skipped.
+func downloadTemplate() string {
+	// config.toml's download_path arrives as CSHARE_DOWNLOAD_PATH too;
+	// userEnv tells the two apart.
+	template := os.Getenv("CSHARE_DOWNLOAD_PATH")
+	valid := template != "" && validateDownloadPath(template) == nil
+	if valid && userEnv["CSHARE_DOWNLOAD_PATH"] {
+		return template
+	}
+	if cfg := currentConfig(); cfg.DownloadPath != "" && validateDownloadPath(cfg.DownloadPath) == nil {
+		return cfg.DownloadPath
+	}
+	if valid {
+		return template
+	}
+	if dir := os.Getenv("CSHARE_DOWNLOAD_DIR"); dir != "" {
+		return filepath.ToSlash(filepath.Join(dir, "{filename}"))
+	}
+	return defaultDownloadPath
+}
+
+// downloadRoot returns the folder all downloads land under, as the
+// download path template puts them: the part before its first placeholder,
+// such as "downloads" for "downloads/{site}/{filename}".
+func downloadRoot() string {
+	var dirs []string
+	for _, element := range strings.Split(downloadTemplate(), "/") {
+		if strings.Contains(element, "{") {
+			break
+		}
+		dirs = append(dirs, element)
+	}
+	if len(dirs) == 0 {
+		return "."
+	}
+	if len(dirs) == 1 && dirs[0] == "" {
+		return string(filepath.Separator)
+	}
+	return filepath.FromSlash(strings.Join(dirs, "/"))
+}
+
+// openDownloadPath shows the download path editor with the current template.
+func openDownloadPath(m *Model) {
+	m.pathTemplate = downloadTemplate()
+	m.goTo(stateDownloadPath)
+}
+
+// downloadPathSavedMsg reports a saved download path template.
+type downloadPathSavedMsg struct {
+	template string
+	err      error
+}
+
+// saveDownloadPath stores template in the settings file.
+func saveDownloadPath(template string) tea.Cmd {
+	return func() tea.Msg {
+		err := updateConfig(func(cfg *Config) {
+			cfg.DownloadPath = template
+			if template == defaultDownloadPath {
+				cfg.DownloadPath = ""
+			}
+		})
+		return downloadPathSavedMsg{template: template, err: err}
+	}
+}
+
+// handleDownloadPathSaved returns to the site settings once the template is
+// saved.
+func handleDownloadPathSaved(m *Model, msg downloadPathSavedMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		m.status = notice(msg.err.Error())
+		return m, nil
+	}
+	m.status = success("Downloads are saved to " + msg.template)
+	m.goTo(stateSiteSettings)
+	return m, nil
+}
+
+// handleDownloadPathInput edits the download path template. Enter saves it
+// if it is valid.
+func handleDownloadPathInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		if err := validateDownloadPath(m.pathTemplate); err != nil {
+			m.status = notice("Invalid download path: " + err.Error())
+			return m, nil
+		}
+		return m, saveDownloadPath(m.pathTemplate)
+	case "ctrl+r":
+		m.pathTemplate = defaultDownloadPath
+	case "esc":
+		m.goTo(stateSiteSettings)
+	case "backspace":
+		m.pathTemplate = deleteLastChar(m.pathTemplate)
+	default:
+		m.pathTemplate += typedText(msg)
+	}
+	return m, nil
+}
+
+// renderDownloadPath renders the template being edited, the placeholders
+// and where the selected file would be saved.
+func renderDownloadPath(m Model) string {
+	lines := []string{"Save downloads to:", m.pathTemplate + "█", ""}
+
+	example := "report.pdf"
+	if file, ok := selectedFile(m); ok {
+		example = file.FileName
+	}
+	if err := validateDownloadPath(m.pathTemplate); err != nil {
+		lines = append(lines, errorStyle.UnsetPadding().Render("✗ "+err.Error()))
+	} else {
+		dest := expandDownloadPath(m.pathTemplate, m.siteName, example, time.Now())
+		lines = append(lines, successStyle.UnsetPadding().Render("✓ "+example+" → "+dest))
+	}
+
+	lines = append(lines, "", "Placeholders:")
+	for _, name := range []string{"site", "date", "year", "month", "type", "filename", "name", "ext"} {
+		lines = append(lines, mutedStyle.Render(fmt.Sprintf("  %-11s %s", "{"+name+"}", downloadPlaceholders[name])))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestDownloadTemplatePrecedence(t *testing.T) {
+	old := settings.Load()
+	t.Cleanup(func() {
+		settings.Store(old)
+		delete(userEnv, "CSHARE_DOWNLOAD_PATH")
+	})
+
+	tests := []struct {
+		name    string
+		env     string
+		fromEnv bool // set by the user rather than from config.toml
+		json    string
+		dir     string
+		want    string
+	}{
+		{"environment over cshare.json", "env/{filename}", true, "json/{filename}", "", "env/{filename}"},
+		{"cshare.json over config.toml", "toml/{filename}", false, "json/{filename}", "", "json/{filename}"},
+		{"config.toml", "toml/{filename}", false, "", "", "toml/{filename}"},
+		{"cshare.json", "", false, "json/{filename}", "", "json/{filename}"},
+		{"invalid environment skipped", "env/{nope}", true, "json/{filename}", "", "json/{filename}"},
+		{"download folder", "", false, "", "out", "out/{filename}"},
+		{"default", "", false, "", "", defaultDownloadPath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CSHARE_DOWNLOAD_PATH", tt.env)
+			t.Setenv("CSHARE_DOWNLOAD_DIR", tt.dir)
+			userEnv["CSHARE_DOWNLOAD_PATH"] = tt.fromEnv
+			settings.Store(&Config{DownloadPath: tt.json})
+			if got := downloadTemplate(); got != tt.want {
+				t.Errorf("downloadTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
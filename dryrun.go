@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// visiblePlan is how many lines of a dry-run plan are shown at once.
+const visiblePlan = 15
+
+// plannedAction is one thing a dry run found would happen.
+type plannedAction struct {
+	action string // upload, download, delete, skip or refuse
+	path   string
+	size   int64 // -1 if unknown, e.g. for remote files
+	reason string
+}
+
+// dryRunArgs takes --dry-run out of args.
+func dryRunArgs(args []string) ([]string, bool) {
+	dryRun := false
+	var rest []string
+	for _, arg := range args {
+		if arg == "--dry-run" {
+			dryRun = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return rest, dryRun
+}
+
+// planUploads lists what uploading targets would send, and what it would
+// leave out and why.
+func planUploads(targets, excludes []string) ([]plannedAction, error) {
+	var plan []plannedAction
+	for _, target := range targets {
+		err := walkUpload(target, excludes, func(path string, d fs.DirEntry, excluded *ignoreRule) {
+			size := int64(-1)
+			if info, err := d.Info(); err == nil && !d.IsDir() {
+				size = info.Size()
+			}
+			switch {
+			case excluded != nil:
+				plan = append(plan, plannedAction{action: "skip", path: path, size: size, reason: "excluded by " + excluded.String()})
+			case d.Type().IsRegular():
+				if problem := uploadProblem(uploadName(path), size); problem != "" {
+					plan = append(plan, plannedAction{action: "refuse", path: path, size: size, reason: problem + "; stops the upload"})
+					return
+				}
+				reason := "new upload"
+				if size >= multipartThreshold && supports(featureMultipart) {
+					reason = fmt.Sprintf("sent in %d parts", len(splitParts(size)))
+				}
+				plan = append(plan, plannedAction{action: "upload", path: path, size: size, reason: reason})
+			default:
+				plan = append(plan, plannedAction{action: "skip", path: path, size: -1, reason: "not a regular file"})
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", target, err)
+		}
+	}
+	return plan, nil
+}
+
+// planDownloads lists where downloading files would save them and what
+// the conflict policy would do with files that already exist.
+func planDownloads(siteName string, files []FileInfo, policy conflictPolicy) []plannedAction {
+	var plan []plannedAction
+	for _, file := range files {
+		dest := downloadDest(siteName, file.FileName)
+		info, err := os.Stat(dest)
+		if err != nil {
+			plan = append(plan, plannedAction{action: "download", path: dest, size: -1, reason: "new file"})
+			continue
+		}
+		action := plannedAction{action: "download", path: dest, size: -1}
+		switch policy {
+		case conflictOverwrite:
+			action.reason = fmt.Sprintf("overwrites the existing %s", formatBytes(info.Size()))
+		case conflictRename:
+			action.path = uniquePath(dest)
+			action.reason = "exists, kept both"
+		case conflictSkip:
+			action.action = "skip"
+			action.reason = "exists"
+		case conflictFail:
+			action.action = "skip"
+			action.reason = "exists, stops the batch (--on-conflict=fail)"
+		default:
+			action.reason = "exists, you'd be asked what to do"
+		}
+		plan = append(plan, action)
+	}
+	return plan
+}
+
+// formatPlan renders a plan one action per line, followed by a summary.
+func formatPlan(plan []plannedAction) []string {
+	counts := make(map[string]int)
+	totals := make(map[string]int64)
+	var lines []string
+	for _, a := range plan {
+		size := "-"
+		if a.size >= 0 {
+			size = formatBytes(a.size)
+			totals[a.action] += a.size
+		}
+		counts[a.action]++
+		lines = append(lines, fmt.Sprintf("%-8s %10s  %s (%s)", a.action, size, a.path, a.reason))
+	}
+
+	var summary []string
+	for _, action := range []string{"upload", "download", "delete", "skip", "refuse"} {
+		if counts[action] == 0 {
+			continue
+		}
+		part := fmt.Sprintf("%d to %s", counts[action], action)
+		if action == "skip" {
+			part = fmt.Sprintf("%d skipped", counts[action])
+		} else if action == "refuse" {
+			part = fmt.Sprintf("%d the server would refuse", counts[action])
+		} else if totals[action] > 0 {
+			part += " (" + formatBytes(totals[action]) + ")"
+		}
+		summary = append(summary, part)
+	}
+	if len(summary) == 0 {
+		summary = append(summary, "nothing to do")
+	}
+	return append(lines, "", "Dry run: "+strings.Join(summary, ", ")+". Nothing was changed.")
+}
+
+// runDryRunUpload implements `cshare --dry-run <path>...`: it prints what
+// uploading the paths would do and exits.
+func runDryRunUpload(paths, excludes []string) error {
+	plan, err := planUploads(paths, excludes)
+	if err != nil {
+		return err
+	}
+	for _, line := range formatPlan(plan) {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// showPlan opens the dry-run screen with the plan for an operation that
+// was not carried out.
+func showPlan(m *Model, title string, plan []plannedAction) tea.Cmd {
+	m.planTitle = title
+	m.planLines = formatPlan(plan)
+	m.planOffset = 0
+	m.goTo(stateDryRun)
+	return nil
+}
+
+// handleDryRunInput scrolls the dry-run plan.
+func handleDryRunInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up":
+		if m.planOffset > 0 {
+			m.planOffset--
+		}
+	case "down":
+		if m.planOffset < len(m.planLines)-visiblePlan {
+			m.planOffset++
+		}
+	case "esc", "enter":
+		m.goTo(stateViewFiles)
+	}
+	return m, nil
+}
+
+// renderDryRun renders the visible lines of the plan.
+func renderDryRun(m Model) string {
+	end := min(m.planOffset+visiblePlan, len(m.planLines))
+	lines := slices.Clone(m.planLines[m.planOffset:end])
+	if len(m.planLines) > visiblePlan {
+		lines = append(lines, fmt.Sprintf("%d-%d of %d", m.planOffset+1, end, len(m.planLines)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// dryRunDelete plans the deletion of a remote file.
+func dryRunDelete(siteName string, file FileInfo) []plannedAction {
+	return []plannedAction{{action: "delete", path: siteName + "/" + file.FileName, size: -1, reason: "removed for everyone"}}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxEditSize caps the files E opens in an editor; larger files are rarely
+// notes or configs.
+const maxEditSize = 5 << 20
+
+// editFetchedMsg reports that a file was fetched for editing.
+type editFetchedMsg struct {
+	file FileInfo
+	path string
+	sum  [sha256.Size]byte
+	err  error
+}
+
+// editDoneMsg reports that the editor exited.
+type editDoneMsg struct {
+	file FileInfo
+	path string
+	sum  [sha256.Size]byte // of the file before editing
+	err  error
+}
+
+// editorCommand returns the user's editor: $VISUAL, then $EDITOR, then the
+// platform default. The variables may include arguments, e.g. "code --wait".
+func editorCommand(path string) *exec.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+	args := strings.Fields(editor)
+	return exec.Command(args[0], append(args[1:], path)...)
+}
+
+// editRemoteFile fetches a text file into a temporary folder for editing.
+func editRemoteFile(siteName string, file FileInfo) tea.Cmd {
+	return func() tea.Msg {
+		dir, err := os.MkdirTemp("", "cshare-edit-*")
+		if err != nil {
+			return editFetchedMsg{err: fmt.Errorf("error creating temporary folder: %v", err)}
+		}
+		path := filepath.Join(dir, pathElement(file.FileName))
+		err = saveFile(path, func(w io.Writer) (int64, error) {
+			return fetchFile(siteName, file.ID, file.FileName, w)
+		})
+		if err != nil {
+			os.RemoveAll(dir)
+			return editFetchedMsg{err: err}
+		}
+		data, err := os.ReadFile(path)
+		switch {
+		case err != nil:
+			err = fmt.Errorf("error reading %s: %v", file.FileName, err)
+		case len(data) > maxEditSize:
+			err = fmt.Errorf("%s is too large to edit (%s)", file.FileName, formatBytes(int64(len(data))))
+		case bytes.IndexByte(data, 0) >= 0:
+			err = fmt.Errorf("%s is not a text file", file.FileName)
+		}
+		if err != nil {
+			os.RemoveAll(dir)
+			return editFetchedMsg{err: err}
+		}
+		return editFetchedMsg{file: file, path: path, sum: sha256.Sum256(data)}
+	}
+}
+
+// handleEditFetched hands the fetched file to the editor. The TUI is
+// suspended until the editor exits.
+func handleEditFetched(m *Model, msg editFetchedMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		m.status = notice(msg.err.Error())
+		return m, nil
+	}
+	return m, tea.ExecProcess(editorCommand(msg.path), func(err error) tea.Msg {
+		return editDoneMsg{file: msg.file, path: msg.path, sum: msg.sum, err: err}
+	})
+}
+
+// handleEditDone uploads the edited file as the new version if it changed.
+func handleEditDone(m *Model, msg editDoneMsg) (tea.Model, tea.Cmd) {
+	dir := filepath.Dir(msg.path)
+	if msg.err != nil {
+		os.RemoveAll(dir)
+		m.status = notice(fmt.Sprintf("Error running the editor: %v (set $EDITOR to choose one)", msg.err))
+		return m, nil
+	}
+	data, err := os.ReadFile(msg.path)
+	if err != nil {
+		os.RemoveAll(dir)
+		m.status = notice(fmt.Sprintf("Error reading the edited file: %v", err))
+		return m, nil
+	}
+	if sha256.Sum256(data) == msg.sum {
+		os.RemoveAll(dir)
+		m.status = success("No changes to " + msg.file.FileName)
+		return m, nil
+	}
+
+	siteName, password := m.siteName, m.password
+	m.status = notice("Uploading the new version of " + msg.file.FileName + "...")
+	return m, trackTransfer(m, func() tea.Msg {
+		defer os.RemoveAll(dir)
+		result, err := api.upload(siteName, password, msg.path, uploadName(msg.path))
+		if err != nil {
+			return err
+		}
+		if result.verified {
+			result.status = success("Saved the new version of " + msg.file.FileName)
+		}
+		return result
+	})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Update doesn't touch the disk: what it saves is captured as it handles a
+// message and written by a command, and the settings and tokens it reads
+// are loaded at startup and kept in memory (see currentConfig). Feeding
+// messages to a Model is enough to check its state transitions, as
+// update_test.go does.
+//
+// Commands run on their own goroutines, so they never get the Model: they
+// are built from copies of the values they need, and slices the Model keeps
+// editing are cloned first. Everything they learn comes back as a message.
+
+// writeQueue orders the writes commands make to the same file. Commands run
+// concurrently, so a write that gets its turn after a newer one has landed
+// is dropped instead of putting older state back.
+type writeQueue struct {
+	mu      sync.Mutex
+	queued  map[string]uint64
+	written map[string]uint64
+}
+
+// diskWrites orders the files Update saves in the background.
+var diskWrites = &writeQueue{queued: make(map[string]uint64), written: make(map[string]uint64)}
+
+// write returns a command that runs write for path unless a write queued
+// later has already run. It must be called from Update, which fixes the
+// order.
+func (q *writeQueue) write(path string, write func()) tea.Cmd {
+	q.mu.Lock()
+	q.queued[path]++
+	seq := q.queued[path]
+	q.mu.Unlock()
+	return func() tea.Msg {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if seq > q.written[path] {
+			q.written[path] = seq
+			write()
+		}
+		return nil
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// expiryWarning is how close to its expiry a site starts showing a warning.
+const expiryWarning = 24 * time.Hour
+
+// ttlChoice is a lifetime offered when creating a site.
+type ttlChoice struct {
+	label string
+	ttl   time.Duration // 0 means the site never expires
+}
+
+// ttlChoices are the site lifetimes, in the order ←/→ cycles through them.
+var ttlChoices = []ttlChoice{
+	{label: "never", ttl: 0},
+	{label: "24h", ttl: 24 * time.Hour},
+	{label: "7d", ttl: 7 * 24 * time.Hour},
+	{label: "30d", ttl: 30 * 24 * time.Hour},
+}
+
+// siteLoadedMsg carries a site's files and, for expiring sites, when it
+// expires. staleSince is set when the files come from the offline copy.
+type siteLoadedMsg struct {
+	files      []FileInfo
+	expiresAt  time.Time
+	staleSince time.Time
+}
+
+// renderTTLPicker renders the lifetime choices, highlighting the selected one.
+func renderTTLPicker(selected int) string {
+	var choices []string
+	for i, choice := range ttlChoices {
+		if i == selected {
+			choices = append(choices, selectedStyle.Render("["+choice.label+"]"))
+		} else {
+			choices = append(choices, " "+choice.label+" ")
+		}
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, choices...)
+}
+
+// renderExpiry describes how long an expiring site has left, or returns an
+// empty string for sites that never expire.
+func renderExpiry(expiresAt time.Time) string {
+	if expiresAt.IsZero() {
+		return ""
+	}
+	remaining := time.Until(expiresAt)
+	if remaining <= 0 {
+		return errorStyle.Render("⚠ This site has expired")
+	}
+	if remaining < expiryWarning {
+		return errorStyle.Render("⚠ Expires in " + formatRemaining(remaining) + ", download anything you need")
+	}
+	return "⏳ Expires in " + formatRemaining(remaining)
+}
+
+// formatRemaining renders a duration as days and hours, or hours and
+// minutes when less than a day is left.
+func formatRemaining(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	hours := int(d/time.Hour) % 24
+	minutes := int(d/time.Minute) % 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// autoExtract reports whether downloaded archives are extracted, as set
+// by CSHARE_AUTO_EXTRACT.
+func autoExtract() bool {
+	return envOn("CSHARE_AUTO_EXTRACT")
+}
+
+// archiveBase strips the archive extension from a file name, e.g.
+// "photos.tar.gz" becomes "photos".
+func archiveBase(fileName string) string {
+	lower := strings.ToLower(fileName)
+	for _, ext := range []string{".tar.gz", ".tar.zst", ".tgz", ".tzst", ".tar", ".zip"} {
+		if strings.HasSuffix(lower, ext) {
+			return fileName[:len(fileName)-len(ext)]
+		}
+	}
+	return fileName
+}
+
+// tarReader decompresses a tar stream of the given format. zstd has no
+// decoder in the standard library, so the zstd command does that.
+func tarReader(format string, r io.Reader) (*tar.Reader, func() error, error) {
+	switch format {
+	case archiveTarGz:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading archive: %v", err)
+		}
+		return tar.NewReader(gz), gz.Close, nil
+	case archiveTarZst:
+		cmd := exec.Command("zstd", "-d", "-c", "-q")
+		cmd.Stdin = r
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading archive: %v", err)
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, nil, fmt.Errorf("extracting .tar.zst needs the zstd command: %v", err)
+		}
+		stop := func() error {
+			out.Close()
+			return cmd.Wait()
+		}
+		return tar.NewReader(out), stop, nil
+	}
+	return tar.NewReader(r), func() error { return nil }, nil
+}
+
+// safeJoin joins an archive entry's name to dir, refusing names that are
+// absolute or climb out of dir with "..".
+func safeJoin(dir, name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || filepath.VolumeName(clean) != "" || strings.HasPrefix(name, "/") ||
+		clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("unsafe path %q in archive", name)
+	}
+	return filepath.Join(dir, clean), nil
+}
+
+// extractArchive unpacks a downloaded archive into a new folder named after
+// it, next to it. Entries that would land outside that folder stop the
+// extraction; links are skipped. It returns the folder and the number of
+// files written.
+func extractArchive(archive string) (string, int, error) {
+	format := archiveFormat(archive)
+	if format == "" {
+		return "", 0, fmt.Errorf("%s is not a supported archive", filepath.Base(archive))
+	}
+	dir := filepath.Join(filepath.Dir(archive), archiveBase(filepath.Base(archive)))
+	if _, err := os.Stat(dir); err == nil {
+		dir = uniquePath(dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", 0, fmt.Errorf("error creating %s: %v", dir, err)
+	}
+
+	var n int
+	var err error
+	if format == archiveZip {
+		n, err = extractZip(archive, dir)
+	} else {
+		n, err = extractTar(archive, format, dir)
+	}
+	return dir, n, err
+}
+
+func extractZip(archive, dir string) (int, error) {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return 0, fmt.Errorf("error reading archive: %v", err)
+	}
+	defer zr.Close()
+
+	n := 0
+	for _, f := range zr.File {
+		dest, err := safeJoin(dir, f.Name)
+		if err != nil {
+			return n, err
+		}
+		mode := f.FileInfo().Mode()
+		switch {
+		case mode.IsDir():
+			err = os.MkdirAll(dest, 0755)
+		case mode.IsRegular():
+			var rc io.ReadCloser
+			if rc, err = f.Open(); err == nil {
+				err = writeEntry(dest, rc)
+				rc.Close()
+				n++
+			}
+		}
+		if err != nil {
+			return n, fmt.Errorf("error extracting %s: %v", f.Name, err)
+		}
+	}
+	return n, nil
+}
+
+func extractTar(archive, format, dir string) (n int, err error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return 0, fmt.Errorf("error reading archive: %v", err)
+	}
+	defer f.Close()
+	tr, stop, err := tarReader(format, f)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if stopErr := stop(); err == nil && stopErr != nil {
+			err = fmt.Errorf("error reading archive: %v", stopErr)
+		}
+	}()
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, fmt.Errorf("error reading archive: %v", err)
+		}
+		dest, err := safeJoin(dir, hdr.Name)
+		if err != nil {
+			return n, err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(dest, 0755)
+		case tar.TypeReg:
+			err = writeEntry(dest, tr)
+			n++
+		}
+		if err != nil {
+			return n, fmt.Errorf("error extracting %s: %v", hdr.Name, err)
+		}
+	}
+}
+
+// writeEntry writes one extracted file.
+func writeEntry(dest string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// extractNote extracts a downloaded archive when CSHARE_AUTO_EXTRACT is on
+// and describes the outcome for the download's status.
+func extractNote(path string) string {
+	if !autoExtract() || archiveFormat(path) == "" {
+		return ""
+	}
+	dir, n, err := extractArchive(path)
+	if err != nil {
+		return fmt.Sprintf(" • extraction failed: %v", err)
+	}
+	return fmt.Sprintf(" • extracted %d file(s) to %s", n, dir)
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// fakeServer is an in-memory stand-in for cshare's backends with just the
+// endpoints behind the core flows: creating and opening sites, uploading,
+// downloading, checksums, deleting and logging out. Of the optional
+// features it only reports taking passwords in a header, so the UI hides
+// the rest.
+type fakeServer struct {
+	mu     sync.Mutex
+	sites  map[string]*fakeSite
+	files  map[int]*fakeFile
+	nextID int
+}
+
+type fakeSite struct {
+	password  string
+	token     string
+	expiresAt time.Time
+}
+
+type fakeFile struct {
+	site        string
+	name        string
+	contentType string
+	data        []byte
+}
+
+// newFakeServer starts a fake server and points cshare at it until the
+// returned function is called, which also stops the server. It is meant
+// for tests of the TUI flows that shouldn't need a real backend.
+func newFakeServer() (*httptest.Server, func()) {
+	fs := &fakeServer{sites: make(map[string]*fakeSite), files: make(map[int]*fakeFile)}
+	ts := httptest.NewServer(fs.handler())
+	oldServer, oldLogin := serverURL, loginServerURL
+	serverURL, loginServerURL = ts.URL, ts.URL
+	return ts, func() {
+		serverURL, loginServerURL = oldServer, oldLogin
+		ts.Close()
+	}
+}
+
+func (fs *fakeServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ping", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("GET /capabilities", func(w http.ResponseWriter, r *http.Request) {
+		writeFakeJSON(w, http.StatusOK, map[string]any{"features": []string{featurePasswordHeader}})
+	})
+	mux.HandleFunc("POST /createsite", fs.createSite)
+	mux.HandleFunc("POST /logout", fs.logout)
+	mux.HandleFunc("GET /site/{site}", fs.openSite)
+	mux.HandleFunc("POST /upload/{site}", fs.upload)
+	mux.HandleFunc("GET /getfile/{id}", fs.download)
+	mux.HandleFunc("GET /checksum/{id}", fs.checksum)
+	mux.HandleFunc("DELETE /site/{site}/files/{id}", fs.deleteFile)
+	return mux
+}
+
+// writeFakeJSON answers with a JSON body.
+func writeFakeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// fakeError answers with an error in the server's format.
+func fakeError(w http.ResponseWriter, status int, message string) {
+	writeFakeJSON(w, status, map[string]string{"error": message})
+}
+
+func (fs *fakeServer) createSite(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SiteName  string `json:"site_name"`
+		Password  string `json:"password"`
+		ExpiresIn int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SiteName == "" {
+		fakeError(w, http.StatusBadRequest, "site_name is required")
+		return
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if _, ok := fs.sites[req.SiteName]; ok {
+		fakeError(w, http.StatusConflict, "site already exists")
+		return
+	}
+	site := &fakeSite{password: req.Password, token: newRequestID()}
+	if req.ExpiresIn > 0 {
+		site.expiresAt = time.Now().Add(time.Duration(req.ExpiresIn) * time.Second)
+	}
+	fs.sites[req.SiteName] = site
+	writeFakeJSON(w, http.StatusCreated, map[string]string{"message": "Site created", "auth_token": site.token})
+}
+
+func (fs *fakeServer) openSite(w http.ResponseWriter, r *http.Request) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	name := r.PathValue("site")
+	site, ok := fs.sites[name]
+	if !ok {
+		fakeError(w, http.StatusNotFound, "site not found")
+		return
+	}
+	password := r.URL.Query().Get("password")
+	if encoded := r.Header.Get(sitePasswordHeader); encoded != "" {
+		decoded, _ := base64.StdEncoding.DecodeString(encoded)
+		password = string(decoded)
+	}
+	if password != site.password && r.Header.Get("Authorization") != site.token {
+		fakeError(w, http.StatusUnauthorized, "wrong password")
+		return
+	}
+	files := []FileInfo{}
+	for id := 1; id <= fs.nextID; id++ {
+		if f, ok := fs.files[id]; ok && f.site == name {
+			files = append(files, FileInfo{ID: id, FileName: f.name, ContentType: f.contentType})
+		}
+	}
+	writeFakeJSON(w, http.StatusOK, map[string]any{"auth_token": site.token, "files": files, "expires_at": site.expiresAt})
+}
+
+func (fs *fakeServer) logout(w http.ResponseWriter, r *http.Request) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for _, site := range fs.sites {
+		if site.token != "" && r.Header.Get("Authorization") == site.token {
+			site.token = newRequestID()
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorized reports whether the request carries the token of site.
+func (fs *fakeServer) authorized(r *http.Request, site string) bool {
+	s, ok := fs.sites[site]
+	return ok && r.Header.Get("Authorization") == s.token
+}
+
+func (fs *fakeServer) upload(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			fakeError(w, http.StatusBadRequest, "bad gzip body")
+			return
+		}
+		r.Body = gz
+	}
+	part, header, err := r.FormFile("file")
+	if err != nil {
+		fakeError(w, http.StatusBadRequest, "missing file")
+		return
+	}
+	defer part.Close()
+	data, err := io.ReadAll(part)
+	if err != nil {
+		fakeError(w, http.StatusBadRequest, "error reading file")
+		return
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	site := r.PathValue("site")
+	if !fs.authorized(r, site) {
+		fakeError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	fs.nextID++
+	fs.files[fs.nextID] = &fakeFile{site: site, name: header.Filename, contentType: header.Header.Get("Content-Type"), data: data}
+	writeFakeJSON(w, http.StatusOK, map[string]any{"message": "File uploaded", "id": fs.nextID})
+}
+
+// file looks up the file a request names, checking its site's token.
+func (fs *fakeServer) file(w http.ResponseWriter, r *http.Request) (*fakeFile, int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	f, ok := fs.files[id]
+	if err != nil || !ok {
+		fakeError(w, http.StatusNotFound, "file not found")
+		return nil, 0, false
+	}
+	if !fs.authorized(r, f.site) {
+		fakeError(w, http.StatusUnauthorized, "invalid token")
+		return nil, 0, false
+	}
+	return f, id, true
+}
+
+func (fs *fakeServer) download(w http.ResponseWriter, r *http.Request) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f, _, ok := fs.file(w, r)
+	if !ok {
+		return
+	}
+	contentType := f.contentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": f.name}))
+	w.Header().Set("Content-Length", fmt.Sprint(len(f.data)))
+	_, _ = w.Write(f.data)
+}
+
+func (fs *fakeServer) checksum(w http.ResponseWriter, r *http.Request) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f, _, ok := fs.file(w, r)
+	if !ok {
+		return
+	}
+	sum := sha256.Sum256(f.data)
+	writeFakeJSON(w, http.StatusOK, map[string]string{"sha256": hex.EncodeToString(sum[:])})
+}
+
+func (fs *fakeServer) deleteFile(w http.ResponseWriter, r *http.Request) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f, id, ok := fs.file(w, r)
+	if !ok {
+		return
+	}
+	if f.site != r.PathValue("site") {
+		fakeError(w, http.StatusNotFound, "file not found")
+		return
+	}
+	delete(fs.files, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// inTempDir runs the test from an empty folder, as cshare keeps .env, its
+// config and caches in the working folder.
+func inTempDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	t.Setenv("auth_token", "")
+	return dir
+}
+
+// run runs a command to completion and returns its message.
+func run(t *testing.T, cmd tea.Cmd) tea.Msg {
+	t.Helper()
+	if cmd == nil {
+		t.Fatal("no command")
+	}
+	return cmd()
+}
+
+func TestSiteFlow(t *testing.T) {
+	dir := inTempDir(t)
+	_, stop := newFakeServer()
+	defer stop()
+
+	if msg := run(t, api.createSite("notes", "hunter2", false, 0)); msg != (siteCreatedMsg{}) {
+		t.Fatalf("createSite: got %#v", msg)
+	}
+
+	content := []byte("the quick brown fox\n")
+	local := filepath.Join(dir, "fox.txt")
+	if err := os.WriteFile(local, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	uploaded, err := api.upload("notes", "hunter2", local, "fox.txt")
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if !uploaded.verified {
+		t.Fatalf("upload not verified: %s", uploaded.status.text)
+	}
+
+	loaded, ok := run(t, api.openSite("notes", "hunter2", "")).(siteLoadedMsg)
+	if !ok {
+		t.Fatal("openSite: no file list")
+	}
+	if len(loaded.files) != 1 || loaded.files[0].FileName != "fox.txt" {
+		t.Fatalf("openSite: got files %+v", loaded.files)
+	}
+
+	dest := filepath.Join(dir, "downloads", "fox.txt")
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if msg, ok := run(t, api.download("notes", loaded.files[0].ID, "fox.txt", dest)).(downloadDoneMsg); !ok || msg.path != dest {
+		t.Fatalf("download: got %#v", msg)
+	}
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(content) {
+		t.Errorf("downloaded %q, want %q", got, content)
+	}
+
+	if msg, ok := run(t, api.deleteFile("notes", loaded.files[0].ID, "fox.txt")).(fileDeletedMsg); !ok {
+		t.Fatalf("deleteFile: got %#v", msg)
+	}
+	loaded = run(t, api.openSite("notes", "hunter2", "")).(siteLoadedMsg)
+	if len(loaded.files) != 0 {
+		t.Errorf("files left after delete: %+v", loaded.files)
+	}
+}
+
+func TestOpenSiteErrors(t *testing.T) {
+	inTempDir(t)
+	_, stop := newFakeServer()
+	defer stop()
+
+	if msg := run(t, api.createSite("notes", "hunter2", false, 0)); msg != (siteCreatedMsg{}) {
+		t.Fatalf("createSite: got %#v", msg)
+	}
+	tests := []struct {
+		site, password string
+	}{
+		{"notes", "wrong"},
+		{"missing", "hunter2"},
+	}
+	for _, tt := range tests {
+		os.Setenv("auth_token", "")
+		if msg, ok := run(t, api.openSite(tt.site, secret(tt.password), "")).(error); !ok {
+			t.Errorf("openSite(%q, %q): got %#v, want an error", tt.site, tt.password, msg)
+		}
+	}
+	if msg, ok := run(t, api.createSite("notes", "other", false, 0)).(error); !ok {
+		t.Errorf("createSite of a taken name: got %#v, want an error", msg)
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// kindOrder is the order of the sections of the grouped view and of the
+// type filters.
+var kindOrder = []fileKind{kindDocument, kindImage, kindCode, kindArchive, kindAudio, kindVideo, kindOther}
+
+// kindNames label sections and filters.
+var kindNames = map[fileKind]string{
+	kindOther:    "Other",
+	kindCode:     "Code",
+	kindImage:    "Images",
+	kindArchive:  "Archives",
+	kindDocument: "Documents",
+	kindAudio:    "Audio",
+	kindVideo:    "Video",
+}
+
+// fileFilter narrows the file list to one type, tag or extension. The zero
+// value shows every file.
+type fileFilter struct {
+	byKind bool
+	kind   fileKind
+	tag    string
+	ext    string // e.g. ".pdf"
+}
+
+func (f fileFilter) matches(file FileInfo) bool {
+	switch {
+	case f.ext != "":
+		return strings.EqualFold(filepath.Ext(file.FileName), f.ext)
+	case f.tag != "":
+		return slices.Contains(file.Tags, f.tag)
+	case f.byKind:
+		return kindOf(file.FileName, file.ContentType) == f.kind
+	}
+	return true
+}
+
+func (f fileFilter) String() string {
+	switch {
+	case f.ext != "":
+		return "*" + f.ext
+	case f.tag != "":
+		return "#" + f.tag
+	case f.byKind:
+		return kindNames[f.kind]
+	}
+	return "All files"
+}
+
+// listRow is a row of the file list: a file, or in the grouped view the
+// header of a section.
+type listRow struct {
+	header bool
+	kind   fileKind
+	count  int
+	file   FileInfo
+}
+
+// filteredFiles returns the files the filter lets through.
+func filteredFiles(m Model) []FileInfo {
+	if m.filter == (fileFilter{}) {
+		return m.files
+	}
+	var files []FileInfo
+	for _, file := range m.files {
+		if m.filter.matches(file) {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// fileRows lays out the file list as shown: filtered and, in the grouped
+// view, split into sections per type whose files are hidden while the
+// section is collapsed. selectedIdx and fileOffset index these rows.
+func fileRows(m Model) []listRow {
+	files := filteredFiles(m)
+	if !m.groupByType {
+		rows := make([]listRow, len(files))
+		for i, file := range files {
+			rows[i] = listRow{file: file}
+		}
+		return rows
+	}
+
+	sections := make(map[fileKind][]FileInfo)
+	for _, file := range files {
+		kind := kindOf(file.FileName, file.ContentType)
+		sections[kind] = append(sections[kind], file)
+	}
+	var rows []listRow
+	for _, kind := range kindOrder {
+		if len(sections[kind]) == 0 {
+			continue
+		}
+		rows = append(rows, listRow{header: true, kind: kind, count: len(sections[kind])})
+		if m.collapsed[kind] {
+			continue
+		}
+		for _, file := range sections[kind] {
+			rows = append(rows, listRow{kind: kind, file: file})
+		}
+	}
+	return rows
+}
+
+// selectedFile returns the file under the cursor, if the cursor is on one.
+func selectedFile(m Model) (FileInfo, bool) {
+	rows := fileRows(m)
+	if m.selectedIdx < 0 || m.selectedIdx >= len(rows) || rows[m.selectedIdx].header {
+		return FileInfo{}, false
+	}
+	return rows[m.selectedIdx].file, true
+}
+
+// toggleSection collapses or expands the section whose header is selected.
+// It reports false if the cursor isn't on a header.
+func toggleSection(m *Model) bool {
+	rows := fileRows(*m)
+	if m.selectedIdx < 0 || m.selectedIdx >= len(rows) || !rows[m.selectedIdx].header {
+		return false
+	}
+	if m.collapsed == nil {
+		m.collapsed = make(map[fileKind]bool)
+	}
+	kind := rows[m.selectedIdx].kind
+	m.collapsed[kind] = !m.collapsed[kind]
+	keepSelectionVisible(m)
+	return true
+}
+
+// toggleGrouping switches between the flat and the grouped view, keeping
+// the selected file selected.
+func toggleGrouping(m *Model) {
+	file, ok := selectedFile(*m)
+	m.groupByType = !m.groupByType
+	selectFile(m, file.ID, ok)
+}
+
+// selectFile moves the cursor to the file with the given ID if it is
+// shown, and to the top otherwise.
+func selectFile(m *Model, id int, ok bool) {
+	m.selectedIdx = 0
+	if ok {
+		for i, row := range fileRows(*m) {
+			if !row.header && row.file.ID == id {
+				m.selectedIdx = i
+				break
+			}
+		}
+	}
+	keepSelectionVisible(m)
+}
+
+// filterOptions are the entries of the filter menu: the types, then the
+// tags used in the site. The extension entry comes last.
+func filterOptions(files []FileInfo) []fileFilter {
+	options := []fileFilter{{}}
+	for _, kind := range kindOrder {
+		options = append(options, fileFilter{byKind: true, kind: kind})
+	}
+	for _, tag := range siteTags(files) {
+		options = append(options, fileFilter{tag: tag})
+	}
+	return options
+}
+
+// openFilterMenu shows the filter menu with the current filter selected.
+func openFilterMenu(m *Model) {
+	m.filterIdx = 0
+	m.filterExt = m.filter.ext
+	options := filterOptions(m.files)
+	for i, option := range options {
+		if option == m.filter {
+			m.filterIdx = i
+		}
+	}
+	if m.filter.ext != "" {
+		m.filterIdx = len(options)
+	}
+	m.goTo(stateFileFilter)
+}
+
+// handleFilterInput handles input in the filter menu. Typing enters an
+// extension and selects the extension entry.
+func handleFilterInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	options := filterOptions(m.files)
+	switch msg.String() {
+	case "up":
+		if m.filterIdx > 0 {
+			m.filterIdx--
+		}
+	case "down":
+		if m.filterIdx < len(options) {
+			m.filterIdx++
+		}
+	case "enter":
+		file, ok := selectedFile(*m)
+		if m.filterIdx < len(options) {
+			m.filter = options[m.filterIdx]
+		} else {
+			ext := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(m.filterExt), "*"))
+			if ext == "" {
+				m.status = notice("Type an extension, such as .pdf")
+				return m, nil
+			}
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			m.filter = fileFilter{ext: ext}
+		}
+		m.goTo(stateViewFiles)
+		selectFile(m, file.ID, ok)
+	case "esc":
+		m.goTo(stateViewFiles)
+	case "backspace":
+		m.filterExt = deleteLastChar(m.filterExt)
+		m.filterIdx = len(options)
+	default:
+		if text := typedText(msg); text != "" {
+			m.filterExt += text
+			m.filterIdx = len(options)
+		}
+	}
+	return m, nil
+}
+
+// renderFilterMenu renders the filter choices with the number of files
+// each would show.
+func renderFilterMenu(m Model) string {
+	var b strings.Builder
+	options := filterOptions(m.files)
+	for i, option := range options {
+		count := 0
+		for _, file := range m.files {
+			if option.matches(file) {
+				count++
+			}
+		}
+		line := fmt.Sprintf("%s (%d)", option, count)
+		if i == m.filterIdx {
+			b.WriteString(selectedStyle.Render("➜  "+line) + "\n")
+		} else {
+			b.WriteString("   " + line + "\n")
+		}
+	}
+	line := "Extension: " + m.filterExt
+	if m.filterIdx == len(options) {
+		b.WriteString(selectedStyle.Render("➜  " + line + "█"))
+	} else {
+		b.WriteString("   " + line)
+	}
+	return b.String()
+}
+
+// renderSectionHeader renders the header of a section of the grouped view.
+func renderSectionHeader(row listRow, collapsed bool) string {
+	arrow := "▾"
+	if collapsed {
+		arrow = "▸"
+	}
+	return lipgloss.NewStyle().Bold(true).Render(fmt.Sprintf("%s %s (%d)", arrow, kindNames[row.kind], row.count))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxNameBytes is the longest file name the common file systems (NTFS,
+// APFS, ext4) all accept.
+const maxNameBytes = 255
+
+// reservedNames are the device names Windows won't create files under,
+// with or without an extension.
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// pathElement makes s a file name that is valid, and means the same file,
+// on Windows, macOS and Linux, so a site or file name can't add folders or
+// break a download on another system. Every local path built from a name
+// the server sent goes through it: downloads, the commander's copies and
+// the cache of files and listings.
+//   - Unicode is normalized to NFC, so an "é" typed on macOS (which may
+//     hand out decomposed names) matches the same name from Windows or Linux
+//   - separators, the characters NTFS forbids and control characters
+//     become "_"
+//   - trailing dots and spaces, which Windows silently drops, are removed
+//   - Windows device names such as CON or nul.txt get a "_" appended
+//   - names longer than maxNameBytes are shortened, keeping the extension
+func pathElement(s string) string {
+	s = norm.NFC.String(s)
+	s = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, s)
+	s = strings.TrimRight(s, ". ")
+	if s == "" {
+		return "_"
+	}
+	base, ext, _ := strings.Cut(s, ".")
+	if reservedNames[strings.ToUpper(strings.TrimSpace(base))] {
+		s = base + "_"
+		if ext != "" {
+			s += "." + ext
+		}
+	}
+	return truncateName(s, maxNameBytes)
+}
+
+// truncateName shortens name to at most n bytes without splitting a
+// character, cutting from the end of the name before the extension.
+func truncateName(name string, n int) string {
+	if len(name) <= n {
+		return name
+	}
+	ext := filepath.Ext(name)
+	if len(ext) > n/2 {
+		ext = ""
+	}
+	stem := name[:len(name)-len(ext)]
+	limit := n - len(ext)
+	for limit > 0 && !utf8.RuneStart(stem[limit]) {
+		limit--
+	}
+	return stem[:limit] + ext
+}
+
+// uploadName is the name a local file is uploaded under: its base name,
+// made portable so whoever downloads it gets a usable file.
+func uploadName(path string) string {
+	return pathElement(filepath.Base(path))
+}
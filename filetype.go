@@ -0,0 +1,124 @@
+package main
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// fileKind groups content types for the icons and colors of the file list.
+type fileKind int
+
+const (
+	kindOther fileKind = iota
+	kindCode
+	kindImage
+	kindArchive
+	kindDocument
+	kindAudio
+	kindVideo
+)
+
+// kindIcons are shown before each file name; plain mode drops them.
+var kindIcons = map[fileKind]string{
+	kindOther:    "📎",
+	kindCode:     "📜",
+	kindImage:    "📷",
+	kindArchive:  "📦",
+	kindDocument: "📄",
+	kindAudio:    "🎵",
+	kindVideo:    "🎬",
+}
+
+// kindStyles color file names by kind. Other files keep the default color.
+var kindStyles = map[fileKind]lipgloss.Style{
+	kindCode:     lipgloss.NewStyle().Foreground(accentColor),
+	kindImage:    lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#8B008B", Dark: "#FF79C6"}),
+	kindArchive:  lipgloss.NewStyle().Foreground(highlightColor),
+	kindDocument: lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#1F4FBF", Dark: "#8BE9FD"}),
+	kindAudio:    lipgloss.NewStyle().Foreground(mutedColor),
+	kindVideo:    lipgloss.NewStyle().Foreground(mutedColor),
+}
+
+// kindByExtension covers what MIME types don't tell apart, such as source
+// code that is all text/plain.
+var kindByExtension = map[string]fileKind{
+	".go": kindCode, ".py": kindCode, ".js": kindCode, ".ts": kindCode, ".jsx": kindCode,
+	".tsx": kindCode, ".java": kindCode, ".c": kindCode, ".h": kindCode, ".cpp": kindCode,
+	".rs": kindCode, ".rb": kindCode, ".php": kindCode, ".sh": kindCode, ".ps1": kindCode,
+	".cs": kindCode, ".kt": kindCode, ".swift": kindCode, ".sql": kindCode, ".html": kindCode,
+	".css": kindCode, ".json": kindCode, ".yaml": kindCode, ".yml": kindCode, ".toml": kindCode,
+	".xml": kindCode,
+	".zip": kindArchive, ".tar": kindArchive, ".gz": kindArchive, ".tgz": kindArchive,
+	".bz2": kindArchive, ".xz": kindArchive, ".7z": kindArchive, ".rar": kindArchive, ".zst": kindArchive,
+	".pdf": kindDocument, ".doc": kindDocument, ".docx": kindDocument, ".odt": kindDocument,
+	".xls": kindDocument, ".xlsx": kindDocument, ".ods": kindDocument, ".ppt": kindDocument,
+	".pptx": kindDocument, ".odp": kindDocument, ".txt": kindDocument, ".md": kindDocument,
+	".rtf": kindDocument, ".csv": kindDocument,
+}
+
+// kindOf classifies a file by its extension where that tells more than the
+// content type does, and otherwise by the content type the server reports
+// or the extension implies.
+func kindOf(fileName, contentType string) fileKind {
+	if kind, ok := kindByExtension[strings.ToLower(filepath.Ext(fileName))]; ok {
+		return kind
+	}
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(fileName))
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		return kindImage
+	case strings.HasPrefix(mediaType, "audio/"):
+		return kindAudio
+	case strings.HasPrefix(mediaType, "video/"):
+		return kindVideo
+	case strings.HasPrefix(mediaType, "text/"):
+		return kindDocument
+	}
+	switch mediaType {
+	case "application/zip", "application/gzip", "application/x-gzip", "application/x-tar",
+		"application/x-7z-compressed", "application/vnd.rar", "application/x-rar-compressed":
+		return kindArchive
+	case "application/pdf":
+		return kindDocument
+	}
+	return kindOther
+}
+
+// detectContentType sniffs a local file's type from its first bytes, using
+// the extension when the content alone is inconclusive.
+func detectContentType(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	sniffed := http.DetectContentType(head[:n])
+	if sniffed == "application/octet-stream" || strings.HasPrefix(sniffed, "text/plain") {
+		if byExt := mime.TypeByExtension(filepath.Ext(path)); byExt != "" {
+			return byExt
+		}
+	}
+	return sniffed
+}
+
+// renderFileName renders a file list entry with its type's icon and, unless
+// it is selected, its color.
+func renderFileName(file FileInfo, name string, selected bool) string {
+	kind := kindOf(file.FileName, file.ContentType)
+	if style, ok := kindStyles[kind]; ok && !selected {
+		name = style.Render(name)
+	}
+	return kindIcons[kind] + " " + name
+}
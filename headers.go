@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// extraHeaders are the headers from the config file added to every request
+// to cshare's servers, loaded once at startup.
+var extraHeaders http.Header
+
+// initHeaders loads the configured headers. Values may refer to variables
+// from the environment or .env, e.g. "${CF_ACCESS_SECRET}", so secrets
+// don't have to live in cshare.json.
+func initHeaders() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	headers := make(http.Header)
+	for name, value := range cfg.Headers {
+		if !validHeaderName(name) {
+			return fmt.Errorf("invalid header name %q in %s", name, configPath)
+		}
+		value = os.ExpandEnv(value)
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("invalid value for header %s in %s", name, configPath)
+		}
+		headers.Set(name, value)
+	}
+	extraHeaders = headers
+	return nil
+}
+
+// validHeaderName reports whether name is a valid HTTP header field name.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r > 0x7e || r <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// ownServer reports whether u points at one of cshare's servers. Configured
+// headers often carry proxy credentials, so requests elsewhere, such as
+// fetching a URL for upload, don't get them.
+func ownServer(u *url.URL) bool {
+	for _, base := range []string{serverURL, loginServerURL} {
+		if b, err := url.Parse(base); err == nil && strings.EqualFold(b.Host, u.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyExtraHeaders adds the configured headers to a request for one of
+// cshare's servers. Headers cshare sets itself are set afterwards and win.
+func applyExtraHeaders(req *http.Request) {
+	if len(extraHeaders) == 0 || !ownServer(req.URL) {
+		return
+	}
+	for name, values := range extraHeaders {
+		req.Header[textproto.CanonicalMIMEHeaderKey(name)] = append([]string(nil), values...)
+	}
+}
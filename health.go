@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// slowLatency is the round trip above which the server counts as slow.
+const slowLatency = 500 * time.Millisecond
+
+// healthLevel grades a health check for the green/yellow/red indicator.
+type healthLevel int
+
+const (
+	healthUnknown healthLevel = iota
+	healthGood                // answered quickly
+	healthSlow                // answered, but slowly
+	healthDown                // no answer, or a server error
+)
+
+// healthCheck is the result of timing one request to a server's /ping.
+type healthCheck struct {
+	level   healthLevel
+	latency time.Duration
+	status  int   // HTTP status, 0 if there was no response
+	err     error // why there was no response
+}
+
+// healthMsg carries a background health check to the model.
+type healthMsg struct {
+	check healthCheck
+}
+
+// checkHealth times a request to base/ping. Any answer below 500 means the
+// server is up, even one refusing an unauthenticated ping; no answer at all
+// points at the network or this machine, a 5xx at the server itself.
+func checkHealth(base string) healthCheck {
+	call, err := newAPICall(opMetadata, "GET", base+"/ping", nil)
+	if err != nil {
+		return healthCheck{level: healthDown, err: err}
+	}
+	defer call.close()
+
+	start := time.Now()
+	resp, err := call.do()
+	if err != nil {
+		return healthCheck{level: healthDown, err: err}
+	}
+	resp.Body.Close()
+	h := healthCheck{latency: time.Since(start), status: resp.StatusCode}
+	switch {
+	case resp.StatusCode >= 500:
+		h.level = healthDown
+	case h.latency >= slowLatency:
+		h.level = healthSlow
+	default:
+		h.level = healthGood
+	}
+	return h
+}
+
+// probeHealth is the background check behind the status bar indicator.
+func probeHealth() tea.Msg {
+	return healthMsg{check: checkHealth(serverURL)}
+}
+
+// summary describes a check in a few words.
+func (h healthCheck) summary() string {
+	switch {
+	case h.level == healthUnknown:
+		return "checking"
+	case h.err != nil:
+		return "unreachable"
+	case h.status >= 500:
+		return fmt.Sprintf("server error %d", h.status)
+	case h.level == healthSlow:
+		return "slow " + formatLatency(h.latency)
+	default:
+		return formatLatency(h.latency)
+	}
+}
+
+// formatLatency renders a round trip in whole milliseconds.
+func formatLatency(d time.Duration) string {
+	return fmt.Sprintf("%d ms", d.Milliseconds())
+}
+
+// runPingCommand implements `cshare ping [-c <count>]`: it checks every
+// server cshare talks to and says whether a problem is likely on this side
+// or the server's.
+func runPingCommand(args []string) error {
+	count := 1
+	switch {
+	case len(args) == 0:
+	case len(args) == 2 && args[0] == "-c":
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid count %q", args[1])
+		}
+		count = n
+	default:
+		return fmt.Errorf("usage: cshare ping [-c <count>]")
+	}
+
+	failed := false
+	for _, base := range []string{serverURL, loginServerURL} {
+		host := base
+		if u, err := url.Parse(base); err == nil {
+			host = u.Host
+		}
+
+		var total, best, worst time.Duration
+		var last healthCheck
+		answered := 0
+		for i := 0; i < count; i++ {
+			if i > 0 {
+				time.Sleep(time.Second)
+			}
+			last = checkHealth(base)
+			if last.err != nil {
+				continue
+			}
+			answered++
+			total += last.latency
+			if answered == 1 || last.latency < best {
+				best = last.latency
+			}
+			worst = max(worst, last.latency)
+		}
+
+		switch {
+		case answered == 0:
+			failed = true
+			fmt.Printf("%-45s unreachable: %v\n", host, last.err)
+			fmt.Printf("%-45s likely a problem on this side: check your network, proxy and DNS settings\n", "")
+		case last.status >= 500:
+			failed = true
+			fmt.Printf("%-45s server error %d: the problem is on the server side\n", host, last.status)
+		case count == 1:
+			fmt.Printf("%-45s %s\n", host, last.summary())
+		default:
+			fmt.Printf("%-45s %d/%d answered, min/avg/max %s/%s/%s\n", host, answered, count,
+				formatLatency(best), formatLatency(total/time.Duration(answered)), formatLatency(worst))
+		}
+	}
+	if failed {
+		return fmt.Errorf("some servers are not healthy")
+	}
+	return nil
+}
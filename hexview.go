@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// hexViewBytes is how much of a file the hex viewer fetches.
+	hexViewBytes = 16 << 10
+	// hexPageLines is how many 16-byte lines the hex viewer shows at once.
+	hexPageLines = 16
+)
+
+// hexLoadedMsg carries the start of a file for the hex viewer.
+type hexLoadedMsg struct {
+	fileID int
+	data   []byte
+	err    error
+}
+
+// openHexView shows the start of the selected file as a hexdump.
+func openHexView(m *Model) tea.Cmd {
+	file, ok := selectedFile(*m)
+	if !ok {
+		return nil
+	}
+	m.hexFile = file
+	m.hexLines = nil
+	m.hexOffset = 0
+	m.hexErr = nil
+	m.goTo(stateHexView)
+	return func() tea.Msg {
+		data, err := fetchHead(file.ID, hexViewBytes)
+		return hexLoadedMsg{fileID: file.ID, data: data, err: err}
+	}
+}
+
+// handleHexLoaded formats the fetched bytes as offset, hex and ASCII
+// columns, one line per 16 bytes.
+func handleHexLoaded(m *Model, msg hexLoadedMsg) (tea.Model, tea.Cmd) {
+	if m.state != stateHexView || msg.fileID != m.hexFile.ID {
+		return m, nil
+	}
+	m.hexErr = msg.err
+	m.hexLines = strings.Split(strings.TrimSuffix(hex.Dump(msg.data), "\n"), "\n")
+	if len(msg.data) == 0 {
+		m.hexLines = nil
+	}
+	return m, nil
+}
+
+// handleHexInput pages through the hexdump.
+func handleHexInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	last := max(0, len(m.hexLines)-hexPageLines)
+	switch msg.String() {
+	case "up":
+		m.hexOffset = max(0, m.hexOffset-1)
+	case "down":
+		m.hexOffset = min(last, m.hexOffset+1)
+	case "pgup":
+		m.hexOffset = max(0, m.hexOffset-hexPageLines)
+	case "pgdown", " ":
+		m.hexOffset = min(last, m.hexOffset+hexPageLines)
+	case "home":
+		m.hexOffset = 0
+	case "end":
+		m.hexOffset = last
+	case "enter":
+		file := m.hexFile
+		m.goTo(stateViewFiles)
+		if m.dryRun {
+			return m, showPlan(m, "Dry run: download", planDownloads(m.siteName, []FileInfo{file}, m.onConflict))
+		}
+		return m, queueDownloads(m, m.siteName, []FileInfo{file})
+	case "esc":
+		m.goTo(stateViewFiles)
+	}
+	return m, nil
+}
+
+// renderHexView renders a page of the hexdump.
+func renderHexView(m Model) string {
+	switch {
+	case m.hexErr != nil:
+		return errorStyle.UnsetPadding().Render(m.hexErr.Error())
+	case m.hexLines == nil:
+		return mutedStyle.Render("Loading...")
+	}
+	end := min(m.hexOffset+hexPageLines, len(m.hexLines))
+	lines := append([]string{}, m.hexLines[m.hexOffset:end]...)
+	lines = append(lines, "", mutedStyle.Render(fmt.Sprintf("Lines %d-%d of %d (first %s of the file)",
+		m.hexOffset+1, end, len(m.hexLines), formatBytes(hexViewBytes))))
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The transfer history is the statistics log read back row by row: every
+// upload and download attempt with its site, file, size, duration and
+// result.
+
+// historyDateLayout is how --since and --until dates are written.
+const historyDateLayout = "2006-01-02"
+
+// visibleHistory is how many history rows the history screen shows at once.
+const visibleHistory = 15
+
+// historyRanges are the date filters the history screen cycles through with
+// D; zero means all time.
+var historyRanges = []struct {
+	label string
+	days  int
+}{
+	{"All time", 0},
+	{"Today", 1},
+	{"Last 7 days", 7},
+	{"Last 30 days", 30},
+}
+
+// historyFilter selects transfers by site and time. Zero values match
+// everything.
+type historyFilter struct {
+	site  string
+	since time.Time
+	until time.Time // exclusive
+}
+
+// matches reports whether a transfer passes the filter.
+func (f historyFilter) matches(stat TransferStat) bool {
+	if f.site != "" && stat.Site != f.site {
+		return false
+	}
+	if !f.since.IsZero() && stat.Time.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && !stat.Time.Before(f.until) {
+		return false
+	}
+	return true
+}
+
+// filterHistory returns the matching transfers, newest first.
+func filterHistory(stats []TransferStat, f historyFilter) []TransferStat {
+	var rows []TransferStat
+	for i := len(stats) - 1; i >= 0; i-- {
+		if f.matches(stats[i]) {
+			rows = append(rows, stats[i])
+		}
+	}
+	return rows
+}
+
+// formatHistoryRow renders one transfer as a line for `cshare history`.
+func formatHistoryRow(stat TransferStat) string {
+	return fmt.Sprintf("%s %s %-20s %-32s %10s %7.1fs %s",
+		stat.Time.Format("2006-01-02 15:04"), directionArrow(stat), stat.Site, stat.File,
+		formatBytes(stat.Bytes), stat.Seconds, transferResult(stat))
+}
+
+// formatHistoryLine is formatHistoryRow narrowed to fit the history screen.
+func formatHistoryLine(stat TransferStat) string {
+	return fmt.Sprintf("%s %s %s %s %9s %s",
+		stat.Time.Format("01-02 15:04"), directionArrow(stat), padName(stat.Site, 12), padName(stat.File, 18),
+		formatBytes(stat.Bytes), transferResult(stat))
+}
+
+// directionArrow marks uploads ↑ and downloads ↓.
+func directionArrow(stat TransferStat) string {
+	if stat.Direction == directionUpload {
+		return "↑"
+	}
+	return "↓"
+}
+
+// transferResult describes whether a transfer succeeded.
+func transferResult(stat TransferStat) string {
+	if stat.OK {
+		return "ok"
+	}
+	return "failed"
+}
+
+// runHistoryCommand implements
+// `cshare history [--site <site>] [--since <date>] [--until <date>]`.
+func runHistoryCommand(args []string) error {
+	const usage = "usage: cshare history [--site <site>] [--since YYYY-MM-DD] [--until YYYY-MM-DD]"
+	var f historyFilter
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			return fmt.Errorf("%s", usage)
+		}
+		value := args[i+1]
+		switch args[i] {
+		case "--site":
+			f.site = resolveSite(value)
+		case "--since", "--until":
+			day, err := time.ParseInLocation(historyDateLayout, value, time.Local)
+			if err != nil {
+				return fmt.Errorf("invalid date %q: use YYYY-MM-DD", value)
+			}
+			if args[i] == "--since" {
+				f.since = day
+			} else {
+				f.until = day.AddDate(0, 0, 1) // the whole day is included
+			}
+		default:
+			return fmt.Errorf("%s", usage)
+		}
+	}
+
+	stats, err := loadTransferStats()
+	if err != nil {
+		return err
+	}
+	rows := filterHistory(stats, f)
+	if len(rows) == 0 {
+		fmt.Println("No transfers found.")
+		return nil
+	}
+	for _, row := range rows {
+		line := formatHistoryRow(row)
+		if !row.OK && row.Error != "" {
+			line += ": " + row.Error
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// openHistory loads the history and shows the history screen.
+func openHistory(m *Model) {
+	stats, err := loadTransferStats()
+	if err != nil {
+		m.status = notice(err.Error())
+		return
+	}
+	m.historyAll = stats
+	m.historySite = ""
+	m.historyRange = 0
+	m.historyOffset = 0
+	m.goTo(stateHistory)
+}
+
+// historyRows applies the screen's site and date filters.
+func historyRows(m Model) []TransferStat {
+	f := historyFilter{site: m.historySite}
+	if days := historyRanges[m.historyRange].days; days > 0 {
+		y, mo, d := time.Now().Date()
+		f.since = time.Date(y, mo, d, 0, 0, 0, 0, time.Local).AddDate(0, 0, 1-days)
+	}
+	return filterHistory(m.historyAll, f)
+}
+
+// historySites lists the sites in the history, in first-seen order.
+func historySites(stats []TransferStat) []string {
+	seen := make(map[string]bool)
+	var sites []string
+	for _, stat := range stats {
+		if stat.Site != "" && !seen[stat.Site] {
+			seen[stat.Site] = true
+			sites = append(sites, stat.Site)
+		}
+	}
+	return sites
+}
+
+// handleHistoryInput handles input on the history screen.
+func handleHistoryInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up":
+		if m.historyOffset > 0 {
+			m.historyOffset--
+		}
+	case "down":
+		if m.historyOffset < len(historyRows(*m))-visibleHistory {
+			m.historyOffset++
+		}
+	case "s", "S":
+		// Cycle through the sites, then back to all sites.
+		sites := append([]string{""}, historySites(m.historyAll)...)
+		for i, site := range sites {
+			if site == m.historySite {
+				m.historySite = sites[(i+1)%len(sites)]
+				break
+			}
+		}
+		m.historyOffset = 0
+	case "d", "D":
+		m.historyRange = (m.historyRange + 1) % len(historyRanges)
+		m.historyOffset = 0
+	case "esc":
+		m.goTo(stateMenu)
+	}
+	return m, nil
+}
+
+// renderHistory renders the filters and the visible history rows.
+func renderHistory(m Model) string {
+	site := m.historySite
+	if site == "" {
+		site = "All sites"
+	}
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Site: %s • %s\n\n", highlightStyle.Render(site), highlightStyle.Render(historyRanges[m.historyRange].label)))
+
+	rows := historyRows(m)
+	if len(rows) == 0 {
+		b.WriteString("No transfers found.")
+		return b.String()
+	}
+	end := min(m.historyOffset+visibleHistory, len(rows))
+	for _, row := range rows[m.historyOffset:end] {
+		line := formatHistoryLine(row)
+		if !row.OK {
+			line = errorStyle.UnsetPadding().Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	if len(rows) > visibleHistory {
+		b.WriteString(fmt.Sprintf("\n%d-%d of %d", m.historyOffset+1, end, len(rows)))
+	}
+	return b.String()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// hookTimeout bounds how long a hook command may run.
+const hookTimeout = 30 * time.Second
+
+// Hook events.
+const (
+	hookPostDownload = "post_download"
+	hookPostUpload   = "post_upload"
+)
+
+// Hooks are shell commands run after transfers. They receive the file path,
+// site name and event in CSHARE_FILE, CSHARE_SITE and CSHARE_EVENT.
+type Hooks struct {
+	PostDownload string `json:"post_download,omitempty"`
+	PostUpload   string `json:"post_upload,omitempty"`
+}
+
+// runHook runs the command configured for event, if any. It returns a note
+// to append to the status line when the hook fails, or "" otherwise.
+func runHook(event, path, siteName string) string {
+	cfg, err := loadConfig()
+	if err != nil {
+		return ""
+	}
+	command := cfg.Hooks.PostDownload
+	if event == hookPostUpload {
+		command = cfg.Hooks.PostUpload
+	}
+	if command == "" {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(),
+		"CSHARE_FILE="+path,
+		"CSHARE_SITE="+siteName,
+		"CSHARE_EVENT="+event,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Sprintf(" (%s hook failed: %v %s)", event, err, lastLine(output))
+	}
+	return ""
+}
+
+// lastLine returns the last non-empty line of a command's output.
+func lastLine(output []byte) string {
+	end := len(output)
+	for end > 0 && (output[end-1] == '\n' || output[end-1] == '\r') {
+		end--
+	}
+	start := end
+	for start > 0 && output[start-1] != '\n' {
+		start--
+	}
+	return string(output[start:end])
+}
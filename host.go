@@ -0,0 +1,215 @@
+package main
+
+import (
+	"cmp"
+	"crypto/subtle"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/skip2/go-qrcode"
+)
+
+// hiddenPath reports whether a request path reaches into a dotfile or dot
+// folder, such as .env with its auth token, which `cshare host` never
+// serves.
+func hiddenPath(urlPath string) bool {
+	for _, element := range strings.Split(urlPath, "/") {
+		if strings.HasPrefix(element, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// hostFiles is the file system `cshare host` serves: the files under
+// root, leaving out dotfiles and links that lead outside root, both when
+// opened and from folder listings.
+type hostFiles struct {
+	root string // with its links resolved
+}
+
+func (h hostFiles) Open(name string) (http.File, error) {
+	if hiddenPath(name) || !h.contains(name) {
+		return nil, os.ErrNotExist
+	}
+	file, err := http.Dir(h.root).Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return hostFile{file, h, name}, nil
+}
+
+// contains reports whether the file at the request path name is inside
+// root once symbolic links are followed.
+func (h hostFiles) contains(name string) bool {
+	resolved, err := filepath.EvalSymlinks(filepath.Join(h.root, filepath.FromSlash(path.Clean("/"+name))))
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(h.root, resolved)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// hostFile is a file or folder of hostFiles.
+type hostFile struct {
+	http.File
+	files hostFiles
+	name  string
+}
+
+func (f hostFile) Readdir(n int) ([]fs.FileInfo, error) {
+	entries, err := f.File.Readdir(n)
+	visible := entries[:0]
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), ".") && f.files.contains(path.Join(f.name, entry.Name())) {
+			visible = append(visible, entry)
+		}
+	}
+	return visible, err
+}
+
+// hostHandler serves the files under dir, asking for password (with any
+// user name) when it isn't empty, and logs each request.
+func hostHandler(dir, password string) http.Handler {
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	files := http.FileServer(hostFiles{root: dir})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if password != "" {
+			_, given, _ := r.BasicAuth()
+			if subtle.ConstantTimeCompare([]byte(given), []byte(password)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="cshare", charset="UTF-8"`)
+				http.Error(w, "password required", http.StatusUnauthorized)
+				return
+			}
+		}
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		fmt.Printf("%s %s from %s\n", r.Method, r.URL.Path, host)
+		files.ServeHTTP(w, r)
+	})
+}
+
+// lanAddress returns this machine's address on the local network: its
+// first private IPv4 address, else any non-loopback one.
+func lanAddress() (string, bool) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", false
+	}
+	var fallback string
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.To4() == nil {
+			continue
+		}
+		if ipNet.IP.IsPrivate() {
+			return ipNet.IP.String(), true
+		}
+		if fallback == "" {
+			fallback = ipNet.IP.String()
+		}
+	}
+	return fallback, fallback != ""
+}
+
+// runHostCommand implements `cshare host [<folder>] [--password] [--port <n>]`:
+// it serves a folder, the downloads folder by default, over HTTP on the
+// local network until interrupted, so devices with only a browser can fetch
+// its files. It prints the URL and a QR code of it; the port is random
+// unless given. With --password, or CSHARE_HOST_PASSWORD set, browsers
+// have to give a password, taken from CSHARE_HOST_PASSWORD or asked for on
+// the terminal; it is never given on the command line, where other users
+// of the machine could see it.
+func runHostCommand(args []string) error {
+	const usage = "usage: cshare host [<folder>] [--password] [--port <n>]"
+	var dir string
+	askPassword := false
+	port := 0
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--password":
+			askPassword = true
+		case arg == "--port":
+			if i+1 == len(args) {
+				return fmt.Errorf("%s", usage)
+			}
+			i++
+			n, err := strconv.Atoi(args[i])
+			if err != nil || n < 1 || n > 65535 {
+				return fmt.Errorf("--port needs a port number from 1 to 65535")
+			}
+			port = n
+		case strings.HasPrefix(arg, "--") || dir != "":
+			return fmt.Errorf("%s", usage)
+		default:
+			dir = arg
+		}
+	}
+	dir = cmp.Or(dir, downloadRoot())
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s is not a folder", dir)
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	password, err := hostPassword(askPassword)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return fmt.Errorf("error serving %s: %v", dir, err)
+	}
+	host, ok := lanAddress()
+	if !ok {
+		host = "localhost"
+	}
+	address := "http://" + net.JoinHostPort(host, strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)) + "/"
+
+	fmt.Printf("Serving %s at %s\n", dir, address)
+	if password != "" {
+		fmt.Println("Browsers will ask for the password; any user name works.")
+	}
+	if !plainMode {
+		if qr, err := qrcode.New(address, qrcode.Low); err == nil {
+			fmt.Print(qr.ToSmallString(false))
+		}
+	}
+	fmt.Println("Press Ctrl+C to stop.")
+	return http.Serve(listener, hostHandler(dir, password))
+}
+
+// hostPassword returns the password `cshare host` asks browsers for:
+// CSHARE_HOST_PASSWORD when set, else, if ask is set, one typed on the
+// terminal without echoing it, else none.
+func hostPassword(ask bool) (string, error) {
+	if password := os.Getenv("CSHARE_HOST_PASSWORD"); password != "" {
+		return password, nil
+	}
+	if !ask {
+		return "", nil
+	}
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return "", fmt.Errorf("--password needs a terminal to ask on: set CSHARE_HOST_PASSWORD instead")
+	}
+	fmt.Print("Password for browsers: ")
+	password, err := term.ReadPassword(os.Stdin.Fd())
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("error reading password: %v", err)
+	}
+	if len(password) == 0 {
+		return "", fmt.Errorf("the password can't be empty")
+	}
+	return string(password), nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHostHandlerServesOnlyTheFolder(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	for name, body := range map[string]string{
+		filepath.Join(root, "photo.txt"):     "photo",
+		filepath.Join(root, ".env"):          "auth_token=abc",
+		filepath.Join(outside, "secret.txt"): "secret",
+	} {
+		if err := os.WriteFile(name, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "secret.txt")); err != nil {
+		t.Skipf("no symbolic links here: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "elsewhere")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("photo.txt", filepath.Join(root, "alias.txt")); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(hostHandler(root, ""))
+	defer server.Close()
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/photo.txt", http.StatusOK},
+		{"/alias.txt", http.StatusOK},
+		{"/.env", http.StatusNotFound},
+		{"/secret.txt", http.StatusNotFound},
+		{"/elsewhere/secret.txt", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(server.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.path, resp.StatusCode, tt.want)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var listing bytes.Buffer
+	if _, err := listing.ReadFrom(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	for _, hidden := range []string{".env", "secret.txt", "elsewhere"} {
+		if strings.Contains(listing.String(), hidden) {
+			t.Errorf("listing shows %s:\n%s", hidden, listing.String())
+		}
+	}
+	if !strings.Contains(listing.String(), "photo.txt") {
+		t.Errorf("listing misses photo.txt:\n%s", listing.String())
+	}
+}
+
+func TestHostHandlerPassword(t *testing.T) {
+	server := httptest.NewServer(hostHandler(t.TempDir(), "hunter2"))
+	defer server.Close()
+
+	for password, want := range map[string]int{"": http.StatusUnauthorized, "wrong": http.StatusUnauthorized, "hunter2": http.StatusOK} {
+		req, _ := http.NewRequest("GET", server.URL+"/", nil)
+		if password != "" {
+			req.SetBasicAuth("phone", password)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("password %q: status %d, want %d", password, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestHostPasswordFromEnvironment(t *testing.T) {
+	t.Setenv("CSHARE_HOST_PASSWORD", "hunter2")
+	if got, err := hostPassword(true); err != nil || got != "hunter2" {
+		t.Errorf("hostPassword() = %q, %v", got, err)
+	}
+	t.Setenv("CSHARE_HOST_PASSWORD", "")
+	if got, err := hostPassword(false); err != nil || got != "" {
+		t.Errorf("hostPassword() without --password = %q, %v", got, err)
+	}
+}
+
+func TestDownloadRootFollowsConfig(t *testing.T) {
+	old := settings.Load()
+	t.Cleanup(func() { settings.Store(old) })
+	settings.Store(&Config{})
+	t.Setenv("CSHARE_DOWNLOAD_PATH", "")
+	t.Setenv("CSHARE_DOWNLOAD_DIR", "from-config")
+	if got := downloadRoot(); got != "from-config" {
+		t.Errorf("downloadRoot() = %q, want from-config", got)
+	}
+	settings.Store(&Config{DownloadPath: "{site}/{filename}"})
+	if got := downloadRoot(); got != "." {
+		t.Errorf("downloadRoot() = %q, want .", got)
+	}
+}
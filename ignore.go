@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFile lists patterns of files a folder upload leaves out. It works
+// like .gitignore: one pattern per line, # for comments, ! to re-include, a
+// trailing / for directories only and a / elsewhere to anchor the pattern to
+// the folder holding the file. Every folder can have its own.
+const ignoreFile = ".cshareignore"
+
+// ignoreRule is one pattern of an ignore file or --exclude flag.
+type ignoreRule struct {
+	source   string // the ignore file or flag the rule comes from
+	base     string // slash-separated folder the pattern is relative to
+	line     string // the pattern as written
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// parseIgnoreRule parses a pattern line, returning false for blank lines
+// and comments.
+func parseIgnoreRule(source, base, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{source: source, base: base, line: line}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	line = strings.TrimPrefix(line, `\`)
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	rule.pattern = line
+	return rule, true
+}
+
+// matches reports whether the rule applies to rel, a slash-separated path
+// relative to the upload root.
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		var ok bool
+		if rel, ok = strings.CutPrefix(rel, r.base+"/"); !ok {
+			return false
+		}
+	}
+	if !r.anchored {
+		return matchGlob(r.pattern, path.Base(rel))
+	}
+	return matchGlob(r.pattern, rel)
+}
+
+// matchGlob matches a slash-separated path against a pattern in which **
+// stands for any number of folders.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := len(name); i >= 0; i-- {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// ignoreList is the rules in effect while walking a folder, in order; the
+// last rule that matches a path decides.
+type ignoreList []ignoreRule
+
+// excludeRules turns --exclude patterns into rules for every folder.
+func excludeRules(patterns []string) ignoreList {
+	var rules ignoreList
+	for _, pattern := range patterns {
+		if rule, ok := parseIgnoreRule("--exclude", "", pattern); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// load adds the rules of dir's ignore file, if it has one. rel is dir
+// relative to the upload root.
+func (l ignoreList) load(dir, rel string) (ignoreList, error) {
+	source := filepath.Join(dir, ignoreFile)
+	f, err := os.Open(source)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return l, fmt.Errorf("error reading %s: %v", source, err)
+	}
+	defer f.Close()
+
+	if rel == "." {
+		rel = ""
+	}
+	rules := append(ignoreList(nil), l...)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(source, rel, scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return l, fmt.Errorf("error reading %s: %v", source, err)
+	}
+	return rules, nil
+}
+
+// ignored reports whether rel, relative to the upload root, is left out,
+// and if so the rule that excludes it.
+func (l ignoreList) ignored(rel string, isDir bool) (ignoreRule, bool) {
+	var decided ignoreRule
+	ignored := false
+	for _, rule := range l {
+		if rule.matches(rel, isDir) {
+			decided, ignored = rule, !rule.negate
+		}
+	}
+	return decided, ignored
+}
+
+// String describes the rule for a dry run, e.g. `"*.log" in .cshareignore`.
+func (r ignoreRule) String() string {
+	if r.source == "--exclude" {
+		return fmt.Sprintf("--exclude %q", r.line)
+	}
+	return fmt.Sprintf("%q in %s", r.line, r.source)
+}
+
+// excludeArgs takes --exclude flags out of args. Each takes one pattern and
+// the flag can be repeated.
+func excludeArgs(args []string) ([]string, []string, error) {
+	var rest, patterns []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		value, ok := strings.CutPrefix(arg, "--exclude=")
+		if !ok && arg == "--exclude" {
+			if i+1 == len(args) {
+				return nil, nil, fmt.Errorf("--exclude needs a pattern, e.g. --exclude node_modules/")
+			}
+			i++
+			value, ok = args[i], true
+		}
+		if !ok {
+			rest = append(rest, arg)
+			continue
+		}
+		patterns = append(patterns, value)
+	}
+	return rest, patterns, nil
+}
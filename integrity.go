@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultVerifyRetries is how many times a mismatched upload is resent.
+const defaultVerifyRetries = 0
+
+// uploadedMsg reports a finished upload and the result of its integrity
+// check.
+type uploadedMsg struct {
+	files    []FileInfo
+	fileID   int
+	verified bool
+	status   statusLine
+}
+
+// verifyRetries reads CSHARE_VERIFY_RETRIES, the number of times an upload
+// whose server checksum doesn't match is retried automatically.
+func verifyRetries() int {
+	n, err := strconv.Atoi(os.Getenv("CSHARE_VERIFY_RETRIES"))
+	if err != nil || n < 0 {
+		return defaultVerifyRetries
+	}
+	return n
+}
+
+// findUploadedFile looks up the ID of a just-uploaded file by name. The last
+// match wins, since the server lists files in upload order.
+func findUploadedFile(files []FileInfo, name string) (int, bool) {
+	for i := len(files) - 1; i >= 0; i-- {
+		if files[i].FileName == name {
+			return files[i].ID, true
+		}
+	}
+	return 0, false
+}
+
+// fetchRemoteChecksum asks the server for the SHA-256 of a stored file.
+func fetchRemoteChecksum(fileID int) (string, error) {
+	authToken, err := loadAuthToken()
+	if err != nil {
+		return "", err
+	}
+
+	call, err := newAPICall(opMetadata, "GET", fmt.Sprintf("%s/checksum/%d", serverURL, fileID), nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+	call.req.Header.Set("Authorization", authToken.reveal())
+
+	resp, err := call.do()
+	if err != nil {
+		return "", fmt.Errorf("error connecting to server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", call.fail(resp, "failed to fetch checksum")
+	}
+
+	var result struct {
+		SHA256 string `json:"sha256"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("error parsing response: %v", err)
+	}
+	return strings.ToLower(result.SHA256), nil
+}
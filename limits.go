@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// maxListedProblems caps how many refused files a batch error names.
+const maxListedProblems = 3
+
+// uploadLimits are the server's rules for uploaded files, from the
+// "limits" of its /capabilities descriptor, e.g.
+//
+//	{"max_file_size": 2147483648, "blocked_extensions": [".exe"], "empty_files": false}
+//
+// Anything left out isn't limited; servers without a descriptor have no
+// limits here and refuse what they refuse themselves.
+type uploadLimits struct {
+	MaxFileSize       int64    `json:"max_file_size"`
+	BlockedExtensions []string `json:"blocked_extensions"`
+	EmptyFiles        *bool    `json:"empty_files"`
+}
+
+// serverLimits returns the limits of the last descriptor fetched.
+func serverLimits() uploadLimits {
+	if caps := serverCaps.Load(); caps != nil {
+		return caps.limits
+	}
+	return uploadLimits{}
+}
+
+// blocked reports whether the server refuses files named name by their
+// extension. Extensions are compared without case, with or without the
+// leading dot.
+func (l uploadLimits) blocked(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext != "" && slices.ContainsFunc(l.BlockedExtensions, func(b string) bool {
+		return "."+strings.TrimPrefix(strings.ToLower(b), ".") == ext
+	})
+}
+
+// uploadProblem says why the server would refuse a file of size bytes
+// uploaded as name, or returns "" if it wouldn't.
+func uploadProblem(name string, size int64) string {
+	limits := serverLimits()
+	switch {
+	case limits.blocked(name):
+		return "the server doesn't accept " + strings.ToLower(filepath.Ext(name)) + " files"
+	case size == 0 && limits.EmptyFiles != nil && !*limits.EmptyFiles:
+		return "it is empty, and the server doesn't accept empty files"
+	case limits.MaxFileSize > 0 && size > limits.MaxFileSize:
+		return fmt.Sprintf("it is %s, over the server's limit of %s", formatBytes(size), formatBytes(limits.MaxFileSize))
+	}
+	return ""
+}
+
+// checkUpload fails a file the server would refuse before any of it is
+// sent.
+func checkUpload(name string, size int64) error {
+	if problem := uploadProblem(name, size); problem != "" {
+		return fmt.Errorf("%s can't be uploaded: %s", name, problem)
+	}
+	return nil
+}
+
+// checkUploads checks every file of a batch before it starts, so a file
+// the server would refuse stops the batch up front rather than partway
+// through. names are the names the files are uploaded under.
+func checkUploads(paths []string, names map[string]string) error {
+	var problems []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", path, err)
+		}
+		if problem := uploadProblem(names[path], info.Size()); problem != "" {
+			problems = append(problems, names[path]+" ("+problem+")")
+		}
+	}
+	count := len(problems)
+	switch {
+	case count == 0:
+		return nil
+	case count > maxListedProblems:
+		problems = append(problems[:maxListedProblems], fmt.Sprintf("and %d more", count-maxListedProblems))
+	}
+	return fmt.Errorf("%d file(s) can't be uploaded, so nothing was: %s. Remove them or leave them out with --exclude",
+		count, strings.Join(problems, ", "))
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// listingCacheName is the file in a site's cache folder that keeps its
+// last listing.
+const listingCacheName = "listing.json"
+
+// cachedListing is the last listing of a site, for browsing it offline
+// and for conditional refreshes. Nothing derived from the password is
+// stored, since anyone able to read the cache could try passwords against
+// it; see onlineLogins.
+type cachedListing struct {
+	SavedAt      time.Time  `json:"saved_at"`
+	ExpiresAt    time.Time  `json:"expires_at,omitempty"`
+	ETag         string     `json:"etag,omitempty"`
+	LastModified string     `json:"last_modified,omitempty"`
+	Files        []FileInfo `json:"files"`
+}
+
+// listingCachePath is where a site's last listing is kept. The site name is
+// made a single path element, so it can't reach outside cacheDir.
+func listingCachePath(siteName string) string {
+	return filepath.Join(cacheDir, pathElement(siteName), listingCacheName)
+}
+
+// onlineLogins holds, for each site opened from the server since cshare
+// started, the password that opened it. Offline copies open only for
+// these sites and with that password, which is kept in memory only.
+var onlineLogins sync.Map // site name -> secret
+
+// newCachedListing prepares files for the cache.
+func newCachedListing(files []FileInfo, expiresAt time.Time) cachedListing {
+	return cachedListing{SavedAt: time.Now(), ExpiresAt: expiresAt, Files: files}
+}
+
+// readListing reads a site's cached listing.
+func readListing(siteName string) (cachedListing, error) {
+	var c cachedListing
+	data, err := os.ReadFile(listingCachePath(siteName))
+	if err != nil {
+		return c, fmt.Errorf("no offline copy of %s", siteName)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("error reading the offline copy of %s: %v", siteName, err)
+	}
+	return c, nil
+}
+
+// writeListing replaces a site's cached listing. The file is swapped in
+// whole, since refreshes in the background and the UI both write it.
+// Caching is best effort and never interrupts the UI.
+func writeListing(siteName string, c cachedListing) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return
+	}
+	path := listingCachePath(siteName)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".listing-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// saveListing keeps a freshly loaded listing and unlocks its offline copy
+// for password. The validators of the previous copy are kept: the files
+// are at least as new as the version they name, so a 304 for them still
+// means these files are current.
+func saveListing(siteName string, password secret, files []FileInfo, expiresAt time.Time) tea.Cmd {
+	onlineLogins.Store(siteName, password)
+	files = slices.Clone(files)
+	return diskWrites.write(listingCachePath(siteName), func() {
+		c := newCachedListing(files, expiresAt)
+		if old, err := readListing(siteName); err == nil {
+			c.ETag, c.LastModified = old.ETag, old.LastModified
+		}
+		writeListing(siteName, c)
+	})
+}
+
+// offlineListing opens a site from its cached listing when the server
+// can't be reached, provided the site was opened from the server earlier
+// in this run with the same password.
+func offlineListing(siteName string, password secret) (siteLoadedMsg, error) {
+	known, ok := onlineLogins.Load(siteName)
+	if !ok {
+		return siteLoadedMsg{}, fmt.Errorf("the offline copy of %s opens only after the site was opened online since cshare started", siteName)
+	}
+	if subtle.ConstantTimeCompare([]byte(known.(secret).reveal()), []byte(password.reveal())) != 1 {
+		return siteLoadedMsg{}, fmt.Errorf("wrong password for the offline copy of %s", siteName)
+	}
+	c, err := readListing(siteName)
+	if err != nil {
+		return siteLoadedMsg{}, err
+	}
+	return siteLoadedMsg{files: c.Files, expiresAt: c.ExpiresAt, staleSince: c.SavedAt}, nil
+}
+
+// reloadStale reloads a site shown from its offline copy once the server
+// answers again. It waits for the file list so the reload doesn't pull the
+// user off another screen.
+func reloadStale(m *Model) tea.Cmd {
+	if m.listStale.IsZero() || serverUnreachable(m) || m.state != stateViewFiles {
+		return nil
+	}
+	m.status = notice("Server reachable again: reloading " + m.siteName + "...")
+	return api.openSite(m.siteName, m.password, "")
+}
+
+// listingRefreshedMsg carries a refreshed listing.
+type listingRefreshedMsg struct {
+	site      string
+	files     []FileInfo
+	unchanged bool // the server answered 304 Not Modified
+}
+
+// refreshListing re-fetches the open site's listing. Thanks to the cached
+// validators an unchanged site costs a single empty response.
+func refreshListing(m *Model) tea.Cmd {
+	if !m.listStale.IsZero() {
+		return reloadStale(m)
+	}
+	siteName, password := m.siteName, m.password
+	m.status = notice("Refreshing...")
+	return func() tea.Msg {
+		files, unchanged, err := refreshFiles(siteName, password)
+		if err != nil {
+			return err
+		}
+		return listingRefreshedMsg{site: siteName, files: files, unchanged: unchanged}
+	}
+}
+
+// handleListingRefreshed shows a refreshed listing.
+func handleListingRefreshed(m *Model, msg listingRefreshedMsg) (tea.Model, tea.Cmd) {
+	if msg.site != m.siteName {
+		return m, nil
+	}
+	if msg.unchanged {
+		m.status = success("Up to date")
+		return m, nil
+	}
+	m.files = msg.files
+	keepSelectionVisible(m)
+	m.status = success(fmt.Sprintf("Refreshed, %d file(s)", len(m.files)))
+	return m, nil
+}
+
+// renderStale marks a listing shown from the offline copy.
+func renderStale(since time.Time) string {
+	if since.IsZero() {
+		return ""
+	}
+	return " " + highlightStyle.Render("⚠ offline copy from "+since.Local().Format("Jan 2 15:04"))
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOfflineListingNeedsOnlineLogin(t *testing.T) {
+	inTempDir(t)
+	t.Cleanup(func() { onlineLogins.Delete("notes") })
+	files := []FileInfo{{ID: 1, FileName: "a.txt"}}
+	writeListing("notes", newCachedListing(files, time.Time{}))
+
+	// A copy left by an earlier run doesn't open: nothing on disk can
+	// check the password.
+	if _, err := offlineListing("notes", "hunter2"); err == nil {
+		t.Fatal("the offline copy opened without an online login")
+	}
+
+	run(t, saveListing("notes", "hunter2", files, time.Time{}))
+	data, err := os.ReadFile(listingCachePath("notes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"hunter2", `"salt"`, `"check"`} {
+		if strings.Contains(string(data), field) {
+			t.Errorf("the cache holds %s: %s", field, data)
+		}
+	}
+	if _, err := offlineListing("notes", "wrong"); err == nil {
+		t.Error("the offline copy opened with the wrong password")
+	}
+	msg, err := offlineListing("notes", "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.files) != 1 || msg.staleSince.IsZero() {
+		t.Errorf("offline listing = %+v", msg)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// listingBatchSize is how many entries are handed to the UI at once.
+	listingBatchSize = 500
+	// listingFlushInterval bounds how long received entries wait before
+	// being shown, so slow streams still render progressively.
+	listingFlushInterval = 100 * time.Millisecond
+)
+
+// listingHeader is the first line of an NDJSON listing. The remaining lines
+// are one file each, in the response's API version.
+type listingHeader struct {
+	AuthToken string    `json:"auth_token"`
+	Total     int       `json:"total"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// listingStartMsg opens a site whose listing is still streaming in.
+type listingStartMsg struct {
+	stream    <-chan listingMsg
+	stop      func()
+	total     int
+	expiresAt time.Time
+}
+
+// listingMsg carries the next batch of a streamed listing.
+type listingMsg struct {
+	stream <-chan listingMsg
+	files  []FileInfo
+	loaded int
+	done   bool
+	err    error
+}
+
+// isNDJSON reports whether the server chose to stream the listing.
+func isNDJSON(resp *http.Response) bool {
+	return strings.HasPrefix(resp.Header.Get("Content-Type"), "application/x-ndjson")
+}
+
+// streamListing reads the listing header, stores the auth token and hands
+// the rest of the body to a goroutine that batches entries onto a channel.
+// It owns call and resp from here on.
+func streamListing(call *apiCall, resp *http.Response) tea.Msg {
+	call.stream()
+	scanner := bufio.NewScanner(call.watch(resp.Body))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	version := responseAPIVersion(resp)
+	var header listingHeader
+	if !scanner.Scan() {
+		resp.Body.Close()
+		call.close()
+		return fmt.Errorf("error reading server response: %v", scanner.Err())
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		resp.Body.Close()
+		call.close()
+		return fmt.Errorf("error parsing server response: %v", err)
+	}
+	if err := storeAuthToken(header.AuthToken); err != nil {
+		resp.Body.Close()
+		call.close()
+		return err
+	}
+
+	stream := make(chan listingMsg)
+	send := func(msg listingMsg) bool {
+		select {
+		case stream <- msg:
+			return true
+		case <-call.ctx.Done():
+			return false
+		}
+	}
+	go func() {
+		defer recoverCrash()
+		defer call.close()
+		defer resp.Body.Close()
+		defer close(stream)
+
+		var batch []FileInfo
+		loaded := 0
+		lastFlush := time.Now()
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			if len(line) == 0 {
+				continue
+			}
+			file, err := decodeFile(version, line)
+			if err != nil {
+				send(listingMsg{files: batch, loaded: loaded, err: fmt.Errorf("error parsing server response: %v", err)})
+				return
+			}
+			batch = append(batch, file)
+			loaded++
+			if len(batch) >= listingBatchSize || time.Since(lastFlush) >= listingFlushInterval {
+				if !send(listingMsg{files: batch, loaded: loaded}) {
+					return
+				}
+				batch = nil
+				lastFlush = time.Now()
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			send(listingMsg{files: batch, loaded: loaded, err: fmt.Errorf("error reading server response: %v", err)})
+			return
+		}
+		send(listingMsg{files: batch, loaded: loaded, done: true})
+	}()
+
+	stop := func() { call.cancel(nil) }
+	return listingStartMsg{stream: stream, stop: stop, total: header.Total, expiresAt: header.ExpiresAt}
+}
+
+// waitListing waits for the next batch of a streamed listing.
+func waitListing(stream <-chan listingMsg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-stream
+		if !ok {
+			return nil
+		}
+		msg.stream = stream
+		return msg
+	}
+}
+
+// handleListing appends a batch to the file list and waits for the next
+// one. Batches from a listing the user has since left are dropped.
+func handleListing(m *Model, msg listingMsg) (tea.Model, tea.Cmd) {
+	if msg.stream != m.listStream {
+		return m, nil
+	}
+	m.files = append(m.files, msg.files...)
+	m.listLoaded = msg.loaded
+	if msg.err != nil {
+		stopListing(m)
+		m.status = notice(msg.err.Error())
+		return m, nil
+	}
+	if msg.done {
+		stopListing(m)
+		return m, tea.Batch(applyRestore(m), flushOffline(m), saveListing(m.siteName, m.password, m.files, m.expiresAt))
+	}
+	return m, waitListing(msg.stream)
+}
+
+// stopListing abandons a listing that is still streaming, if any.
+func stopListing(m *Model) {
+	if m.listStop != nil {
+		m.listStop()
+	}
+	m.listStream = nil
+	m.listStop = nil
+}
+
+// renderListingProgress renders "loaded 3,000 of ~12,000", leaving out the
+// total when the server didn't estimate one.
+func renderListingProgress(loaded, total int) string {
+	if total <= 0 {
+		return "loaded " + formatCount(loaded)
+	}
+	return fmt.Sprintf("loaded %s of ~%s", formatCount(loaded), formatCount(total))
+}
+
+// formatCount renders n with thousands separators.
+func formatCount(n int) string {
+	digits := strconv.Itoa(n)
+	var out strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			out.WriteByte(',')
+		}
+		out.WriteRune(d)
+	}
+	return out.String()
+}
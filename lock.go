@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// lockCheckInterval is how often the client checks whether the idle lock
+// is due.
+const lockCheckInterval = 15 * time.Second
+
+// lockTickMsg fires every lockCheckInterval.
+type lockTickMsg struct{}
+
+// lockAfter reads CSHARE_LOCK_AFTER, how long without input locks the
+// session, such as "15m". Unset or "0" never locks on its own.
+func lockAfter() time.Duration {
+	return timeoutSetting("CSHARE_LOCK_AFTER", 0)
+}
+
+// lockTick schedules the next idle lock check.
+func lockTick() tea.Cmd {
+	return tea.Tick(lockCheckInterval, func(time.Time) tea.Msg {
+		return lockTickMsg{}
+	})
+}
+
+// handleLockTick locks the session once the user has been idle for
+// lockAfter. Transfers still running count as activity, since the queued
+// ones need the credentials the lock would clear.
+func handleLockTick(m *Model) (tea.Model, tea.Cmd) {
+	after := lockAfter()
+	if after <= 0 || !unlocked(m) || time.Since(m.lastInput) < after {
+		return m, lockTick()
+	}
+	if m.transfers > 0 || m.downloads.running || m.batchStream != nil {
+		return m, lockTick()
+	}
+	lockSession(m)
+	m.status = notice(fmt.Sprintf("Session locked after %s without input", after))
+	return m, lockTick()
+}
+
+// unlocked reports whether there is anything for a lock to clear: an open
+// or half-opened site, or a token kept in memory.
+func unlocked(m *Model) bool {
+	return m.siteName != "" || m.password != "" || m.authToken != "" ||
+		os.Getenv("auth_token") != "" || os.Getenv("account_token") != ""
+}
+
+// Set by lockSession, these keep the tokens saved in .env out of use
+// until the next site login or account sign-in, as loadAuthToken would
+// otherwise read them straight back.
+var siteLocked, accountLocked atomic.Bool
+
+// lockSession forgets the credentials held in memory (the site password,
+// two-factor code, the site and account tokens and the account's sites)
+// and returns to the menu, so the next person at the terminal has to log
+// in again. Tokens saved in .env are left alone but not used until then;
+// transfers already sent keep going.
+func lockSession(m *Model) {
+	leaveSite(m)
+	m.account = ""
+	m.mySites = nil
+	m.siteIdx = 0
+	siteLocked.Store(true)
+	accountLocked.Store(true)
+	os.Unsetenv("auth_token")
+	os.Unsetenv("account_token")
+}
+
+// accountToken returns the signed-in account's token, or "" when there is
+// none or the session is locked.
+func accountToken() string {
+	if accountLocked.Load() {
+		return ""
+	}
+	return os.Getenv("account_token")
+}
+
+// leaveSite closes the open site and returns to the menu, dropping the
+// password, codes and files it was opened with.
+func leaveSite(m *Model) {
+	m.confirm = nil
+	m.goTo(stateMenu)
+	m.siteName = ""
+	m.password = ""
+	m.confirmPassword = ""
+	m.totpCode = ""
+	m.authToken = ""
+	m.showPassword = false
+	m.files = nil
+	m.details = nil
+	m.verified = nil
+	m.signatures = nil
+	m.pendingUploads = nil
+}
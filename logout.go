@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// errRevokeUnsupported means the server has no way to revoke tokens, so a
+// logged out token stays valid until it expires.
+var errRevokeUnsupported = errors.New("the server can't revoke tokens; the old one stays valid until it expires")
+
+// loggedOutMsg reports a logout. The token is gone locally either way;
+// revokeErr says why the server may still accept it.
+type loggedOutMsg struct {
+	siteName  string
+	revokeErr error
+}
+
+// logOut revokes the stored site token on the server and deletes it from
+// .env and the environment.
+func logOut(siteName string) tea.Cmd {
+	return func() tea.Msg {
+		revokeErr := revokeStoredToken()
+		if revokeErr != nil && !errors.Is(revokeErr, errRevokeUnsupported) {
+			revokeErr = fmt.Errorf("the server couldn't revoke the token: %w", revokeErr)
+		}
+		if err := saveEnvValue("auth_token", ""); err != nil {
+			return fmt.Errorf("error logging out: %v", err)
+		}
+		return loggedOutMsg{siteName: siteName, revokeErr: revokeErr}
+	}
+}
+
+// revokeStoredToken asks the login server to invalidate the stored site
+// token. Having no token is not an error.
+func revokeStoredToken() error {
+	authToken, err := loadAuthToken()
+	if err != nil {
+		return nil
+	}
+
+	call, err := newAPICall(opMetadata, "POST", loginServerURL+"/logout", nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+	call.req.Header.Set("Authorization", authToken.reveal())
+
+	resp, err := call.do()
+	if err != nil {
+		return fmt.Errorf("error connecting to server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusUnauthorized:
+		// Unauthorized means the token was already invalid.
+		return nil
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return errRevokeUnsupported
+	}
+	return call.fail(resp, "")
+}
+
+// handleLoggedOut closes the site once its token is gone.
+func handleLoggedOut(m *Model, msg loggedOutMsg) (tea.Model, tea.Cmd) {
+	leaveSite(m)
+	if msg.revokeErr != nil {
+		m.status = notice(fmt.Sprintf("Logged out of %s, but %v", msg.siteName, msg.revokeErr))
+	} else {
+		m.status = success(fmt.Sprintf("Logged out of %s", msg.siteName))
+	}
+	return m, nil
+}
+
+// runLogoutCommand implements `cshare logout`.
+func runLogoutCommand(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: cshare logout")
+	}
+	msg := logOut("")()
+	if err, ok := msg.(error); ok {
+		return err
+	}
+	if err := msg.(loggedOutMsg).revokeErr; err != nil {
+		fmt.Printf("Logged out, but %v\n", err)
+		return nil
+	}
+	fmt.Println("Logged out")
+	return nil
+}
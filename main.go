@@ -2,111 +2,350 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/joho/godotenv"
-	"github.com/sqweek/dialog"
 )
 
 // Model represents the application's state.
 type Model struct {
-	cursor      int
-	selectedIdx int
-	siteName    string
-	password    string
-	files       []FileInfo
-	state       string
-	errorMsg    string
-	authToken   string
-	uploadPath  string
+	cursor       int
+	selectedIdx  int
+	siteName     string
+	password     secret
+	files        []FileInfo
+	state        viewState
+	status       statusLine
+	authToken    secret
+	uploadPath   string
 	fileToUpload string
+	members      []Member
+	memberIdx    int
+	inviteUser   string
+	inviteRole   string
+	totpCode     secret
+	enableTOTP   bool
+	otpauthURL   string
+
+	confirmPassword secret
+	showPassword    bool
+	verified        map[int]bool
+	signatures      map[int]signatureCheck // downloads checked against their signatures
+	account         string
+	provider        string
+	device          deviceAuth
+	shareSlug       string
+	shareURL        string
+	mySites         []SiteInfo
+	siteIdx         int
+	quickQuery      string
+	quickIdx        int
+	quickMatches    []quickMatch
+	quickReturn     viewState
+	settingsIdx     int
+	pathTemplate    string // download path template being edited
+	tagFile         FileInfo
+	tagInput        string
+	commentFile     FileInfo
+	comments        []Comment
+	commentOffset   int
+	commentInput    string
+	uploadURL       string
+	hexFile         FileInfo
+	hexLines        []string
+	hexOffset       int
+	hexErr          error
+	archiveFile     FileInfo
+	archiveEntries  []archiveEntry
+	archiveIdx      int
+	archiveErr      error
+	clipImage       []byte // PNG found on the clipboard when the upload screen opened
+	chatSite        string // site the chat history belongs to
+	chat            []chatMessage
+	chatInput       string
+	chatUnread      int
+	chatPolling     bool
+	chatErr         error
+	online          []string // users viewing the open site, from the chat poll
+	deleteConfirm   string
+	ttlIdx          int
+	expiresAt       time.Time
+	lastInput       time.Time
+	listStream      <-chan listingMsg
+	listStop        func()
+	lastDownload    string
+	folderToUpload  string
+	batchStream     <-chan uploadProgressMsg
+	batchStop       func()
+	batchTotal      int
+	batchHashed     int
+	batchUploaded   int
+	batchSent       []string // files of the batch uploaded so far, kept to resume it
+	batchActive     []string // files of the batch being uploaded
+	batchFailed     []string
+	pendingUploads  []string
+	canDiagnose     bool
+	diagResults     []diagResult
+	diagRunning     bool
+	listLoaded      int
+	listTotal       int
+	fileOffset      int
+	listTop         int
+	listRows        int
+	lastClick       clickTarget
+	confirm         *confirmation
+	transfers       int
+	downloads       downloadQueue
+	historyAll      []TransferStat
+	historySite     string
+	historyRange    int
+	historyOffset   int
+	onConflict      conflictPolicy
+	excludes        []string // --exclude patterns for folder uploads
+	filter          fileFilter
+	filterIdx       int
+	filterExt       string
+	groupByType     bool
+	collapsed       map[fileKind]bool // collapsed sections of the grouped view
+	showDetails     bool
+	details         map[int]*fileDetails // by file ID, loaded as the pane shows them
+	dryRun          bool                 // show what batch operations would do instead
+	planTitle       string
+	planLines       []string
+	planOffset      int
+	localDir        string // folder of the two-pane view's local side
+	localEntries    []localEntry
+	localIdx        int
+	remoteIdx       int
+	pane            int // paneLocal or paneRemote
+	savedSession    savedSession
+	health          healthCheck
+	speed           float64
+	lastBytes       int64
+	rates           map[int]*transferRate // per-transfer speed samples, by liveTransfer id
+	transferIdx     int
+	transfersReturn viewState // the screen Ctrl+B was pressed on
+	sessionReturn   viewState // the screen Ctrl+W was pressed on
+	picking         bool      // the upload screen shows the file picker
+	pickFolder      bool      // the file picker picks a folder
+	uploadAs        string    // the name fileToUpload is saved as; "" keeps its own
+	naming          bool      // the upload screen shows the save-as prompt
+	nameInput       string
+	offline         []offlineItem
+	savedOffline    []offlineItem // the offline queue as last saved
+	listStale       time.Time     // when the offline copy shown was saved; zero for a live listing
+	offlineFlushing bool
+	statusTicks     int
+	lastError       *apiError
+	restore         *savedSession
 }
 
 type FileInfo struct {
-	ID       int    `json:"id"`
-	FileName string `json:"file_name"`
+	ID          int               `json:"id"`
+	FileName    string            `json:"file_name"`
+	ContentType string            `json:"content_type,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Folder      string            `json:"folder,omitempty"`
+	Size        int64             `json:"size,omitempty"`
+	ModifiedAt  time.Time         `json:"modified_at,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	ScanStatus  string            `json:"scan_status,omitempty"` // pending, clean or flagged
 }
 
 // Update the style definitions
 var (
 	appStyle = lipgloss.NewStyle().
-		Padding(1, 2).
-		Border(lipgloss.NormalBorder()).
-		BorderForeground(lipgloss.Color("#3C3C3C")).
-		Width(80)
+			Padding(1, 2).
+			Border(lipgloss.NormalBorder()).
+			BorderForeground(borderColor).
+			Width(80)
 
 	headerStyle = lipgloss.NewStyle().
-		Bold(true).
-		Foreground(lipgloss.Color("#00FF00")).
-		Background(lipgloss.Color("#1A1A1A")).
-		Width(76).
-		Align(lipgloss.Center).
-		Padding(0, 1)
+			Bold(true).
+			Foreground(accentColor).
+			Background(barColor).
+			Width(76).
+			Align(lipgloss.Center).
+			Padding(0, 1)
 
 	contentStyle = lipgloss.NewStyle().
-		Padding(1, 2)
+			Padding(1, 2)
 
 	menuBoxStyle = lipgloss.NewStyle().
-		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("#3C3C3C")).
-		Padding(1, 2).
-		Width(70)
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(borderColor).
+			Padding(1, 2).
+			Width(70)
 
 	inputBoxStyle = lipgloss.NewStyle().
-		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("#3C3C3C")).
-		Padding(1, 2).
-		Width(70)
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(borderColor).
+			Padding(1, 2).
+			Width(70)
 
 	fileListStyle = lipgloss.NewStyle().
-		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("#3C3C3C")).
-		Padding(1, 2).
-		Width(70)
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(borderColor).
+			Padding(1, 2).
+			Width(70)
 
 	statusBarStyle = lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#AAAAAA")).
-		Background(lipgloss.Color("#1A1A1A")).
-		Width(76).
-		Align(lipgloss.Left).
-		Padding(0, 1)
+			Foreground(mutedColor).
+			Background(barColor).
+			Width(76).
+			Align(lipgloss.Left).
+			Padding(0, 1)
 
 	errorStyle = lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#FF0000")).
-		Padding(0, 2)
+			Foreground(errorColor).
+			Padding(0, 2)
 
 	successStyle = lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#00FF00")).
-		Padding(0, 2)
+			Foreground(accentColor).
+			Padding(0, 2)
 
 	selectedStyle = lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#00FFFF")).
-		Bold(true)
+			Foreground(selectedColor).
+			Bold(true)
 
 	highlightStyle = lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#FFD700")) // Gold
+			Foreground(highlightColor)
+
+	mutedStyle = lipgloss.NewStyle().
+			Foreground(mutedColor)
 )
 
 // Update the view states
 const (
-	stateMenu       = "menu"
-	stateSiteName   = "siteName"
-	statePassword   = "password"
-	stateCreateSiteName = "createSiteName"    // New state for site creation name
-	stateCreatePassword = "createPassword"    // New state for site creation password
-	stateViewFiles  = "viewFiles"
-	stateUploadFile = "uploadFile"
+	stateMenu viewState = iota
+	stateSiteName
+	statePassword
+	stateCreateSiteName // New state for site creation name
+	stateCreatePassword // New state for site creation password
+	stateCreateConfirm
+	stateViewFiles
+	stateUploadFile
+	stateMembers
+	stateInviteMember
+	stateTOTP
+	stateTOTPSetup
+	stateLogin
+	stateDeviceAuth
+	stateShareLink
+	stateMySites
+	stateQuickOpen
+	stateSiteSettings
+	stateDeleteSite
+	stateDiagnose
+	stateHistory
+	stateErrorDetail
+	stateDryRun
+	stateFileFilter
+	stateCommander
+	stateDownloadPath
+	stateEditTags
+	stateComments
+	stateChat
+	stateUploadURL
+	stateHexView
+	stateArchive
+	stateTransfers
+	stateSession
+
+	// stateCount is the number of screens; it must stay last.
+	stateCount
+)
+
+// stateNames names the screens in crash reports and error messages.
+var stateNames = [...]string{
+	stateMenu:           "menu",
+	stateSiteName:       "siteName",
+	statePassword:       "password",
+	stateCreateSiteName: "createSiteName",
+	stateCreatePassword: "createPassword",
+	stateCreateConfirm:  "createConfirm",
+	stateViewFiles:      "viewFiles",
+	stateUploadFile:     "uploadFile",
+	stateMembers:        "members",
+	stateInviteMember:   "inviteMember",
+	stateTOTP:           "totp",
+	stateTOTPSetup:      "totpSetup",
+	stateLogin:          "login",
+	stateDeviceAuth:     "deviceAuth",
+	stateShareLink:      "shareLink",
+	stateMySites:        "mySites",
+	stateQuickOpen:      "quickOpen",
+	stateSiteSettings:   "siteSettings",
+	stateDeleteSite:     "deleteSite",
+	stateDiagnose:       "diagnose",
+	stateHistory:        "history",
+	stateErrorDetail:    "errorDetail",
+	stateDryRun:         "dryRun",
+	stateFileFilter:     "fileFilter",
+	stateCommander:      "commander",
+	stateDownloadPath:   "downloadPath",
+	stateEditTags:       "editTags",
+	stateComments:       "comments",
+	stateChat:           "chat",
+	stateUploadURL:      "uploadURL",
+	stateHexView:        "hexView",
+	stateArchive:        "archive",
+	stateTransfers:      "transfers",
+	stateSession:        "session",
+}
+
+// A screen added without a name fails to compile here.
+var _ = [1]struct{}{}[len(stateNames)-int(stateCount)]
+
+// Main menu entries, in display order.
+const (
+	menuAccessSite = iota
+	menuCreateSite
+	menuMySites
+	menuHistory
+	menuSignIn
+	menuExit
 )
 
+var menuItems = []string{
+	"📂  Access Existing Site",
+	"✨  Create New Site",
+	"🗂️  My Sites",
+	"🕘  Transfer History",
+	"🔑  Sign In",
+	"🚪  Exit Application",
+}
+
+// Default servers, which server_url and login_server_url in config.toml
+// replace.
+const (
+	defaultServerURL      = "http://localhost:8080"
+	defaultLoginServerURL = "https://filesharingcli-production.up.railway.app"
+)
+
+// serverURL is the base URL of the file sharing backend.
+var serverURL = defaultServerURL
+
+// loginServerURL is the backend that handles site login and creation.
+var loginServerURL = defaultLoginServerURL
+
 // Add file dialog support
 type fileSelectMsg struct {
 	path string
@@ -115,13 +354,44 @@ type fileSelectMsg struct {
 
 // Init initializes the model (required by Bubble Tea).
 func (m *Model) Init() tea.Cmd {
-	return nil
+	return guardCmd(tea.Batch(keepaliveTick(), prefetchTick(), scanTick(), statusTick(), chatTick(), lockTick(), checkConfigFile(configModTime)))
 }
 
 // Update handles user input and updates the model.
-func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+func (m *Model) Update(msg tea.Msg) (model tea.Model, cmd tea.Cmd) {
+	model = m
+	defer recoverCrash()
+	model, cmd = m.update(msg)
+	cmd = tea.Batch(cmd, persistSession(m), persistOffline(m))
+	crashState.Store(snapshot(m))
+	return model, guardCmd(cmd)
+}
+
+// update handles a single message; Update wraps it to save the session.
+func (m *Model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		m.lastInput = time.Now()
+		if m.confirm != nil {
+			return handleConfirmInput(m, msg)
+		}
+		if msg.String() == keyBindings["quick_open"] && m.state != stateQuickOpen {
+			openQuickSwitcher(m)
+			return m, nil
+		}
+		if msg.String() == keyBindings["transfers"] && m.state != stateTransfers {
+			openTransfers(m)
+			return m, nil
+		}
+		if msg.String() == keyBindings["session"] && m.state != stateSession {
+			openSessionPanel(m)
+			return m, nil
+		}
+		if msg.String() == keyBindings["lock"] {
+			lockSession(m)
+			m.status = success("Session locked")
+			return m, nil
+		}
 		switch m.state {
 		case stateMenu:
 			return handleMenuInput(m, msg)
@@ -133,36 +403,304 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return handleCreateSiteNameInput(m, msg)
 		case stateCreatePassword:
 			return handleCreatePasswordInput(m, msg)
+		case stateCreateConfirm:
+			return handleCreateConfirmInput(m, msg)
 		case stateViewFiles:
-			return handleFileSelection(m, msg)
+			model, cmd := handleFileSelection(m, msg)
+			return model, tea.Batch(cmd, loadDetails(m))
 		case stateUploadFile:
+			if m.picking {
+				return handlePickerInput(m, msg)
+			}
+			if m.naming {
+				return handleSaveAsInput(m, msg)
+			}
 			return handleUploadSelectInput(m, msg)
+		case stateMembers:
+			return handleMembersInput(m, msg)
+		case stateInviteMember:
+			return handleInviteMemberInput(m, msg)
+		case stateTOTP:
+			return handleTOTPInput(m, msg)
+		case stateTOTPSetup:
+			return handleTOTPSetupInput(m, msg)
+		case stateLogin:
+			return handleLoginInput(m, msg)
+		case stateDeviceAuth:
+			return handleDeviceAuthInput(m, msg)
+		case stateShareLink:
+			return handleShareLinkInput(m, msg)
+		case stateMySites:
+			return handleMySitesInput(m, msg)
+		case stateQuickOpen:
+			return handleQuickOpenInput(m, msg)
+		case stateSiteSettings:
+			return handleSiteSettingsInput(m, msg)
+		case stateDeleteSite:
+			return handleDeleteSiteInput(m, msg)
+		case stateDownloadPath:
+			return handleDownloadPathInput(m, msg)
+		case stateDiagnose:
+			return handleDiagnoseInput(m, msg)
+		case stateHistory:
+			return handleHistoryInput(m, msg)
+		case stateErrorDetail:
+			return handleErrorDetailInput(m, msg)
+		case stateDryRun:
+			return handleDryRunInput(m, msg)
+		case stateFileFilter:
+			return handleFilterInput(m, msg)
+		case stateCommander:
+			return handleCommanderInput(m, msg)
+		case stateEditTags:
+			return handleTagInput(m, msg)
+		case stateComments:
+			return handleCommentsInput(m, msg)
+		case stateChat:
+			return handleChatInput(m, msg)
+		case stateUploadURL:
+			return handleUploadURLInput(m, msg)
+		case stateHexView:
+			return handleHexInput(m, msg)
+		case stateArchive:
+			return handleArchiveInput(m, msg)
+		case stateTransfers:
+			return handleTransfersInput(m, msg)
+		case stateSession:
+			return handleSessionInput(m, msg)
 		}
-	case []FileInfo:
-		m.files = msg
-		m.state = stateViewFiles
-	case error:
-		m.state = stateMenu
-		m.errorMsg = msg.Error()
-	case string:
-		if strings.HasPrefix(msg, "Success") {
-			m.errorMsg = ""
-			m.state = stateMenu
-		} else {
-			m.errorMsg = msg
+	case tea.MouseMsg:
+		m.lastInput = time.Now()
+		if m.confirm != nil {
+			return m, nil
+		}
+		return handleMouse(m, msg)
+	case downloadStepMsg:
+		return handleDownloadStep(m, msg)
+	case transferDoneMsg:
+		m.transfers--
+		return m.update(msg.msg)
+	case listingStartMsg:
+		stopListing(m)
+		m.files = nil
+		m.selectedIdx = 0
+		m.fileOffset = 0
+		m.expiresAt = msg.expiresAt
+		m.listStream = msg.stream
+		m.listStop = msg.stop
+		m.listLoaded = 0
+		m.listTotal = msg.total
+		m.listStale = time.Time{}
+		m.goTo(stateViewFiles)
+		if len(m.pendingUploads) > 0 || m.fileToUpload != "" {
+			m.goTo(stateUploadFile)
+		}
+		return m, tea.Batch(waitListing(msg.stream), fetchCapabilities, recordRecent(QuickItem{Kind: quickSite, Site: m.siteName}))
+	case listingMsg:
+		return handleListing(m, msg)
+	case siteLoadedMsg:
+		stopListing(m)
+		m.files = msg.files
+		m.expiresAt = msg.expiresAt
+		m.listStale = msg.staleSince
+		if !m.listStale.IsZero() {
+			m.status = notice("Server unreachable: showing the offline copy of " + m.siteName)
+		}
+		keepSelectionVisible(m)
+		m.goTo(stateViewFiles)
+		if len(m.pendingUploads) > 0 || m.fileToUpload != "" {
+			m.goTo(stateUploadFile)
+		}
+		cmds := []tea.Cmd{applyRestore(m), flushOffline(m), recordRecent(QuickItem{Kind: quickSite, Site: m.siteName})}
+		if m.listStale.IsZero() {
+			cmds = append(cmds, saveListing(m.siteName, m.password, m.files, m.expiresAt), fetchCapabilities)
+		}
+		return m, tea.Batch(cmds...)
+	case capabilitiesMsg:
+		return handleCapabilities(m, msg)
+	case pinToggledMsg:
+		return handlePinToggled(m, msg)
+	case clipboardSavedMsg:
+		return handleClipboardSaved(m, msg)
+	case fileInfoCopiedMsg:
+		return handleFileInfoCopied(m, msg)
+	case downloadPathSavedMsg:
+		return handleDownloadPathSaved(m, msg)
+	case membersMsg:
+		m.members = msg.members
+		m.goTo(stateMembers)
+		m.status = msg.status
+		if m.memberIdx >= len(m.members) {
+			m.memberIdx = 0
+		}
+	case totpRequiredMsg:
+		m.goTo(stateTOTP)
+		m.totpCode = ""
+		m.status = statusLine{}
+	case totpSetupMsg:
+		m.otpauthURL = msg.otpauthURL
+		m.goTo(stateTOTPSetup)
+		m.status = statusLine{}
+	case deviceAuthMsg:
+		m.device = msg.device
+		m.goTo(stateDeviceAuth)
+		m.status = statusLine{}
+		return m, pollDeviceToken(m.device, m.device.Interval)
+	case deviceTokenMsg:
+		return handleDeviceToken(m, msg)
+	case signedOutMsg:
+		m.account = ""
+		m.status = success("Signed out")
+	case mySitesMsg:
+		m.mySites = msg.sites
+		m.goTo(stateMySites)
+		m.status = statusLine{}
+		if m.siteIdx >= len(m.mySites) {
+			m.siteIdx = 0
 		}
+	case siteDeletedMsg:
+		m.goTo(stateMenu)
+		m.siteName = ""
+		m.password = ""
+		m.files = nil
+		m.status = success(fmt.Sprintf("Site %s deleted", msg.siteName))
+	case shareLinkMsg:
+		m.shareURL = msg.url
+		m.status = msg.status
+	case uploadedMsg:
+		m.files = msg.files
+		m.goTo(stateViewFiles)
+		m.status = msg.status
+		if msg.verified {
+			if m.verified == nil {
+				m.verified = make(map[int]bool)
+			}
+			m.verified[msg.fileID] = true
+		}
+	case pasteMsg:
+		if msg.err != nil {
+			m.status = notice(fmt.Sprintf("Error reading clipboard: %v", msg.err))
+		} else if field := passwordField(m); field != nil {
+			*field += secret(msg.text)
+		}
+	case keepaliveMsg:
+		return handleKeepalive(m)
+	case folderSelectMsg:
+		if msg.err != nil {
+			m.status = notice(fmt.Sprintf("Error selecting folder: %v", msg.err))
+		} else if msg.path != "" {
+			selectUpload(m, msg.path, true)
+		}
+	case uploadStartedMsg:
+		m.batchStream = msg.stream
+		m.batchStop = msg.stop
+		m.batchTotal = msg.total
+		m.batchHashed = 0
+		m.batchUploaded = 0
+		m.batchActive = nil
+		m.batchFailed = nil
+		return m, waitBatch(msg.stream)
+	case uploadProgressMsg:
+		return handleBatchProgress(m, msg)
+	case diagResult:
+		return handleDiagResult(m, msg)
+	case fileDeletedMsg:
+		for i, file := range m.files {
+			if file.ID == msg.fileID {
+				m.files = append(m.files[:i], m.files[i+1:]...)
+				break
+			}
+		}
+		keepSelectionVisible(m)
+		m.status = success(fmt.Sprintf("Deleted %s", msg.fileName))
+	case downloadDoneMsg:
+		m.lastDownload = msg.path
+		m.status = msg.status
+	case openedMsg:
+		if msg.err != nil {
+			m.status = notice(msg.err.Error())
+		}
+	case prefetchMsg:
+		return handlePrefetch(m)
+	case scanTickMsg:
+		return handleScanTick(m)
+	case scanPolledMsg:
+		return handleScanPolled(m, msg)
+	case prefetchedMsg:
+		// Prefetching is best effort and runs silently.
+	case pingResultMsg:
+		// Keepalive failures are silent; the next real request reports them.
+	case statusTickMsg:
+		return handleStatusTick(m)
+	case healthMsg:
+		m.health = msg.check
+		return m, tea.Batch(reloadStale(m), flushOffline(m))
+	case offlineQueueMsg:
+		queueOffline(m, msg.site, msg.paths)
+		m.goTo(stateViewFiles)
+	case offlineFlushedMsg:
+		return handleOfflineFlushed(m, msg)
+	case listingRefreshedMsg:
+		return handleListingRefreshed(m, msg)
+	case archiveListedMsg:
+		return handleArchiveListed(m, msg)
+	case hexLoadedMsg:
+		return handleHexLoaded(m, msg)
+	case editFetchedMsg:
+		return handleEditFetched(m, msg)
+	case editDoneMsg:
+		return handleEditDone(m, msg)
+	case clipboardImageMsg:
+		return handleClipboardImage(m, msg)
+	case chatTickMsg:
+		return handleChatTick(m)
+	case lockTickMsg:
+		return handleLockTick(m)
+	case configFileMsg:
+		return handleConfigFile(m, msg)
+	case loggedOutMsg:
+		return handleLoggedOut(m, msg)
+	case chatPolledMsg:
+		return handleChatPolled(m, msg)
+	case chatSentMsg:
+		return handleChatSent(m, msg)
+	case commentsMsg:
+		return handleComments(m, msg)
+	case tagsSavedMsg:
+		return handleTagsSaved(m, msg)
+	case commanderDoneMsg:
+		return handleCommanderDone(m, msg)
+	case detailsMsg:
+		if m.details != nil {
+			d := msg.details
+			m.details[msg.fileID] = &d
+		}
+	case apiErrorMsg:
+		showError(m, msg.err)
+	case error:
+		// Failures carried inside other messages arrive unwrapped.
+		showError(m, msg)
+	case siteCreatedMsg:
+		m.status = statusLine{}
+		m.goTo(stateMenu)
 	case fileSelectMsg:
 		if msg.err != nil {
-			m.errorMsg = fmt.Sprintf("Error selecting file: %v", msg.err)
-		} else {
-			m.fileToUpload = msg.path
+			m.status = notice(fmt.Sprintf("Error selecting file: %v", msg.err))
+		} else if msg.path != "" {
+			selectUpload(m, msg.path, false)
 		}
 	}
 	return m, nil
 }
 
 // View renders the UI based on the current state.
-func (m *Model) View() string {
+func (m *Model) View() (view string) {
+	defer recoverCrash()
+	return m.view()
+}
+
+// view renders the current screen; View wraps it to catch panics.
+func (m *Model) view() string {
 	var content strings.Builder
 
 	// Header
@@ -170,22 +708,30 @@ func (m *Model) View() string {
 	content.WriteString(header)
 	content.WriteString("\n")
 
-	// Error/Success message
-	if m.errorMsg != "" {
-		var msgBox string
-		if strings.HasPrefix(m.errorMsg, "Success") {
-			msgBox = successStyle.Render("✅ " + m.errorMsg)
-		} else {
-			msgBox = errorStyle.Render("❌ " + m.errorMsg)
-		}
-		content.WriteString(msgBox)
+	// Status line
+	if m.status.text != "" {
+		content.WriteString(m.status.render())
 		content.WriteString("\n")
 	}
 
+	// Mouse clicks are mapped to list rows using where the list was last
+	// drawn: below the app border and padding, the text so far, and the
+	// box's own border and padding.
+	m.listTop = 2 + strings.Count(content.String(), "\n") + 2
+	m.listRows = 0
+
+	// A confirmation dialog replaces the screen until it is answered.
+	if m.confirm != nil {
+		content.WriteString(renderConfirm(*m.confirm))
+		return frameView(*m, content.String())
+	}
+
 	// Main content
 	switch m.state {
 	case stateMenu:
-		menu := menuBoxStyle.Render(renderMenu(m.cursor))
+		m.listTop += 3 // title, rule, blank line
+		m.listRows = len(menuItems)
+		menu := menuBoxStyle.Render(renderMenu(m.cursor, m.account))
 		content.WriteString(menu)
 
 	case stateSiteName:
@@ -203,9 +749,9 @@ func (m *Model) View() string {
 		inputBox := inputBoxStyle.Render(
 			lipgloss.JoinVertical(lipgloss.Left,
 				"Site: "+m.siteName,
-				"Password: "+strings.Repeat("•", len(m.password))+"█",
+				"Password: "+maskPassword(m.password.reveal(), m.showPassword)+"█",
 				"",
-				highlightStyle.Render("Enter - Continue • Esc - Back"),
+				highlightStyle.Render("Enter - Continue • Ctrl+V - Paste • Ctrl+T - Show/Hide • Esc - Back"),
 			),
 		)
 		content.WriteString(inputBox)
@@ -214,7 +760,7 @@ func (m *Model) View() string {
 		inputBox := inputBoxStyle.Render(
 			lipgloss.JoinVertical(lipgloss.Left,
 				"Create New Site",
-				"Enter Site Name: " + m.siteName + "█",
+				"Enter Site Name: "+m.siteName+"█",
 				"",
 				highlightStyle.Render("Enter - Continue • Esc - Back"),
 			),
@@ -224,46 +770,418 @@ func (m *Model) View() string {
 	case stateCreatePassword:
 		inputBox := inputBoxStyle.Render(
 			lipgloss.JoinVertical(lipgloss.Left,
-				"Create Site: " + m.siteName,
-				"Enter Password: " + strings.Repeat("•", len(m.password)) + "█",
+				"Create Site: "+m.siteName,
+				"Enter Password: "+maskPassword(m.password.reveal(), m.showPassword)+"█",
+				"Strength: "+renderStrengthMeter(m.password.reveal()),
+				"Two-factor auth: "+renderTOTPToggle(m.enableTOTP),
+				"Expires: "+renderTTLPicker(m.ttlIdx),
 				"",
-				highlightStyle.Render("Enter - Create Site • Esc - Back"),
+				highlightStyle.Render("Enter - Continue • Tab - Toggle 2FA • ←/→ - Expiry • Ctrl+V - Paste • Ctrl+T - Show/Hide • Esc - Back"),
+			),
+		)
+		content.WriteString(inputBox)
+
+	case stateCreateConfirm:
+		inputBox := inputBoxStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"Create Site: "+m.siteName,
+				"Confirm Password: "+maskPassword(m.confirmPassword.reveal(), m.showPassword)+"█",
+				"",
+				highlightStyle.Render("Enter - Create Site • Ctrl+V - Paste • Ctrl+T - Show/Hide • Esc - Back"),
 			),
 		)
 		content.WriteString(inputBox)
 
 	case stateViewFiles:
+		m.listTop += 2 // site line, rule
+		m.listRows = min(visibleFiles, len(fileRows(*m))-m.fileOffset)
+		hints := highlightStyle.Render(renderFileListHints())
+		if rows := renderTransfers(*m); rows != "" {
+			hints = rows + "\n\n" + hints
+		}
+		if pending := renderOfflineQueue(*m); pending != "" {
+			hints = pending + "\n\n" + hints
+		}
+		if m.showDetails {
+			// The pane takes its width from the list; the hints move below
+			// both so the list rows stay where the mouse expects them.
+			listWidth := fileListStyle.GetWidth() - detailsWidth - 2
+			expiry := renderExpiry(m.expiresAt) + renderStale(m.listStale)
+			list := fileListStyle.Width(listWidth).Render(
+				lipgloss.JoinVertical(lipgloss.Left,
+					"�� "+truncate(m.siteName, max(8, listWidth-9-lipgloss.Width(expiry)))+"  "+expiry,
+					strings.Repeat("─", listWidth-6),
+					renderFileList(*m),
+				),
+			)
+			pane := fileListStyle.Width(detailsWidth).Render(renderDetails(*m))
+			content.WriteString(lipgloss.JoinVertical(lipgloss.Left,
+				lipgloss.JoinHorizontal(lipgloss.Top, list, pane),
+				lipgloss.NewStyle().Width(fileListStyle.GetWidth()).Render(hints),
+			))
+			break
+		}
 		fileBox := fileListStyle.Render(
 			lipgloss.JoinVertical(lipgloss.Left,
-				"�� "+m.siteName,
+				"�� "+m.siteName+"  "+renderExpiry(m.expiresAt)+renderStale(m.listStale),
 				strings.Repeat("─", 50),
 				renderFileList(*m),
 				"",
-				highlightStyle.Render("U - Upload • Enter - Download • Esc - Back"),
+				hints,
 			),
 		)
 		content.WriteString(fileBox)
 
 	case stateUploadFile:
+		if m.picking {
+			content.WriteString(renderPickerBox(*m))
+			break
+		}
 		uploadBox := inputBoxStyle.Render(
 			lipgloss.JoinVertical(lipgloss.Left,
 				"📤 Upload to: "+m.siteName,
 				"",
-				"Press F to select file",
-				m.fileToUpload,
+				"Press F to add a file, D to add a folder or L to add from a URL",
+				m.fileToUpload+m.folderToUpload+renderPendingUploads(m.pendingUploads),
+				renderSaveAs(*m),
+				renderClipboardOffer(*m),
+				renderUploadProgress(*m),
 				"",
-				highlightStyle.Render("Enter - Upload • Esc - Cancel"),
+				highlightStyle.Render("Enter - Upload • Backspace - Remove Last • Esc - Cancel"),
 			),
 		)
 		content.WriteString(uploadBox)
+
+	case stateArchive:
+		archiveBox := fileListStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"📦 "+m.archiveFile.FileName,
+				strings.Repeat("─", 50),
+				renderArchive(*m),
+				"",
+				highlightStyle.Render("↑/↓ - Navigate • Enter - Download File • Esc - Back"),
+			),
+		)
+		content.WriteString(archiveBox)
+
+	case stateTransfers:
+		transfersBox := fileListStyle.Width(transfersWidth).Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"⇅ Transfers",
+				strings.Repeat("─", 50),
+				renderTransfersScreen(*m),
+				"",
+				highlightStyle.Render("↑/↓ - Select • +/- - Priority • P - Downloads First • Esc - Back"),
+			),
+		)
+		content.WriteString(transfersBox)
+
+	case stateSession:
+		sessionBox := fileListStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"👤 Session",
+				strings.Repeat("─", 50),
+				renderSessionPanel(*m),
+				"",
+				highlightStyle.Render("Esc - Back"),
+			),
+		)
+		content.WriteString(sessionBox)
+
+	case stateHexView:
+		hexBox := fileListStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"🔢 "+m.hexFile.FileName,
+				strings.Repeat("─", 50),
+				renderHexView(*m),
+				"",
+				highlightStyle.Render("↑/↓ - Scroll • PgUp/PgDn - Page • Enter - Download • Esc - Back"),
+			),
+		)
+		content.WriteString(hexBox)
+
+	case stateUploadURL:
+		urlBox := inputBoxStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"🌐 Upload from URL to: "+m.siteName,
+				"",
+				"Paste an http(s) link. The server fetches it directly when it can;",
+				"otherwise it is downloaded here first and then uploaded.",
+				"",
+				"URL: "+m.uploadURL+"█",
+				"",
+				highlightStyle.Render("Enter - Add to Site • Esc - Back"),
+			),
+		)
+		content.WriteString(urlBox)
+
+	case stateMembers:
+		membersBox := fileListStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"👥 Members of "+m.siteName,
+				strings.Repeat("─", 50),
+				renderMemberList(*m),
+				"",
+				highlightStyle.Render("I - Invite • D - Revoke • R - Refresh • Esc - Back"),
+			),
+		)
+		content.WriteString(membersBox)
+
+	case stateInviteMember:
+		inviteBox := inputBoxStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"Invite to: "+m.siteName,
+				"Username: "+m.inviteUser+"█",
+				"Role: "+renderRolePicker(m.inviteRole),
+				"",
+				highlightStyle.Render("Enter - Invite • Tab - Change Role • Esc - Back"),
+			),
+		)
+		content.WriteString(inviteBox)
+
+	case stateTOTP:
+		inputBox := inputBoxStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"Site: "+m.siteName,
+				"Authentication code: "+m.totpCode.reveal()+"█",
+				"",
+				highlightStyle.Render("Enter - Verify • Esc - Back"),
+			),
+		)
+		content.WriteString(inputBox)
+
+	case stateTOTPSetup:
+		setupBox := inputBoxStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"🔐 Two-factor setup for "+m.siteName,
+				"Scan this code with your authenticator app:",
+				"",
+				renderTOTPSetup(m.otpauthURL),
+				"",
+				highlightStyle.Render("Enter - Done"),
+			),
+		)
+		content.WriteString(setupBox)
+
+	case stateLogin:
+		loginBox := inputBoxStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"🔑 Sign in to your account",
+				"Provider: "+renderProviderPicker(m.provider),
+				"",
+				highlightStyle.Render("Enter - Continue • Tab - Change Provider • Esc - Back"),
+			),
+		)
+		content.WriteString(loginBox)
+
+	case stateDeviceAuth:
+		deviceBox := inputBoxStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"🔑 Sign in with "+m.provider,
+				"",
+				"Open: "+highlightStyle.Render(m.device.VerificationURI),
+				"Enter code: "+selectedStyle.Render(m.device.UserCode),
+				"",
+				"Waiting for you to approve the sign-in...",
+				"",
+				highlightStyle.Render("Esc - Cancel"),
+			),
+		)
+		content.WriteString(deviceBox)
+
+	case stateShareLink:
+		var shareBox string
+		shareFile, _ := selectedFile(*m)
+		if m.shareURL != "" {
+			shareBox = inputBoxStyle.Render(
+				lipgloss.JoinVertical(lipgloss.Left,
+					"🔗 Share link for "+shareFile.FileName,
+					"",
+					highlightStyle.Render(m.shareURL),
+					"",
+					highlightStyle.Render("Enter - Done"),
+				),
+			)
+		} else {
+			shareBox = inputBoxStyle.Render(
+				lipgloss.JoinVertical(lipgloss.Left,
+					"🔗 Share "+shareFile.FileName,
+					"Custom short code (optional): /s/"+m.shareSlug+"█",
+					"",
+					highlightStyle.Render("Enter - Create Link • Esc - Cancel"),
+				),
+			)
+		}
+		content.WriteString(shareBox)
+
+	case stateMySites:
+		sitesBox := fileListStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"🗂️  My Sites ("+m.account+")",
+				strings.Repeat("─", 50),
+				renderMySites(*m),
+				"",
+				highlightStyle.Render("Enter - Open • R - Refresh • Esc - Back"),
+			),
+		)
+		content.WriteString(sitesBox)
+
+	case stateQuickOpen:
+		quickBox := fileListStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"Quick Open",
+				strings.Repeat("─", 50),
+				renderQuickOpen(*m),
+				"",
+				highlightStyle.Render("Type to filter • Enter - Open • Esc - Close"),
+			),
+		)
+		content.WriteString(quickBox)
+
+	case stateSiteSettings:
+		settingsBox := menuBoxStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"⚙️  Settings for "+m.siteName,
+				strings.Repeat("─", 40),
+				"",
+				renderSiteSettings(m.settingsIdx),
+				highlightStyle.Render("Enter - Select • Esc - Back"),
+			),
+		)
+		content.WriteString(settingsBox)
+
+	case stateDownloadPath:
+		pathBox := inputBoxStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"📁 Download path (all sites)",
+				strings.Repeat("─", 40),
+				renderDownloadPath(*m),
+				"",
+				highlightStyle.Render("Enter - Save • Ctrl+R - Reset • Esc - Cancel"),
+			),
+		)
+		content.WriteString(pathBox)
+
+	case stateDeleteSite:
+		deleteBox := inputBoxStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				errorStyle.Render("Delete "+m.siteName+" and all of its files?"),
+				"This cannot be undone. Type the site name to confirm:",
+				m.deleteConfirm+"█",
+				"",
+				highlightStyle.Render("Enter - Delete Site • Esc - Cancel"),
+			),
+		)
+		content.WriteString(deleteBox)
+
+	case stateDiagnose:
+		diagBox := fileListStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"🩺 Connection troubleshooting: "+loginServerURL,
+				strings.Repeat("─", 50),
+				renderDiagnostics(*m),
+				"",
+				highlightStyle.Render("R - Run Again • Esc - Back"),
+			),
+		)
+		content.WriteString(diagBox)
+
+	case stateHistory:
+		historyBox := fileListStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"🕘 Transfer history",
+				strings.Repeat("─", 50),
+				renderHistory(*m),
+				"",
+				highlightStyle.Render("↑/↓ - Scroll • S - Site • D - Date Range • Esc - Back"),
+			),
+		)
+		content.WriteString(historyBox)
+
+	case stateErrorDetail:
+		errorBox := inputBoxStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				renderErrorDetail(*m),
+				"",
+				highlightStyle.Render("C - Copy to Clipboard • Esc - Back"),
+			),
+		)
+		content.WriteString(errorBox)
+
+	case stateChat:
+		chatBox := inputBoxStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"💬 Chat in "+m.siteName,
+				strings.Repeat("─", 40),
+				renderChat(*m),
+				"",
+				highlightStyle.Render("Enter - Send • Esc - Back"),
+			),
+		)
+		content.WriteString(chatBox)
+
+	case stateComments:
+		commentsBox := inputBoxStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"💬 Comments on "+m.commentFile.FileName,
+				strings.Repeat("─", 40),
+				renderComments(*m),
+				"",
+				highlightStyle.Render("↑/↓ - Scroll • Enter - Post • Esc - Back"),
+			),
+		)
+		content.WriteString(commentsBox)
+
+	case stateEditTags:
+		tagBox := inputBoxStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"🏷️  Tags",
+				"",
+				renderTagEditor(*m),
+				"",
+				highlightStyle.Render("Separate tags with commas or spaces • Enter - Save • Esc - Cancel"),
+			),
+		)
+		content.WriteString(tagBox)
+
+	case stateFileFilter:
+		filterBox := inputBoxStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"🔎 Show in "+m.siteName,
+				"",
+				renderFilterMenu(*m),
+				"",
+				highlightStyle.Render("↑/↓ - Choose • Type - Extension • Enter - Apply • Esc - Back"),
+			),
+		)
+		content.WriteString(filterBox)
+
+	case stateCommander:
+		content.WriteString(lipgloss.JoinVertical(lipgloss.Left,
+			renderCommander(*m),
+			highlightStyle.Render("Tab - Switch Pane • Enter - Open Folder • Backspace - Up • F5/C - Copy • F6/M - Move • R - Refresh • Esc - Back"),
+		))
+
+	case stateDryRun:
+		planBox := fileListStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				"🔍 "+m.planTitle,
+				strings.Repeat("─", 50),
+				renderDryRun(*m),
+				"",
+				highlightStyle.Render("↑/↓ - Scroll • Esc - Back"),
+			),
+		)
+		content.WriteString(planBox)
 	}
 
-	// Status bar
-	statusBar := statusBarStyle.Render(getStatusText(*m))
-	content.WriteString("\n" + statusBar)
+	return frameView(*m, content.String())
+}
 
-	// Wrap everything in the app container
-	return appStyle.Render(content.String())
+// frameView adds the status bar and wraps the screen in the app container.
+func frameView(m Model, content string) string {
+	statusBar := renderStatusBar(m)
+	return plainText(appStyle.Render(content + "\n" + statusBar))
 }
 
 // handleMenuInput handles input in the menu state.
@@ -274,42 +1192,94 @@ func handleMenuInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.cursor--
 		}
 	case "down":
-		if m.cursor < 2 {
+		if m.cursor < len(menuItems)-1 {
 			m.cursor++
 		}
+	case "t", "T":
+		if m.canDiagnose {
+			return m, startDiagnostics(m)
+		}
+	case "e", "E":
+		if m.lastError != nil {
+			m.goTo(stateErrorDetail)
+		}
 	case "enter":
 		switch m.cursor {
-		case 0:
-			m.state = stateSiteName
+		case menuAccessSite:
+			m.goTo(stateSiteName)
 			m.siteName = ""
 			m.password = ""
-		case 1:
-			m.state = stateCreateSiteName
+		case menuCreateSite:
+			m.goTo(stateCreateSiteName)
 			m.siteName = ""
 			m.password = ""
-		case 2:
-			return m, tea.Quit
+			m.enableTOTP = false
+			m.ttlIdx = 0
+		case menuMySites:
+			return m, fetchMySites
+		case menuHistory:
+			openHistory(m)
+		case menuSignIn:
+			if m.account != "" {
+				return m, signOut
+			}
+			m.goTo(stateLogin)
+			if m.provider == "" {
+				m.provider = loginProviders[0]
+			}
+		case menuExit:
+			return m, confirmQuit(m)
 		}
 	}
 	return m, nil
 }
 
+// showError returns to the menu with a failed command's error.
+func showError(m *Model, err error) {
+	// A failed login can be investigated from the menu.
+	m.canDiagnose = m.state == statePassword || m.state == stateTOTP
+	m.goTo(stateMenu)
+	recordError(m, err)
+	if m.canDiagnose {
+		m.status.text += " (press T to troubleshoot)"
+	}
+}
+
+// recordError shows err as the status without leaving the screen. Server
+// errors keep their response for the details screen.
+func recordError(m *Model, err error) {
+	m.status = notice(err.Error())
+	m.lastError = nil
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		detail := *apiErr
+		detail.what = strings.TrimSuffix(err.Error(), ": "+apiErr.body)
+		m.lastError = &detail
+		if apiErr.requestID != "" {
+			m.status.text += fmt.Sprintf(" (request ID %s, press E for details)", apiErr.requestID)
+		} else {
+			m.status.text += " (press E for details)"
+		}
+	}
+}
+
 // handleSiteNameInput handles input in the siteName state.
 func handleSiteNameInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "enter":
-		m.state = statePassword
+		m.siteName = resolveSite(m.siteName)
+		if _, ok := storeFor(m.siteName); ok {
+			// The store's own credentials stand in for a site password.
+			return m, api.openSite(m.siteName, "", "")
+		}
+		m.goTo(statePassword)
 	case "esc":
-		m.state = stateMenu
+		m.goTo(stateMenu)
 		m.siteName = ""
 	case "backspace":
-		if len(m.siteName) > 0 {
-			m.siteName = m.siteName[:len(m.siteName)-1]
-		}
+		m.siteName = deleteLastChar(m.siteName)
 	default:
-		if len(msg.String()) == 1 {
-			m.siteName += msg.String()
-		}
+		m.siteName += typedText(msg)
 	}
 	return m, nil
 }
@@ -318,18 +1288,18 @@ func handleSiteNameInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 func handlePasswordInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "enter":
-		return m, fetchFiles(m.siteName, m.password)
+		return m, api.openSite(m.siteName, m.password, "")
 	case "esc":
-		m.state = stateMenu
+		m.goTo(stateMenu)
 		m.password = ""
 	case "backspace":
-		if len(m.password) > 0 {
-			m.password = m.password[:len(m.password)-1]
-		}
+		m.password = deleteLastChar(m.password)
+	case "ctrl+v":
+		return m, readClipboard
+	case "ctrl+t":
+		m.showPassword = !m.showPassword
 	default:
-		if len(msg.String()) == 1 {
-			m.password += msg.String()
-		}
+		m.password += secret(typedText(msg))
 	}
 	return m, nil
 }
@@ -339,19 +1309,15 @@ func handleCreateSiteNameInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "enter":
 		if m.siteName != "" {
-			m.state = stateCreatePassword
+			m.goTo(stateCreatePassword)
 		}
 	case "esc":
-		m.state = stateMenu
+		m.goTo(stateMenu)
 		m.siteName = ""
 	case "backspace":
-		if len(m.siteName) > 0 {
-			m.siteName = m.siteName[:len(m.siteName)-1]
-		}
+		m.siteName = deleteLastChar(m.siteName)
 	default:
-		if len(msg.String()) == 1 {
-			m.siteName += msg.String()
-		}
+		m.siteName += typedText(msg)
 	}
 	return m, nil
 }
@@ -363,18 +1329,29 @@ func handleCreatePasswordInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if m.siteName == "" || m.password == "" {
 			return m, nil
 		}
-		return m, createSite(m.siteName, m.password)
+		if passwordStrength(m.password.reveal()) < minPasswordScore {
+			m.status = notice("Password is too weak: use a longer password with mixed characters")
+			return m, nil
+		}
+		m.status = statusLine{}
+		m.goTo(stateCreateConfirm)
+	case "tab":
+		m.enableTOTP = !m.enableTOTP
+	case "left":
+		m.ttlIdx = (m.ttlIdx + len(ttlChoices) - 1) % len(ttlChoices)
+	case "right":
+		m.ttlIdx = (m.ttlIdx + 1) % len(ttlChoices)
 	case "esc":
-		m.state = stateCreateSiteName
+		m.goTo(stateCreateSiteName)
 		m.password = ""
 	case "backspace":
-		if len(m.password) > 0 {
-			m.password = m.password[:len(m.password)-1]
-		}
+		m.password = deleteLastChar(m.password)
+	case "ctrl+v":
+		return m, readClipboard
+	case "ctrl+t":
+		m.showPassword = !m.showPassword
 	default:
-		if len(msg.String()) == 1 {
-			m.password += msg.String()
-		}
+		m.password += secret(typedText(msg))
 	}
 	return m, nil
 }
@@ -383,51 +1360,170 @@ func handleCreatePasswordInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 func handleUploadSelectInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "f", "F":
-		return m, openFileDialog
+		if m.batchStream == nil {
+			return m, pickUpload(m, false)
+		}
+	case "d", "D":
+		if m.batchStream == nil {
+			return m, pickUpload(m, true)
+		}
+	case "l", "L":
+		if m.batchStream == nil {
+			m.goTo(stateUploadURL)
+		}
+	case "v", "V":
+		if m.batchStream == nil {
+			return m, useClipboardImage(m)
+		}
+	case "n", "N":
+		if m.batchStream == nil {
+			openSaveAs(m)
+		}
+	case "backspace":
+		if m.batchStream == nil {
+			unselectUpload(m)
+		}
 	case "enter":
-		if m.fileToUpload != "" {
-			return m, uploadFile(m)
+		if m.batchStream != nil {
+			return m, nil
+		}
+		if m.dryRun {
+			targets := append(slices.Clone(m.pendingUploads), m.folderToUpload, m.fileToUpload)
+			targets = slices.DeleteFunc(targets, func(t string) bool { return t == "" })
+			if len(targets) == 0 {
+				return m, nil
+			}
+			plan, err := planUploads(targets, m.excludes)
+			if err != nil {
+				m.status = notice(err.Error())
+				return m, nil
+			}
+			return m, showPlan(m, "Dry run: upload to "+m.siteName, plan)
 		}
+		if workingOffline(m) {
+			queueOffline(m, m.siteName, append(slices.Clone(m.pendingUploads), m.folderToUpload, m.fileToUpload))
+			m.pendingUploads = nil
+			m.folderToUpload = ""
+			m.fileToUpload = ""
+			m.goTo(stateViewFiles)
+			return m, nil
+		}
+		return m, beginUpload(m)
 	case "esc":
-		m.state = stateViewFiles
-		m.fileToUpload = ""
+		if m.batchStop != nil {
+			m.batchStop()
+			m.batchStream = nil
+			m.batchStop = nil
+			m.status = notice("Batch upload cancelled")
+		}
+		m.goTo(stateViewFiles)
 	}
 	return m, nil
 }
 
 // handleFileSelection allows users to select a file using arrow keys.
 func handleFileSelection(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if status := unsupported(msg.String()); status != "" {
+		m.status = notice(status)
+		return m, nil
+	}
 	switch msg.String() {
 	case "u", "U":
-		m.state = stateUploadFile
-		m.fileToUpload = ""
+		m.goTo(stateUploadFile)
+		return m, checkClipboardImage
+	case "m", "M":
+		return m, fetchMembers(m.siteName)
+	case "o":
+		if m.lastDownload != "" {
+			return m, openFile(m.lastDownload)
+		}
+	case "O":
+		if m.lastDownload != "" {
+			return m, revealFile(m.lastDownload)
+		}
+	case "g", "G":
+		m.goTo(stateSiteSettings)
+	case "p", "P":
+		if file, ok := selectedFile(*m); ok {
+			return m, togglePin(QuickItem{Kind: quickFile, Site: m.siteName, FileID: file.ID, FileName: file.FileName})
+		}
+	case "s", "S":
+		if _, ok := selectedFile(*m); ok {
+			m.goTo(stateShareLink)
+		}
+	case "f", "F":
+		openFilterMenu(m)
+	case "v", "V":
+		toggleGrouping(m)
+	case "i", "I":
+		return m, toggleDetails(m)
+	case "t", "T":
+		openCommander(m)
+	case "#":
+		openTagEditor(m)
+	case "c", "C":
+		return m, openComments(m)
+	case "k", "K":
+		openChat(m)
+	case "h", "H":
+		return m, openHexView(m)
+	case "y", "Y":
+		return m, copyFileInfo(m)
+	case "z", "Z":
+		return m, openArchive(m)
+	case "r", "R":
+		return m, refreshListing(m)
+	case "e", "E":
+		if file, ok := selectedFile(*m); ok {
+			m.status = notice("Fetching " + file.FileName + " for editing...")
+			return m, editRemoteFile(m.siteName, file)
+		}
 	case "up":
 		if m.selectedIdx > 0 {
 			m.selectedIdx--
+			keepSelectionVisible(m)
 		}
 	case "down":
-		if m.selectedIdx < len(m.files)-1 {
+		if m.selectedIdx < len(fileRows(*m))-1 {
 			m.selectedIdx++
+			keepSelectionVisible(m)
 		}
-	case "enter":
-		if len(m.files) > 0 && m.selectedIdx >= 0 && m.selectedIdx < len(m.files) {
-			selectedFile := m.files[m.selectedIdx]
-			return m, downloadFile(selectedFile.ID, selectedFile.FileName)
+	case "enter", " ":
+		if toggleSection(m) {
+			return m, nil
+		}
+		if file, ok := selectedFile(*m); ok && msg.String() == "enter" {
+			if m.dryRun {
+				return m, showPlan(m, "Dry run: download", planDownloads(m.siteName, []FileInfo{file}, m.onConflict))
+			}
+			return m, tea.Batch(recordRecent(QuickItem{Kind: quickFile, Site: m.siteName, FileID: file.ID, FileName: file.FileName}),
+				queueDownloads(m, m.siteName, []FileInfo{file}))
+		}
+	case "a", "A":
+		// With a filter on, only the files shown are downloaded.
+		files := filteredFiles(*m)
+		if len(files) > 0 && m.dryRun {
+			return m, showPlan(m, "Dry run: download "+m.filter.String()+" of "+m.siteName, planDownloads(m.siteName, files, m.onConflict))
+		}
+		if len(files) > 0 {
+			return m, queueDownloads(m, m.siteName, files)
+		}
+	case "x", "X", "delete":
+		if file, ok := selectedFile(*m); ok {
+			if m.dryRun {
+				return m, showPlan(m, "Dry run: delete", dryRunDelete(m.siteName, file))
+			}
+			askConfirm(m, "Delete "+file.FileName+"?", "It is removed from "+m.siteName+" for everyone.",
+				func(m *Model) tea.Cmd { return api.deleteFile(m.siteName, file.ID, file.FileName) })
 		}
 	case "esc":
-		m.state = stateMenu
-		m.selectedIdx = 0
+		m.goTo(stateMenu)
 	}
 	return m, nil
 }
 
 // renderMenu renders the menu UI.
-func renderMenu(cursor int) string {
-	menuItems := []string{
-		"📂  Access Existing Site",
-		"✨  Create New Site",
-		"🚪  Exit Application",
-	}
+func renderMenu(cursor int, account string) string {
 	var menu strings.Builder
 
 	menu.WriteString("Main Menu\n")
@@ -435,6 +1531,9 @@ func renderMenu(cursor int) string {
 	menu.WriteString("\n\n")
 
 	for i, item := range menuItems {
+		if i == menuSignIn && account != "" {
+			item = "🔓  Sign Out (" + account + ")"
+		}
 		if i == cursor {
 			menu.WriteString(selectedStyle.Render("➜  " + item))
 		} else {
@@ -447,23 +1546,47 @@ func renderMenu(cursor int) string {
 }
 
 // fetchFiles fetches files from the server and stores the auth token.
-func fetchFiles(siteName, password string) tea.Cmd {
+// totpCode is sent for sites with two-factor auth and is empty otherwise.
+func fetchFiles(siteName string, password, totpCode secret) tea.Cmd {
 	return func() tea.Msg {
-		url := fmt.Sprintf("https://filesharingcli-production.up.railway.app/site/%s?password=%s", siteName, password)
-		resp, err := http.Get(url)
+		call, err := newSiteCall(loginServerURL, siteName, password, totpCode)
 		if err != nil {
+			return fmt.Errorf("error creating request: %v", err)
+		}
+		// Large sites can stream their listing as NDJSON.
+		call.req.Header.Set("Accept", "application/x-ndjson, application/json")
+
+		resp, err := call.do()
+		if err != nil {
+			call.close()
+			if cached, cacheErr := offlineListing(siteName, password); cacheErr == nil {
+				return cached
+			}
 			return fmt.Errorf("error connecting to server: %v", err)
 		}
+
+		if resp.StatusCode == http.StatusOK && isNDJSON(resp) {
+			// The stream takes over the call and the response body.
+			return streamListing(call, resp)
+		}
+		defer call.close()
 		defer resp.Body.Close()
 
+		if resp.StatusCode == http.StatusUnauthorized && resp.Header.Get("X-TOTP-Required") == "true" {
+			if totpCode != "" {
+				return fmt.Errorf("invalid authentication code")
+			}
+			return totpRequiredMsg{}
+		}
+
 		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			return fmt.Errorf("failed to fetch site: %s (status code: %d)", string(body), resp.StatusCode)
+			return call.fail(resp, "failed to fetch site")
 		}
 
 		var result struct {
-			AuthToken string     `json:"auth_token"`
-			Files     []FileInfo `json:"files"`
+			AuthToken string          `json:"auth_token"`
+			Files     json.RawMessage `json:"files"`
+			ExpiresAt time.Time       `json:"expires_at"`
 		}
 
 		body, err := io.ReadAll(resp.Body)
@@ -474,54 +1597,49 @@ func fetchFiles(siteName, password string) tea.Cmd {
 		if err := json.Unmarshal(body, &result); err != nil {
 			return fmt.Errorf("error parsing server response: %v", err)
 		}
-
-		// Store auth token in .env file
-		err = godotenv.Load()
+		files, err := decodeFiles(responseAPIVersion(resp), result.Files)
 		if err != nil {
-			// If .env doesn't exist, create it
-			f, err := os.Create(".env")
-			if err != nil {
-				return fmt.Errorf("error creating .env file: %v", err)
-			}
-			f.Close()
+			return fmt.Errorf("error parsing server response: %v", err)
 		}
-		
-		err = os.Setenv("auth_token", result.AuthToken)
-		if err != nil {
-			return fmt.Errorf("error saving auth token: %v", err)
+
+		if err := storeAuthToken(result.AuthToken); err != nil {
+			return err
 		}
 
 		// Return empty slice if no files, don't return error
-		return result.Files
+		return siteLoadedMsg{files: files, expiresAt: result.ExpiresAt}
 	}
 }
 
-// createSite creates a new site on the server.
-func createSite(siteName, password string) tea.Cmd {
+// createSite creates a new site on the server, optionally with TOTP
+// two-factor auth enabled. A zero ttl creates a site that never expires.
+func createSite(siteName string, password secret, enableTOTP bool, ttl time.Duration) tea.Cmd {
 	return func() tea.Msg {
 		// Prepare request data
-		data := map[string]string{
-			"site_name": siteName,
-			"password": password,
+		data := map[string]interface{}{
+			"site_name":   siteName,
+			"password":    password.reveal(),
+			"enable_totp": enableTOTP,
+			"expires_in":  int(ttl.Seconds()),
 		}
-		
+
 		jsonData, err := json.Marshal(data)
 		if err != nil {
 			return fmt.Errorf("error preparing request: %v", err)
 		}
 
 		// Create request
-		req, err := http.NewRequest("POST", "https://filesharingcli-production.up.railway.app/createsite", bytes.NewBuffer(jsonData))
+		call, err := newAPICall(opMetadata, "POST", loginServerURL+"/createsite", bytes.NewBuffer(jsonData))
 		if err != nil {
 			return fmt.Errorf("error creating request: %v", err)
 		}
+		defer call.close()
 
 		// Set headers
-		req.Header.Set("Content-Type", "application/json")
+		call.req.Header.Set("Content-Type", "application/json")
 
 		// Send request
-		client := &http.Client{}
-		resp, err := client.Do(req)
+		resp, err := call.do()
 		if err != nil {
 			return fmt.Errorf("error connecting to server: %v", err)
 		}
@@ -535,13 +1653,14 @@ func createSite(siteName, password string) tea.Cmd {
 
 		// Check response status
 		if resp.StatusCode != http.StatusCreated {
-			return fmt.Errorf("failed to create site: %s", string(body))
+			return call.failWith(resp, body, "failed to create site")
 		}
 
 		// Parse response
 		var result struct {
 			Message    string `json:"message"`
-			AuthToken string `json:"auth_token"`
+			AuthToken  string `json:"auth_token"`
+			OTPAuthURL string `json:"otpauth_url"`
 		}
 
 		if err := json.Unmarshal(body, &result); err != nil {
@@ -549,215 +1668,417 @@ func createSite(siteName, password string) tea.Cmd {
 		}
 
 		// Save auth token to .env file
-		f, err := os.Create(".env")
-		if err != nil {
-			return fmt.Errorf("error creating .env file: %v", err)
-		}
-		defer f.Close()
-
-		_, err = f.WriteString(fmt.Sprintf("auth_token=%s\n", result.AuthToken))
-		if err != nil {
+		if err := saveEnvValue("auth_token", result.AuthToken); err != nil {
 			return fmt.Errorf("error writing auth token: %v", err)
 		}
 
-		return "Success: Site created successfully!"
+		if result.OTPAuthURL != "" {
+			return totpSetupMsg{otpauthURL: result.OTPAuthURL}
+		}
+		return siteCreatedMsg{}
 	}
 }
 
-// downloadFile fetches the selected file from the server.
-func downloadFile(fileID int, fileName string) tea.Cmd {
+// downloadFile fetches the selected file from the server and saves it to
+// downloadPath. A fresh copy in the pinned-file cache is used instead when
+// there is one, and a stale copy is used when the server can't be reached.
+func downloadFile(siteName string, fileID int, fileName, downloadPath string) tea.Cmd {
 	return func() tea.Msg {
-		// Load auth token from .env file
-		err := godotenv.Load()
+		cached := cachePath(siteName, fileID, fileName)
+		exists, fresh := cacheStatus(cached)
+		source := ""
+		var err error
+		if fresh {
+			err = copyFile(cached, downloadPath)
+		} else {
+			err = saveFile(downloadPath, func(w io.Writer) (int64, error) {
+				return fetchFile(siteName, fileID, fileName, w)
+			})
+			if err != nil && exists && copyFile(cached, downloadPath) == nil {
+				err = nil
+				source = " (offline copy)"
+			}
+		}
 		if err != nil {
-			return fmt.Errorf("error loading .env file: %v", err)
+			return err
 		}
 
-		authToken := os.Getenv("auth_token")
-		if authToken == "" {
-			return fmt.Errorf("auth token is missing")
+		hookNote := extractNote(downloadPath) + runHook(hookPostDownload, downloadPath, siteName)
+		return downloadDoneMsg{
+			path:   downloadPath,
+			status: success(fmt.Sprintf("File downloaded to %s%s (o - Open • O - Show in Folder)%s", downloadPath, source, hookNote)),
 		}
+	}
+}
 
-		// Create the download request
-		url := fmt.Sprintf("http://localhost:8080/getfile/%d", fileID)
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return fmt.Errorf("error creating request: %v", err)
+// fileDeletedMsg reports that a file was removed from the open site.
+type fileDeletedMsg struct {
+	fileID   int
+	fileName string
+}
+
+// deleteFile removes a file from a site.
+func deleteFile(siteName string, fileID int, fileName string) tea.Cmd {
+	return func() tea.Msg {
+		endpoint := fmt.Sprintf("%s/site/%s/files/%d", serverURL, url.PathEscape(siteName), fileID)
+		if err := sendMemberRequest("DELETE", endpoint, nil); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", fileName, err)
 		}
+		return fileDeletedMsg{fileID: fileID, fileName: fileName}
+	}
+}
 
-		// Add authorization token to the request header
-		req.Header.Set("Authorization", authToken)
+// uploadFile uploads a file to the server as name, then confirms the
+// server stored the same bytes by comparing checksums. Mismatched uploads
+// are retried up to verifyRetries times.
+func uploadFile(m *Model, name string) tea.Cmd {
+	siteName, password, path := m.siteName, m.password, m.fileToUpload
+	return func() tea.Msg {
+		if path == "" {
+			return fmt.Errorf("no file selected")
+		}
+		// A pasted clipboard image goes with its folder however the upload
+		// ends, unless it waits in the offline queue, which removes it once
+		// sent.
+		queued := false
+		if isClipboardImage(path) {
+			defer func() {
+				if !queued {
+					os.RemoveAll(filepath.Dir(path))
+				}
+			}()
+		}
 
-		// Send the request
-		client := &http.Client{}
-		resp, err := client.Do(req)
+		sig, cleanup, err := signUpload(path)
 		if err != nil {
-			return fmt.Errorf("error downloading file: %v", err)
+			return err
 		}
-		defer resp.Body.Close()
+		defer cleanup()
 
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			return fmt.Errorf("failed to download file: %s", string(body))
+		result, err := api.upload(siteName, password, path, name)
+		if err != nil {
+			if checkHealth(serverURL).err != nil {
+				queued = true
+				return offlineQueueMsg{site: siteName, paths: []string{path}}
+			}
+			return err
 		}
-
-		// Parse the response
-		var result struct {
-			Message string `json:"message"`
-			File    string `json:"file"`
+		if sig != "" {
+			if err := postSignature(siteName, sig, name); err != nil {
+				result.status = notice(err.Error())
+			} else if files, err := fetchFilesDirectly(siteName, password); err == nil {
+				result.files = files
+				result.status.text += " (signed)"
+			}
 		}
+		result.status.text += runHook(hookPostUpload, path, siteName)
+		return result
+	}
+}
 
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return fmt.Errorf("error parsing response: %v", err)
+// uploadAndVerify uploads path as name, refreshes the listing and checks
+// the server's checksum, resending on mismatch up to verifyRetries times.
+func uploadAndVerify(siteName string, password secret, path, name string) (uploadedMsg, error) {
+	var result uploadedMsg
+	for attempt := 0; attempt <= verifyRetries(); attempt++ {
+		localSum, err := sendUpload(siteName, path, name, attempt)
+		if err != nil {
+			return result, err
 		}
 
-		// Create downloads directory if it doesn't exist
-		err = os.MkdirAll("downloads", 0755)
+		// After successful upload, refresh the file list
+		files, err := fetchFilesDirectly(siteName, password)
 		if err != nil {
-			return fmt.Errorf("error creating downloads directory: %v", err)
+			return result, fmt.Errorf("file uploaded but error refreshing list: %v", err)
 		}
+		result = uploadedMsg{files: files, fileID: -1}
 
-		// Save the file
-		downloadPath := filepath.Join("downloads", fileName)
-		err = os.WriteFile(downloadPath, []byte(result.File), 0644)
+		fileID, ok := findUploadedFile(files, name)
+		if !ok {
+			result.status = notice("File uploaded, but it could not be verified: not found in the site listing")
+			return result, nil
+		}
+		result.fileID = fileID
+
+		remoteSum, err := fetchRemoteChecksum(fileID)
 		if err != nil {
-			return fmt.Errorf("error saving file: %v", err)
+			result.status = notice(fmt.Sprintf("File uploaded, but it could not be verified: %v", err))
+			return result, nil
+		}
+		if remoteSum == localSum {
+			result.verified = true
+			result.status = success("File uploaded and verified!")
+			return result, nil
 		}
+	}
+	result.status = notice("Upload checksum mismatch: the server's copy differs from the local file")
+	return result, nil
+}
 
-		return fmt.Sprintf("Success: File downloaded to %s", downloadPath)
+// sendUpload posts a file to the site as name and returns the SHA-256 of
+// the bytes that were sent. attempt counts earlier tries of the same
+// upload.
+func sendUpload(siteName, path, name string, attempt int) (string, error) {
+	hash := sha256.New()
+	if err := postFile(siteName, path, name, hash, attempt); err != nil {
+		return "", err
 	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
-// uploadFile uploads a file to the server.
-func uploadFile(m *Model) tea.Cmd {
-	return func() tea.Msg {
-		if m.fileToUpload == "" {
-			return fmt.Errorf("no file selected")
+// postFile posts a file to the site under name, copying the bytes sent
+// into hash, and records the transfer's statistics. Large files go up in
+// parallel parts when the server supports it, and when the site already
+// has a copy and the server lists delta uploads, only what changed is
+// sent.
+func postFile(siteName, path, name string, hash io.Writer, retries int) (err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("error opening file: %v", err)
+	}
+	if err := checkUpload(name, info.Size()); err != nil {
+		return err
+	}
+	if info.Size() >= deltaThreshold && advertises(featureDelta) {
+		err := postDelta(siteName, path, name, hash, retries)
+		if !errors.Is(err, errDeltaUnavailable) {
+			return err
 		}
-
-		file, err := os.Open(m.fileToUpload)
-		if err != nil {
-			return fmt.Errorf("error opening file: %v", err)
+	}
+	if info.Size() >= multipartThreshold && supports(featureMultipart) {
+		err := postMultipart(siteName, path, name, info.Size(), hash, retries)
+		if !errors.Is(err, errMultipartUnsupported) {
+			return err
 		}
-		defer file.Close()
+	}
 
-		// Create multipart form
-		body := &bytes.Buffer{}
-		writer := multipart.NewWriter(body)
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
 
-		// Add file to form
-		part, err := writer.CreateFormFile("file", filepath.Base(m.fileToUpload))
-		if err != nil {
-			return fmt.Errorf("error creating form file: %v", err)
-		}
+	// Create multipart form
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
 
-		_, err = io.Copy(part, file)
-		if err != nil {
-			return fmt.Errorf("error copying file content: %v", err)
-		}
+	// Add file to form, typed so the server can store its content type
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{"name": "file", "filename": name}))
+	header.Set("Content-Type", detectContentType(path))
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return fmt.Errorf("error creating form file: %v", err)
+	}
 
-		err = writer.Close()
-		if err != nil {
-			return fmt.Errorf("error closing writer: %v", err)
-		}
+	_, err = io.Copy(part, io.TeeReader(file, hash))
+	if err != nil {
+		return fmt.Errorf("error copying file content: %v", err)
+	}
 
-		// Create request
-		url := fmt.Sprintf("http://localhost:8080/upload/%s", m.siteName)
-		req, err := http.NewRequest("POST", url, body)
-		if err != nil {
-			return fmt.Errorf("error creating request: %v", err)
-		}
+	err = writer.Close()
+	if err != nil {
+		return fmt.Errorf("error closing writer: %v", err)
+	}
+	body, encoding := gzipBody(body)
 
-		// Load auth token
-		err = godotenv.Load()
-		if err != nil {
-			return fmt.Errorf("error loading .env file: %v", err)
-		}
+	// Create request
+	url := fmt.Sprintf("%s/upload/%s", serverURL, siteName)
+	call, err := newAPICall(opTransfer, "POST", url, body)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
 
-		authToken := os.Getenv("auth_token")
-		if authToken == "" {
-			return fmt.Errorf("auth token is missing")
-		}
+	// Load auth token
+	authToken, err := loadAuthToken()
+	if err != nil {
+		return err
+	}
 
-		// Set headers
-		req.Header.Set("Content-Type", writer.FormDataContentType())
-		req.Header.Set("Authorization", authToken)
+	// Set headers
+	call.req.Header.Set("Content-Type", writer.FormDataContentType())
+	call.req.Header.Set("Authorization", authToken.reveal())
+	if encoding != "" {
+		call.req.Header.Set("Content-Encoding", encoding)
+	}
 
-		// Send request
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			return fmt.Errorf("error uploading file: %v", err)
-		}
-		defer resp.Body.Close()
+	stat := newTransferStat(directionUpload, url, siteName, name, retries)
+	size := int64(body.Len())
+	call.live = stat.live
+	call.live.setTotal(size)
+	defer func() { stat.finish(size, err) }()
 
-		if resp.StatusCode != http.StatusOK {
-			bodyBytes, _ := io.ReadAll(resp.Body)
-			return fmt.Errorf("failed to upload file: %s", string(bodyBytes))
-		}
+	// Send request
+	resp, err := call.do()
+	if err != nil {
+		return fmt.Errorf("error uploading file: %v", err)
+	}
+	defer resp.Body.Close()
 
-		// After successful upload, refresh the file list
-		files, err := fetchFilesDirectly(m.siteName, m.password)
-		if err != nil {
-			return fmt.Errorf("file uploaded but error refreshing list: %v", err)
-		}
-		m.files = files
-		return "Success: File uploaded successfully!"
+	if resp.StatusCode != http.StatusOK {
+		return call.fail(resp, "failed to upload file")
 	}
+
+	return nil
 }
 
 // Add helper function to fetch files directly
-func fetchFilesDirectly(siteName, password string) ([]FileInfo, error) {
-	url := fmt.Sprintf("http://localhost:8080/site/%s?password=%s", siteName, password)
-	resp, err := http.Get(url)
+func fetchFilesDirectly(siteName string, password secret) ([]FileInfo, error) {
+	files, _, err := refreshFiles(siteName, password)
+	return files, err
+}
+
+// refreshFiles fetches a site's listing, conditionally when the cached
+// listing has an ETag or Last-Modified date: if the server answers 304 Not
+// Modified the cached files are returned and unchanged is set.
+func refreshFiles(siteName string, password secret) (files []FileInfo, unchanged bool, err error) {
+	if store, ok := storeFor(siteName); ok {
+		files, err := store.list(siteName)
+		return files, false, err
+	}
+	call, err := newSiteCall(serverURL, siteName, password, "")
 	if err != nil {
-		return nil, fmt.Errorf("error connecting to server: %v", err)
+		return nil, false, fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+
+	// The session token stands in for the TOTP code on sites with 2FA.
+	if authToken, err := loadAuthToken(); err == nil {
+		call.req.Header.Set("Authorization", authToken.reveal())
+	}
+	cached, cacheErr := readListing(siteName)
+	if cacheErr == nil {
+		if cached.ETag != "" {
+			call.req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			call.req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := call.do()
+	if err != nil {
+		return nil, false, fmt.Errorf("error connecting to server: %v", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && cacheErr == nil {
+		return cached.Files, true, nil
+	}
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to fetch site: %s", string(body))
+		return nil, false, call.fail(resp, "failed to fetch site")
 	}
 
 	var result struct {
-		AuthToken string     `json:"auth_token"`
-		Files     []FileInfo `json:"files"`
+		AuthToken string          `json:"auth_token"`
+		Files     json.RawMessage `json:"files"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("error parsing response: %v", err)
+		return nil, false, fmt.Errorf("error parsing response: %v", err)
+	}
+	files, err = decodeFiles(responseAPIVersion(resp), result.Files)
+	if err != nil {
+		return nil, false, fmt.Errorf("error parsing response: %v", err)
 	}
 
-	return result.Files, nil
+	if etag, modified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"); etag != "" || modified != "" {
+		c := newCachedListing(files, cached.ExpiresAt)
+		c.ETag, c.LastModified = etag, modified
+		writeListing(siteName, c)
+	}
+	return files, false, nil
 }
 
-// Update openFileDialog to use dialog package
-func openFileDialog() tea.Msg {
-	filename, err := dialog.File().Load()
-	if err != nil {
-		if err == dialog.Cancelled {
-			return fileSelectMsg{path: "", err: nil}
+// storeAuthToken makes a site's auth token available to later requests.
+func storeAuthToken(authToken string) error {
+	// .env is only read at startup: loading it again would bring back
+	// tokens cleared since, and .env's in place of CSHARE_TOKEN's.
+	if _, err := os.Stat(".env"); os.IsNotExist(err) {
+		f, err := os.Create(".env")
+		if err != nil {
+			return fmt.Errorf("error creating .env file: %v", err)
 		}
-		return fileSelectMsg{path: "", err: err}
+		f.Close()
 	}
-	return fileSelectMsg{path: filename, err: nil}
+
+	err := os.Setenv("auth_token", authToken)
+	if err != nil {
+		return fmt.Errorf("error saving auth token: %v", err)
+	}
+	siteLocked.Store(false)
+	return nil
+}
+
+// loadAuthToken returns the auth token of the last site login, or the one
+// given as CSHARE_TOKEN or in .env. It only looks at the environment, which
+// main loads .env into once, so guards can call it from Update.
+func loadAuthToken() (secret, error) {
+	if siteLocked.Load() {
+		return "", fmt.Errorf("the session is locked: open the site again")
+	}
+	authToken := os.Getenv("auth_token")
+	if authToken == "" {
+		return "", fmt.Errorf("auth token is missing")
+	}
+	return secret(authToken), nil
 }
 
 // Update renderFileList function
 func renderFileList(m Model) string {
 	var files strings.Builder
 	if len(m.files) == 0 {
+		if m.listStream != nil {
+			return "Loading files..."
+		}
 		return "No files found. Press U to upload a file."
 	}
+	rows := fileRows(m)
+	if len(rows) == 0 {
+		return fmt.Sprintf("No %s files. Press F to change the filter.", m.filter)
+	}
 
-	for i, file := range m.files {
+	// Rows are cut to the list's width: a long name would wrap and shift
+	// every row below it. The details pane narrows the list.
+	width := fileListStyle.GetWidth() - 4
+	if m.showDetails {
+		width -= detailsWidth + 2
+	}
+	end := min(m.fileOffset+visibleFiles, len(rows))
+	for i := m.fileOffset; i < end; i++ {
+		row := rows[i]
 		prefix := "   "
 		if i == m.selectedIdx {
 			prefix = "➜  "
-			files.WriteString(selectedStyle.Render(prefix + file.FileName))
+		}
+		if row.header {
+			line := prefix + renderSectionHeader(row, m.collapsed[row.kind])
+			if i == m.selectedIdx {
+				line = selectedStyle.Render(line)
+			}
+			files.WriteString(line + "\n")
+			continue
+		}
+		file := row.file
+		if m.groupByType {
+			prefix += "  "
+		}
+		mark := ""
+		if m.verified[file.ID] {
+			mark = " ✓"
+		}
+		mark += scanMarks[file.ScanStatus] + signatureMark(m, file.ID)
+		room := width - lipgloss.Width(prefix) - lipgloss.Width(kindIcons[kindOf(file.FileName, file.ContentType)]) - 1
+		name := fitName(file.FileName, room-lipgloss.Width(mark)) + mark
+		if i == m.selectedIdx {
+			files.WriteString(selectedStyle.Render(prefix + renderFileName(file, name, true)))
 		} else {
-			files.WriteString(prefix + file.FileName)
+			files.WriteString(prefix + renderFileName(file, name, false))
+		}
+		if !m.showDetails {
+			// The details pane lists the tags instead.
+			files.WriteString(renderTags(file.Tags, room-lipgloss.Width(name)))
 		}
 		files.WriteString("\n")
 	}
@@ -770,7 +2091,21 @@ func getStatusText(m Model) string {
 	case stateMenu:
 		return "Use ↑/↓ to navigate, Enter to select"
 	case stateViewFiles:
-		return fmt.Sprintf("Files: %d | Site: %s", len(m.files), m.siteName)
+		if m.listStream != nil {
+			return "Loading... " + renderListingProgress(m.listLoaded, m.listTotal)
+		}
+		status := fmt.Sprintf("Files: %d", len(m.files))
+		if rows := len(fileRows(m)); rows > visibleFiles {
+			status = fmt.Sprintf("Rows: %d-%d of %d", m.fileOffset+1, min(m.fileOffset+visibleFiles, rows), rows)
+		}
+		if m.filter != (fileFilter{}) {
+			status += fmt.Sprintf(" | %s: %d", m.filter, len(filteredFiles(m)))
+		}
+		return status
+	case stateMembers:
+		return fmt.Sprintf("Members: %d", len(m.members))
+	case stateMySites:
+		return fmt.Sprintf("Sites: %d | Account: %s", len(m.mySites), m.account)
 	default:
 		return "FileShare CLI"
 	}
@@ -778,13 +2113,199 @@ func getStatusText(m Model) string {
 
 // main is the entry point of the application.
 func main() {
-	p := tea.NewProgram(
-		&Model{state: stateMenu},
+	// A missing .env is fine; it is created on first login.
+	_ = godotenv.Load()
+	initTokenOverrides()
+	if err := initConfigFile(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	initBandwidth()
+	if err := initHeaders(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := initPins(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	// From here on Update reads the settings from memory.
+	currentConfig()
+
+	offline := loadOfflineQueue()
+	model := &Model{state: stateMenu, account: os.Getenv("account_name"), offline: offline, savedOffline: offline, lastInput: time.Now()}
+
+	args, policy, err := conflictArgs(os.Args[1:])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	model.onConflict = policy
+
+	args, model.excludes, err = excludeArgs(args)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	args, model.dryRun = dryRunArgs(args)
+
+	args, uploadAs, err := nameArgs(args)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	args, plain := plainArgs(args)
+	if plain {
+		enablePlainMode()
+	} else {
+		detectBackground()
+	}
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "alias":
+			if err := runAliasCommand(args[1:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "stats":
+			if err := runStatsCommand(args[1:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "ping":
+			if err := runPingCommand(args[1:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "history":
+			if err := runHistoryCommand(args[1:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "pin":
+			if err := runPinCommand(args[1:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "config":
+			if err := runConfigCommand(args[1:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "backup":
+			if err := runBackupCommand(args[1:], model.excludes, model.dryRun); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "restore":
+			if err := runRestoreCommand(args[1:], model.dryRun); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "host":
+			if err := runHostCommand(args[1:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "audit":
+			if err := runAuditCommand(args[1:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "whoami":
+			if err := runWhoamiCommand(args[1:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "logout":
+			if err := runLogoutCommand(args[1:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		default:
+			if paths := expandGlobs(args); pathArgs(paths) {
+				if model.dryRun {
+					if err := runDryRunUpload(paths, model.excludes); err != nil {
+						fmt.Printf("Error: %v\n", err)
+						os.Exit(1)
+					}
+					return
+				}
+				// `cshare <path>...` (or files dropped onto the binary)
+				// goes straight to uploading them, logging in first only
+				// when no site session is cached.
+				model.pendingUploads = paths
+				if uploadAs != "" {
+					if err := checkNameTarget(paths); err != nil {
+						fmt.Printf("Error: %v\n", err)
+						os.Exit(1)
+					}
+					model.pendingUploads = nil
+					model.fileToUpload, model.uploadAs = paths[0], uploadAs
+				}
+				if site, ok := cachedSite(); ok {
+					model.siteName = site
+					model.state = stateUploadFile
+				} else {
+					model.state = stateSiteName
+					model.status = notice(fmt.Sprintf("Log in to a site to upload %d item(s)", len(paths)))
+				}
+				break
+			}
+			// `cshare <site>` jumps straight to the password prompt.
+			model.siteName = resolveSite(args[0])
+			model.state = statePassword
+		}
+	}
+
+	if uploadAs != "" && model.fileToUpload == "" {
+		fmt.Println("Error: --name needs a file to upload, e.g. cshare --name report.pdf draft.pdf")
+		os.Exit(1)
+	}
+
+	// With no arguments, open CSHARE_SITE, or else offer to pick up where
+	// the last session left off.
+	if len(args) == 0 {
+		if site := os.Getenv("CSHARE_SITE"); site != "" {
+			model.siteName = resolveSite(site)
+			model.state = statePassword
+		} else if s, ok := loadSession(); ok {
+			offerRestore(model, s)
+		}
+	}
+
+	if err := startMetrics(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	program = tea.NewProgram(
+		model,
 		tea.WithAltScreen(),       // Use alternate screen
 		tea.WithMouseCellMotion(), // Enables mouse support
 	)
-	
-	if err := p.Start(); err != nil {
+
+	_, err = program.Run()
+	if crash.value != nil {
+		reportCrash()
+		os.Exit(2)
+	}
+	if err != nil {
 		fmt.Printf("Error: %v", err)
 		os.Exit(1)
 	}
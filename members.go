@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Site roles understood by the member-management endpoints.
+const (
+	roleOwner    = "owner"
+	roleUploader = "uploader"
+	roleViewer   = "viewer"
+)
+
+// inviteRoles are the roles the owner can hand out, in Tab order.
+var inviteRoles = []string{roleUploader, roleViewer}
+
+// Member is a user with access to a site.
+type Member struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// membersMsg carries a freshly fetched member list and an optional status line.
+type membersMsg struct {
+	members []Member
+	status  statusLine
+}
+
+// handleMembersInput handles input on the site members screen.
+func handleMembersInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up":
+		if m.memberIdx > 0 {
+			m.memberIdx--
+		}
+	case "down":
+		if m.memberIdx < len(m.members)-1 {
+			m.memberIdx++
+		}
+	case "i", "I":
+		m.goTo(stateInviteMember)
+	case "d", "D", "delete":
+		if m.memberIdx >= 0 && m.memberIdx < len(m.members) {
+			member := m.members[m.memberIdx]
+			if member.Role == roleOwner {
+				m.status = notice("The site owner cannot be removed")
+				return m, nil
+			}
+			return m, revokeMember(m.siteName, member.Username)
+		}
+	case "r", "R":
+		return m, fetchMembers(m.siteName)
+	case "esc":
+		m.goTo(stateViewFiles)
+		m.memberIdx = 0
+	}
+	return m, nil
+}
+
+// handleInviteMemberInput handles input in the inviteMember state.
+func handleInviteMemberInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		if m.inviteUser == "" {
+			return m, nil
+		}
+		return m, inviteMember(m.siteName, m.inviteUser, m.inviteRole)
+	case "tab":
+		for i, role := range inviteRoles {
+			if role == m.inviteRole {
+				m.inviteRole = inviteRoles[(i+1)%len(inviteRoles)]
+				break
+			}
+		}
+	case "esc":
+		m.goTo(stateMembers)
+	case "backspace":
+		m.inviteUser = deleteLastChar(m.inviteUser)
+	default:
+		m.inviteUser += typedText(msg)
+	}
+	return m, nil
+}
+
+// renderMemberList renders the members of the current site with their roles.
+func renderMemberList(m Model) string {
+	if len(m.members) == 0 {
+		return "No members yet. Press I to invite a collaborator."
+	}
+
+	var list strings.Builder
+	for i, member := range m.members {
+		line := fmt.Sprintf("%-40s %s", member.Username, member.Role)
+		if i == m.memberIdx {
+			list.WriteString(selectedStyle.Render("➜  " + line))
+		} else {
+			list.WriteString("   " + line)
+		}
+		list.WriteString("\n")
+	}
+	return list.String()
+}
+
+// renderRolePicker renders the role choices, highlighting the selected one.
+func renderRolePicker(selected string) string {
+	var roles []string
+	for _, role := range inviteRoles {
+		if role == selected {
+			roles = append(roles, selectedStyle.Render("["+role+"]"))
+		} else {
+			roles = append(roles, " "+role+" ")
+		}
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, roles...)
+}
+
+// fetchMembers loads the member list of a site.
+func fetchMembers(siteName string) tea.Cmd {
+	return func() tea.Msg {
+		members, err := fetchMembersDirectly(siteName)
+		if err != nil {
+			return err
+		}
+		return membersMsg{members: members}
+	}
+}
+
+// inviteMember grants a user access to a site with the given role.
+func inviteMember(siteName, username, role string) tea.Cmd {
+	return func() tea.Msg {
+		jsonData, err := json.Marshal(Member{Username: username, Role: role})
+		if err != nil {
+			return fmt.Errorf("error preparing request: %v", err)
+		}
+
+		endpoint := fmt.Sprintf("%s/site/%s/members", serverURL, url.PathEscape(siteName))
+		if err := sendMemberRequest("POST", endpoint, bytes.NewBuffer(jsonData)); err != nil {
+			return fmt.Errorf("failed to invite member: %w", err)
+		}
+
+		members, err := fetchMembersDirectly(siteName)
+		if err != nil {
+			return fmt.Errorf("member invited but error refreshing list: %v", err)
+		}
+		return membersMsg{
+			members: members,
+			status:  success(fmt.Sprintf("Invited %s as %s", username, role)),
+		}
+	}
+}
+
+// revokeMember removes a user's access to a site.
+func revokeMember(siteName, username string) tea.Cmd {
+	return func() tea.Msg {
+		endpoint := fmt.Sprintf("%s/site/%s/members/%s", serverURL, url.PathEscape(siteName), url.PathEscape(username))
+		if err := sendMemberRequest("DELETE", endpoint, nil); err != nil {
+			return fmt.Errorf("failed to revoke access: %w", err)
+		}
+
+		members, err := fetchMembersDirectly(siteName)
+		if err != nil {
+			return fmt.Errorf("access revoked but error refreshing list: %v", err)
+		}
+		return membersMsg{
+			members: members,
+			status:  success(fmt.Sprintf("Revoked access for %s", username)),
+		}
+	}
+}
+
+// fetchMembersDirectly requests the member list of a site.
+func fetchMembersDirectly(siteName string) ([]Member, error) {
+	authToken, err := loadAuthToken()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := fmt.Sprintf("%s/site/%s/members", serverURL, url.PathEscape(siteName))
+	call, err := newAPICall(opMetadata, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+	call.req.Header.Set("Authorization", authToken.reveal())
+
+	resp, err := call.do()
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, call.fail(resp, "failed to fetch members")
+	}
+
+	var result struct {
+		Members []Member `json:"members"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+	return result.Members, nil
+}
+
+// sendMemberRequest sends an authorized member-management request and
+// returns the server's message on failure.
+func sendMemberRequest(method, endpoint string, body io.Reader) error {
+	authToken, err := loadAuthToken()
+	if err != nil {
+		return err
+	}
+
+	call, err := newAPICall(opMetadata, method, endpoint, body)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+	call.req.Header.Set("Content-Type", "application/json")
+	call.req.Header.Set("Authorization", authToken.reveal())
+
+	resp, err := call.do()
+	if err != nil {
+		return fmt.Errorf("error connecting to server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		return call.fail(resp, "")
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+)
+
+// transferCounts tallies finished transfers of one direction for the
+// metrics endpoint.
+type transferCounts struct {
+	ok, failed, bytes, retries atomic.Int64
+}
+
+// transferMetrics are the counters behind /metrics, by direction.
+var transferMetrics = map[string]*transferCounts{
+	directionUpload:   {},
+	directionDownload: {},
+}
+
+// queueDepths are the transfers waiting in each queue, as last seen by the
+// status tick: "downloads" queued one after another, "uploads" left in a
+// running batch and "offline" waiting for the server to come back.
+var queueDepths struct {
+	downloads, uploads, offline atomic.Int64
+}
+
+// countTransfer adds a finished transfer to the metrics.
+func countTransfer(s TransferStat) {
+	counts, ok := transferMetrics[s.Direction]
+	if !ok {
+		return
+	}
+	if s.OK {
+		counts.ok.Add(1)
+		counts.bytes.Add(s.Bytes)
+	} else {
+		counts.failed.Add(1)
+	}
+	counts.retries.Add(int64(s.Retries))
+}
+
+// sampleQueues records the queue depths for the metrics endpoint.
+func sampleQueues(m *Model) {
+	queueDepths.downloads.Store(int64(len(m.downloads.items)))
+	uploads := 0
+	if m.batchStream != nil {
+		uploads = m.batchTotal - m.batchUploaded - len(m.batchFailed)
+	}
+	queueDepths.uploads.Store(int64(max(uploads, 0)))
+	queueDepths.offline.Store(int64(len(m.offline)))
+}
+
+// activeTransfers counts the transfers still running.
+func activeTransfers() int {
+	liveTransfers.Lock()
+	defer liveTransfers.Unlock()
+	n := 0
+	for _, t := range liveTransfers.list {
+		if t.ended.IsZero() {
+			n++
+		}
+	}
+	return n
+}
+
+// writeMetrics writes the metrics in the Prometheus text format.
+func writeMetrics(w io.Writer) {
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	directions := []string{directionUpload, directionDownload}
+
+	metric("cshare_transfers_total", "counter", "Transfers finished, by direction and result.")
+	for _, direction := range directions {
+		counts := transferMetrics[direction]
+		fmt.Fprintf(w, "cshare_transfers_total{direction=%q,result=\"ok\"} %d\n", direction, counts.ok.Load())
+		fmt.Fprintf(w, "cshare_transfers_total{direction=%q,result=\"failed\"} %d\n", direction, counts.failed.Load())
+	}
+	metric("cshare_transferred_bytes_total", "counter", "Bytes of the transfers that finished, by direction.")
+	for _, direction := range directions {
+		fmt.Fprintf(w, "cshare_transferred_bytes_total{direction=%q} %d\n", direction, transferMetrics[direction].bytes.Load())
+	}
+	metric("cshare_transfer_retries_total", "counter", "Attempts resent after a failure, by direction.")
+	for _, direction := range directions {
+		fmt.Fprintf(w, "cshare_transfer_retries_total{direction=%q} %d\n", direction, transferMetrics[direction].retries.Load())
+	}
+	metric("cshare_moved_bytes_total", "counter", "Bytes moved so far, including transfers still running.")
+	fmt.Fprintf(w, "cshare_moved_bytes_total %d\n", transferredBytes.Load())
+	metric("cshare_active_transfers", "gauge", "Transfers running now.")
+	fmt.Fprintf(w, "cshare_active_transfers %d\n", activeTransfers())
+	metric("cshare_queue_depth", "gauge", "Transfers waiting, by queue.")
+	fmt.Fprintf(w, "cshare_queue_depth{queue=\"downloads\"} %d\n", queueDepths.downloads.Load())
+	fmt.Fprintf(w, "cshare_queue_depth{queue=\"uploads\"} %d\n", queueDepths.uploads.Load())
+	fmt.Fprintf(w, "cshare_queue_depth{queue=\"offline\"} %d\n", queueDepths.offline.Load())
+}
+
+// startMetrics serves /metrics on CSHARE_METRICS_ADDR, such as
+// 127.0.0.1:9464, for as long as cshare runs. Without it nothing listens.
+func startMetrics() error {
+	addr := os.Getenv("CSHARE_METRICS_ADDR")
+	if addr == "" {
+		return nil
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("error serving metrics on %s: %v", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeMetrics(w)
+	})
+	go func() {
+		defer recoverCrash()
+		_ = http.Serve(listener, mux)
+	}()
+	return nil
+}
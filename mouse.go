@@ -0,0 +1,120 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// visibleFiles is how many rows of the file list are shown at once; longer
+// lists scroll with the selection or the mouse wheel.
+const visibleFiles = 15
+
+// wheelStep is how many rows one wheel notch scrolls the file list.
+const wheelStep = 3
+
+// doubleClickWindow is the longest gap between two clicks on the same row
+// that still counts as a double-click.
+const doubleClickWindow = 400 * time.Millisecond
+
+// clickTarget remembers the last click so a second one can be recognized as
+// a double-click.
+type clickTarget struct {
+	row int
+	at  time.Time
+}
+
+// handleMouse moves the cursor on click, activates the row on double-click
+// and scrolls on the wheel. Only the menu and the file list react; rows are
+// located from the layout View recorded in listTop.
+func handleMouse(m *Model, msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	switch m.state {
+	case stateMenu, stateViewFiles:
+	default:
+		return m, nil
+	}
+
+	switch msg.Button {
+	case tea.MouseButtonWheelUp:
+		scrollList(m, -1)
+		return m, nil
+	case tea.MouseButtonWheelDown:
+		scrollList(m, 1)
+		return m, nil
+	case tea.MouseButtonLeft:
+		if msg.Action != tea.MouseActionPress {
+			return m, nil
+		}
+	default:
+		return m, nil
+	}
+
+	row := msg.Y - m.listTop
+	if row < 0 || row >= m.listRows {
+		return m, nil
+	}
+	double := m.lastClick.row == row && time.Since(m.lastClick.at) <= doubleClickWindow
+	m.lastClick = clickTarget{row: row, at: time.Now()}
+
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+	if m.state == stateMenu {
+		m.cursor = row
+		if double {
+			m.lastClick = clickTarget{}
+			return handleMenuInput(m, enter)
+		}
+		return m, nil
+	}
+	m.selectedIdx = m.fileOffset + row
+	if double {
+		m.lastClick = clickTarget{}
+		return handleFileSelection(m, enter)
+	}
+	return m, nil
+}
+
+// scrollList moves the menu cursor one row per notch, or scrolls the file
+// list by wheelStep rows, keeping the selection on screen.
+func scrollList(m *Model, dir int) {
+	if m.state == stateMenu {
+		if next := m.cursor + dir; next >= 0 && next < len(menuItems) {
+			m.cursor = next
+		}
+		return
+	}
+
+	m.fileOffset += dir * wheelStep
+	if last := len(fileRows(*m)) - visibleFiles; m.fileOffset > last {
+		m.fileOffset = last
+	}
+	if m.fileOffset < 0 {
+		m.fileOffset = 0
+	}
+	if m.selectedIdx < m.fileOffset {
+		m.selectedIdx = m.fileOffset
+	}
+	if last := m.fileOffset + visibleFiles - 1; m.selectedIdx > last {
+		m.selectedIdx = last
+	}
+}
+
+// keepSelectionVisible scrolls the file list just enough to show the
+// selected row, clamping the selection to the list first.
+func keepSelectionVisible(m *Model) {
+	rows := len(fileRows(*m))
+	if m.selectedIdx >= rows {
+		m.selectedIdx = rows - 1
+	}
+	if m.selectedIdx < 0 {
+		m.selectedIdx = 0
+	}
+	if last := rows - visibleFiles; m.fileOffset > last {
+		m.fileOffset = max(0, last)
+	}
+	if m.selectedIdx < m.fileOffset {
+		m.fileOffset = m.selectedIdx
+	}
+	if m.selectedIdx >= m.fileOffset+visibleFiles {
+		m.fileOffset = m.selectedIdx - visibleFiles + 1
+	}
+}
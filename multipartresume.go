@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// multipartJournalPath records the multipart uploads that are open on the
+// server and the parts of each that made it, so a large upload cut off by
+// a quit or crash carries on where it stopped instead of starting over.
+const multipartJournalPath = ".cshare-multipart.json"
+
+// multipartResumeAge is how long an open multipart upload is worth
+// resuming; the server expires abandoned uploads after a day.
+const multipartResumeAge = 24 * time.Hour
+
+// openMultipart is a multipart upload the server has started. The file's
+// size and modification time must still match for it to be resumed.
+type openMultipart struct {
+	Site     string       `json:"site"`
+	Path     string       `json:"path"`
+	Name     string       `json:"name"`
+	Size     int64        `json:"size"`
+	ModTime  time.Time    `json:"mod_time"`
+	UploadID string       `json:"upload_id"`
+	Parts    []uploadPart `json:"parts,omitempty"` // the parts sent so far
+	Started  time.Time    `json:"started"`
+}
+
+// multipartJournal guards the journal file, which the upload workers
+// update as their parts complete.
+type multipartJournal struct {
+	mu sync.Mutex
+}
+
+var openMultiparts = &multipartJournal{}
+
+func (u openMultipart) matches(site, path, name string, info os.FileInfo) bool {
+	return u.Site == site && u.Path == path && u.Name == name &&
+		u.Size == info.Size() && u.ModTime.Equal(info.ModTime()) &&
+		time.Since(u.Started) < multipartResumeAge
+}
+
+// load reads the journal. The caller holds mu.
+func (j *multipartJournal) load() []openMultipart {
+	data, err := os.ReadFile(multipartJournalPath)
+	if err != nil {
+		return nil
+	}
+	var uploads []openMultipart
+	_ = json.Unmarshal(data, &uploads)
+	return uploads
+}
+
+// save writes the journal, leaving out uploads too old to resume, and
+// removes it once it's empty. The caller holds mu. Saving is best effort:
+// without the journal an upload just starts over.
+func (j *multipartJournal) save(uploads []openMultipart) {
+	uploads = slices.DeleteFunc(uploads, func(u openMultipart) bool {
+		return time.Since(u.Started) >= multipartResumeAge
+	})
+	if len(uploads) == 0 {
+		_ = os.Remove(multipartJournalPath)
+		return
+	}
+	data, err := json.MarshalIndent(uploads, "", "  ")
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(multipartJournalPath, data, 0600)
+}
+
+// find returns the open upload of path to site as name, if it can be
+// resumed.
+func (j *multipartJournal) find(site, path, name string, info os.FileInfo) (openMultipart, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, u := range j.load() {
+		if u.matches(site, path, name, info) {
+			return u, true
+		}
+	}
+	return openMultipart{}, false
+}
+
+// start records a newly opened upload, replacing any older one of the same
+// file.
+func (j *multipartJournal) start(upload openMultipart) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	uploads := slices.DeleteFunc(j.load(), func(u openMultipart) bool {
+		return u.Site == upload.Site && u.Path == upload.Path && u.Name == upload.Name
+	})
+	j.save(append(uploads, upload))
+}
+
+// sent records a part of an upload as sent.
+func (j *multipartJournal) sent(uploadID string, part uploadPart) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	uploads := j.load()
+	for i := range uploads {
+		if uploads[i].UploadID == uploadID {
+			uploads[i].Parts = append(uploads[i].Parts, part)
+			j.save(uploads)
+			return
+		}
+	}
+}
+
+// drop forgets an upload once it is complete or given up.
+func (j *multipartJournal) drop(uploadID string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.save(slices.DeleteFunc(j.load(), func(u openMultipart) bool { return u.UploadID == uploadID }))
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// SiteInfo is a site the signed-in account owns or has been invited to.
+type SiteInfo struct {
+	Name string `json:"site_name"`
+	Role string `json:"role"`
+}
+
+// mySitesMsg carries the sites of the signed-in account.
+type mySitesMsg struct {
+	sites []SiteInfo
+}
+
+// handleMySitesInput handles input on the my sites screen.
+func handleMySitesInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up":
+		if m.siteIdx > 0 {
+			m.siteIdx--
+		}
+	case "down":
+		if m.siteIdx < len(m.mySites)-1 {
+			m.siteIdx++
+		}
+	case "enter":
+		if m.siteIdx >= 0 && m.siteIdx < len(m.mySites) {
+			// The account token authorizes the account's own sites, so no
+			// site password is needed.
+			m.siteName = m.mySites[m.siteIdx].Name
+			m.password = ""
+			return m, api.openSite(m.siteName, "", "")
+		}
+	case "r", "R":
+		return m, fetchMySites
+	case "esc":
+		m.goTo(stateMenu)
+	}
+	return m, nil
+}
+
+// renderMySites renders the account's sites with the account's role in each.
+func renderMySites(m Model) string {
+	if len(m.mySites) == 0 {
+		return "No sites yet. Create one from the main menu."
+	}
+
+	var list strings.Builder
+	for i, site := range m.mySites {
+		line := fmt.Sprintf("%-40s %s", site.Name, site.Role)
+		if i == m.siteIdx {
+			list.WriteString(selectedStyle.Render("➜  " + line))
+		} else {
+			list.WriteString("   " + line)
+		}
+		list.WriteString("\n")
+	}
+	return list.String()
+}
+
+// fetchMySites loads the sites associated with the signed-in account.
+func fetchMySites() tea.Msg {
+	if accountToken() == "" {
+		return fmt.Errorf("sign in to list your sites")
+	}
+
+	call, err := newAPICall(opMetadata, "GET", serverURL+"/account/sites", nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+
+	resp, err := call.do()
+	if err != nil {
+		return fmt.Errorf("error connecting to server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return call.fail(resp, "failed to fetch your sites")
+	}
+
+	var result struct {
+		Sites []SiteInfo `json:"sites"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("error parsing response: %v", err)
+	}
+	return mySitesMsg{sites: result.Sites}
+}
@@ -0,0 +1,42 @@
+//go:build nativedialog
+
+package main
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/sqweek/dialog"
+)
+
+// pickUpload opens the system's file or folder dialog. Building with
+// -tags nativedialog selects it over the built-in picker; it needs cgo and
+// GTK on Linux.
+func pickUpload(m *Model, folder bool) tea.Cmd {
+	if folder {
+		return openFolderDialog
+	}
+	return openFileDialog
+}
+
+// openFileDialog lets the user pick a file to upload.
+func openFileDialog() tea.Msg {
+	filename, err := dialog.File().Load()
+	if err != nil {
+		if err == dialog.Cancelled {
+			return fileSelectMsg{path: "", err: nil}
+		}
+		return fileSelectMsg{path: "", err: err}
+	}
+	return fileSelectMsg{path: filename, err: nil}
+}
+
+// openFolderDialog lets the user pick a folder to upload.
+func openFolderDialog() tea.Msg {
+	path, err := dialog.Directory().Browse()
+	if err != nil {
+		if err == dialog.Cancelled {
+			return folderSelectMsg{}
+		}
+		return folderSelectMsg{err: err}
+	}
+	return folderSelectMsg{path: path}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// offlinePath holds transfers queued while the server was unreachable.
+const offlinePath = ".cshare-offline.json"
+
+// offlineItem is an upload of a local file or folder, or a download of a
+// remote file, waiting for the server to come back. Passwords aren't
+// stored, so a site's transfers start the next time it is open while the
+// server is reachable.
+type offlineItem struct {
+	Site      string    `json:"site"`
+	Direction string    `json:"direction,omitempty"` // "" for uploads
+	Path      string    `json:"path,omitempty"`      // uploads
+	FileID    int       `json:"file_id,omitempty"`   // downloads
+	FileName  string    `json:"file_name,omitempty"` // downloads
+	QueuedAt  time.Time `json:"queued_at"`
+}
+
+func (u offlineItem) isDownload() bool {
+	return u.Direction == directionDownload
+}
+
+// offlineQueueMsg asks to queue uploads that failed because the server is
+// unreachable.
+type offlineQueueMsg struct {
+	site  string
+	paths []string
+}
+
+// offlineFlushedMsg reports the outcome of sending a site's pending
+// uploads.
+type offlineFlushedMsg struct {
+	site  string
+	sent  []string // queued paths that are done with, uploaded or gone
+	files []FileInfo
+	notes []string
+	err   error // why the rest are still pending
+}
+
+// loadOfflineQueue reads the pending transfers.
+func loadOfflineQueue() []offlineItem {
+	data, err := os.ReadFile(offlinePath)
+	if err != nil {
+		return nil
+	}
+	var queue []offlineItem
+	_ = json.Unmarshal(data, &queue)
+	return queue
+}
+
+// saveOfflineQueue writes the pending transfers, removing the file once the
+// queue is empty. Saving is best effort.
+func saveOfflineQueue(queue []offlineItem) {
+	if len(queue) == 0 {
+		_ = os.Remove(offlinePath)
+		return
+	}
+	data, err := json.MarshalIndent(queue, "", "  ")
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(offlinePath, data, 0600)
+}
+
+// persistOffline saves the offline queue when it changed since it was last
+// saved.
+func persistOffline(m *Model) tea.Cmd {
+	if slices.Equal(m.offline, m.savedOffline) {
+		return nil
+	}
+	queue := slices.Clone(m.offline)
+	m.savedOffline = queue
+	return diskWrites.write(offlinePath, func() { saveOfflineQueue(queue) })
+}
+
+// serverUnreachable reports whether the last health check got no answer.
+func serverUnreachable(m *Model) bool {
+	return m.health.level == healthDown && m.health.err != nil
+}
+
+// workingOffline reports whether transfers should wait: the server is
+// unreachable or the open site is showing its offline copy.
+func workingOffline(m *Model) bool {
+	return serverUnreachable(m) || !m.listStale.IsZero()
+}
+
+// queueOffline adds uploads to the offline queue, skipping paths already
+// pending for the site.
+func queueOffline(m *Model, site string, paths []string) {
+	var items []offlineItem
+	for _, path := range paths {
+		if path != "" {
+			items = append(items, offlineItem{Site: site, Path: path})
+		}
+	}
+	addOffline(m, items)
+}
+
+// deferDownloads queues the files that have no cached copy to fall back on
+// in the offline queue and returns the rest, which can be downloaded now.
+func deferDownloads(m *Model, site string, files []FileInfo) []FileInfo {
+	var now []FileInfo
+	var items []offlineItem
+	for _, file := range files {
+		if exists, _ := cacheStatus(cachePath(site, file.ID, file.FileName)); exists {
+			now = append(now, file)
+			continue
+		}
+		items = append(items, offlineItem{Site: site, Direction: directionDownload, FileID: file.ID, FileName: file.FileName})
+	}
+	if len(items) > 0 {
+		addOffline(m, items)
+	}
+	return now
+}
+
+// addOffline appends items to the offline queue, skipping those already
+// pending.
+func addOffline(m *Model, items []offlineItem) {
+	added := 0
+	for _, item := range items {
+		if slices.ContainsFunc(m.offline, func(u offlineItem) bool {
+			return u.Site == item.Site && u.Direction == item.Direction && u.Path == item.Path && u.FileID == item.FileID
+		}) {
+			continue
+		}
+		item.QueuedAt = time.Now()
+		m.offline = append(m.offline, item)
+		added++
+	}
+	m.status = notice(fmt.Sprintf("Server unreachable: %d transfer(s) pending, started automatically once it's back", added))
+}
+
+// offlineFor lists the transfers pending for a site.
+func offlineFor(queue []offlineItem, site string) []offlineItem {
+	var pending []offlineItem
+	for _, u := range queue {
+		if u.Site == site {
+			pending = append(pending, u)
+		}
+	}
+	return pending
+}
+
+// flushOffline starts the open site's pending transfers once the server is
+// reachable again: downloads join the download queue, uploads are sent in
+// the background.
+func flushOffline(m *Model) tea.Cmd {
+	pending := offlineFor(m.offline, m.siteName)
+	if len(pending) == 0 || m.offlineFlushing || !inSite(m.state) || workingOffline(m) {
+		return nil
+	}
+
+	var downloads []FileInfo
+	var uploads []offlineItem
+	for _, u := range pending {
+		if u.isDownload() {
+			downloads = append(downloads, FileInfo{ID: u.FileID, FileName: u.FileName})
+		} else {
+			uploads = append(uploads, u)
+		}
+	}
+	var cmds []tea.Cmd
+	if len(downloads) > 0 {
+		m.offline = slices.DeleteFunc(m.offline, func(u offlineItem) bool {
+			return u.Site == m.siteName && u.isDownload()
+		})
+		cmds = append(cmds, queueDownloads(m, m.siteName, downloads))
+	}
+	if len(uploads) == 0 {
+		return tea.Batch(cmds...)
+	}
+
+	m.offlineFlushing = true
+	siteName, password, excludes := m.siteName, m.password, m.excludes
+	return tea.Batch(append(cmds, trackTransfer(m, func() tea.Msg {
+		msg := offlineFlushedMsg{site: siteName}
+		for _, u := range uploads {
+			paths, err := collectFiles(u.Path, excludes)
+			if err != nil {
+				msg.notes = append(msg.notes, fmt.Sprintf("dropped %s: %v", filepath.Base(u.Path), err))
+				msg.sent = append(msg.sent, u.Path)
+				continue
+			}
+			for _, path := range paths {
+				result, err := api.upload(siteName, password, path, uploadName(path))
+				if err != nil {
+					msg.err = err
+					return msg
+				}
+				msg.files = result.files
+				if isClipboardImage(path) {
+					os.RemoveAll(filepath.Dir(path))
+				}
+				if !result.verified {
+					msg.notes = append(msg.notes, filepath.Base(path)+": "+result.status.text)
+				}
+			}
+			msg.sent = append(msg.sent, u.Path)
+		}
+		return msg
+	}))...)
+}
+
+// handleOfflineFlushed removes the uploads that went through from the
+// queue and keeps the rest pending.
+func handleOfflineFlushed(m *Model, msg offlineFlushedMsg) (tea.Model, tea.Cmd) {
+	m.offlineFlushing = false
+	m.offline = slices.DeleteFunc(m.offline, func(u offlineItem) bool {
+		return u.Site == msg.site && !u.isDownload() && slices.Contains(msg.sent, u.Path)
+	})
+	if msg.files != nil && msg.site == m.siteName {
+		m.files = msg.files
+	}
+
+	switch {
+	case msg.err != nil:
+		m.status = notice(fmt.Sprintf("Pending uploads paused: %v", msg.err))
+	case len(msg.notes) > 0:
+		m.status = notice(fmt.Sprintf("Sent %d pending upload(s): %s", len(msg.sent), strings.Join(msg.notes, "; ")))
+	default:
+		m.status = success(fmt.Sprintf("Sent %d pending upload(s)", len(msg.sent)))
+	}
+	return m, nil
+}
+
+// renderOfflineQueue lists the open site's pending transfers, or "" when
+// there are none.
+func renderOfflineQueue(m Model) string {
+	pending := offlineFor(m.offline, m.siteName)
+	if len(pending) == 0 {
+		return ""
+	}
+	lines := []string{highlightStyle.Render(fmt.Sprintf("⏸ %d pending until the server is reachable", len(pending)))}
+	for _, u := range pending {
+		arrow, name := "↑", filepath.Base(u.Path)
+		if u.isDownload() {
+			arrow, name = "↓", u.FileName
+		}
+		lines = append(lines, mutedStyle.Render(fmt.Sprintf("  %s %s (queued %s)",
+			arrow, fitName(name, 40), u.QueuedAt.Local().Format("Jan 2 15:04"))))
+	}
+	return strings.Join(lines, "\n")
+}
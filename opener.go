@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// downloadDoneMsg reports a finished download.
+type downloadDoneMsg struct {
+	path   string
+	status statusLine
+}
+
+// openedMsg reports the outcome of handing a file to the desktop.
+type openedMsg struct {
+	err error
+}
+
+// openFile opens path with the system's default application.
+func openFile(path string) tea.Cmd {
+	return func() tea.Msg {
+		var cmd *exec.Cmd
+		switch runtime.GOOS {
+		case "darwin":
+			cmd = exec.Command("open", path)
+		case "windows":
+			// Not `cmd /c start`: cmd would run what follows a & or | in
+			// the file's name, which comes from the server.
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+			cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", path)
+		default:
+			cmd = exec.Command("xdg-open", path)
+		}
+		return openedMsg{err: startDetached(cmd)}
+	}
+}
+
+// revealFile shows path in the system file manager, selecting it where the
+// platform supports that.
+func revealFile(path string) tea.Cmd {
+	return func() tea.Msg {
+		var cmd *exec.Cmd
+		switch runtime.GOOS {
+		case "darwin":
+			cmd = exec.Command("open", "-R", path)
+		case "windows":
+			cmd = exec.Command("explorer", "/select,"+path)
+		default:
+			cmd = exec.Command("xdg-open", filepath.Dir(path))
+		}
+		return openedMsg{err: startDetached(cmd)}
+	}
+}
+
+// startDetached starts cmd without waiting for it, so a long-running
+// viewer doesn't block the TUI.
+func startDetached(cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error opening file: %v", err)
+	}
+	go cmd.Wait()
+	return nil
+}
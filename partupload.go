@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// multipartThreshold is the file size from which uploads are split into
+	// parts sent in parallel.
+	multipartThreshold = 64 << 20
+	// uploadPartSize is the size of every part but the last.
+	uploadPartSize = 16 << 20
+	// uploadPartWorkers is how many parts are in flight at once.
+	uploadPartWorkers = 4
+	// partRetries is how often a failed part is resent before the whole
+	// upload is given up.
+	partRetries = 2
+)
+
+// errMultipartUnsupported means the server has no multipart endpoint, so
+// the file has to go up in a single request.
+var errMultipartUnsupported = errors.New("multipart upload not supported by server")
+
+// uploadPart is one byte range of a multipart upload and, once sent, its
+// checksum.
+type uploadPart struct {
+	Number int    `json:"number"`
+	Offset int64  `json:"-"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// splitParts divides size bytes into uploadPartSize parts, numbered from 1.
+func splitParts(size int64) []uploadPart {
+	var parts []uploadPart
+	for offset := int64(0); offset < size; offset += uploadPartSize {
+		parts = append(parts, uploadPart{
+			Number: len(parts) + 1,
+			Offset: offset,
+			Size:   min(uploadPartSize, size-offset),
+		})
+	}
+	return parts
+}
+
+// postMultipart uploads a large file as parts sent in parallel, then asks
+// the server to assemble them. The whole file is copied into hash before the
+// parts go out, as the completion call carries its checksum. An upload of
+// the file left open by an earlier run is resumed from the parts it sent
+// (see multipartJournal). It returns errMultipartUnsupported if the server
+// can't take multipart uploads.
+func postMultipart(siteName, path, name string, size int64, hash io.Writer, retries int) (err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("error reading file: %v", err)
+	}
+
+	authToken, err := loadAuthToken()
+	if err != nil {
+		return err
+	}
+
+	// Nothing may reach hash before the server has agreed, so a fallback to
+	// a single request starts from a clean hash.
+	base := fmt.Sprintf("%s/upload/%s/multipart", serverURL, siteName)
+	open, resumed := openMultiparts.find(siteName, path, name, info)
+	if !resumed {
+		if open, err = beginMultipart(base, authToken, siteName, path, name, info); err != nil {
+			return err
+		}
+	}
+	fail := func() {
+		abortMultipart(base+"/"+open.UploadID, authToken)
+		openMultiparts.drop(open.UploadID)
+	}
+
+	sum := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(hash, sum), file); err != nil {
+		fail()
+		return fmt.Errorf("error reading file: %v", err)
+	}
+
+	stat := newTransferStat(directionUpload, base, siteName, name, retries)
+	stat.ChunkSize = uploadPartSize
+	stat.live.setTotal(size)
+	defer func() { stat.finish(size, err) }()
+
+	parts := splitParts(size)
+	for _, sent := range open.Parts {
+		if i := sent.Number - 1; i >= 0 && i < len(parts) && parts[i].Size == sent.Size {
+			parts[i].SHA256 = sent.SHA256
+			stat.live.count(int(sent.Size))
+		}
+	}
+	err = sendParts(base+"/"+open.UploadID, authToken, file, parts, stat.live, func(part uploadPart) {
+		openMultiparts.sent(open.UploadID, part)
+	})
+	if err != nil && resumed && uploadGone(err) {
+		// The server dropped the upload since it was left open; start over.
+		openMultiparts.drop(open.UploadID)
+		stat.live.restart()
+		if open, err = beginMultipart(base, authToken, siteName, path, name, info); err != nil {
+			return err
+		}
+		parts = splitParts(size)
+		err = sendParts(base+"/"+open.UploadID, authToken, file, parts, stat.live, func(part uploadPart) {
+			openMultiparts.sent(open.UploadID, part)
+		})
+	}
+	if err != nil {
+		fail()
+		return err
+	}
+	if err := completeMultipart(base+"/"+open.UploadID, authToken, parts, hex.EncodeToString(sum.Sum(nil))); err != nil {
+		fail()
+		return err
+	}
+	openMultiparts.drop(open.UploadID)
+	return nil
+}
+
+// beginMultipart opens a multipart upload of the file at path and records
+// it in the journal.
+func beginMultipart(base string, authToken secret, siteName, path, name string, info os.FileInfo) (openMultipart, error) {
+	uploadID, err := startMultipart(base, authToken, name, detectContentType(path), info.Size())
+	if err != nil {
+		return openMultipart{}, err
+	}
+	open := openMultipart{Site: siteName, Path: path, Name: name, Size: info.Size(), ModTime: info.ModTime(),
+		UploadID: uploadID, Started: time.Now()}
+	openMultiparts.start(open)
+	return open, nil
+}
+
+// uploadGone reports whether the server no longer knows a multipart upload.
+func uploadGone(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && (apiErr.status == http.StatusNotFound || apiErr.status == http.StatusGone)
+}
+
+// startMultipart opens a multipart upload and returns its ID.
+func startMultipart(base string, authToken secret, name, contentType string, size int64) (string, error) {
+	payload, err := json.Marshal(map[string]any{"name": name, "content_type": contentType, "size": size, "part_size": uploadPartSize})
+	if err != nil {
+		return "", fmt.Errorf("error encoding request: %v", err)
+	}
+	call, err := newAPICall(opMetadata, "POST", base, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+	call.req.Header.Set("Content-Type", "application/json")
+	call.req.Header.Set("Authorization", authToken.reveal())
+
+	resp, err := call.do()
+	if err != nil {
+		return "", fmt.Errorf("error uploading file: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return "", errMultipartUnsupported
+	default:
+		return "", call.fail(resp, "failed to start upload")
+	}
+
+	var result struct {
+		UploadID string `json:"upload_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("error parsing response: %v", err)
+	}
+	if result.UploadID == "" {
+		return "", fmt.Errorf("error parsing response: no upload ID")
+	}
+	return result.UploadID, nil
+}
+
+// sendParts uploads the parts with uploadPartWorkers in parallel, filling
+// in their checksums, counting their bytes towards live and passing each
+// one to sent once it is up. Parts that already have a checksum were sent
+// before and are skipped. The first part that fails for good stops the
+// rest.
+func sendParts(base string, authToken secret, file *os.File, parts []uploadPart, live *liveTransfer, sent func(uploadPart)) error {
+	jobs := make(chan *uploadPart)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr != nil
+	}
+	for i := 0; i < uploadPartWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer recoverCrash()
+			for part := range jobs {
+				if failed() {
+					continue
+				}
+				var err error
+				for attempt := 0; attempt <= partRetries; attempt++ {
+					if err = sendPart(base, authToken, file, part, live); err == nil {
+						break
+					}
+				}
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					continue
+				}
+				sent(*part)
+			}
+		}()
+	}
+	for i := range parts {
+		if parts[i].SHA256 == "" {
+			jobs <- &parts[i]
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return firstErr
+}
+
+// sendPart uploads one part and records its checksum.
+func sendPart(base string, authToken secret, file *os.File, part *uploadPart, live *liveTransfer) error {
+	data := make([]byte, part.Size)
+	if _, err := file.ReadAt(data, part.Offset); err != nil {
+		return fmt.Errorf("error reading file: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	part.SHA256 = hex.EncodeToString(sum[:])
+
+	call, err := newAPICall(opTransfer, "PUT", fmt.Sprintf("%s/%d", base, part.Number), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+	call.live = live
+	call.req.Header.Set("Content-Type", "application/octet-stream")
+	call.req.Header.Set("Authorization", authToken.reveal())
+	call.req.Header.Set("X-Part-SHA256", part.SHA256)
+
+	resp, err := call.do()
+	if err != nil {
+		return fmt.Errorf("error uploading part %d: %v", part.Number, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return call.fail(resp, fmt.Sprintf("failed to upload part %d", part.Number))
+	}
+	return nil
+}
+
+// completeMultipart asks the server to assemble the parts into the file.
+func completeMultipart(base string, authToken secret, parts []uploadPart, sum string) error {
+	payload, err := json.Marshal(map[string]any{"parts": parts, "sha256": sum})
+	if err != nil {
+		return fmt.Errorf("error encoding request: %v", err)
+	}
+	call, err := newAPICall(opMetadata, "POST", base+"/complete", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+	call.req.Header.Set("Content-Type", "application/json")
+	call.req.Header.Set("Authorization", authToken.reveal())
+
+	resp, err := call.do()
+	if err != nil {
+		return fmt.Errorf("error completing upload: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return call.fail(resp, "failed to complete upload")
+	}
+	return nil
+}
+
+// abortMultipart tells the server to drop the parts of a failed upload. It
+// is best effort; the server expires abandoned uploads on its own.
+func abortMultipart(base string, authToken secret) {
+	call, err := newAPICall(opMetadata, "DELETE", base, nil)
+	if err != nil {
+		return
+	}
+	defer call.close()
+	call.req.Header.Set("Authorization", authToken.reveal())
+	if resp, err := call.do(); err == nil {
+		resp.Body.Close()
+	}
+}
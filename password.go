@@ -0,0 +1,207 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/rivo/uniseg"
+)
+
+// minPasswordScore is the weakest password strength accepted for new sites.
+const minPasswordScore = 2
+
+// strengthLabels names each score returned by passwordStrength.
+var strengthLabels = []string{"Very weak", "Weak", "Fair", "Strong", "Very strong"}
+
+// strengthColors colors the meter for each score.
+var strengthColors = []lipgloss.AdaptiveColor{
+	{Light: "#C00000", Dark: "#FF0000"},
+	{Light: "#B35900", Dark: "#FF8C00"},
+	{Light: "#8A6D00", Dark: "#FFD700"},
+	{Light: "#4D7A00", Dark: "#9ACD32"},
+	{Light: "#007A00", Dark: "#00FF00"},
+}
+
+// commonPasswords are rejected outright regardless of length.
+var commonPasswords = map[string]bool{
+	"password": true, "password1": true, "123456": true, "12345678": true,
+	"123456789": true, "qwerty": true, "qwerty123": true, "letmein": true,
+	"welcome": true, "admin": true, "iloveyou": true, "monkey": true,
+	"dragon": true, "abc123": true, "111111": true, "passw0rd": true,
+}
+
+// passwordStrength estimates how hard a password is to guess on a 0-4
+// scale, in the spirit of zxcvbn: length and character variety raise the
+// score, while common passwords, repeats and keyboard/alphabet runs lower it.
+func passwordStrength(password string) int {
+	if password == "" || commonPasswords[strings.ToLower(password)] {
+		return 0
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+
+	length := len([]rune(password)) - predictableRuns(password)
+	score := 0
+	switch {
+	case length >= 16:
+		score = 3
+	case length >= 12:
+		score = 2
+	case length >= 8:
+		score = 1
+	}
+	if classes >= 3 {
+		score++
+	}
+	if classes == 1 && score > 0 {
+		score--
+	}
+	if score > 4 {
+		score = 4
+	}
+	return score
+}
+
+// predictableRuns counts characters that repeat or continue a sequence
+// from the previous one ("aaa", "abc", "321"), which add little entropy.
+func predictableRuns(password string) int {
+	runes := []rune(strings.ToLower(password))
+	count := 0
+	for i := 1; i < len(runes); i++ {
+		diff := runes[i] - runes[i-1]
+		if diff == 0 || diff == 1 || diff == -1 {
+			count++
+		}
+	}
+	return count
+}
+
+// renderStrengthMeter renders a five-segment bar with the strength label.
+func renderStrengthMeter(password string) string {
+	if password == "" {
+		return ""
+	}
+	score := passwordStrength(password)
+	style := lipgloss.NewStyle().Foreground(strengthColors[score])
+	bar := strings.Repeat("█", score+1) + strings.Repeat("░", len(strengthLabels)-score-1)
+	return style.Render(bar + " " + strengthLabels[score])
+}
+
+// handleCreateConfirmInput handles input in the createConfirm state.
+func handleCreateConfirmInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		if m.confirmPassword != m.password {
+			m.status = notice("Passwords do not match")
+			m.confirmPassword = ""
+			return m, nil
+		}
+		m.status = statusLine{}
+		return m, api.createSite(m.siteName, m.password, m.enableTOTP, ttlChoices[m.ttlIdx].ttl)
+	case "esc":
+		m.goTo(stateCreatePassword)
+	case "backspace":
+		m.confirmPassword = deleteLastChar(m.confirmPassword)
+	case "ctrl+v":
+		return m, readClipboard
+	case "ctrl+t":
+		m.showPassword = !m.showPassword
+	default:
+		m.confirmPassword += secret(typedText(msg))
+	}
+	return m, nil
+}
+
+// pasteMsg carries text read from the system clipboard.
+type pasteMsg struct {
+	text string
+	err  error
+}
+
+// readClipboard reads the system clipboard for Ctrl+V in password fields.
+func readClipboard() tea.Msg {
+	text, err := clipboard.ReadAll()
+	if err != nil {
+		return pasteMsg{err: err}
+	}
+	return pasteMsg{text: singleLine(text)}
+}
+
+// typedText returns the text a key press adds to an input field: the
+// characters typed, or the whole text of a bracketed paste. Input methods
+// commit a composed word as several runes at once, and letters outside
+// ASCII take more than one byte, so the runes are taken as they come;
+// only Alt combinations and named keys add nothing.
+func typedText(msg tea.KeyMsg) string {
+	switch {
+	case msg.Paste:
+		return singleLine(string(msg.Runes))
+	case msg.Alt:
+		return ""
+	case msg.Type == tea.KeySpace:
+		return " "
+	case msg.Type == tea.KeyRunes:
+		return string(msg.Runes)
+	}
+	return ""
+}
+
+// deleteLastChar removes the last character of an input field for
+// Backspace. A character is a grapheme cluster, so an accented letter
+// typed as a base and a combining mark, or an emoji built from several
+// code points, goes in one press rather than leaving half of it behind.
+func deleteLastChar[S ~string](s S) S {
+	last := 0
+	g := uniseg.NewGraphemes(string(s))
+	for g.Next() {
+		last, _ = g.Positions()
+	}
+	return s[:last]
+}
+
+// singleLine drops line breaks so a copied password with a trailing newline
+// doesn't end up with one.
+func singleLine(text string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(text)
+}
+
+// passwordField returns the password being edited in the current state, or
+// nil when no password field has focus.
+func passwordField(m *Model) *secret {
+	switch m.state {
+	case statePassword, stateCreatePassword:
+		return &m.password
+	case stateCreateConfirm:
+		return &m.confirmPassword
+	}
+	return nil
+}
+
+// maskPassword renders a password as bullets unless it is revealed.
+func maskPassword(password string, show bool) string {
+	if show {
+		return password
+	}
+	return strings.Repeat("•", uniseg.GraphemeClusterCount(password))
+}
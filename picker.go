@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// openPicker shows the built-in picker for a file or, with folder set, a
+// folder to upload, in place of the upload screen. It browses the same
+// folder as the two-pane view's local side, starting in the working
+// directory.
+func openPicker(m *Model, folder bool) {
+	if m.localDir == "" {
+		if wd, err := os.Getwd(); err == nil {
+			m.localDir = wd
+		}
+	}
+	m.pickFolder = folder
+	m.localIdx = 0
+	readLocalDir(m)
+	m.picking = true
+}
+
+// handlePickerInput handles input in the file picker: Enter opens a folder
+// or picks a file, Space picks the highlighted item (the current folder on
+// ".."), Backspace goes up.
+func handlePickerInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up":
+		if m.localIdx > 0 {
+			m.localIdx--
+		}
+	case "down":
+		if m.localIdx < len(m.localEntries)-1 {
+			m.localIdx++
+		}
+	case "backspace":
+		enterLocalDir(m, "..")
+	case "enter":
+		if m.localIdx >= len(m.localEntries) {
+			return m, nil
+		}
+		entry := m.localEntries[m.localIdx]
+		if entry.isDir {
+			enterLocalDir(m, entry.name)
+		} else if !m.pickFolder {
+			pickPath(m, filepath.Join(m.localDir, entry.name))
+		}
+	case " ":
+		if m.localIdx >= len(m.localEntries) {
+			if m.pickFolder {
+				pickPath(m, m.localDir)
+			}
+			return m, nil
+		}
+		entry := m.localEntries[m.localIdx]
+		switch {
+		case entry.name == ".." && m.pickFolder:
+			pickPath(m, m.localDir)
+		case entry.isDir == m.pickFolder && entry.name != "..":
+			pickPath(m, filepath.Join(m.localDir, entry.name))
+		}
+	case "esc":
+		m.picking = false
+	}
+	return m, nil
+}
+
+// pickPath adds a picked file or folder to the upload and closes the
+// picker.
+func pickPath(m *Model, path string) {
+	selectUpload(m, path, m.pickFolder)
+	m.picking = false
+}
+
+// renderPickerBox renders the picker with its title and keys.
+func renderPickerBox(m Model) string {
+	title, keys := "📄 Pick a file to upload", "↑/↓ - Navigate • Enter - Open/Pick • Backspace - Up • Esc - Back"
+	if m.pickFolder {
+		title, keys = "📁 Pick a folder to upload", "↑/↓ - Navigate • Enter - Open • Space - Pick • Backspace - Up • Esc - Back"
+	}
+	return fileListStyle.Render(
+		lipgloss.JoinVertical(lipgloss.Left,
+			title,
+			strings.Repeat("─", 50),
+			renderPicker(m),
+			"",
+			highlightStyle.Render(keys),
+		),
+	)
+}
+
+// renderPicker renders the visible entries of the picker's folder.
+func renderPicker(m Model) string {
+	lines := []string{highlightStyle.Render(truncatePath(m.localDir, 60)), ""}
+	if len(m.localEntries) == 0 {
+		lines = append(lines, "(empty)")
+	}
+	offset := max(0, m.localIdx-visibleFiles+1)
+	end := min(offset+visibleFiles, len(m.localEntries))
+	for i := offset; i < end; i++ {
+		e := m.localEntries[i]
+		line := fitName(e.name, 44) + "/"
+		if !e.isDir {
+			line = fmt.Sprintf("%s %10s", padName(e.name, 44), formatBytes(e.size))
+		}
+		if !e.isDir && m.pickFolder {
+			line = mutedStyle.Render(line)
+		}
+		if i == m.localIdx {
+			lines = append(lines, selectedStyle.Render("➜  "+line))
+		} else {
+			lines = append(lines, "   "+line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// pinPrefix starts a pin: the base64 SHA-256 of a certificate's public key
+// (its SPKI), as in "sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=".
+const pinPrefix = "sha256/"
+
+// certPins maps lowercase host names to their accepted pins, loaded once at
+// startup.
+var certPins map[string][]string
+
+// initPins loads and checks the pins from the config file.
+func initPins() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	pins := make(map[string][]string)
+	for host, hostPins := range cfg.Pins {
+		if len(hostPins) == 0 {
+			return fmt.Errorf("no pins for %s in %s", host, configPath)
+		}
+		for _, pin := range hostPins {
+			sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, pinPrefix))
+			if !strings.HasPrefix(pin, pinPrefix) || err != nil || len(sum) != sha256.Size {
+				return fmt.Errorf("invalid pin %q for %s in %s (expected sha256/<base64>)", pin, host, configPath)
+			}
+		}
+		pins[strings.ToLower(host)] = hostPins
+	}
+	certPins = pins
+	return nil
+}
+
+// spkiPin returns the pin of a certificate's public key.
+func spkiPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return pinPrefix + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// verifyPins refuses a TLS connection to a pinned host unless one of the
+// certificates it presented carries a pinned key. It runs after the usual
+// certificate checks, so pinning only ever narrows what is trusted.
+func verifyPins(cs tls.ConnectionState) error {
+	pins, ok := certPins[strings.ToLower(cs.ServerName)]
+	if !ok {
+		return nil
+	}
+	for _, cert := range cs.PeerCertificates {
+		if slices.Contains(pins, spkiPin(cert)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("the certificate of %s doesn't match its pinned key; refusing to connect (someone may be intercepting the connection)", cs.ServerName)
+}
+
+// checkPinnedScheme refuses plain HTTP to a pinned host, since a pin can
+// only be checked over TLS.
+func checkPinnedScheme(u *url.URL) error {
+	if _, ok := certPins[strings.ToLower(u.Hostname())]; ok && u.Scheme != "https" {
+		return fmt.Errorf("%s is pinned but the request isn't using HTTPS; refusing to connect", u.Hostname())
+	}
+	return nil
+}
+
+// runPinCommand implements `cshare pin [host[:port]]`: it connects to the
+// host, or to cshare's HTTPS servers, and prints the pins of the
+// certificates presented, to copy into the "pins" section of cshare.json.
+func runPinCommand(args []string) error {
+	var hosts []string
+	switch len(args) {
+	case 0:
+		for _, base := range []string{serverURL, loginServerURL} {
+			if u, err := url.Parse(base); err == nil && u.Scheme == "https" {
+				hosts = append(hosts, u.Host)
+			}
+		}
+	case 1:
+		hosts = args
+	default:
+		return fmt.Errorf("usage: cshare pin [host[:port]]")
+	}
+
+	for _, host := range hosts {
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, "443")
+		}
+		dialer := &net.Dialer{Timeout: 10 * time.Second}
+		conn, err := tls.DialWithDialer(dialer, "tcp", host, &tls.Config{})
+		if err != nil {
+			return fmt.Errorf("error connecting to %s: %v", host, err)
+		}
+		certs := conn.ConnectionState().PeerCertificates
+		conn.Close()
+
+		fmt.Println(host)
+		for i, cert := range certs {
+			role := "intermediate"
+			switch {
+			case i == 0:
+				role = "server"
+			case i == len(certs)-1:
+				role = "issuer"
+			}
+			fmt.Printf("  %-12s %s  %s\n", role, spkiPin(cert), cert.Subject.CommonName)
+		}
+	}
+	return nil
+}
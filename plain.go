@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// plainMode drops colors, emoji and box-drawing characters so the screen
+// works with screen readers and can be copied as plain text. It is turned on
+// by a non-empty NO_COLOR or the --plain flag.
+var plainMode bool
+
+// asciiBorder replaces the rounded and line borders in plain mode. Only the
+// horizontal rules are drawn: plainText changes line widths after layout, so
+// a right-hand border would no longer line up, and screen readers would
+// announce the side bars on every line.
+var asciiBorder = lipgloss.Border{Top: "-", Bottom: "-"}
+
+// plainGlyphs maps the symbols that carry meaning to ASCII stand-ins.
+var plainGlyphs = strings.NewReplacer(
+	"❌ ", "Error: ",
+	"➜", ">",
+	"▾", "[-]",
+	"▸", "[+]",
+	"✓", "[ok]",
+	"✔", "[ok]",
+	"✘", "[x]",
+	"⚠️", "!",
+	"⚠", "!",
+	"•", "|",
+	"·", "-",
+	"…", "...",
+	"←", "<-",
+	"→", "->",
+	"↑", "Up",
+	"↓", "Down",
+	"─", "-",
+	"●", "*",
+	"⇅", "transfers:",
+	"█", "#",
+	"░", ".",
+)
+
+// decoration matches the remaining emoji (with any variation selector and
+// the spacing after them), which are purely decorative.
+var decoration = regexp.MustCompile(`[\x{1F300}-\x{1FAFF}\x{2600}-\x{27BF}\x{23E9}-\x{23FA}\x{FFFD}]\x{FE0F}? *`)
+
+// plainArgs strips --plain from the command line and reports whether plain
+// mode was requested there or through NO_COLOR.
+func plainArgs(args []string) ([]string, bool) {
+	plain := os.Getenv("NO_COLOR") != ""
+	var rest []string
+	for _, arg := range args {
+		if arg == "--plain" {
+			plain = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return rest, plain
+}
+
+// enablePlainMode switches every style to colorless ASCII output.
+func enablePlainMode() {
+	plainMode = true
+	lipgloss.SetColorProfile(termenv.Ascii)
+	appStyle = plainBox(appStyle)
+	menuBoxStyle = plainBox(menuBoxStyle)
+	inputBoxStyle = plainBox(inputBoxStyle)
+	fileListStyle = plainBox(fileListStyle)
+}
+
+// plainBox swaps a box's border for top and bottom ASCII rules.
+func plainBox(s lipgloss.Style) lipgloss.Style {
+	return s.BorderStyle(asciiBorder).BorderLeft(false).BorderRight(false)
+}
+
+// plainText rewrites a rendered screen to ASCII when plain mode is on.
+func plainText(s string) string {
+	if !plainMode {
+		return s
+	}
+	return decoration.ReplaceAllString(plainGlyphs.Replace(s), "")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// cacheDir holds prefetched copies of pinned files.
+	cacheDir = ".cshare-cache"
+	// cacheMaxAge is how long a cached copy counts as the latest version.
+	cacheMaxAge = time.Hour
+	// prefetchInterval is how often the client checks for idle time.
+	prefetchInterval = time.Minute
+	// idleThreshold is how long without input counts as idle.
+	idleThreshold = 2 * time.Minute
+)
+
+// prefetchMsg fires every prefetchInterval.
+type prefetchMsg struct{}
+
+// prefetchedMsg reports the outcome of one background prefetch.
+type prefetchedMsg struct {
+	err error
+}
+
+// prefetchEnabled reports whether CSHARE_PREFETCH_PINNED turns on
+// background prefetching of pinned files.
+func prefetchEnabled() bool {
+	return envOn("CSHARE_PREFETCH_PINNED")
+}
+
+// prefetchTick schedules the next idle check.
+func prefetchTick() tea.Cmd {
+	return tea.Tick(prefetchInterval, func(time.Time) tea.Msg {
+		return prefetchMsg{}
+	})
+}
+
+// handlePrefetch refreshes one stale pinned file of the open site when the
+// user has been idle, then schedules the next check. Only the open site's
+// files are fetched since its auth token is the one stored.
+func handlePrefetch(m *Model) (tea.Model, tea.Cmd) {
+	if !prefetchEnabled() || !inSite(m.state) || time.Since(m.lastInput) < idleThreshold {
+		return m, prefetchTick()
+	}
+	return m, tea.Batch(prefetchStale(m.siteName), prefetchTick())
+}
+
+// prefetchStale downloads the first pinned file of a site whose cached
+// copy is missing or stale, if there is one.
+func prefetchStale(siteName string) tea.Cmd {
+	pinned := currentConfig().Pinned
+	return func() tea.Msg {
+		for _, item := range pinned {
+			if item.Kind != quickFile || item.Site != siteName {
+				continue
+			}
+			if _, fresh := cacheStatus(cachePath(item.Site, item.FileID, item.FileName)); !fresh {
+				return prefetchFile(item)()
+			}
+		}
+		return nil
+	}
+}
+
+// prefetchFile downloads a pinned file into the cache.
+func prefetchFile(item QuickItem) tea.Cmd {
+	return func() tea.Msg {
+		path := cachePath(item.Site, item.FileID, item.FileName)
+		err := saveFile(path, func(w io.Writer) (int64, error) {
+			return fetchFile(item.Site, item.FileID, item.FileName, w)
+		})
+		return prefetchedMsg{err: err}
+	}
+}
+
+// cachePath is where a file's prefetched copy is kept. Both names come
+// from the server, so each is made a single path element that stays
+// inside cacheDir.
+func cachePath(siteName string, fileID int, fileName string) string {
+	return filepath.Join(cacheDir, pathElement(siteName), pathElement(fmt.Sprintf("%d-%s", fileID, fileName)))
+}
+
+// cacheStatus reports whether there is a cached copy at path and whether it
+// is recent enough to stand in for the server's latest version.
+func cacheStatus(path string) (exists, fresh bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, false
+	}
+	return true, time.Since(info.ModTime()) < cacheMaxAge
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// maxPresenceShown is how many viewers the status bar lists by initials
+// before summarizing the rest as "+n".
+const maxPresenceShown = 4
+
+// otherViewers returns the users viewing the open site, apart from the
+// signed-in account.
+func otherViewers(m Model) []string {
+	var others []string
+	for _, user := range m.online {
+		if user != m.account {
+			others = append(others, user)
+		}
+	}
+	return others
+}
+
+// initials shortens a username to up to two letters: the first letters of
+// its first two words, or its first two letters if it is one word.
+func initials(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var letters []rune
+	switch {
+	case len(words) >= 2:
+		letters = []rune{[]rune(words[0])[0], []rune(words[1])[0]}
+	case len(words) == 1:
+		letters = []rune(words[0])[:min(2, len([]rune(words[0])))]
+	default:
+		return "?"
+	}
+	return strings.ToUpper(string(letters))
+}
+
+// renderPresence summarizes who else is viewing the site for the status
+// bar, e.g. "👥 AB KS +2". It is empty when nobody else is.
+func renderPresence(m Model) string {
+	others := otherViewers(m)
+	if len(others) == 0 {
+		return ""
+	}
+	var shown []string
+	for _, user := range others[:min(len(others), maxPresenceShown)] {
+		shown = append(shown, initials(user))
+	}
+	if extra := len(others) - maxPresenceShown; extra > 0 {
+		shown = append(shown, fmt.Sprintf("+%d", extra))
+	}
+	return "👥 " + strings.Join(shown, " ")
+}
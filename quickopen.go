@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Kinds of quick-open entries.
+const (
+	quickSite = "site"
+	quickFile = "file"
+)
+
+// maxRecent is how many recently used sites and files are remembered.
+const maxRecent = 20
+
+// maxQuickResults caps the number of matches shown in the switcher.
+const maxQuickResults = 12
+
+// QuickItem is a site or file that can be reopened from the switcher.
+type QuickItem struct {
+	Kind     string `json:"kind"`
+	Site     string `json:"site"`
+	FileID   int    `json:"file_id,omitempty"`
+	FileName string `json:"file_name,omitempty"`
+}
+
+// label is how the item is shown and matched in the switcher.
+func (q QuickItem) label() string {
+	if q.Kind == quickFile {
+		return q.Site + "/" + q.FileName
+	}
+	return q.Site
+}
+
+// quickMatch is a scored switcher entry.
+type quickMatch struct {
+	item   QuickItem
+	icon   string
+	score  int
+	pinned bool
+}
+
+// openQuickSwitcher shows the Ctrl+O switcher on top of the current screen.
+func openQuickSwitcher(m *Model) {
+	m.quickReturn = m.state
+	m.goTo(stateQuickOpen)
+	m.quickMatches = quickCandidates(m, "")
+}
+
+// handleQuickOpenInput handles input in the quick-open switcher.
+func handleQuickOpenInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "ctrl+p":
+		if m.quickIdx > 0 {
+			m.quickIdx--
+		}
+	case "down", "ctrl+n":
+		if m.quickIdx < len(m.quickMatches)-1 {
+			m.quickIdx++
+		}
+	case "enter":
+		if m.quickIdx < len(m.quickMatches) {
+			return openQuickItem(m, m.quickMatches[m.quickIdx].item)
+		}
+	case "esc", keyBindings["quick_open"]:
+		m.goTo(m.quickReturn)
+	case "backspace":
+		if len(m.quickQuery) > 0 {
+			m.quickQuery = deleteLastChar(m.quickQuery)
+			m.quickMatches = quickCandidates(m, m.quickQuery)
+			m.quickIdx = 0
+		}
+	default:
+		if text := typedText(msg); text != "" {
+			m.quickQuery += text
+			m.quickMatches = quickCandidates(m, m.quickQuery)
+			m.quickIdx = 0
+		}
+	}
+	return m, nil
+}
+
+// openQuickItem opens a site or downloads a file picked in the switcher.
+// Files of the site that is currently open are downloaded right away;
+// anything else opens its site first.
+func openQuickItem(m *Model, item QuickItem) (tea.Model, tea.Cmd) {
+	if item.Kind == quickFile && item.Site == m.siteName && inSite(m.quickReturn) {
+		m.goTo(m.quickReturn)
+		return m, tea.Batch(recordRecent(item), queueDownloads(m, item.Site, []FileInfo{{ID: item.FileID, FileName: item.FileName}}))
+	}
+
+	m.siteName = item.Site
+	m.password = ""
+	m.showPassword = false
+	if _, ok := storeFor(m.siteName); ok {
+		return m, api.openSite(m.siteName, "", "")
+	}
+	for _, site := range m.mySites {
+		if site.Name == item.Site {
+			return m, api.openSite(m.siteName, "", "")
+		}
+	}
+	m.goTo(statePassword)
+	return m, nil
+}
+
+// renderQuickOpen renders the query line and the best matches.
+func renderQuickOpen(m Model) string {
+	var list strings.Builder
+	list.WriteString("🔎 " + m.quickQuery + "█\n\n")
+	if len(m.quickMatches) == 0 {
+		list.WriteString("No matches.")
+		return list.String()
+	}
+	for i, match := range m.quickMatches {
+		line := match.icon + " " + match.item.label()
+		if match.pinned {
+			line += " 📌"
+		}
+		if i == m.quickIdx {
+			list.WriteString(selectedStyle.Render("➜  " + line))
+		} else {
+			list.WriteString("   " + line)
+		}
+		list.WriteString("\n")
+	}
+	return list.String()
+}
+
+// quickCandidates gathers pinned items, recent items, the account's sites,
+// aliases and the open site's files, and ranks those matching query.
+func quickCandidates(m *Model, query string) []quickMatch {
+	cfg := currentConfig()
+	seen := make(map[string]bool)
+	var matches []quickMatch
+	add := func(item QuickItem, icon string, pinned bool, bonus int) {
+		key := item.Kind + ":" + item.label()
+		if seen[key] {
+			return
+		}
+		score, ok := fuzzyScore(query, item.label())
+		if !ok {
+			return
+		}
+		seen[key] = true
+		matches = append(matches, quickMatch{item: item, icon: icon, score: score + bonus, pinned: pinned})
+	}
+
+	for _, item := range cfg.Pinned {
+		add(item, quickIcon(item), true, 30)
+	}
+	for _, item := range cfg.Recent {
+		add(item, quickIcon(item), false, 20)
+	}
+	for _, site := range m.mySites {
+		add(QuickItem{Kind: quickSite, Site: site.Name}, "🗂️", false, 10)
+	}
+	for _, site := range cfg.Aliases {
+		add(QuickItem{Kind: quickSite, Site: site}, "🏷️", false, 10)
+	}
+	if inSite(m.state) || inSite(m.quickReturn) {
+		for _, file := range m.files {
+			add(QuickItem{Kind: quickFile, Site: m.siteName, FileID: file.ID, FileName: file.FileName}, "📄", false, 0)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	if len(matches) > maxQuickResults {
+		matches = matches[:maxQuickResults]
+	}
+	return matches
+}
+
+func quickIcon(item QuickItem) string {
+	if item.Kind == quickFile {
+		return "📄"
+	}
+	return "📂"
+}
+
+// fuzzyScore reports whether every rune of query appears in target in order
+// (case-insensitively) and scores the match: consecutive runs and matches at
+// word starts rank higher, long gaps rank lower.
+func fuzzyScore(query, target string) (int, bool) {
+	if query == "" {
+		return 0, true
+	}
+	q := []rune(strings.ToLower(query))
+	t := []rune(strings.ToLower(target))
+
+	score, qi, last := 0, 0, -1
+	for ti := 0; ti < len(t) && qi < len(q); ti++ {
+		if t[ti] != q[qi] {
+			continue
+		}
+		score += 10
+		if last == ti-1 {
+			score += 15
+		}
+		if ti == 0 || !unicode.IsLetter(t[ti-1]) && !unicode.IsDigit(t[ti-1]) {
+			score += 10
+		}
+		if last >= 0 {
+			score -= ti - last - 1
+		}
+		last = ti
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	return score, true
+}
+
+// recordRecent moves item to the front of the recent list. Recording is
+// best effort.
+func recordRecent(item QuickItem) tea.Cmd {
+	return func() tea.Msg {
+		_ = updateConfig(func(cfg *Config) {
+			recent := []QuickItem{item}
+			for _, existing := range cfg.Recent {
+				if existing != item && len(recent) < maxRecent {
+					recent = append(recent, existing)
+				}
+			}
+			cfg.Recent = recent
+		})
+		return nil
+	}
+}
+
+// pinToggledMsg reports whether a file is pinned after toggling it.
+type pinToggledMsg struct {
+	fileName string
+	pinned   bool
+	err      error
+}
+
+// togglePin pins or unpins a file of the open site.
+func togglePin(item QuickItem) tea.Cmd {
+	return func() tea.Msg {
+		msg := pinToggledMsg{fileName: item.FileName}
+		msg.err = updateConfig(func(cfg *Config) {
+			for i, existing := range cfg.Pinned {
+				if existing == item {
+					cfg.Pinned = append(cfg.Pinned[:i], cfg.Pinned[i+1:]...)
+					return
+				}
+			}
+			cfg.Pinned = append(cfg.Pinned, item)
+			msg.pinned = true
+		})
+		return msg
+	}
+}
+
+// handlePinToggled reports a pinned or unpinned file.
+func handlePinToggled(m *Model, msg pinToggledMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.err != nil:
+		m.status = notice(fmt.Sprintf("Error pinning file: %v", msg.err))
+	case msg.pinned:
+		m.status = success("Pinned " + msg.fileName)
+	default:
+		m.status = success("Unpinned " + msg.fileName)
+	}
+	return m, nil
+}
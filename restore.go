@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// parseRestoreTime reads a restore --at: a backup's own stamp
+// (20240501-153000, in UTC), a date and time in local time, or a date,
+// which means the end of that day.
+func parseRestoreTime(value string) (time.Time, error) {
+	if t, err := time.Parse(backupTimeLayout, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if day, err := time.ParseInLocation(historyDateLayout, value, time.Local); err == nil {
+		return day.AddDate(0, 0, 1).Add(-time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --at %q: use YYYY-MM-DD, \"YYYY-MM-DD HH:MM\" or a backup's timestamp", value)
+}
+
+// backupAt picks the newest backup taken at or before at; a zero at picks
+// the newest of all.
+func backupAt(backups []backupArchive, at time.Time) (backupArchive, bool) {
+	for _, backup := range backups {
+		if at.IsZero() || !backup.taken.After(at) {
+			return backup, true
+		}
+	}
+	return backupArchive{}, false
+}
+
+// runRestoreCommand implements
+// `cshare restore [--site <site>] [--at <time>] [--folder <name>] <dest>`:
+// it downloads the backup of a folder that was current at the given time
+// (the newest without --at) and unpacks it into dest, which must be new or
+// empty. Backups are found by the folder's name, dest's own name unless
+// --folder says otherwise. --dry-run lists what would be restored.
+func runRestoreCommand(args []string, dryRun bool) error {
+	const usage = "usage: cshare restore [--site <site>] [--at <time>] [--folder <name>] <dest>"
+	var dest, site, folder string
+	var at time.Time
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			if dest != "" {
+				return fmt.Errorf("%s", usage)
+			}
+			dest = arg
+			continue
+		}
+		if i+1 == len(args) {
+			return fmt.Errorf("%s", usage)
+		}
+		i++
+		switch arg {
+		case "--site":
+			site = resolveSite(args[i])
+		case "--folder":
+			folder = args[i]
+		case "--at":
+			var err error
+			if at, err = parseRestoreTime(args[i]); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s", usage)
+		}
+	}
+	if dest == "" {
+		return fmt.Errorf("%s", usage)
+	}
+	if abs, err := filepath.Abs(dest); err == nil {
+		dest = abs
+	}
+	if folder == "" {
+		folder = dest
+	}
+	if entries, err := os.ReadDir(dest); err == nil && len(entries) > 0 {
+		return fmt.Errorf("%s is not empty; restore into a new or empty folder", dest)
+	}
+	if site == "" {
+		cached, ok := cachedSite()
+		if !ok {
+			return fmt.Errorf("no site given and none logged in: use --site, or open a site first")
+		}
+		site = cached
+	}
+	password, err := sitePassword(site)
+	if err != nil {
+		return err
+	}
+
+	files, err := fetchFilesDirectly(site, password)
+	if err != nil {
+		return err
+	}
+	backups := siteBackups(files, folder)
+	if len(backups) == 0 {
+		return fmt.Errorf("no backups of %s on %s", filepath.Base(folder), site)
+	}
+	backup, ok := backupAt(backups, at)
+	if !ok {
+		return fmt.Errorf("no backup of %s on %s is that old; the oldest is from %s",
+			filepath.Base(folder), site, backups[len(backups)-1].taken.Local().Format("2006-01-02 15:04"))
+	}
+
+	dir, err := os.MkdirTemp("", "cshare-restore-*")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	archive := filepath.Join(dir, pathElement(backup.file.FileName))
+	if err := saveFile(archive, func(w io.Writer) (int64, error) {
+		return fetchFile(site, backup.file.ID, backup.file.FileName, w)
+	}); err != nil {
+		return err
+	}
+
+	taken := backup.taken.Local().Format("2006-01-02 15:04:05")
+	if dryRun {
+		f, err := os.Open(archive)
+		if err != nil {
+			return fmt.Errorf("error reading archive: %v", err)
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return fmt.Errorf("error reading archive: %v", err)
+		}
+		entries, err := zipEntries(f, info.Size())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Would restore %s (taken %s) into %s:\n", backup.file.FileName, taken, dest)
+		for _, entry := range entries {
+			if !entry.isDir {
+				fmt.Printf("  %s (%s)\n", entry.name, formatBytes(entry.size))
+			}
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return fmt.Errorf("error creating %s: %v", dest, err)
+	}
+	n, err := extractZip(archive, dest)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored %d files from %s (taken %s) into %s\n", n, backup.file.FileName, taken, dest)
+	return nil
+}
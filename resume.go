@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// sessionPath holds the last session, rewritten whenever it changes so it
+// survives crashes and accidental quits.
+const sessionPath = ".cshare-session.json"
+
+// savedSession is what can be restored of a session: the open site, the
+// selected file and the transfers that hadn't finished. Uploaded lists the
+// files of an interrupted batch that were already sent, so restoring it
+// doesn't send them again. The password is never saved; restoring asks
+// for it again.
+type savedSession struct {
+	Site      string          `json:"site"`
+	Selected  int             `json:"selected"`
+	Uploads   []string        `json:"uploads,omitempty"`
+	UploadAs  string          `json:"upload_as,omitempty"`
+	Uploaded  []string        `json:"uploaded,omitempty"`
+	Downloads []savedDownload `json:"downloads,omitempty"`
+	SavedAt   time.Time       `json:"saved_at"`
+}
+
+// savedDownload is a download that was queued or running.
+type savedDownload struct {
+	Site     string `json:"site"`
+	FileID   int    `json:"file_id"`
+	FileName string `json:"file_name"`
+}
+
+// snapshotSession captures the restorable part of the model. The zero value
+// means there's nothing worth restoring.
+func snapshotSession(m *Model) savedSession {
+	var s savedSession
+	if inSite(m.state) {
+		s.Site = m.siteName
+		s.Selected = m.selectedIdx
+	}
+	s.Uploads = append(s.Uploads, m.pendingUploads...)
+	if m.state == stateUploadFile {
+		for _, path := range []string{m.fileToUpload, m.folderToUpload} {
+			if path != "" {
+				s.Uploads = append(s.Uploads, path)
+			}
+		}
+		if m.fileToUpload != "" {
+			s.UploadAs = m.uploadAs
+		}
+	}
+	if len(s.Uploads) > 0 {
+		s.Uploaded = slices.Clone(m.batchSent)
+	}
+	queued := m.downloads.items
+	if m.downloads.running {
+		queued = append([]queuedDownload{m.downloads.current}, queued...)
+	}
+	for _, item := range queued {
+		s.Downloads = append(s.Downloads, savedDownload{Site: item.site, FileID: item.file.ID, FileName: item.file.FileName})
+	}
+	if s.Site == "" && (len(s.Uploads) > 0 || len(s.Downloads) > 0) {
+		s.Site = m.siteName
+	}
+	return s
+}
+
+// persistSession writes the session file when the snapshot changed since
+// the last write, and removes it once there's nothing to restore. Saving is
+// best effort and never interrupts the UI.
+func persistSession(m *Model) tea.Cmd {
+	s := snapshotSession(m)
+	if reflect.DeepEqual(s, m.savedSession) {
+		return nil
+	}
+	m.savedSession = s
+	return diskWrites.write(sessionPath, func() {
+		if s.Site == "" {
+			_ = os.Remove(sessionPath)
+			return
+		}
+		s.SavedAt = time.Now()
+		data, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return
+		}
+		_ = os.WriteFile(sessionPath, data, 0600)
+	})
+}
+
+// loadSession reads the previous session, if there is one to restore.
+func loadSession() (savedSession, bool) {
+	var s savedSession
+	data, err := os.ReadFile(sessionPath)
+	if err != nil {
+		return s, false
+	}
+	if err := json.Unmarshal(data, &s); err != nil || s.Site == "" {
+		return s, false
+	}
+	return s, true
+}
+
+// offerRestore asks on startup whether to pick up the previous session.
+// Restoring goes to the site's password prompt; the rest is applied once
+// the site has loaded (see applyRestore).
+func offerRestore(m *Model, s savedSession) {
+	detail := fmt.Sprintf("Saved %s.", s.SavedAt.Local().Format("Jan 2 15:04"))
+	if n := len(s.Uploads) + len(s.Downloads); n > 0 {
+		detail += fmt.Sprintf(" %d unfinished transfer(s) will be restarted.", n)
+	}
+	if len(s.Uploaded) > 0 {
+		detail += fmt.Sprintf(" %d file(s) already uploaded won't be sent again.", len(s.Uploaded))
+	}
+	askConfirm(m, "Restore your previous session on "+s.Site+"?", detail, func(m *Model) tea.Cmd {
+		m.siteName = s.Site
+		m.restore = &s
+		m.goTo(statePassword)
+		m.status = notice("Log in to " + s.Site + " to restore your session")
+		return nil
+	})
+	m.savedSession = s
+}
+
+// applyRestore puts back the selection and restarts unfinished transfers
+// once the restored site has loaded.
+func applyRestore(m *Model) tea.Cmd {
+	s := m.restore
+	if s == nil || s.Site != m.siteName {
+		return nil
+	}
+	m.restore = nil
+	m.selectedIdx = s.Selected
+	keepSelectionVisible(m)
+
+	for _, path := range s.Uploads {
+		if _, err := os.Stat(path); err == nil {
+			m.pendingUploads = append(m.pendingUploads, path)
+		}
+	}
+	m.batchSent = s.Uploaded
+	if s.UploadAs != "" && len(m.pendingUploads) == 1 {
+		// A single file keeps the name it was going to be saved as.
+		m.fileToUpload, m.uploadAs = m.pendingUploads[0], s.UploadAs
+		m.pendingUploads = nil
+	}
+	var cmd tea.Cmd
+	for _, d := range s.Downloads {
+		cmd = tea.Batch(cmd, queueDownloads(m, d.Site, []FileInfo{{ID: d.FileID, FileName: d.FileName}}))
+	}
+	if len(m.pendingUploads) > 0 || m.fileToUpload != "" {
+		m.goTo(stateUploadFile)
+	}
+	return cmd
+}
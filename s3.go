@@ -0,0 +1,375 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// presignExpiry is how long a presigned bucket request stays valid. It
+// only has to outlast the start of the request.
+const presignExpiry = 15 * time.Minute
+
+// Bucket is an S3-compatible bucket (AWS S3, MinIO, ...) backing a site in
+// place of cshare's servers, for where the server can't be deployed. The
+// credentials come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and, for
+// temporary ones, AWS_SESSION_TOKEN.
+type Bucket struct {
+	// Endpoint is the S3 API's base URL, such as
+	// "https://s3.eu-west-1.amazonaws.com" or "http://localhost:9000".
+	Endpoint string `json:"endpoint"`
+	Bucket   string `json:"bucket"`
+	// Region signs the requests; MinIO accepts the default us-east-1.
+	Region string `json:"region,omitempty"`
+	// Prefix keeps the site's files under a folder of the bucket.
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// key returns the object key of a file of the site.
+func (b Bucket) key(name string) string {
+	if prefix := strings.Trim(b.Prefix, "/"); prefix != "" {
+		return prefix + "/" + name
+	}
+	return name
+}
+
+// presign returns a URL for one request to the bucket, signed with AWS
+// Signature Version 4 in its query string so that it needs no other
+// authentication. key is "" for requests to the bucket itself.
+func (b Bucket) presign(method, key string, query url.Values, now time.Time) (string, error) {
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return "", fmt.Errorf("bucket %s needs credentials: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY", b.Bucket)
+	}
+	endpoint, err := url.Parse(b.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return "", fmt.Errorf("invalid endpoint %q for bucket %s", b.Endpoint, b.Bucket)
+	}
+	region := b.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	// Path-style addressing, which S3-compatible servers all support.
+	path := strings.TrimSuffix(endpoint.Path, "/") + "/" + awsEscape(b.Bucket, false)
+	if key != "" {
+		path += "/" + awsEscape(key, true)
+	}
+	stamp := now.UTC().Format("20060102T150405Z")
+	scope := stamp[:8] + "/" + region + "/s3/aws4_request"
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", accessKey+"/"+scope)
+	query.Set("X-Amz-Date", stamp)
+	query.Set("X-Amz-Expires", fmt.Sprint(int(presignExpiry.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		query.Set("X-Amz-Security-Token", token)
+	}
+	canonicalQuery := canonicalQuery(query)
+
+	request := strings.Join([]string{
+		method, path, canonicalQuery,
+		"host:" + endpoint.Host + "\n", "host", "UNSIGNED-PAYLOAD",
+	}, "\n")
+	digest := sha256.Sum256([]byte(request))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(digest[:])
+
+	signingKey := []byte("AWS4" + secretKey)
+	for _, part := range []string{stamp[:8], region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, toSign))
+	return endpoint.Scheme + "://" + endpoint.Host + path + "?" + canonicalQuery + "&X-Amz-Signature=" + signature, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes a query string the way SigV4 signs it: sorted by
+// name, with everything but unreserved characters percent-encoded.
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for _, name := range slices.Sorted(maps.Keys(query)) {
+		for _, value := range query[name] {
+			pairs = append(pairs, awsEscape(name, false)+"="+awsEscape(value, false))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape percent-encodes s as SigV4 expects, leaving slashes alone in
+// object keys.
+func awsEscape(s string, keepSlash bool) string {
+	var out strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && keepSlash:
+			out.WriteByte(c)
+		default:
+			fmt.Fprintf(&out, "%%%02X", c)
+		}
+	}
+	return out.String()
+}
+
+// listBucket lists the site's files in the bucket with ListObjectsV2.
+func listBucket(siteName string, b Bucket) ([]FileInfo, error) {
+	prefix := b.key("")
+	var files []FileInfo
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if prefix != "" {
+			query.Set("prefix", prefix)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		endpoint, err := b.presign("GET", "", query, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		call, err := newAPICall(opMetadata, "GET", endpoint, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %v", err)
+		}
+		resp, err := call.do()
+		if err != nil {
+			call.close()
+			return nil, fmt.Errorf("error connecting to bucket: %v", err)
+		}
+
+		var result struct {
+			Contents []struct {
+				Key          string
+				LastModified time.Time
+				Size         int64
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		if resp.StatusCode != http.StatusOK {
+			err = call.fail(resp, "failed to list bucket")
+		} else if decodeErr := xml.NewDecoder(resp.Body).Decode(&result); decodeErr != nil {
+			err = fmt.Errorf("error parsing bucket listing: %v", decodeErr)
+		}
+		resp.Body.Close()
+		call.close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			name := strings.TrimPrefix(object.Key, prefix)
+			if name == "" || strings.HasSuffix(name, "/") {
+				continue // folder markers
+			}
+			files = append(files, FileInfo{
+				ID:         remoteFileID(siteName, object.Key),
+				FileName:   name,
+				Size:       object.Size,
+				ModifiedAt: object.LastModified,
+			})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return files, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// putObject uploads a file to the bucket as name and returns the MD5 of
+// the bytes sent and the ETag the bucket gave the object.
+func putObject(siteName string, b Bucket, path, name string, retries int) (sum, etag string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", "", fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", "", fmt.Errorf("error opening file: %v", err)
+	}
+
+	endpoint, err := b.presign("PUT", b.key(name), nil, time.Now())
+	if err != nil {
+		return "", "", err
+	}
+	hash := md5.New()
+	call, err := newAPICall(opTransfer, "PUT", endpoint, io.TeeReader(file, hash))
+	if err != nil {
+		return "", "", fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+	call.req.ContentLength = info.Size()
+	call.req.Header.Set("Content-Type", detectContentType(path))
+
+	stat := newTransferStat(directionUpload, endpoint, siteName, name, retries)
+	call.live = stat.live
+	call.live.setTotal(info.Size())
+	defer func() { stat.finish(info.Size(), err) }()
+
+	resp, err := call.do()
+	if err != nil {
+		return "", "", fmt.Errorf("error uploading file: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", call.fail(resp, "failed to upload file")
+	}
+	return hex.EncodeToString(hash.Sum(nil)), strings.Trim(resp.Header.Get("ETag"), `"`), nil
+}
+
+// getObject downloads a file of the site from the bucket into w.
+func getObject(siteName string, b Bucket, fileID int, fileName string, w io.Writer) (n int64, err error) {
+	key, ok := remoteKey(siteName, fileID)
+	if !ok {
+		key = b.key(fileName)
+	}
+	endpoint, err := b.presign("GET", key, nil, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	call, err := newAPICall(opTransfer, "GET", endpoint, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+
+	stat := newTransferStat(directionDownload, endpoint, siteName, fileName, 0)
+	call.live = stat.live
+	defer func() { stat.finish(n, err) }()
+
+	resp, err := call.do()
+	if err != nil {
+		return 0, fmt.Errorf("error downloading file: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, call.fail(resp, "failed to download file")
+	}
+	stat.live.setTotal(resp.ContentLength)
+	n, err = io.Copy(w, call.watch(resp.Body))
+	if err != nil {
+		return n, fmt.Errorf("error downloading file: %v", err)
+	}
+	if resp.ContentLength >= 0 && n != resp.ContentLength {
+		return n, fmt.Errorf("error downloading file: got %d of %d bytes", n, resp.ContentLength)
+	}
+	return n, nil
+}
+
+// bucketBackend serves a site from its bucket. Downloads share
+// downloadFile's cache handling, as fetchFile reads from the bucket.
+type bucketBackend struct {
+	bucket Bucket
+}
+
+func (b bucketBackend) list(siteName string) ([]FileInfo, error) {
+	return listBucket(siteName, b.bucket)
+}
+
+func (b bucketBackend) fetch(siteName string, fileID int, fileName string, w io.Writer) (int64, error) {
+	return getObject(siteName, b.bucket, fileID, fileName, w)
+}
+
+func (b bucketBackend) createSite(siteName string, password secret, enableTOTP bool, ttl time.Duration) tea.Cmd {
+	return func() tea.Msg {
+		return fmt.Errorf("%s is backed by bucket %s, which is created with your storage provider rather than by cshare", siteName, b.bucket.Bucket)
+	}
+}
+
+func (b bucketBackend) openSite(siteName string, password, totpCode secret) tea.Cmd {
+	return func() tea.Msg {
+		files, err := listBucket(siteName, b.bucket)
+		if err != nil {
+			return err
+		}
+		return siteLoadedMsg{files: files}
+	}
+}
+
+// upload puts the file in the bucket and checks it against the object's
+// ETag, which is the MD5 of its content for single-request uploads to
+// unencrypted buckets.
+func (b bucketBackend) upload(siteName string, password secret, path, name string) (uploadedMsg, error) {
+	var result uploadedMsg
+	for attempt := 0; attempt <= verifyRetries(); attempt++ {
+		sum, etag, err := putObject(siteName, b.bucket, path, name, attempt)
+		if err != nil {
+			return result, err
+		}
+
+		files, err := listBucket(siteName, b.bucket)
+		if err != nil {
+			return result, fmt.Errorf("file uploaded but error refreshing list: %v", err)
+		}
+		result = uploadedMsg{files: files, fileID: -1}
+		if fileID, ok := findUploadedFile(files, name); ok {
+			result.fileID = fileID
+		}
+
+		switch {
+		case etag == sum:
+			result.verified = true
+			result.status = success("File uploaded and verified!")
+			return result, nil
+		case len(etag) != md5.Size*2 || strings.Contains(etag, "-"):
+			result.status = notice("File uploaded, but it could not be verified: the bucket's ETag isn't an MD5")
+			return result, nil
+		}
+	}
+	result.status = notice("Upload checksum mismatch: the bucket's copy differs from the local file")
+	return result, nil
+}
+
+func (b bucketBackend) download(siteName string, fileID int, fileName, dest string) tea.Cmd {
+	return downloadFile(siteName, fileID, fileName, dest)
+}
+
+func (b bucketBackend) deleteFile(siteName string, fileID int, fileName string) tea.Cmd {
+	return func() tea.Msg {
+		key, ok := remoteKey(siteName, fileID)
+		if !ok {
+			key = b.bucket.key(fileName)
+		}
+		endpoint, err := b.bucket.presign("DELETE", key, nil, time.Now())
+		if err != nil {
+			return err
+		}
+		call, err := newAPICall(opMetadata, "DELETE", endpoint, nil)
+		if err != nil {
+			return fmt.Errorf("error creating request: %v", err)
+		}
+		defer call.close()
+		resp, err := call.do()
+		if err != nil {
+			return fmt.Errorf("failed to delete %s: %v", fileName, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+			return call.fail(resp, "failed to delete "+fileName)
+		}
+		return fileDeletedMsg{fileID: fileID, fileName: fileName}
+	}
+}
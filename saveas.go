@@ -0,0 +1,102 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// openSaveAs shows the save-as prompt for the file about to be uploaded,
+// filled in with the name it would get. Folders and several files keep
+// their own names.
+func openSaveAs(m *Model) {
+	if m.fileToUpload == "" {
+		m.status = notice("Save as is for a single file; folders and batches keep their names")
+		return
+	}
+	m.nameInput = cmp.Or(m.uploadAs, uploadName(m.fileToUpload))
+	m.naming = true
+}
+
+// handleSaveAsInput handles input on the save-as prompt. The name is made
+// portable the same way local names are, and setting it back to the
+// file's own name drops the custom one.
+func handleSaveAsInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		name := strings.TrimSpace(m.nameInput)
+		if name == "" {
+			m.status = notice("Enter a name to save the file as")
+			return m, nil
+		}
+		m.uploadAs = pathElement(name)
+		if m.uploadAs == uploadName(m.fileToUpload) {
+			m.uploadAs = ""
+		}
+		m.naming = false
+		m.status = statusLine{}
+	case "esc":
+		m.naming = false
+	case "backspace":
+		m.nameInput = deleteLastChar(m.nameInput)
+	default:
+		m.nameInput += typedText(msg)
+	}
+	return m, nil
+}
+
+// renderSaveAs renders the save-as prompt while it is open, and otherwise
+// the name a single file will be saved as.
+func renderSaveAs(m Model) string {
+	switch {
+	case m.naming:
+		return "Save as: " + m.nameInput + "█\n" + mutedStyle.Render("Enter - Use Name • Esc - Cancel")
+	case m.fileToUpload == "":
+		return ""
+	case m.uploadAs != "":
+		return "Save as: " + m.uploadAs + mutedStyle.Render(" (N - Rename)")
+	}
+	return mutedStyle.Render("N - Save under another name")
+}
+
+// nameArgs removes a --name NAME (or --name=NAME) option from args and
+// returns the name, which `cshare --name NAME <file>` uploads the file as.
+func nameArgs(args []string) ([]string, string, error) {
+	var rest []string
+	name := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		value, ok := strings.CutPrefix(arg, "--name=")
+		if !ok && arg == "--name" {
+			if i+1 == len(args) {
+				return nil, "", fmt.Errorf("--name needs the name to save the file as")
+			}
+			i++
+			value, ok = args[i], true
+		}
+		if !ok {
+			rest = append(rest, arg)
+			continue
+		}
+		if strings.TrimSpace(value) == "" {
+			return nil, "", fmt.Errorf("--name needs the name to save the file as")
+		}
+		name = pathElement(strings.TrimSpace(value))
+	}
+	return rest, name, nil
+}
+
+// checkNameTarget reports whether paths can take a --name: it renames one
+// file, so folders and several files are refused.
+func checkNameTarget(paths []string) error {
+	if len(paths) != 1 {
+		return fmt.Errorf("--name renames a single file, but %d were given", len(paths))
+	}
+	if info, err := os.Stat(paths[0]); err == nil && info.IsDir() {
+		return fmt.Errorf("--name renames a single file, but %s is a folder", paths[0])
+	}
+	return nil
+}
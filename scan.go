@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Virus-scan statuses, as a listing's scan_status. Files of servers that
+// don't scan have none.
+const (
+	scanPending = "pending"
+	scanClean   = "clean"
+	scanFlagged = "flagged"
+)
+
+// scanPollInterval is how often the listing is re-fetched while a file of
+// the open site is still being scanned.
+const scanPollInterval = 15 * time.Second
+
+// scanMarks flag files in the list whose scan isn't clean. Clean files
+// show their status in the details pane only.
+var scanMarks = map[string]string{
+	scanPending: " ◌ scanning",
+	scanFlagged: " ⚠ flagged",
+}
+
+// scanLabels describe each status in the details pane.
+var scanLabels = map[string]string{
+	scanPending: "scanning...",
+	scanClean:   "clean",
+	scanFlagged: "⚠ flagged by the server",
+}
+
+// scanTickMsg fires every scanPollInterval.
+type scanTickMsg struct{}
+
+// scanPolledMsg carries the listing fetched to update scan statuses.
+type scanPolledMsg struct {
+	site      string
+	files     []FileInfo
+	unchanged bool
+}
+
+// scanTick schedules the next scan status check.
+func scanTick() tea.Cmd {
+	return tea.Tick(scanPollInterval, func(time.Time) tea.Msg {
+		return scanTickMsg{}
+	})
+}
+
+// scanning reports whether any of files is waiting for its scan.
+func scanning(files []FileInfo) bool {
+	for _, file := range files {
+		if file.ScanStatus == scanPending {
+			return true
+		}
+	}
+	return false
+}
+
+// handleScanTick re-fetches the open site's listing while a file is still
+// being scanned, then schedules the next check.
+func handleScanTick(m *Model) (tea.Model, tea.Cmd) {
+	if !inSite(m.state) || m.listStream != nil || serverUnreachable(m) || !scanning(m.files) {
+		return m, scanTick()
+	}
+	siteName, password := m.siteName, m.password
+	poll := func() tea.Msg {
+		files, unchanged, err := refreshFiles(siteName, password)
+		if err != nil {
+			// The next check tries again; real requests report failures.
+			return nil
+		}
+		return scanPolledMsg{site: siteName, files: files, unchanged: unchanged}
+	}
+	return m, tea.Batch(poll, scanTick())
+}
+
+// handleScanPolled updates the scan statuses of the listed files, leaving
+// the rest of the list as it is, and reports files that were just flagged.
+func handleScanPolled(m *Model, msg scanPolledMsg) (tea.Model, tea.Cmd) {
+	if msg.site != m.siteName || msg.unchanged {
+		return m, nil
+	}
+	statuses := make(map[int]string, len(msg.files))
+	for _, file := range msg.files {
+		statuses[file.ID] = file.ScanStatus
+	}
+	var flagged []string
+	for i, file := range m.files {
+		status, ok := statuses[file.ID]
+		if !ok || status == file.ScanStatus {
+			continue
+		}
+		if status == scanFlagged {
+			flagged = append(flagged, file.FileName)
+		}
+		m.files[i].ScanStatus = status
+	}
+	switch len(flagged) {
+	case 0:
+	case 1:
+		m.status = notice("⚠ The virus scan flagged " + flagged[0])
+	default:
+		m.status = notice(fmt.Sprintf("⚠ The virus scan flagged %d files", len(flagged)))
+	}
+	return m, nil
+}
+
+// flaggedFiles returns the files among files that the open site lists as
+// flagged by its virus scan. Files queued without their listing entry, such
+// as recent files, are looked up by ID.
+func flaggedFiles(m *Model, site string, files []FileInfo) []FileInfo {
+	if site != m.siteName {
+		return nil
+	}
+	statuses := make(map[int]string, len(m.files))
+	for _, file := range m.files {
+		statuses[file.ID] = file.ScanStatus
+	}
+	var flagged []FileInfo
+	for _, file := range files {
+		if file.ScanStatus == scanFlagged || statuses[file.ID] == scanFlagged {
+			flagged = append(flagged, file)
+		}
+	}
+	return flagged
+}
+
+// askFlaggedDownload asks before downloading files the virus scan flagged:
+// they are skipped unless the user picks "Download anyway". Esc cancels
+// the whole download.
+func askFlaggedDownload(m *Model, site string, files, flagged []FileInfo) {
+	prompt := flagged[0].FileName + " was flagged by the virus scan"
+	if len(flagged) > 1 {
+		prompt = fmt.Sprintf("%d files were flagged by the virus scan (%s, ...)", len(flagged), flagged[0].FileName)
+	}
+	m.confirm = &confirmation{
+		prompt:  prompt,
+		detail:  "The server found something harmful in it. Only download it if you trust where it came from.",
+		choices: []string{"Skip", "Download anyway"},
+		keys:    map[string]int{"s": 0, "d": 1},
+		onPick: func(m *Model, choice int) tea.Cmd {
+			if choice == 0 {
+				files = slices.DeleteFunc(slices.Clone(files), func(file FileInfo) bool {
+					return slices.ContainsFunc(flagged, func(f FileInfo) bool { return f.ID == file.ID })
+				})
+				if len(files) == 0 {
+					m.status = notice("Skipped the flagged download")
+					return nil
+				}
+			}
+			return enqueueDownloads(m, site, files)
+		},
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+// redacted is what a secret prints as.
+const redacted = "[redacted]"
+
+// secret holds a password, token or two-factor code. It prints as
+// "[redacted]" however it is formatted, so it can't slip into an error
+// message, the debug log or a crash report; reveal hands the value to the
+// places that have to send or check it.
+type secret string
+
+func (s secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s secret) GoString() string {
+	return `"` + s.String() + `"`
+}
+
+// MarshalJSON keeps secrets out of anything saved as JSON.
+func (s secret) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+// reveal returns the secret's value.
+func (s secret) reveal() string {
+	return string(s)
+}
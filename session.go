@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// keepaliveInterval is how often an open site session pings the server so
+// that idle connections and auth tokens don't go stale.
+const keepaliveInterval = 5 * time.Minute
+
+// httpClient is shared by all API calls. Idle connections are dropped well
+// before typical server and proxy timeouts, and TCP keepalives are sent on
+// open connections, so the first request after a long idle period doesn't
+// land on a connection the server has already closed.
+var httpClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		IdleConnTimeout:     60 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     &tls.Config{VerifyConnection: verifyPins},
+		MaxIdleConnsPerHost: 4,
+	},
+}
+
+// keepaliveMsg fires every keepaliveInterval.
+type keepaliveMsg struct{}
+
+// pingResultMsg reports the outcome of a keepalive ping.
+type pingResultMsg struct {
+	err error
+}
+
+// keepaliveTick schedules the next keepalive.
+func keepaliveTick() tea.Cmd {
+	return tea.Tick(keepaliveInterval, func(time.Time) tea.Msg {
+		return keepaliveMsg{}
+	})
+}
+
+// inSite reports whether the model is showing one of the site screens.
+func inSite(state viewState) bool {
+	switch state {
+	case stateViewFiles, stateUploadFile, stateMembers, stateInviteMember,
+		stateShareLink, stateSiteSettings, stateDeleteSite, stateDryRun, stateFileFilter,
+		stateCommander, stateDownloadPath, stateEditTags, stateComments, stateChat, stateUploadURL, stateHexView, stateArchive:
+		return true
+	}
+	return false
+}
+
+// handleKeepalive pings the server while a site is open and schedules the
+// next tick.
+func handleKeepalive(m *Model) (tea.Model, tea.Cmd) {
+	if !inSite(m.state) {
+		return m, keepaliveTick()
+	}
+	return m, tea.Batch(pingSession(m.siteName), keepaliveTick())
+}
+
+// pingSession touches the site session so the server keeps the auth token
+// alive. A failed ping drops pooled connections so the next real request
+// dials a fresh one instead of failing on a dead socket.
+func pingSession(siteName string) tea.Cmd {
+	return func() tea.Msg {
+		err := sendPing(siteName)
+		if err != nil {
+			httpClient.CloseIdleConnections()
+		}
+		return pingResultMsg{err: err}
+	}
+}
+
+// sendPing issues a single authorized ping for the site.
+func sendPing(siteName string) error {
+	authToken, err := loadAuthToken()
+	if err != nil {
+		return err
+	}
+
+	call, err := newAPICall(opMetadata, "GET", fmt.Sprintf("%s/ping", serverURL), nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+	call.req.Header.Set("Authorization", authToken.reveal())
+	call.req.Header.Set("X-Site", siteName)
+
+	resp, err := call.do()
+	if err != nil {
+		return fmt.Errorf("error connecting to server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("ping failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// settingsItems are the entries of the site settings screen.
+var settingsItems = []string{
+	"📁 Download Path",
+	"🚪 Log Out",
+	"🗑️  Delete Site",
+}
+
+const (
+	settingsDownloadPath = iota
+	settingsLogOut
+	settingsDeleteSite
+)
+
+// siteDeletedMsg reports that the open site was deleted.
+type siteDeletedMsg struct {
+	siteName string
+}
+
+// handleSiteSettingsInput handles input on the site settings screen.
+func handleSiteSettingsInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up":
+		if m.settingsIdx > 0 {
+			m.settingsIdx--
+		}
+	case "down":
+		if m.settingsIdx < len(settingsItems)-1 {
+			m.settingsIdx++
+		}
+	case "enter":
+		switch m.settingsIdx {
+		case settingsDownloadPath:
+			openDownloadPath(m)
+		case settingsLogOut:
+			return m, logOut(m.siteName)
+		case settingsDeleteSite:
+			m.goTo(stateDeleteSite)
+		}
+	case "esc":
+		m.goTo(stateViewFiles)
+	}
+	return m, nil
+}
+
+// handleDeleteSiteInput handles the type-the-site-name confirmation.
+func handleDeleteSiteInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		if m.deleteConfirm != m.siteName {
+			m.status = notice("Type the site name exactly to confirm")
+			return m, nil
+		}
+		m.status = statusLine{}
+		return m, deleteSite(m.siteName)
+	case "esc":
+		m.goTo(stateSiteSettings)
+	case "backspace":
+		m.deleteConfirm = deleteLastChar(m.deleteConfirm)
+	default:
+		m.deleteConfirm += typedText(msg)
+	}
+	return m, nil
+}
+
+// renderSiteSettings renders the settings entries.
+func renderSiteSettings(cursor int) string {
+	var items strings.Builder
+	for i, item := range settingsItems {
+		if i == cursor {
+			items.WriteString(selectedStyle.Render("➜  " + item))
+		} else {
+			items.WriteString("   " + item)
+		}
+		items.WriteString("\n")
+	}
+	return items.String()
+}
+
+// deleteSite deletes a site and all its files. Only the owner may do this;
+// the server rejects anyone else.
+func deleteSite(siteName string) tea.Cmd {
+	return func() tea.Msg {
+		endpoint := fmt.Sprintf("%s/site/%s", serverURL, url.PathEscape(siteName))
+		if err := sendMemberRequest("DELETE", endpoint, nil); err != nil {
+			return fmt.Errorf("failed to delete site: %w", err)
+		}
+		forgetSite(siteName)
+		return siteDeletedMsg{siteName: siteName}
+	}
+}
+
+// forgetSite drops recent and pinned entries that point at a deleted site.
+func forgetSite(siteName string) {
+	keep := func(items []QuickItem) []QuickItem {
+		var kept []QuickItem
+		for _, item := range items {
+			if item.Site != siteName {
+				kept = append(kept, item)
+			}
+		}
+		return kept
+	}
+	_ = updateConfig(func(cfg *Config) {
+		cfg.Recent = keep(cfg.Recent)
+		cfg.Pinned = keep(cfg.Pinned)
+	})
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// SFTPServer is a folder on an SFTP server backing a site in place of
+// cshare's servers. cshare drives the system's sftp command in batch mode,
+// so the server has to accept a key (from identity, ssh's config or the
+// agent) without a password prompt.
+type SFTPServer struct {
+	// Host is where to connect, as "user@host" or a Host of ~/.ssh/config.
+	Host string `json:"host"`
+	Port int    `json:"port,omitempty"`
+	// Path is the folder holding the site's files; the login folder if
+	// empty.
+	Path string `json:"path,omitempty"`
+	// Identity is the private key file to log in with.
+	Identity string `json:"identity,omitempty"`
+}
+
+// remotePath returns where a file of the site is kept on the server.
+func (s SFTPServer) remotePath(name string) string {
+	if s.Path == "" {
+		return name
+	}
+	return path.Join(s.Path, name)
+}
+
+// sftpQuote quotes a path for an sftp batch command.
+func sftpQuote(p string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(p) + `"`
+}
+
+// run runs batch commands on the server and returns what they printed.
+// sftp stops at the first command that fails; its complaint, the last line
+// it wrote to stderr, becomes the error.
+func (s SFTPServer) run(commands ...string) (string, error) {
+	if _, err := exec.LookPath("sftp"); err != nil {
+		return "", fmt.Errorf("sites on SFTP servers need the sftp command: %v", err)
+	}
+	args := []string{"-b", "-", "-o", "BatchMode=yes"}
+	if timeout := metadataTimeout(); timeout >= time.Second {
+		args = append(args, "-o", "ConnectTimeout="+strconv.Itoa(int(timeout.Seconds())))
+	}
+	if s.Port != 0 {
+		args = append(args, "-P", strconv.Itoa(s.Port))
+	}
+	if s.Identity != "" {
+		args = append(args, "-i", s.Identity)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("sftp", append(args, s.Host)...)
+	cmd.Stdin = strings.NewReader(strings.Join(commands, "\n") + "\n")
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %s", s.Host, msg[strings.LastIndex(msg, "\n")+1:])
+		}
+		return "", fmt.Errorf("%s: %v", s.Host, err)
+	}
+	return stdout.String(), nil
+}
+
+// sftpListLine matches a regular file in the output of sftp's `ls -ln`:
+// mode, links, owner, group, size, date and name.
+var sftpListLine = regexp.MustCompile(`^-\S*\s+\d+\s+\S+\s+\S+\s+(\d+)\s+(\w{3}\s+\d+\s+(?:\d+:\d+|\d{4}))\s(.+)$`)
+
+// parseSFTPList reads the files of an `ls -ln` listing, skipping folders,
+// links and the echoed commands. Dates are in local time, with the time
+// of day for the last six months and the year before that.
+func parseSFTPList(out string, now time.Time) []sftpEntry {
+	var entries []sftpEntry
+	for _, line := range strings.Split(out, "\n") {
+		match := sftpListLine.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if match == nil {
+			continue
+		}
+		size, _ := strconv.ParseInt(match[1], 10, 64)
+		date := strings.Join(strings.Fields(match[2]), " ")
+		modified, err := time.ParseInLocation("Jan 2 2006", date, time.Local)
+		if err != nil {
+			modified, _ = time.ParseInLocation("Jan 2 15:04 2006", date+" "+strconv.Itoa(now.Year()), time.Local)
+			if modified.After(now) {
+				modified = modified.AddDate(-1, 0, 0)
+			}
+		}
+		entries = append(entries, sftpEntry{name: path.Base(match[3]), size: size, modified: modified})
+	}
+	return entries
+}
+
+// sftpEntry is a file listed on an SFTP server.
+type sftpEntry struct {
+	name     string
+	size     int64
+	modified time.Time
+}
+
+// sftpBackend serves a site from a folder on an SFTP server. Downloads
+// share downloadFile's cache handling, as fetchFile reads from the server.
+type sftpBackend struct {
+	server SFTPServer
+}
+
+func (b sftpBackend) list(siteName string) ([]FileInfo, error) {
+	dir := b.server.Path
+	if dir == "" {
+		dir = "."
+	}
+	out, err := b.server.run("ls -ln " + sftpQuote(dir))
+	if err != nil {
+		return nil, fmt.Errorf("error listing %s: %v", siteName, err)
+	}
+	var files []FileInfo
+	for _, entry := range parseSFTPList(out, time.Now()) {
+		files = append(files, FileInfo{
+			ID:         remoteFileID(siteName, b.server.remotePath(entry.name)),
+			FileName:   entry.name,
+			Size:       entry.size,
+			ModifiedAt: entry.modified,
+		})
+	}
+	return files, nil
+}
+
+// fetch gets the file into a temporary folder first, as sftp only writes
+// to files.
+func (b sftpBackend) fetch(siteName string, fileID int, fileName string, w io.Writer) (n int64, err error) {
+	remote, ok := remoteKey(siteName, fileID)
+	if !ok {
+		remote = b.server.remotePath(fileName)
+	}
+	dir, err := os.MkdirTemp("", "cshare-sftp-*")
+	if err != nil {
+		return 0, fmt.Errorf("error downloading file: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	local := filepath.Join(dir, "file")
+
+	stat := newTransferStat(directionDownload, "sftp://"+b.server.Host, siteName, fileName, 0)
+	defer func() { stat.finish(n, err) }()
+	if _, err := b.server.run("get " + sftpQuote(remote) + " " + sftpQuote(local)); err != nil {
+		return 0, fmt.Errorf("error downloading file: %v", err)
+	}
+	file, err := os.Open(local)
+	if err != nil {
+		return 0, fmt.Errorf("error downloading file: %v", err)
+	}
+	defer file.Close()
+	n, err = io.Copy(w, file)
+	if err != nil {
+		return n, fmt.Errorf("error downloading file: %v", err)
+	}
+	return n, nil
+}
+
+func (b sftpBackend) createSite(siteName string, password secret, enableTOTP bool, ttl time.Duration) tea.Cmd {
+	return func() tea.Msg {
+		return fmt.Errorf("%s is kept on %s; create its folder there rather than with cshare", siteName, b.server.Host)
+	}
+}
+
+func (b sftpBackend) openSite(siteName string, password, totpCode secret) tea.Cmd {
+	return func() tea.Msg {
+		files, err := b.list(siteName)
+		if err != nil {
+			return err
+		}
+		return siteLoadedMsg{files: files}
+	}
+}
+
+// upload puts the file on the server and checks the size it lists for
+// it; SFTP has no way to ask for a checksum.
+func (b sftpBackend) upload(siteName string, password secret, path, name string) (result uploadedMsg, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return result, fmt.Errorf("error opening file: %v", err)
+	}
+	stat := newTransferStat(directionUpload, "sftp://"+b.server.Host, siteName, name, 0)
+	stat.live.setTotal(info.Size())
+	_, err = b.server.run("put " + sftpQuote(path) + " " + sftpQuote(b.server.remotePath(name)))
+	stat.finish(info.Size(), err)
+	if err != nil {
+		return result, fmt.Errorf("error uploading file: %v", err)
+	}
+
+	files, err := b.list(siteName)
+	if err != nil {
+		return result, fmt.Errorf("file uploaded but error refreshing list: %v", err)
+	}
+	result = uploadedMsg{files: files, fileID: -1}
+	fileID, ok := findUploadedFile(files, name)
+	if !ok {
+		result.status = notice("File uploaded, but it could not be verified: not found in the site listing")
+		return result, nil
+	}
+	result.fileID = fileID
+	for _, file := range files {
+		if file.ID == fileID && file.Size != info.Size() {
+			result.status = notice(fmt.Sprintf("Upload size mismatch: the server has %s of %s", formatBytes(file.Size), formatBytes(info.Size())))
+			return result, nil
+		}
+	}
+	result.verified = true
+	result.status = success("File uploaded and its size checked!")
+	return result, nil
+}
+
+func (b sftpBackend) download(siteName string, fileID int, fileName, dest string) tea.Cmd {
+	return downloadFile(siteName, fileID, fileName, dest)
+}
+
+func (b sftpBackend) deleteFile(siteName string, fileID int, fileName string) tea.Cmd {
+	return func() tea.Msg {
+		remote, ok := remoteKey(siteName, fileID)
+		if !ok {
+			remote = b.server.remotePath(fileName)
+		}
+		if _, err := b.server.run("rm " + sftpQuote(remote)); err != nil {
+			return fmt.Errorf("failed to delete %s: %v", fileName, err)
+		}
+		return fileDeletedMsg{fileID: fileID, fileName: fileName}
+	}
+}
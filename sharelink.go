@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// slugPattern is what the server accepts as a custom short code.
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,62}[a-z0-9]$`)
+
+// shareLinkMsg carries a newly created share link.
+type shareLinkMsg struct {
+	url    string
+	status statusLine
+}
+
+// handleShareLinkInput handles input on the share link screen.
+func handleShareLinkInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.shareURL != "" {
+		switch msg.String() {
+		case "enter", "esc":
+			m.goTo(stateViewFiles)
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "enter":
+		if m.shareSlug != "" && !slugPattern.MatchString(m.shareSlug) {
+			m.status = notice("Short codes are 3-64 lowercase letters, digits or dashes")
+			return m, nil
+		}
+		file, ok := selectedFile(*m)
+		if !ok {
+			return m, nil
+		}
+		return m, createShareLink(file.ID, m.shareSlug)
+	case "esc":
+		m.goTo(stateViewFiles)
+	case "backspace":
+		m.shareSlug = deleteLastChar(m.shareSlug)
+	default:
+		m.shareSlug += typedText(msg)
+	}
+	return m, nil
+}
+
+// createShareLink creates a share link for a file. A custom slug is used
+// when it is free; otherwise the server's generated code is used instead.
+func createShareLink(fileID int, slug string) tea.Cmd {
+	return func() tea.Msg {
+		status := success("Share link created")
+		if slug != "" {
+			available, err := slugAvailable(slug)
+			if err != nil {
+				return fmt.Errorf("error checking short code: %w", err)
+			}
+			if !available {
+				status = notice(fmt.Sprintf("Short code %q is taken, using a generated one", slug))
+				slug = ""
+			}
+		}
+
+		link, err := requestShareLink(fileID, slug)
+		if err != nil {
+			return fmt.Errorf("failed to create share link: %w", err)
+		}
+		return shareLinkMsg{url: link, status: status}
+	}
+}
+
+// slugAvailable asks the server whether a custom short code is free.
+func slugAvailable(slug string) (bool, error) {
+	authToken, err := loadAuthToken()
+	if err != nil {
+		return false, err
+	}
+
+	endpoint := fmt.Sprintf("%s/s/%s/available", serverURL, url.PathEscape(slug))
+	call, err := newAPICall(opMetadata, "GET", endpoint, nil)
+	if err != nil {
+		return false, fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+	call.req.Header.Set("Authorization", authToken.reveal())
+
+	resp, err := call.do()
+	if err != nil {
+		return false, fmt.Errorf("error connecting to server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, call.fail(resp, "")
+	}
+
+	var result struct {
+		Available bool `json:"available"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("error parsing response: %v", err)
+	}
+	return result.Available, nil
+}
+
+// requestShareLink creates the link, with the server generating the code
+// when slug is empty.
+func requestShareLink(fileID int, slug string) (string, error) {
+	authToken, err := loadAuthToken()
+	if err != nil {
+		return "", err
+	}
+
+	payload := map[string]interface{}{"file_id": fileID}
+	if slug != "" {
+		payload["slug"] = slug
+	}
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("error preparing request: %v", err)
+	}
+
+	call, err := newAPICall(opMetadata, "POST", serverURL+"/share", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+	call.req.Header.Set("Content-Type", "application/json")
+	call.req.Header.Set("Authorization", authToken.reveal())
+
+	resp, err := call.do()
+	if err != nil {
+		return "", fmt.Errorf("error connecting to server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return "", call.fail(resp, "")
+	}
+
+	var result struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("error parsing response: %v", err)
+	}
+	return result.URL, nil
+}
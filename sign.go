@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Signing tools, as set by CSHARE_SIGN, and the extension each gives the
+// detached signature uploaded next to a file.
+const (
+	signGPG      = "gpg"
+	signMinisign = "minisign"
+)
+
+var signatureExts = map[string]string{
+	signGPG:      ".asc",
+	signMinisign: ".minisig",
+}
+
+// signatureCheck is the outcome of checking a downloaded file against its
+// signature: the trusted key that made it, or why none did.
+type signatureCheck struct {
+	signer string
+	err    error
+}
+
+// signTool reads CSHARE_SIGN, the tool uploads are signed with; "" (or
+// "off") leaves them unsigned.
+func signTool() string {
+	switch tool := os.Getenv("CSHARE_SIGN"); tool {
+	case signGPG, signMinisign:
+		return tool
+	}
+	return ""
+}
+
+// trustedKeys reads CSHARE_TRUSTED_KEYS, the public key files downloads
+// are verified against, separated like PATH.
+func trustedKeys() []string {
+	var keys []string
+	for _, key := range filepath.SplitList(os.Getenv("CSHARE_TRUSTED_KEYS")) {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// runTool runs one of the signing tools, folding the last line of its
+// output, which says what went wrong, into the error when it fails.
+func runTool(name string, args ...string) error {
+	var out bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout, cmd.Stderr = &out, &out
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(out.String()); msg != "" {
+			return fmt.Errorf("%v: %s", err, msg[strings.LastIndex(msg, "\n")+1:])
+		}
+		return err
+	}
+	return nil
+}
+
+// signUpload makes a detached signature of the file at path with the tool
+// CSHARE_SIGN names, using the key in CSHARE_SIGN_KEY (a gpg key ID, or a
+// minisign secret key file) or the tool's default. It returns "" when
+// uploads aren't signed; otherwise the signature is in a temporary folder
+// that the caller removes with cleanup. Uploads run in the background, so
+// a minisign key has to be usable without its password prompt, and gpg
+// asks through its agent.
+func signUpload(path string) (sig string, cleanup func(), err error) {
+	tool := signTool()
+	if tool == "" {
+		return "", func() {}, nil
+	}
+	if _, err := exec.LookPath(tool); err != nil {
+		return "", nil, fmt.Errorf("signing uploads with %s needs the %s command: %v", tool, tool, err)
+	}
+	dir, err := os.MkdirTemp("", "cshare-sign-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("error signing %s: %v", filepath.Base(path), err)
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+	sig = filepath.Join(dir, filepath.Base(path)+signatureExts[tool])
+	key := os.Getenv("CSHARE_SIGN_KEY")
+
+	var args []string
+	switch tool {
+	case signGPG:
+		args = []string{"--batch", "--yes", "--armor", "--detach-sign", "--output", sig}
+		if key != "" {
+			args = append(args, "--local-user", key)
+		}
+		err = runTool("gpg", append(args, path)...)
+	case signMinisign:
+		args = []string{"-S", "-m", path, "-x", sig}
+		if key != "" {
+			args = append(args, "-s", key)
+		}
+		err = runTool("minisign", args...)
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("error signing %s: %v", filepath.Base(path), err)
+	}
+	return sig, cleanup, nil
+}
+
+// postSignature uploads a signature next to the file it signs, named after
+// it: "report.pdf" gets "report.pdf.asc" or "report.pdf.minisig".
+func postSignature(siteName, sig, name string) error {
+	if sig == "" {
+		return nil
+	}
+	sigName := name + filepath.Ext(sig)
+	if err := postFile(siteName, sig, sigName, io.Discard, 0); err != nil {
+		return fmt.Errorf("%s uploaded, but its signature wasn't: %v", name, err)
+	}
+	return nil
+}
+
+// signatureOf finds the signature uploaded next to a file in a listing.
+func signatureOf(files []FileInfo, name string) (FileInfo, bool) {
+	for _, file := range files {
+		for _, ext := range signatureExts {
+			if file.FileName == name+ext {
+				return file, true
+			}
+		}
+	}
+	return FileInfo{}, false
+}
+
+// verifySignature checks a downloaded file against its signature, fetched
+// from the site, with each trusted key in turn. It returns the name of the
+// key file that made the signature.
+func verifySignature(siteName string, sigFile FileInfo, path string, keys []string) (string, error) {
+	dir, err := os.MkdirTemp("", "cshare-verify-*")
+	if err != nil {
+		return "", fmt.Errorf("error checking the signature: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	sig := filepath.Join(dir, pathElement(sigFile.FileName))
+	if err := saveFile(sig, func(w io.Writer) (int64, error) {
+		return fetchFile(siteName, sigFile.ID, sigFile.FileName, w)
+	}); err != nil {
+		return "", fmt.Errorf("error fetching the signature: %v", err)
+	}
+
+	tool := signGPG
+	if strings.HasSuffix(sigFile.FileName, signatureExts[signMinisign]) {
+		tool = signMinisign
+	}
+	if _, err := exec.LookPath(tool); err != nil {
+		return "", fmt.Errorf("checking the signature needs the %s command: %v", tool, err)
+	}
+	for i, key := range keys {
+		var err error
+		if tool == signMinisign {
+			err = runTool("minisign", "-V", "-q", "-p", key, "-m", path, "-x", sig)
+		} else {
+			// A keyring of just this key, so only it can vouch for the file.
+			home := filepath.Join(dir, fmt.Sprintf("gnupg-%d", i))
+			if err = os.Mkdir(home, 0700); err == nil {
+				if err = runTool("gpg", "--batch", "--homedir", home, "--import", key); err == nil {
+					err = runTool("gpg", "--batch", "--homedir", home, "--verify", sig, path)
+				}
+			}
+		}
+		if err == nil {
+			return filepath.Base(key), nil
+		}
+	}
+	return "", fmt.Errorf("the signature doesn't match any trusted key")
+}
+
+// recordSignature keeps how a downloaded file's signature checked out for
+// the list's badge, and says so in the download's status.
+func recordSignature(m *Model, fileID int, check signatureCheck) {
+	if m.signatures == nil {
+		m.signatures = make(map[int]signatureCheck)
+	}
+	m.signatures[fileID] = check
+	if check.err != nil {
+		m.status = notice(fmt.Sprintf("⚠ %s; its signature didn't check out: %v", m.status.text, check.err))
+		return
+	}
+	m.status.text += " (signed by " + check.signer + ")"
+}
+
+// signatureMark badges files whose download was checked against their
+// signature.
+func signatureMark(m Model, fileID int) string {
+	check, ok := m.signatures[fileID]
+	switch {
+	case !ok:
+		return ""
+	case check.err != nil:
+		return " ⚠ bad signature"
+	}
+	return " 🔏"
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// viewState identifies a screen of the TUI.
+type viewState int
+
+// anyState in a route's next list allows moving to every screen.
+const anyState viewState = -1
+
+func (s viewState) String() string {
+	if s == anyState {
+		return "*"
+	}
+	if s < 0 || s >= stateCount {
+		return fmt.Sprintf("viewState(%d)", int(s))
+	}
+	return stateNames[s]
+}
+
+// route declares how a screen is entered and left: the screens it may move
+// to, a guard that must pass before it is entered, and hooks that run on
+// entry and exit. Every screen needs a route; goTo refuses transitions that
+// aren't declared here.
+type route struct {
+	next    []viewState
+	guard   func(m *Model) error
+	onEnter func(m *Model)
+	onExit  func(m *Model)
+}
+
+// globalTargets can be reached from every screen: errors fall back to the
+// menu, Ctrl+O opens the quick switcher, Ctrl+B the transfers and Ctrl+W
+// the session panel anywhere.
+var globalTargets = []viewState{stateMenu, stateQuickOpen, stateTransfers, stateSession}
+
+// siteScreens are the screens that operate on the open site.
+var siteScreens = []viewState{
+	stateViewFiles, stateUploadFile, stateMembers, stateInviteMember,
+	stateShareLink, stateSiteSettings, stateDeleteSite, stateDownloadPath,
+}
+
+// routes is filled in by init, since the hooks refer back to goTo.
+var routes map[viewState]route
+
+func init() {
+	routes = map[viewState]route{
+		stateMenu: {
+			next:    []viewState{stateSiteName, stateCreateSiteName, stateLogin, stateMySites, stateDiagnose, stateHistory, statePassword, stateErrorDetail},
+			onEnter: enterMenu,
+		},
+		stateSiteName:       {next: []viewState{statePassword, stateViewFiles, stateUploadFile}},
+		statePassword:       {next: []viewState{stateTOTP, stateViewFiles, stateUploadFile}, onExit: hidePassword},
+		stateTOTP:           {next: []viewState{statePassword, stateViewFiles, stateUploadFile}},
+		stateCreateSiteName: {next: []viewState{stateCreatePassword}},
+		stateCreatePassword: {next: []viewState{stateCreateSiteName, stateCreateConfirm}, onExit: hidePassword},
+		stateCreateConfirm: {
+			next:    []viewState{stateCreatePassword, stateTOTPSetup},
+			onEnter: func(m *Model) { m.confirmPassword = "" },
+			onExit:  hidePassword,
+		},
+		stateTOTPSetup: {
+			guard: func(m *Model) error {
+				if m.otpauthURL == "" {
+					return fmt.Errorf("no two-factor setup in progress")
+				}
+				return nil
+			},
+			onExit: func(m *Model) { m.otpauthURL = "" },
+		},
+		stateViewFiles: {
+			next:  []viewState{stateUploadFile, stateMembers, stateShareLink, stateSiteSettings, stateDryRun, stateFileFilter, stateCommander, stateEditTags, stateComments, stateChat, stateHexView, stateArchive},
+			guard: requireSite,
+		},
+		stateUploadFile: {
+			next:  []viewState{stateViewFiles, stateDryRun, stateUploadURL},
+			guard: requireSite,
+			onExit: func(m *Model) {
+				m.fileToUpload = ""
+				m.folderToUpload = ""
+				m.pendingUploads = nil
+				m.batchSent = nil
+				m.clipImage = nil
+				m.picking = false
+				m.uploadAs = ""
+				m.naming = false
+			},
+		},
+		stateMembers: {
+			next:  []viewState{stateViewFiles, stateInviteMember},
+			guard: requireSite,
+		},
+		stateInviteMember: {
+			next:  []viewState{stateMembers},
+			guard: requireSite,
+			onEnter: func(m *Model) {
+				m.inviteUser = ""
+				m.inviteRole = inviteRoles[0]
+			},
+			onExit: func(m *Model) { m.inviteUser = "" },
+		},
+		stateShareLink: {
+			next:  []viewState{stateViewFiles},
+			guard: requireSite,
+			onEnter: func(m *Model) {
+				m.shareSlug = ""
+				m.shareURL = ""
+			},
+			onExit: func(m *Model) {
+				m.shareSlug = ""
+				m.shareURL = ""
+			},
+		},
+		stateSiteSettings: {
+			next:    []viewState{stateViewFiles, stateDeleteSite, stateDownloadPath},
+			guard:   requireSite,
+			onEnter: func(m *Model) { m.settingsIdx = 0 },
+		},
+		stateDeleteSite: {
+			next:    []viewState{stateSiteSettings},
+			guard:   requireSite,
+			onEnter: func(m *Model) { m.deleteConfirm = "" },
+			onExit:  func(m *Model) { m.deleteConfirm = "" },
+		},
+		stateDownloadPath: {
+			next:   []viewState{stateSiteSettings},
+			guard:  requireSite,
+			onExit: func(m *Model) { m.pathTemplate = "" },
+		},
+		stateLogin: {next: []viewState{stateDeviceAuth}},
+		stateDeviceAuth: {
+			onExit: func(m *Model) { m.device = deviceAuth{} },
+		},
+		stateMySites: {
+			next: []viewState{stateViewFiles, stateUploadFile},
+			guard: func(m *Model) error {
+				if m.account == "" {
+					return fmt.Errorf("sign in to list your sites")
+				}
+				return nil
+			},
+		},
+		stateQuickOpen: {
+			next: []viewState{anyState},
+			onEnter: func(m *Model) {
+				m.quickQuery = ""
+				m.quickIdx = 0
+			},
+		},
+		stateHistory: {},
+		stateTransfers: {
+			next: []viewState{anyState},
+		},
+		stateSession: {
+			next: []viewState{anyState},
+		},
+		stateFileFilter: {
+			next:  []viewState{stateViewFiles},
+			guard: requireSite,
+		},
+		stateArchive: {
+			next:   []viewState{stateViewFiles, stateDryRun},
+			guard:  requireSite,
+			onExit: func(m *Model) { m.archiveEntries = nil },
+		},
+		stateHexView: {
+			next:   []viewState{stateViewFiles},
+			guard:  requireSite,
+			onExit: func(m *Model) { m.hexLines = nil },
+		},
+		stateUploadURL: {
+			next:    []viewState{stateUploadFile, stateViewFiles},
+			guard:   requireSite,
+			onEnter: func(m *Model) { m.uploadURL = "" },
+		},
+		stateChat: {
+			next:  []viewState{stateViewFiles},
+			guard: requireSite,
+		},
+		stateComments: {
+			next:  []viewState{stateViewFiles},
+			guard: requireSite,
+			onExit: func(m *Model) {
+				m.comments = nil
+				m.commentInput = ""
+			},
+		},
+		stateEditTags: {
+			next:   []viewState{stateViewFiles},
+			guard:  requireSite,
+			onExit: func(m *Model) { m.tagInput = "" },
+		},
+		stateCommander: {
+			next:  []viewState{stateViewFiles},
+			guard: requireSite,
+		},
+		stateDryRun: {
+			next:  []viewState{stateViewFiles},
+			guard: requireSite,
+		},
+		stateErrorDetail: {
+			guard: func(m *Model) error {
+				if m.lastError == nil {
+					return fmt.Errorf("no error to show")
+				}
+				return nil
+			},
+		},
+		stateDiagnose: {
+			onExit: func(m *Model) { m.diagRunning = false },
+		},
+	}
+	// Catch a screen added without a route or name at startup rather than
+	// the first time someone tries to open it.
+	for s := viewState(0); s < stateCount; s++ {
+		if _, ok := routes[s]; !ok || stateNames[s] == "" {
+			panic(fmt.Sprintf("screen %d has no route or name", int(s)))
+		}
+	}
+}
+
+// goTo moves to another screen if a route allows it and the target's guard
+// passes, running the exit hook of the current screen and the entry hook of
+// the next. It reports whether the move happened; a refused move leaves the
+// reason in the status line.
+func (m *Model) goTo(to viewState) bool {
+	from := m.state
+	if from == to {
+		return true
+	}
+	if !canTransition(from, to) {
+		m.status = notice(fmt.Sprintf("Cannot go from %s to %s", from, to))
+		return false
+	}
+	target := routes[to]
+	if target.guard != nil {
+		if err := target.guard(m); err != nil {
+			m.status = notice(err.Error())
+			return false
+		}
+	}
+	if exit := routes[from].onExit; exit != nil {
+		exit(m)
+	}
+	m.state = to
+	if target.onEnter != nil {
+		target.onEnter(m)
+	}
+	return true
+}
+
+// canTransition reports whether routes allow moving from one screen to
+// another.
+func canTransition(from, to viewState) bool {
+	if _, ok := routes[to]; !ok {
+		return false
+	}
+	for _, target := range globalTargets {
+		if to == target {
+			return true
+		}
+	}
+	for _, next := range routes[from].next {
+		if next == to || next == anyState {
+			return true
+		}
+	}
+	return false
+}
+
+// requireSite guards the site screens: a site must be open and its auth
+// token known, unless a store keeps the site, which signs in with its own
+// credentials. It reads no files, as it runs in Update.
+func requireSite(m *Model) error {
+	if m.siteName == "" {
+		return fmt.Errorf("no site is open")
+	}
+	if _, ok := storeFor(m.siteName); ok {
+		return nil
+	}
+	if _, err := loadAuthToken(); err != nil {
+		return fmt.Errorf("not logged in to %s: %v", m.siteName, err)
+	}
+	return nil
+}
+
+// enterMenu leaves any site work that doesn't outlive the site screens.
+func enterMenu(m *Model) {
+	stopListing(m)
+	m.listStale = time.Time{}
+	m.selectedIdx = 0
+	m.fileOffset = 0
+}
+
+// hidePassword re-masks a revealed password when leaving a password field.
+func hidePassword(m *Model) {
+	m.showPassword = false
+}
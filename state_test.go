@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to viewState
+		want     bool
+	}{
+		{stateMenu, stateSiteName, true},
+		{stateSiteName, statePassword, true},
+		{stateSiteName, stateViewFiles, true}, // sites kept in a store
+		{statePassword, stateTOTP, true},
+		{stateViewFiles, stateUploadFile, true},
+		{stateUploadFile, stateViewFiles, true},
+		{stateSiteSettings, stateDeleteSite, true},
+		// Global targets are reachable from everywhere.
+		{stateDeleteSite, stateMenu, true},
+		{stateHexView, stateQuickOpen, true},
+		{stateCreatePassword, stateTransfers, true},
+		{stateTOTP, stateSession, true},
+		// anyState lets the switcher and panels go anywhere.
+		{stateQuickOpen, stateViewFiles, true},
+		{stateTransfers, stateDownloadPath, true},
+		// Everything else has to be declared.
+		{stateMenu, stateViewFiles, false},
+		{stateSiteName, stateMembers, false},
+		{stateViewFiles, stateDeleteSite, false},
+		{stateHistory, stateViewFiles, false},
+		{stateMenu, viewState(stateCount), false},
+	}
+	for _, tt := range tests {
+		if got := canTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("canTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestRouteGuards(t *testing.T) {
+	tests := []struct {
+		name  string
+		to    viewState
+		token string
+		setup func(m *Model)
+		want  bool
+	}{
+		{"site screen without a site", stateViewFiles, "token", func(m *Model) { m.state = statePassword }, false},
+		{"site screen without a token", stateViewFiles, "", func(m *Model) {
+			m.state = statePassword
+			m.siteName = "notes"
+		}, false},
+		{"site screen", stateViewFiles, "token", func(m *Model) {
+			m.state = statePassword
+			m.siteName = "notes"
+		}, true},
+		{"settings without a site", stateSiteSettings, "token", func(m *Model) { m.state = stateViewFiles }, false},
+		{"two-factor setup without a URL", stateTOTPSetup, "", func(m *Model) { m.state = stateCreateConfirm }, false},
+		{"two-factor setup", stateTOTPSetup, "", func(m *Model) {
+			m.state = stateCreateConfirm
+			m.otpauthURL = "otpauth://totp/notes"
+		}, true},
+		{"my sites signed out", stateMySites, "", nil, false},
+		{"my sites", stateMySites, "", func(m *Model) { m.account = "me" }, true},
+		{"error detail without an error", stateErrorDetail, "", nil, false},
+		{"error detail", stateErrorDetail, "", func(m *Model) { m.lastError = &apiError{what: "failed to fetch site", status: 500} }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("auth_token", tt.token)
+			m := &Model{state: stateMenu}
+			if tt.setup != nil {
+				tt.setup(m)
+			}
+			from := m.state
+			if got := m.goTo(tt.to); got != tt.want {
+				t.Fatalf("goTo(%s) = %v, want %v (%s)", tt.to, got, tt.want, m.status.text)
+			}
+			if want := map[bool]viewState{true: tt.to, false: from}[tt.want]; m.state != want {
+				t.Errorf("state = %s, want %s", m.state, want)
+			}
+			if !tt.want && m.status.text == "" {
+				t.Error("a refused move left no reason")
+			}
+		})
+	}
+}
+
+func TestRouteHooks(t *testing.T) {
+	t.Setenv("auth_token", "token")
+	m := &Model{state: stateMenu}
+	m.goTo(stateSiteName)
+	m.goTo(statePassword)
+	m.showPassword = true
+	m.siteName = "notes"
+	if !m.goTo(stateViewFiles) {
+		t.Fatal(m.status.text)
+	}
+	if m.showPassword {
+		t.Error("leaving the password screen left the password shown")
+	}
+
+	m.goTo(stateUploadFile)
+	m.fileToUpload = "a.txt"
+	m.pendingUploads = []string{"b.txt"}
+	m.goTo(stateViewFiles)
+	if m.fileToUpload != "" || m.pendingUploads != nil {
+		t.Errorf("leaving the upload screen kept its selection: %q %v", m.fileToUpload, m.pendingUploads)
+	}
+}
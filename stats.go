@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+)
+
+// statsPath is the append-only log of transfer statistics.
+const statsPath = ".cshare-stats.jsonl"
+
+// Transfer directions.
+const (
+	directionUpload   = "upload"
+	directionDownload = "download"
+)
+
+// TransferStat is one recorded upload or download.
+type TransferStat struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"`
+	Server    string    `json:"server"`
+	Site      string    `json:"site"`
+	File      string    `json:"file"`
+	Bytes     int64     `json:"bytes"`
+	Seconds   float64   `json:"seconds"`
+	Retries   int       `json:"retries"`
+	ChunkSize int64     `json:"chunk_size"` // bytes per request
+	OK        bool      `json:"ok"`
+	Error     string    `json:"error,omitempty"`
+
+	live *liveTransfer // the progress row, while running
+}
+
+// recordTransfer appends a transfer to the statistics log. Statistics are
+// best effort and never fail a transfer.
+func recordTransfer(stat TransferStat) {
+	f, err := os.OpenFile(statsPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	_ = json.NewEncoder(f).Encode(stat)
+}
+
+// newTransferStat starts a record for a transfer to endpoint and its
+// progress row.
+func newTransferStat(direction, endpoint, siteName, fileName string, retries int) TransferStat {
+	server := endpoint
+	if u, err := url.Parse(endpoint); err == nil {
+		server = u.Host
+	}
+	return TransferStat{
+		Time:      time.Now(),
+		Direction: direction,
+		Server:    server,
+		Site:      siteName,
+		File:      fileName,
+		Retries:   retries,
+		live:      startTransfer(direction, fileName),
+	}
+}
+
+// finish completes and records the transfer.
+func (s TransferStat) finish(bytes int64, err error) {
+	s.Seconds = time.Since(s.Time).Seconds()
+	s.Bytes = bytes
+	if s.ChunkSize == 0 {
+		s.ChunkSize = bytes
+	}
+	s.OK = err == nil
+	if err != nil {
+		s.Error = err.Error()
+	}
+	s.live.end(bytes, err)
+	countTransfer(s)
+	recordTransfer(s)
+}
+
+// loadTransferStats reads the statistics log.
+func loadTransferStats() ([]TransferStat, error) {
+	f, err := os.Open(statsPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", statsPath, err)
+	}
+	defer f.Close()
+
+	var stats []TransferStat
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var stat TransferStat
+		if err := json.Unmarshal(scanner.Bytes(), &stat); err == nil {
+			stats = append(stats, stat)
+		}
+	}
+	return stats, scanner.Err()
+}
+
+// sizeBuckets group transfers by size so slowdowns on large files stand out.
+var sizeBuckets = []struct {
+	label string
+	max   int64
+}{
+	{"< 1 MB", 1 << 20},
+	{"1-10 MB", 10 << 20},
+	{"10-100 MB", 100 << 20},
+	{"> 100 MB", 1<<63 - 1},
+}
+
+func sizeBucket(bytes int64) string {
+	for _, bucket := range sizeBuckets {
+		if bytes < bucket.max {
+			return bucket.label
+		}
+	}
+	return sizeBuckets[len(sizeBuckets)-1].label
+}
+
+// statGroup aggregates transfers sharing a server, direction and size.
+type statGroup struct {
+	server, direction, bucket string
+	count, failed, retries    int
+	bytes                     int64
+	seconds                   float64
+}
+
+// runStatsCommand implements `cshare stats transfers`.
+func runStatsCommand(args []string) error {
+	if len(args) != 1 || args[0] != "transfers" {
+		return fmt.Errorf("usage: cshare stats transfers")
+	}
+
+	stats, err := loadTransferStats()
+	if err != nil {
+		return err
+	}
+	if len(stats) == 0 {
+		fmt.Println("No transfers recorded yet.")
+		return nil
+	}
+
+	groups := make(map[string]*statGroup)
+	for _, stat := range stats {
+		bucket := sizeBucket(stat.Bytes)
+		key := stat.Server + "|" + stat.Direction + "|" + bucket
+		g, ok := groups[key]
+		if !ok {
+			g = &statGroup{server: stat.Server, direction: stat.Direction, bucket: bucket}
+			groups[key] = g
+		}
+		g.count++
+		g.retries += stat.Retries
+		if !stat.OK {
+			g.failed++
+			continue
+		}
+		g.bytes += stat.Bytes
+		g.seconds += stat.Seconds
+	}
+
+	sorted := make([]*statGroup, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	bucketOrder := make(map[string]int)
+	for i, bucket := range sizeBuckets {
+		bucketOrder[bucket.label] = i
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.server != b.server {
+			return a.server < b.server
+		}
+		if a.direction != b.direction {
+			return a.direction < b.direction
+		}
+		return bucketOrder[a.bucket] < bucketOrder[b.bucket]
+	})
+
+	fmt.Printf("%-28s %-9s %-10s %6s %6s %8s %12s\n", "SERVER", "DIRECTION", "SIZE", "COUNT", "FAILED", "RETRIES", "AVG SPEED")
+	for _, g := range sorted {
+		speed := "-"
+		if g.seconds > 0 {
+			speed = formatBytes(int64(float64(g.bytes)/g.seconds)) + "/s"
+		}
+		fmt.Printf("%-28s %-9s %-10s %6d %6d %8d %12s\n", g.server, g.direction, g.bucket, g.count, g.failed, g.retries, speed)
+	}
+	return nil
+}
+
+// formatBytes renders a byte count with a binary unit.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
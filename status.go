@@ -0,0 +1,38 @@
+package main
+
+// statusKind says how the status line under the header is drawn.
+type statusKind int
+
+const (
+	// statusNotice is for errors, warnings and notes on work in progress.
+	statusNotice statusKind = iota
+	// statusSuccess is for actions that completed.
+	statusSuccess
+)
+
+// statusLine is what the last action reported. Handlers set it from the
+// typed messages of their operations, and its kind alone picks the style.
+type statusLine struct {
+	kind statusKind
+	text string
+}
+
+// notice returns the status of an error, a warning or a note.
+func notice(text string) statusLine {
+	return statusLine{kind: statusNotice, text: text}
+}
+
+// success returns the status of an action that completed.
+func success(text string) statusLine {
+	return statusLine{kind: statusSuccess, text: text}
+}
+
+// render draws the status line, or nothing when there is none.
+func (s statusLine) render() string {
+	switch s.kind {
+	case statusSuccess:
+		return successStyle.Render("✅ " + s.text)
+	default:
+		return errorStyle.Render("❌ " + s.text)
+	}
+}
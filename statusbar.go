@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// statusInterval is how often the status bar refreshes its transfer speed.
+const statusInterval = time.Second
+
+// probeEvery is how many status ticks pass between server health checks.
+const probeEvery = 30
+
+// statusTickMsg drives the status bar's background refresh.
+type statusTickMsg struct{}
+
+// statusTick schedules the next status bar refresh.
+func statusTick() tea.Cmd {
+	return tea.Tick(statusInterval, func(time.Time) tea.Msg {
+		return statusTickMsg{}
+	})
+}
+
+// handleStatusTick updates the transfer speeds and, every probeEvery ticks,
+// checks the server's health.
+func handleStatusTick(m *Model) (tea.Model, tea.Cmd) {
+	total := transferredBytes.Load()
+	rate := float64(total-m.lastBytes) / statusInterval.Seconds()
+	m.lastBytes = total
+	// Smooth over a few seconds so the readout doesn't jitter.
+	m.speed = 0.6*m.speed + 0.4*rate
+	if m.speed < 1 {
+		m.speed = 0
+	}
+	sampleTransfers(m)
+	sampleQueues(m)
+
+	m.statusTicks++
+	if m.statusTicks%probeEvery == 1 {
+		return m, tea.Batch(probeHealth, statusTick())
+	}
+	return m, statusTick()
+}
+
+// healthDots color the status bar's health indicator.
+var healthDots = map[healthLevel]lipgloss.Style{
+	healthGood: lipgloss.NewStyle().Background(barColor).Foreground(accentColor),
+	healthSlow: lipgloss.NewStyle().Background(barColor).Foreground(highlightColor),
+	healthDown: lipgloss.NewStyle().Background(barColor).Foreground(errorColor),
+}
+
+// renderStatusBar shows the screen's own status on the left and the
+// server health, transfers, queue and open site on the right.
+func renderStatusBar(m Model) string {
+	var parts []string
+	if m.health.level != healthUnknown {
+		parts = append(parts, healthDots[m.health.level].Render("●")+" "+m.health.summary())
+	}
+
+	active := m.transfers
+	if m.batchStream != nil {
+		active++
+	}
+	if active > 0 {
+		transfer := fmt.Sprintf("⇅ %d", active)
+		if m.speed > 0 {
+			transfer += " @ " + formatBytes(int64(m.speed)) + "/s"
+		}
+		parts = append(parts, transfer)
+	}
+	if queued := len(m.downloads.items) + len(m.pendingUploads); queued > 0 {
+		parts = append(parts, fmt.Sprintf("%d queued", queued))
+	}
+	if len(m.offline) > 0 {
+		parts = append(parts, fmt.Sprintf("⏸ %d pending", len(m.offline)))
+	}
+	if presence := renderPresence(m); presence != "" && inSite(m.state) {
+		parts = append(parts, presence)
+	}
+	if m.chatUnread > 0 && inSite(m.state) {
+		parts = append(parts, fmt.Sprintf("💬 %d new", m.chatUnread))
+	}
+	if m.dryRun {
+		parts = append(parts, "dry run")
+	}
+	if m.siteName != "" && inSite(m.state) {
+		parts = append(parts, "site: "+m.siteName)
+	}
+
+	left := getStatusText(m)
+	right := strings.Join(parts, " | ")
+	width := statusBarStyle.GetWidth() - statusBarStyle.GetHorizontalPadding()
+	gap := width - lipgloss.Width(left) - lipgloss.Width(right)
+	if right == "" || gap < 1 {
+		// Too narrow for both: the right-hand summary wins while something
+		// is happening, since the screen's hints are also shown in the box.
+		if right != "" && active > 0 {
+			return statusBarStyle.Render(right)
+		}
+		return statusBarStyle.Render(left)
+	}
+	return statusBarStyle.Render(left + strings.Repeat(" ", gap) + right)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxTags caps the tags of one file so the list stays readable.
+const maxTags = 8
+
+// tagsSavedMsg reports that a file's tags were stored on the server.
+type tagsSavedMsg struct {
+	fileID int
+	tags   []string
+}
+
+// parseTags turns the tag editor's text into tags: comma or space
+// separated, lowercased, without a leading # and without duplicates.
+func parseTags(text string) ([]string, error) {
+	var tags []string
+	for _, field := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ' ' }) {
+		tag := strings.ToLower(strings.TrimLeft(field, "#"))
+		if tag == "" || slices.Contains(tags, tag) {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	if len(tags) > maxTags {
+		return nil, fmt.Errorf("a file can have at most %d tags", maxTags)
+	}
+	return tags, nil
+}
+
+// siteTags lists every tag used in the site, sorted.
+func siteTags(files []FileInfo) []string {
+	var tags []string
+	for _, file := range files {
+		for _, tag := range file.Tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// openTagEditor edits the tags of the selected file.
+func openTagEditor(m *Model) {
+	file, ok := selectedFile(*m)
+	if !ok {
+		return
+	}
+	m.tagFile = file
+	m.tagInput = strings.Join(file.Tags, ", ")
+	m.goTo(stateEditTags)
+}
+
+// handleTagInput handles input in the tag editor.
+func handleTagInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		tags, err := parseTags(m.tagInput)
+		if err != nil {
+			m.status = notice(err.Error())
+			return m, nil
+		}
+		m.status = statusLine{}
+		return m, saveTags(m.siteName, m.tagFile.ID, tags)
+	case "esc":
+		m.goTo(stateViewFiles)
+	case "backspace":
+		m.tagInput = deleteLastChar(m.tagInput)
+	default:
+		m.tagInput += typedText(msg)
+	}
+	return m, nil
+}
+
+// saveTags replaces a file's tags on the server.
+func saveTags(siteName string, fileID int, tags []string) tea.Cmd {
+	return func() tea.Msg {
+		if tags == nil {
+			tags = []string{}
+		}
+		jsonData, err := json.Marshal(map[string][]string{"tags": tags})
+		if err != nil {
+			return fmt.Errorf("error encoding tags: %v", err)
+		}
+		endpoint := fmt.Sprintf("%s/site/%s/files/%d/tags", serverURL, url.PathEscape(siteName), fileID)
+		if err := sendMemberRequest("PUT", endpoint, bytes.NewBuffer(jsonData)); err != nil {
+			return fmt.Errorf("failed to save tags: %w", err)
+		}
+		return tagsSavedMsg{fileID: fileID, tags: tags}
+	}
+}
+
+// handleTagsSaved updates the tagged file in the list.
+func handleTagsSaved(m *Model, msg tagsSavedMsg) (tea.Model, tea.Cmd) {
+	for i := range m.files {
+		if m.files[i].ID == msg.fileID {
+			m.files[i].Tags = msg.tags
+			m.status = success("Tagged " + m.files[i].FileName)
+			if len(msg.tags) == 0 {
+				m.status = success("Removed the tags of " + m.files[i].FileName)
+			}
+		}
+	}
+	if m.state == stateEditTags {
+		m.goTo(stateViewFiles)
+	}
+	keepSelectionVisible(m)
+	return m, nil
+}
+
+// renderTags renders tags as "#a #b" after a file name, in the width
+// cells left on its row.
+func renderTags(tags []string, width int) string {
+	if len(tags) == 0 || width < 3 {
+		return ""
+	}
+	return " " + mutedStyle.Render(truncate("#"+strings.Join(tags, " #"), width-1))
+}
+
+// renderTagEditor renders the tag editor with the tags used in the site as
+// suggestions.
+func renderTagEditor(m Model) string {
+	lines := []string{"Tags for " + m.tagFile.FileName + ":", m.tagInput + "█"}
+	if used := siteTags(m.files); len(used) > 0 {
+		lines = append(lines, "", mutedStyle.Render("In use: #"+strings.Join(used, " #")))
+	}
+	return strings.Join(lines, "\n")
+}
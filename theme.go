@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Colors come in light/dark pairs; lipgloss picks one from the detected
+// terminal background, so the UI stays readable on light terminals.
+var (
+	borderColor    = lipgloss.AdaptiveColor{Light: "#B0B0B0", Dark: "#3C3C3C"}
+	accentColor    = lipgloss.AdaptiveColor{Light: "#007A00", Dark: "#00FF00"}
+	barColor       = lipgloss.AdaptiveColor{Light: "#E6E6E6", Dark: "#1A1A1A"}
+	mutedColor     = lipgloss.AdaptiveColor{Light: "#555555", Dark: "#AAAAAA"}
+	errorColor     = lipgloss.AdaptiveColor{Light: "#C00000", Dark: "#FF0000"}
+	selectedColor  = lipgloss.AdaptiveColor{Light: "#006B8F", Dark: "#00FFFF"}
+	highlightColor = lipgloss.AdaptiveColor{Light: "#8A6D00", Dark: "#FFD700"} // Gold
+)
+
+// detectBackground settles the light/dark choice before the TUI starts.
+// lipgloss otherwise queries the terminal lazily on first render, when
+// Bubble Tea already owns stdin and the reply can end up read as keystrokes.
+// CSHARE_THEME=light or dark skips the query for terminals that don't answer
+// it.
+func detectBackground() {
+	switch strings.ToLower(os.Getenv("CSHARE_THEME")) {
+	case "light":
+		lipgloss.SetHasDarkBackground(false)
+	case "dark":
+		lipgloss.SetHasDarkBackground(true)
+	default:
+		lipgloss.SetHasDarkBackground(lipgloss.HasDarkBackground())
+	}
+}
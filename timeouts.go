@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// transferredBytes counts every byte moved by transfer calls, for the
+// status bar's speed readout.
+var transferredBytes atomic.Int64
+
+// opKind selects which timeout policy applies to an API call.
+type opKind int
+
+const (
+	// opMetadata covers small request/response calls: listings, site
+	// creation, member management and pings.
+	opMetadata opKind = iota
+	// opTransfer covers file uploads and downloads.
+	opTransfer
+)
+
+// Default timeouts, overridable through the environment (or .env).
+const (
+	defaultMetadataTimeout = 10 * time.Second
+	defaultTransferTimeout = 0 // unlimited; the stall detector guards transfers
+	defaultStallTimeout    = 30 * time.Second
+)
+
+// timeoutSetting reads a duration such as "15s" or "2m" from the environment.
+// "0" disables the timeout. Unset or invalid values fall back to def.
+func timeoutSetting(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	if value == "0" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return def
+	}
+	return d
+}
+
+func metadataTimeout() time.Duration {
+	return timeoutSetting("CSHARE_METADATA_TIMEOUT", defaultMetadataTimeout)
+}
+
+func transferTimeout() time.Duration {
+	return timeoutSetting("CSHARE_TRANSFER_TIMEOUT", defaultTransferTimeout)
+}
+
+func stallTimeout() time.Duration {
+	return timeoutSetting("CSHARE_STALL_TIMEOUT", defaultStallTimeout)
+}
+
+// apiCall is an HTTP request bound to the deadline of its operation type and,
+// for transfers, to a stall detector that aborts the call when no bytes move
+// for stallTimeout.
+type apiCall struct {
+	req      *http.Request
+	ctx      context.Context
+	cancel   context.CancelCauseFunc
+	stall    *time.Timer
+	limit    time.Duration
+	deadline *time.Timer
+	counted  bool          // bytes read through watch add to transferredBytes
+	live     *liveTransfer // the transfer's progress row, if it has one
+	trace    *requestTrace // phase timings, with CSHARE_DEBUG
+}
+
+// newAPICall builds a request to cshare's servers for the given operation
+// type. The caller must call close once it is done with the response.
+func newAPICall(op opKind, method, url string, body io.Reader) (*apiCall, error) {
+	c, err := newPlainCall(op, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req := c.req
+	applyExtraHeaders(req)
+	req.Header.Set(requestIDHeader, newRequestID())
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	if ownServer(req.URL) {
+		req.Header.Set(apiVersionHeader, strconv.Itoa(clientAPIVersion))
+		// Identify the signed-in user so the server can keep per-user audit
+		// trails alongside the per-site auth token. Other hosts, such as
+		// buckets, never see it.
+		if accountToken := accountToken(); accountToken != "" {
+			req.Header.Set("X-Account-Token", accountToken)
+		}
+	}
+	return c, nil
+}
+
+// newPlainCall builds a request with the timeouts of its operation type
+// but nothing of cshare's: no configured headers, request ID or tokens. It
+// is for third-party URLs, such as ones fetched for upload.
+func newPlainCall(op opKind, method, url string, body io.Reader) (*apiCall, error) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	c := &apiCall{ctx: ctx, cancel: cancel, counted: op == opTransfer}
+
+	timeout := metadataTimeout()
+	if op == opTransfer {
+		timeout = transferTimeout()
+		c.limit = stallTimeout()
+	}
+	if timeout > 0 {
+		c.deadline = time.AfterFunc(timeout, func() {
+			cancel(fmt.Errorf("request timed out after %s", timeout))
+		})
+		context.AfterFunc(ctx, func() { c.deadline.Stop() })
+	}
+	// Keep the length of in-memory bodies so wrapping them for the stall
+	// detector doesn't turn the upload into a chunked request.
+	length := int64(-1)
+	if sized, ok := body.(interface{ Len() int }); ok {
+		length = int64(sized.Len())
+	}
+	if c.limit > 0 {
+		c.stall = time.AfterFunc(c.limit, func() {
+			cancel(fmt.Errorf("transfer stalled: no progress for %s", c.limit))
+		})
+	}
+	if body != nil {
+		body = c.watch(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		cancel(nil)
+		return nil, err
+	}
+	if err := checkPinnedScheme(req.URL); err != nil {
+		cancel(nil)
+		return nil, err
+	}
+	if length >= 0 && req.ContentLength == 0 {
+		req.ContentLength = length
+	}
+	if debugEnabled() {
+		c.trace = &requestTrace{}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), c.trace.clientTrace()))
+	}
+	c.req = req
+	return c, nil
+}
+
+// do sends the request, reporting a timeout or stall rather than a bare
+// "context canceled" when the call was aborted, and tagging failures with
+// the request ID. Compressed responses are decoded transparently.
+func (c *apiCall) do() (*http.Response, error) {
+	if c.trace != nil {
+		c.trace.start = time.Now()
+	}
+	resp, err := httpClient.Do(c.req)
+	if err != nil {
+		err = c.cause(err)
+		if c.trace != nil {
+			c.trace.mu.Lock()
+			c.trace.err = err
+			c.trace.mu.Unlock()
+		}
+		return nil, c.withRequestID(err)
+	}
+	if c.trace != nil {
+		c.trace.mu.Lock()
+		c.trace.status = resp.StatusCode
+		c.trace.mu.Unlock()
+	}
+	if err := decompress(resp); err != nil {
+		resp.Body.Close()
+		return nil, c.withRequestID(c.cause(err))
+	}
+	return resp, nil
+}
+
+// watch wraps r so that every read that makes progress resets the stall
+// detector and, for transfers, is counted. It is a no-op for calls that
+// need neither.
+func (c *apiCall) watch(r io.Reader) io.Reader {
+	if c.stall == nil && !c.counted {
+		return r
+	}
+	return &stallReader{r: r, call: c}
+}
+
+// stream switches a call whose response arrives incrementally from its
+// fixed deadline to stall detection, so a long but steady response isn't
+// cut off. Reads must go through watch afterwards.
+func (c *apiCall) stream() {
+	if c.deadline != nil {
+		c.deadline.Stop()
+	}
+	if c.stall == nil {
+		c.limit = stallTimeout()
+		if c.limit > 0 {
+			c.stall = time.AfterFunc(c.limit, func() {
+				c.cancel(fmt.Errorf("transfer stalled: no progress for %s", c.limit))
+			})
+		}
+	}
+}
+
+// close releases the call's context and timers and, in debug mode, logs
+// the call's timings.
+func (c *apiCall) close() {
+	if c.stall != nil {
+		c.stall.Stop()
+	}
+	c.cancel(nil)
+	if c.trace != nil {
+		logTrace(c)
+	}
+}
+
+// cause replaces err with the reason the call was aborted, if any.
+func (c *apiCall) cause(err error) error {
+	if c.ctx.Err() != nil {
+		if cause := context.Cause(c.ctx); cause != nil && cause != context.Canceled {
+			return cause
+		}
+	}
+	// Transport errors quote the URL, which may carry a password.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = redactURL(c.req.URL)
+	}
+	return err
+}
+
+// stallReader resets its call's stall detector whenever data flows, counts
+// transferred bytes and holds transfers to the bandwidth limit.
+type stallReader struct {
+	r    io.Reader
+	call *apiCall
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	limited := s.call.counted && bandwidth.limit() > 0
+	if limited && len(p) > limitChunk {
+		p = p[:limitChunk]
+	}
+	n, err := s.r.Read(p)
+	if n > 0 {
+		if limited {
+			// Waiting for bandwidth isn't a stall.
+			if s.call.stall != nil {
+				s.call.stall.Stop()
+			}
+			if waitErr := bandwidth.wait(s.call.ctx, n, s.call.live.priorityOf()); waitErr != nil {
+				return n, waitErr
+			}
+		}
+		if s.call.counted {
+			transferredBytes.Add(int64(n))
+			s.call.live.count(n)
+		}
+		if s.call.stall != nil {
+			s.call.stall.Reset(s.call.limit)
+		}
+	}
+	if err != nil && err != io.EOF {
+		err = s.call.cause(err)
+	}
+	return n, err
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// config.toml only needs flat settings and one level of tables, so rather
+// than pull in a TOML library this reads and writes that subset: comments,
+// [table] headers and key = value lines with strings, numbers and booleans.
+// Values are returned as strings by dotted key, "keys.lock" for lock in
+// [keys]. The rest of TOML (arrays, inline tables, arrays of tables and
+// multi-line strings) is refused with an error rather than misread, as are
+// keys set twice, which TOML forbids.
+
+// parseTOML reads the settings of a config.toml.
+func parseTOML(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	table := ""
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(strings.TrimSuffix(line, "\r"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[[") {
+			return nil, fmt.Errorf("line %d: arrays of tables are not supported", i+1)
+		}
+		if strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if end < 0 || strings.TrimSpace(line[end+1:]) != "" && !strings.HasPrefix(strings.TrimSpace(line[end+1:]), "#") {
+				return nil, fmt.Errorf("line %d: malformed table header", i+1)
+			}
+			table = strings.TrimSpace(line[1:end])
+			continue
+		}
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", i+1)
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"`)
+		value, err := parseTOMLValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		if table != "" {
+			key = table + "." + key
+		}
+		if _, ok := values[key]; ok {
+			return nil, fmt.Errorf("line %d: %s is set twice", i+1, key)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// parseTOMLValue reads a string, number or boolean, dropping a trailing
+// comment.
+func parseTOMLValue(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, `"""`), strings.HasPrefix(raw, "'''"):
+		return "", fmt.Errorf("multi-line strings are not supported")
+	case strings.HasPrefix(raw, "["), strings.HasPrefix(raw, "{"):
+		return "", fmt.Errorf("arrays and inline tables are not supported")
+	case strings.HasPrefix(raw, `"`):
+		for end := 1; end < len(raw); end++ {
+			if raw[end] == '\\' {
+				end++
+				continue
+			}
+			if raw[end] == '"' {
+				if rest := strings.TrimSpace(raw[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+					return "", fmt.Errorf("unexpected %q after string", rest)
+				}
+				value, err := strconv.Unquote(raw[:end+1])
+				if err != nil {
+					return "", fmt.Errorf("invalid string %s", raw[:end+1])
+				}
+				return value, nil
+			}
+		}
+		return "", fmt.Errorf("unterminated string")
+	case strings.HasPrefix(raw, "'"):
+		end := strings.Index(raw[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string")
+		}
+		return raw[1 : end+1], nil
+	}
+	if i := strings.Index(raw, "#"); i >= 0 {
+		raw = strings.TrimSpace(raw[:i])
+	}
+	if raw == "" {
+		return "", fmt.Errorf("missing value")
+	}
+	if raw != "true" && raw != "false" {
+		if _, err := strconv.ParseFloat(strings.ReplaceAll(raw, "_", ""), 64); err != nil {
+			return "", fmt.Errorf("unquoted value %s", raw)
+		}
+	}
+	return raw, nil
+}
+
+// formatTOMLValue writes numbers and booleans bare and anything else as a
+// quoted string.
+func formatTOMLValue(value string) string {
+	if value == "true" || value == "false" {
+		return value
+	}
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return value
+	}
+	return strconv.Quote(value)
+}
+
+// setTOML sets key to value in a config.toml, or removes it when remove is
+// set, keeping the rest of the file (comments included) as it was.
+func setTOML(data []byte, key, value string, remove bool) []byte {
+	table, name := "", key
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		table, name = key[:i], key[i+1:]
+	}
+	entry := name + " = " + formatTOMLValue(value)
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		lines = nil
+	}
+	current := ""
+	insertAt := -1 // after the last setting of the table, for a new key
+	if table == "" {
+		insertAt = 0
+	}
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			if end := strings.Index(trimmed, "]"); end > 0 {
+				current = strings.TrimSpace(trimmed[1:end])
+			}
+			if current == table {
+				insertAt = i + 1
+			}
+			continue
+		}
+		if current != table {
+			continue
+		}
+		if k, _, ok := strings.Cut(trimmed, "="); ok && !strings.HasPrefix(trimmed, "#") {
+			if strings.Trim(strings.TrimSpace(k), `"`) == name {
+				if remove {
+					return joinTOML(append(lines[:i:i], lines[i+1:]...))
+				}
+				lines[i] = entry
+				return joinTOML(lines)
+			}
+			insertAt = i + 1
+		} else if table == "" && trimmed != "" && insertAt == i {
+			// Keep leading comments above new top-level settings.
+			insertAt = i + 1
+		}
+	}
+	if remove {
+		return joinTOML(lines)
+	}
+	if insertAt < 0 {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		return joinTOML(append(lines, "["+table+"]", entry))
+	}
+	lines = append(lines[:insertAt], append([]string{entry}, lines[insertAt:]...)...)
+	return joinTOML(lines)
+}
+
+// joinTOML puts the lines back together with a final newline.
+func joinTOML(lines []string) []byte {
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	data := `# cshare settings
+server_url = "https://files.example.com" # trailing comment
+download_path = 'downloads/{site}/{filename}'
+upload_workers = 4
+compress_uploads = true
+theme = "da\"rk"
+
+[keys]
+lock = "ctrl+k"
+`
+	want := map[string]string{
+		"server_url":       "https://files.example.com",
+		"download_path":    "downloads/{site}/{filename}",
+		"upload_workers":   "4",
+		"compress_uploads": "true",
+		"theme":            `da"rk`,
+		"keys.lock":        "ctrl+k",
+	}
+	got, err := parseTOML([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("parseTOML = %v, want %v", got, want)
+	}
+}
+
+func TestParseTOMLRefuses(t *testing.T) {
+	tests := []struct {
+		data, err string
+	}{
+		{"theme = dark", "unquoted value"},
+		{"theme =", "missing value"},
+		{`theme = "dark`, "unterminated string"},
+		{`theme = "dark" light`, "unexpected"},
+		{"theme", "expected key = value"},
+		{"[keys", "malformed table header"},
+		{"[[sites]]", "arrays of tables"},
+		{`pins = ["sha256/abc"]`, "arrays and inline tables"},
+		{`keys = { lock = "ctrl+k" }`, "arrays and inline tables"},
+		{`theme = """dark"""`, "multi-line strings"},
+		{"upload_workers = 2\nupload_workers = 4", "set twice"},
+		{"[keys]\nlock = \"a\"\n[keys]\nlock = \"b\"", "set twice"},
+	}
+	for _, tt := range tests {
+		_, err := parseTOML([]byte(tt.data))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("parseTOML(%q): got error %v, want one about %q", tt.data, err, tt.err)
+		}
+	}
+}
+
+func TestSetTOML(t *testing.T) {
+	data := []byte("# mine\ntheme = \"dark\"\n\n[keys]\nlock = \"ctrl+k\"\n")
+	data = setTOML(data, "upload_workers", "4", false)
+	data = setTOML(data, "keys.session", "ctrl+e", false)
+	data = setTOML(data, "theme", "", true)
+	got, err := parseTOML(data)
+	if err != nil {
+		t.Fatalf("%v in:\n%s", err, data)
+	}
+	want := map[string]string{"upload_workers": "4", "keys.lock": "ctrl+k", "keys.session": "ctrl+e"}
+	if !maps.Equal(got, want) {
+		t.Errorf("after edits %v, want %v, file:\n%s", got, want, data)
+	}
+	if !strings.HasPrefix(string(data), "# mine\n") {
+		t.Errorf("the comment was lost:\n%s", data)
+	}
+}
+
+func TestDownloadPathSource(t *testing.T) {
+	old := settings.Load()
+	t.Cleanup(func() { settings.Store(old) })
+	opt, _ := findOption("download_path")
+
+	settings.Store(&Config{DownloadPath: "json/{filename}"})
+	if value, source := effectiveOption(opt); value != "json/{filename}" || source != configPath {
+		t.Errorf("with cshare.json: %q from %s", value, source)
+	}
+	settings.Store(&Config{})
+	if _, source := effectiveOption(opt); source == configPath {
+		t.Errorf("without cshare.json the value still came from %s", source)
+	}
+}
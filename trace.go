@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// debugLogPath is where CSHARE_DEBUG writes request timings.
+const debugLogPath = ".cshare-debug.log"
+
+// debugEnabled reports whether CSHARE_DEBUG turns on request tracing.
+func debugEnabled() bool {
+	return envOn("CSHARE_DEBUG")
+}
+
+// requestTrace times the phases of one request. The transport may report
+// them from its own goroutines.
+type requestTrace struct {
+	mu        sync.Mutex
+	start     time.Time
+	dnsStart  time.Time
+	dnsDone   time.Time
+	connStart time.Time
+	connDone  time.Time
+	tlsStart  time.Time
+	tlsDone   time.Time
+	firstByte time.Time
+	reused    bool
+	status    int
+	err       error
+}
+
+// clientTrace hooks the trace into a request's context.
+func (t *requestTrace) clientTrace() *httptrace.ClientTrace {
+	at := func(field *time.Time) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		*field = time.Now()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { at(&t.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { at(&t.dnsDone) },
+		ConnectStart:      func(string, string) { at(&t.connStart) },
+		ConnectDone:       func(string, string, error) { at(&t.connDone) },
+		TLSHandshakeStart: func() { at(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { at(&t.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.reused = info.Reused
+		},
+		GotFirstResponseByte: func() { at(&t.firstByte) },
+	}
+}
+
+// phase renders the time between two events, or "-" if they didn't happen
+// (e.g. no DNS lookup on a reused connection).
+func phase(from, to time.Time) string {
+	if from.IsZero() || to.IsZero() {
+		return "-"
+	}
+	return formatLatency(to.Sub(from))
+}
+
+// line renders the trace for the debug log, e.g.
+// "... 3f9a1c GET http://localhost:8080/ping 200 dns=2 ms connect=1 ms tls=- ttfb=15 ms total=16 ms reused=false".
+func (t *requestTrace) line(c *apiCall) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := fmt.Sprint(t.status)
+	if t.err != nil {
+		result = "error: " + t.err.Error()
+	}
+	return fmt.Sprintf("%s %s %s %s %s dns=%s connect=%s tls=%s ttfb=%s total=%s reused=%t",
+		time.Now().Format(time.RFC3339), c.req.Header.Get(requestIDHeader), c.req.Method, redactURL(c.req.URL), result,
+		phase(t.dnsStart, t.dnsDone), phase(t.connStart, t.connDone), phase(t.tlsStart, t.tlsDone),
+		phase(t.start, t.firstByte), phase(t.start, time.Now()), t.reused)
+}
+
+// debugLog serializes writes to the debug log.
+var debugLog sync.Mutex
+
+// logTrace appends a finished call's timings to the debug log. Logging is
+// best effort.
+func logTrace(c *apiCall) {
+	line := c.trace.line(c)
+	debugLog.Lock()
+	defer debugLog.Unlock()
+	f, err := os.OpenFile(debugLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, strings.ReplaceAll(line, "\n", " "))
+}
+
+// withRequestID adds the call's request ID to an error, so a failure the
+// user reports can be found in the server's logs.
+func (c *apiCall) withRequestID(err error) error {
+	id := c.req.Header.Get(requestIDHeader)
+	if id == "" {
+		return err
+	}
+	return fmt.Errorf("%w (request ID %s)", err, id)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// rateWindow is how many status ticks the rolling average spans.
+	rateWindow = 10
+	// finishedShown is how long a finished transfer's summary stays listed.
+	finishedShown = 10 * time.Second
+)
+
+// liveTransfer is an upload or download in flight. Workers count its bytes
+// as they move; the status tick samples them for the progress rows.
+type liveTransfer struct {
+	id        int
+	direction string
+	file      string
+	started   time.Time
+	total     atomic.Int64 // -1 while unknown
+	done      atomic.Int64
+	priority  atomic.Int32 // a transferPriority
+	ended     time.Time    // guarded by liveTransfers
+	failed    bool         // guarded by liveTransfers
+}
+
+// liveTransfers lists running transfers and those that finished within
+// finishedShown.
+var liveTransfers struct {
+	sync.Mutex
+	next int
+	list []*liveTransfer
+}
+
+// startTransfer registers a transfer for the progress rows.
+func startTransfer(direction, file string) *liveTransfer {
+	t := &liveTransfer{direction: direction, file: file, started: time.Now()}
+	t.total.Store(-1)
+	t.priority.Store(int32(defaultPriority(direction)))
+	liveTransfers.Lock()
+	defer liveTransfers.Unlock()
+	liveTransfers.next++
+	t.id = liveTransfers.next
+	liveTransfers.list = append(liveTransfers.list, t)
+	return t
+}
+
+// priorityOf returns a transfer's priority; untracked calls are normal.
+func (t *liveTransfer) priorityOf() transferPriority {
+	if t == nil {
+		return priorityNormal
+	}
+	return transferPriority(t.priority.Load())
+}
+
+// count adds n moved bytes. It is a no-op on untracked calls.
+func (t *liveTransfer) count(n int) {
+	if t != nil {
+		t.done.Add(int64(n))
+	}
+}
+
+// restart drops the bytes counted so far, for a transfer that starts over.
+func (t *liveTransfer) restart() {
+	if t != nil {
+		t.done.Store(0)
+	}
+}
+
+// setTotal records the transfer's expected size once it is known.
+func (t *liveTransfer) setTotal(n int64) {
+	if t != nil && n >= 0 {
+		t.total.Store(n)
+	}
+}
+
+// end marks the transfer finished with its final byte count.
+func (t *liveTransfer) end(bytes int64, err error) {
+	if t == nil {
+		return
+	}
+	if err == nil {
+		t.done.Store(bytes)
+	}
+	liveTransfers.Lock()
+	defer liveTransfers.Unlock()
+	t.ended = time.Now()
+	t.failed = err != nil
+}
+
+// transferRow is a snapshot of a liveTransfer, safe to render.
+type transferRow struct {
+	id        int
+	direction string
+	file      string
+	started   time.Time
+	ended     time.Time
+	failed    bool
+	priority  transferPriority
+	total     int64
+	done      int64
+}
+
+// transferSnapshot returns the running and recently finished transfers,
+// dropping those finished longer than finishedShown ago.
+func transferSnapshot() []transferRow {
+	liveTransfers.Lock()
+	defer liveTransfers.Unlock()
+	var rows []transferRow
+	kept := liveTransfers.list[:0]
+	for _, t := range liveTransfers.list {
+		if !t.ended.IsZero() && time.Since(t.ended) > finishedShown {
+			continue
+		}
+		kept = append(kept, t)
+		rows = append(rows, transferRow{
+			id: t.id, direction: t.direction, file: t.file,
+			started: t.started, ended: t.ended, failed: t.failed,
+			priority: transferPriority(t.priority.Load()),
+			total:    t.total.Load(), done: t.done.Load(),
+		})
+	}
+	liveTransfers.list = kept
+	return rows
+}
+
+// transferRate holds a running transfer's speed samples.
+type transferRate struct {
+	last    int64     // bytes done at the previous tick
+	instant float64   // bytes per second over the last tick
+	recent  []float64 // the last rateWindow samples
+}
+
+// average is the rolling average over the recent samples.
+func (r *transferRate) average() float64 {
+	if len(r.recent) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range r.recent {
+		sum += v
+	}
+	return sum / float64(len(r.recent))
+}
+
+// sampleTransfers takes a speed sample of every running transfer. It runs
+// on each status tick.
+func sampleTransfers(m *Model) {
+	rates := make(map[int]*transferRate)
+	for _, row := range transferSnapshot() {
+		if !row.ended.IsZero() {
+			continue
+		}
+		r := m.rates[row.id]
+		if r == nil {
+			r = &transferRate{}
+		}
+		r.instant = float64(row.done-r.last) / statusInterval.Seconds()
+		r.last = row.done
+		r.recent = append(r.recent, r.instant)
+		if len(r.recent) > rateWindow {
+			r.recent = r.recent[1:]
+		}
+		rates[row.id] = r
+	}
+	m.rates = rates
+}
+
+// formatElapsed renders a duration with tenths of a second below a minute,
+// e.g. "4.2s" or "3m12s".
+func formatElapsed(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	}
+	return d.Round(time.Second).String()
+}
+
+// renderTransferRow renders a running transfer as its progress bar over
+// its size, speed and ETA, e.g.
+//
+//	↓ report.pdf ████████████░░░░░░░░░░░░░░░░░░ 45%
+//	  3.2 MiB of 7.1 MiB • 1.1 MiB/s (avg 980.0 KiB/s) • ETA 4.0s
+//
+// and a finished transfer as a one-line summary.
+func renderTransferRow(row transferRow, rate *transferRate) string {
+	arrow := "↓"
+	if row.direction == directionUpload {
+		arrow = "↑"
+	}
+	name := fitName(row.file, 20)
+	if !row.ended.IsZero() {
+		elapsed := row.ended.Sub(row.started)
+		if row.failed {
+			return errorStyle.UnsetPadding().Render(fmt.Sprintf("✗ %s %s failed after %s in %s",
+				arrow, name, formatBytes(row.done), formatElapsed(elapsed)))
+		}
+		avg := ""
+		if elapsed > 0 {
+			avg = fmt.Sprintf(" (avg %s/s)", formatBytes(int64(float64(row.done)/elapsed.Seconds())))
+		}
+		return successStyle.UnsetPadding().Render(fmt.Sprintf("✓ %s %s %s in %s%s",
+			arrow, name, formatBytes(row.done), formatElapsed(elapsed), avg))
+	}
+
+	head := arrow + " " + name
+	size := formatBytes(row.done)
+	if row.total > 0 {
+		pct := int(min(row.done, row.total) * 100 / row.total)
+		head += fmt.Sprintf(" %s %d%%", progressBar(pct, 100), pct)
+		size += " of " + formatBytes(row.total)
+	}
+	stats := []string{size}
+	if rate != nil && len(rate.recent) > 0 {
+		avg := rate.average()
+		stats = append(stats, fmt.Sprintf("%s/s (avg %s/s)", formatBytes(int64(rate.instant)), formatBytes(int64(avg))))
+		if row.total > 0 && avg >= 1 {
+			eta := time.Duration(float64(max(row.total-row.done, 0)) / avg * float64(time.Second))
+			stats = append(stats, "ETA "+formatElapsed(eta))
+		}
+	}
+	return head + "\n  " + mutedStyle.Render(strings.Join(stats, " • "))
+}
+
+// renderTransfers renders a row per running or just finished transfer, or
+// "" when there are none.
+func renderTransfers(m Model) string {
+	var lines []string
+	for _, row := range transferSnapshot() {
+		lines = append(lines, renderTransferRow(row, m.rates[row.id]))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// Text in the TUI is measured in terminal cells rather than runes or
+// bytes: CJK characters and most emoji take two cells, combining marks
+// none, and a name that is wider than its column wraps and shifts every
+// row below it.
+
+// truncate shortens s to n cells, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return runewidth.Truncate(s, n, "…")
+}
+
+// truncateMiddle shortens a file name to n cells by cutting from the
+// middle, so the end of the name and its extension stay visible:
+// "quarterly-report-final-v2.pdf" becomes "quarterly…l-v2.pdf".
+func truncateMiddle(name string, n int) string {
+	width := runewidth.StringWidth(name)
+	if width <= n {
+		return name
+	}
+	if n < 5 {
+		return truncate(name, n)
+	}
+	keep := n - 1 // cells left after the ellipsis
+	tailWidth := keep / 2
+	if ext := runewidth.StringWidth(filepath.Ext(name)); ext > tailWidth && ext < keep {
+		tailWidth = ext
+	}
+	tail := lastCells(name, tailWidth)
+	head := runewidth.Truncate(name, keep-runewidth.StringWidth(tail), "")
+	return head + "…" + tail
+}
+
+// lastCells returns the longest end of s that fits in n cells.
+func lastCells(s string, n int) string {
+	for i := range s {
+		if runewidth.StringWidth(s[i:]) <= n {
+			return s[i:]
+		}
+	}
+	return ""
+}
+
+// fitName shortens a file name to n cells the way CSHARE_TRUNCATE_NAMES
+// asks: "end" (the default) cuts the end off, "middle" keeps the
+// extension.
+func fitName(name string, n int) string {
+	if strings.EqualFold(os.Getenv("CSHARE_TRUNCATE_NAMES"), "middle") {
+		return truncateMiddle(name, n)
+	}
+	return truncate(name, n)
+}
+
+// padName fits a name to exactly n cells, for columns that line up.
+func padName(name string, n int) string {
+	return runewidth.FillRight(fitName(name, n), n)
+}
@@ -0,0 +1,12 @@
+//go:build !nativedialog
+
+package main
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// pickUpload opens the built-in file picker, which keeps the default build
+// free of cgo so it cross-compiles to a static binary.
+func pickUpload(m *Model, folder bool) tea.Cmd {
+	openPicker(m, folder)
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// send feeds messages to m in order, as Bubble Tea would, and returns the
+// last command.
+func send(m *Model, msgs ...tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	for _, msg := range msgs {
+		_, cmd = m.Update(msg)
+	}
+	return cmd
+}
+
+// siteModel returns a Model with a site open on its file list.
+func siteModel(t *testing.T) *Model {
+	t.Helper()
+	t.Setenv("auth_token", "token")
+	m := &Model{state: statePassword, siteName: "notes"}
+	send(m, siteLoadedMsg{files: []FileInfo{{ID: 1, FileName: "a.txt"}}})
+	if m.state != stateViewFiles {
+		t.Fatalf("state = %s after loading the site (%s)", m.state, m.status.text)
+	}
+	return m
+}
+
+func TestUpdateSiteLoaded(t *testing.T) {
+	m := siteModel(t)
+	if len(m.files) != 1 || m.files[0].FileName != "a.txt" {
+		t.Errorf("files = %+v", m.files)
+	}
+}
+
+func TestUpdateBatchUpload(t *testing.T) {
+	m := siteModel(t)
+	m.goTo(stateUploadFile)
+	stream := make(chan uploadProgressMsg)
+	if cmd := send(m, uploadStartedMsg{stream: stream, stop: func() {}, total: 2}); cmd == nil {
+		t.Fatal("no command waiting for progress")
+	}
+	if m.batchStream == nil || m.batchTotal != 2 {
+		t.Fatalf("batch not started: total %d", m.batchTotal)
+	}
+
+	send(m, uploadProgressMsg{stream: stream, hashed: 2, uploaded: 1, sent: "a.txt"})
+	if m.batchUploaded != 1 || len(m.batchSent) != 1 {
+		t.Errorf("after progress: uploaded %d, sent %v", m.batchUploaded, m.batchSent)
+	}
+	// Reports of a batch that is no longer shown are dropped.
+	send(m, uploadProgressMsg{stream: make(chan uploadProgressMsg), uploaded: 9})
+	if m.batchUploaded != 1 {
+		t.Errorf("a stale report changed the progress to %d", m.batchUploaded)
+	}
+
+	files := []FileInfo{{ID: 1, FileName: "a.txt"}, {ID: 2, FileName: "b.txt"}}
+	send(m, uploadProgressMsg{stream: stream, hashed: 2, uploaded: 2, done: true, files: files, status: success("2 files uploaded")})
+	if m.batchStream != nil || m.state != stateViewFiles || len(m.files) != 2 {
+		t.Errorf("after the batch: stream %v, state %s, files %+v", m.batchStream, m.state, m.files)
+	}
+	if m.status != success("2 files uploaded") {
+		t.Errorf("status = %q", m.status.text)
+	}
+}
+
+func TestUpdateTrackedDownload(t *testing.T) {
+	m := siteModel(t)
+	m.transfers = 1
+	send(m, transferDoneMsg{msg: downloadDoneMsg{path: "downloads/a.txt", status: success("File downloaded")}})
+	if m.transfers != 0 {
+		t.Errorf("transfers = %d, want 0", m.transfers)
+	}
+	if m.lastDownload != "downloads/a.txt" || m.status != success("File downloaded") {
+		t.Errorf("lastDownload %q, status %q", m.lastDownload, m.status.text)
+	}
+}
+
+func TestUpdateFailedQueuedDownload(t *testing.T) {
+	m := siteModel(t)
+	m.downloads = downloadQueue{running: true, total: 2, done: 1}
+	m.transfers = 1
+	err := &apiError{what: "failed to download file", status: 500, body: "boom"}
+	send(m, transferDoneMsg{msg: downloadStepMsg{msg: err, fileID: 1}})
+	if m.state != stateViewFiles {
+		t.Errorf("state = %s, want the file list", m.state)
+	}
+	if m.status != notice("Downloaded 1 of 2 file(s), 1 failed") {
+		t.Errorf("status = %q", m.status.text)
+	}
+	if m.lastError == nil {
+		t.Error("the failure's details were dropped")
+	}
+}
+
+func TestUpdateAPIError(t *testing.T) {
+	m := siteModel(t)
+	err := &apiError{what: "failed to fetch site", status: 500, body: "boom", requestID: "req-1"}
+	send(m, apiErrorMsg{err: err})
+	if m.state != stateMenu {
+		t.Errorf("state = %s, want the menu", m.state)
+	}
+	if m.lastError == nil || !strings.Contains(m.status.text, "req-1") {
+		t.Errorf("error %q, details %v", m.status.text, m.lastError)
+	}
+	if !m.goTo(stateErrorDetail) {
+		t.Errorf("error details refused: %s", m.status.text)
+	}
+}
+
+func TestGuardCmdWrapsErrors(t *testing.T) {
+	err := errors.New("boom")
+	msg := guardCmd(func() tea.Msg { return err })()
+	if got, ok := msg.(apiErrorMsg); !ok || got.err != err {
+		t.Errorf("got %#v, want apiErrorMsg", msg)
+	}
+	if msg := guardCmd(func() tea.Msg { return downloadDoneMsg{} })(); msg != (downloadDoneMsg{}) {
+		t.Errorf("got %#v, want the message unchanged", msg)
+	}
+}
+
+func TestUpdateOpensStoreSite(t *testing.T) {
+	inTempDir(t) // no cshare auth token: the share's own credentials do
+	share := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PROPFIND" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`<d:multistatus xmlns:d="DAV:"><d:response><d:href>/share/a.txt</d:href>` +
+			`<d:propstat><d:status>HTTP/1.1 200 OK</d:status><d:prop><d:resourcetype/></d:prop></d:propstat>` +
+			`</d:response></d:multistatus>`))
+	}))
+	defer share.Close()
+	old := settings.Load()
+	settings.Store(&Config{WebDAV: map[string]WebDAVShare{"dav": {URL: share.URL + "/share"}}})
+	t.Cleanup(func() { settings.Store(old) })
+
+	open := map[string]func(m *Model) tea.Cmd{
+		"site name prompt": func(m *Model) tea.Cmd {
+			m.goTo(stateSiteName)
+			return send(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("dav")}, tea.KeyMsg{Type: tea.KeyEnter})
+		},
+		"quick open": func(m *Model) tea.Cmd {
+			m.goTo(stateQuickOpen)
+			_, cmd := openQuickItem(m, QuickItem{Kind: quickSite, Site: "dav"})
+			return cmd
+		},
+	}
+	for name, start := range open {
+		t.Run(name, func(t *testing.T) {
+			m := &Model{state: stateMenu}
+			send(m, run(t, start(m)))
+			if m.state != stateViewFiles {
+				t.Fatalf("state = %s (%s), want the file list", m.state, m.status.text)
+			}
+			if m.siteName != "dav" || len(m.files) != 1 || m.files[0].FileName != "a.txt" {
+				t.Errorf("site %q, files %+v", m.siteName, m.files)
+			}
+		})
+	}
+}
+
+func TestStatusStyleFollowsKind(t *testing.T) {
+	m := siteModel(t)
+	m.status = notice("Success is not claimed by the text alone")
+	if view := m.view(); !strings.Contains(view, "❌ Success is not") {
+		t.Errorf("a notice was drawn as a success:\n%s", view)
+	}
+	m.status = success("Deleted a.txt")
+	if view := m.view(); !strings.Contains(view, "✅ Deleted a.txt") {
+		t.Errorf("a success was drawn as a notice:\n%s", view)
+	}
+}
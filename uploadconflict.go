@@ -0,0 +1,183 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// siteFileNames returns the names of a site's files, which uploads check
+// for conflicts.
+func siteFileNames(files []FileInfo) map[string]bool {
+	taken := make(map[string]bool, len(files))
+	for _, file := range files {
+		taken[file.FileName] = true
+	}
+	return taken
+}
+
+// uniqueName numbers a file name as "name (1).ext", "name (2).ext", ...
+// until it isn't taken, the way uniquePath does for downloads.
+func uniqueName(name string, taken map[string]bool) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := 1; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
+
+// remoteNames picks the name each path of a batch is uploaded under. A
+// name already on the site follows policy: overwrite uploads a new version
+// under the same name, rename numbers it, and skip leaves the file out.
+// Files of the batch that share a name, from different folders, are all
+// new, so the later ones are always numbered.
+func remoteNames(paths []string, taken map[string]bool, policy conflictPolicy) (names map[string]string, skipped []string, err error) {
+	taken = maps.Collect(maps.All(taken))
+	inBatch := make(map[string]bool, len(paths))
+	names = make(map[string]string, len(paths))
+	for _, path := range paths {
+		name := uploadName(path)
+		if inBatch[name] {
+			name = uniqueName(name, taken)
+		} else if taken[name] {
+			switch policy {
+			case conflictOverwrite:
+			case conflictRename:
+				name = uniqueName(name, taken)
+			case conflictFail:
+				return nil, nil, fmt.Errorf("%s already exists on the site; nothing was uploaded (--on-conflict=fail)", name)
+			default:
+				skipped = append(skipped, name)
+				continue
+			}
+		}
+		taken[name], inBatch[name] = true, true
+		names[path] = name
+	}
+	return names, skipped, nil
+}
+
+// uploadConflicts lists the names of the selected upload that are already
+// on the site.
+func uploadConflicts(m *Model) ([]string, error) {
+	taken := siteFileNames(m.files)
+	if m.fileToUpload != "" {
+		if name := cmp.Or(m.uploadAs, uploadName(m.fileToUpload)); taken[name] {
+			return []string{name}, nil
+		}
+		return nil, nil
+	}
+	var conflicts []string
+	for _, target := range append(slices.Clone(m.pendingUploads), m.folderToUpload) {
+		if target == "" {
+			continue
+		}
+		paths, err := collectFiles(target, m.excludes)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", target, err)
+		}
+		for _, path := range paths {
+			if name := uploadName(path); taken[name] && !slices.Contains(conflicts, name) {
+				conflicts = append(conflicts, name)
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// beginUpload starts uploading the selection. Unless --on-conflict (or
+// CSHARE_ON_CONFLICT) already says what to do, names that are on the
+// site are asked about first, once for the whole selection.
+func beginUpload(m *Model) tea.Cmd {
+	if m.onConflict != conflictAsk {
+		return startUpload(m, m.onConflict)
+	}
+	conflicts, err := uploadConflicts(m)
+	if err != nil {
+		m.status = notice(err.Error())
+		return nil
+	}
+	if len(conflicts) == 0 {
+		return startUpload(m, conflictOverwrite)
+	}
+	askUploadConflict(m, conflicts)
+	return nil
+}
+
+// askUploadConflict opens the overwrite / keep both / skip dialog for
+// names of the upload that are already on the site. Esc cancels the
+// upload.
+func askUploadConflict(m *Model, conflicts []string) {
+	prompt := conflicts[0] + " already exists on " + m.siteName
+	if len(conflicts) > 1 {
+		prompt = fmt.Sprintf("%d files already exist on %s (%s, ...)", len(conflicts), m.siteName, conflicts[0])
+	}
+	var labels []string
+	keys := map[string]int{}
+	for i, policy := range conflictChoices {
+		labels = append(labels, conflictLabels[policy])
+		keys[strings.ToLower(conflictLabels[policy][:1])] = i
+	}
+	m.confirm = &confirmation{
+		prompt:  prompt,
+		detail:  "Overwrite it with a new version, keep both (the new copy gets a numbered name), or skip it?",
+		choices: labels,
+		keys:    keys,
+		picked:  1,
+		onPick: func(m *Model, choice int) tea.Cmd {
+			return startUpload(m, conflictChoices[choice])
+		},
+	}
+}
+
+// startUpload uploads the selection, settling names already on the site
+// with policy.
+func startUpload(m *Model, policy conflictPolicy) tea.Cmd {
+	taken := siteFileNames(m.files)
+	sent := make(map[string]bool, len(m.batchSent))
+	for _, path := range m.batchSent {
+		sent[path] = true
+	}
+	if len(m.pendingUploads) > 0 {
+		// pendingUploads stay listed until the batch is done, so an
+		// interrupted batch can be restored.
+		targets := slices.Clone(m.pendingUploads)
+		return uploadPaths(m.siteName, m.password, targets, m.excludes, taken, policy, sent)
+	}
+	if m.folderToUpload != "" {
+		return uploadBatch(m.siteName, m.password, m.folderToUpload, m.excludes, taken, policy, sent)
+	}
+	if m.fileToUpload == "" {
+		return nil
+	}
+	name := cmp.Or(m.uploadAs, uploadName(m.fileToUpload))
+	if taken[name] {
+		switch policy {
+		case conflictOverwrite:
+		case conflictRename:
+			name = uniqueName(name, taken)
+		case conflictFail:
+			m.status = notice(name + " already exists on " + m.siteName + "; nothing was uploaded (--on-conflict=fail)")
+			return nil
+		default:
+			m.status = success("Kept the existing " + name + " on " + m.siteName)
+			return nil
+		}
+	}
+	if info, err := os.Stat(m.fileToUpload); err == nil {
+		if err := checkUpload(name, info.Size()); err != nil {
+			m.status = notice(err.Error())
+			return nil
+		}
+	}
+	return trackTransfer(m, uploadFile(m, name))
+}
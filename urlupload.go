@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// parseUploadURL checks that a pasted URL is an absolute http(s) URL.
+func parseUploadURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("only http and https URLs can be uploaded")
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("the URL has no host")
+	}
+	return u, nil
+}
+
+// urlFileName names the file a URL is saved as: the last element of its
+// path, or "download" if it has none.
+func urlFileName(u *url.URL) string {
+	name := path.Base(u.Path)
+	if name == "/" || name == "." || name == "" {
+		return "download"
+	}
+	return pathElement(name)
+}
+
+// handleUploadURLInput handles input on the upload-from-URL screen.
+func handleUploadURLInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		u, err := parseUploadURL(m.uploadURL)
+		if err != nil {
+			m.status = notice(err.Error())
+			return m, nil
+		}
+		m.status = notice("Fetching " + u.String() + "...")
+		return m, trackTransfer(m, uploadFromURL(m.siteName, m.password, u))
+	case "esc":
+		m.goTo(stateUploadFile)
+	case "backspace":
+		m.uploadURL = deleteLastChar(m.uploadURL)
+	default:
+		m.uploadURL += typedText(msg)
+	}
+	return m, nil
+}
+
+// uploadFromURL adds the resource at u to the site. The server fetches it
+// when it can, so the file never passes through this machine; otherwise it
+// is downloaded to a temporary folder and uploaded from there.
+func uploadFromURL(siteName string, password secret, u *url.URL) tea.Cmd {
+	return func() tea.Msg {
+		name := urlFileName(u)
+		fetched, err := requestServerFetch(siteName, u, name)
+		if err != nil {
+			return err
+		}
+		if fetched != "" {
+			files, err := fetchFilesDirectly(siteName, password)
+			if err != nil {
+				return fmt.Errorf("file added but error refreshing list: %v", err)
+			}
+			return uploadedMsg{files: files, fileID: -1, status: success(fetched)}
+		}
+
+		dir, err := os.MkdirTemp("", "cshare-url-*")
+		if err != nil {
+			return fmt.Errorf("error creating temporary folder: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		local, err := downloadURL(u, dir, name)
+		if err != nil {
+			return err
+		}
+		result, err := api.upload(siteName, password, local, uploadName(local))
+		if err != nil {
+			return err
+		}
+		if result.verified {
+			result.status = success("Uploaded " + filepath.Base(local) + " from " + u.Host + " and verified")
+		}
+		return result
+	}
+}
+
+// requestServerFetch asks the server to fetch u into the site. It returns
+// the status to show, or "" if the server can't fetch URLs and the client
+// has to do it.
+func requestServerFetch(siteName string, u *url.URL, name string) (string, error) {
+	if !supports(featureURLUpload) {
+		return "", nil
+	}
+	authToken, err := loadAuthToken()
+	if err != nil {
+		return "", err
+	}
+	jsonData, err := json.Marshal(map[string]string{"url": u.String(), "file_name": name})
+	if err != nil {
+		return "", fmt.Errorf("error encoding request: %v", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/upload/%s/url", serverURL, url.PathEscape(siteName))
+	call, err := newAPICall(opMetadata, "POST", endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+	call.req.Header.Set("Content-Type", "application/json")
+	call.req.Header.Set("Authorization", authToken.reveal())
+
+	resp, err := call.do()
+	if err != nil {
+		return "", fmt.Errorf("error connecting to server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return "The server fetched " + name + " from " + u.Host, nil
+	case http.StatusAccepted:
+		return "The server is fetching " + name + " from " + u.Host + "; it is listed once the download is done", nil
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return "", nil
+	}
+	return "", call.fail(resp, "failed to add file from URL")
+}
+
+// downloadURL saves the resource at u in dir, named after the server's
+// Content-Disposition or else name, and returns its path. The request is a
+// plain one: the third party gets none of cshare's headers or tokens.
+func downloadURL(u *url.URL, dir, name string) (string, error) {
+	call, err := newPlainCall(opTransfer, "GET", u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %v", err)
+	}
+	defer call.close()
+
+	resp, err := call.do()
+	if err != nil {
+		return "", fmt.Errorf("error fetching %s: %v", u.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error fetching %s: %s", u, resp.Status)
+	}
+
+	if disposed := dispositionName(resp); disposed != "" {
+		name = pathElement(disposed)
+	}
+	local := filepath.Join(dir, name)
+	err = saveFile(local, func(w io.Writer) (int64, error) {
+		return io.Copy(w, call.watch(resp.Body))
+	})
+	if err != nil {
+		return "", err
+	}
+	return local, nil
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// propfindBody asks a WebDAV server for what the file list shows.
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop>
+<d:resourcetype/><d:getcontentlength/><d:getlastmodified/><d:getcontenttype/>
+</d:prop></d:propfind>`
+
+// WebDAVShare is a folder shared over WebDAV, such as a Nextcloud or
+// ownCloud share, backing a site in place of cshare's servers.
+type WebDAVShare struct {
+	// URL is the folder's address, such as
+	// "https://cloud.example.com/remote.php/dav/files/me/Shared".
+	URL  string `json:"url"`
+	User string `json:"user,omitempty"`
+	// Password may refer to a variable from the environment or .env as
+	// $NAME or ${NAME}; Nextcloud and ownCloud want an app password.
+	Password string `json:"password,omitempty"`
+}
+
+// fileURL returns the address of a file of the site.
+func (s WebDAVShare) fileURL(name string) string {
+	return strings.TrimSuffix(s.URL, "/") + "/" + url.PathEscape(name)
+}
+
+// newCall builds an authenticated request to the share.
+func (s WebDAVShare) newCall(op opKind, method, endpoint string, body io.Reader) (*apiCall, error) {
+	call, err := newAPICall(op, method, endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	if s.User != "" {
+		call.req.SetBasicAuth(s.User, os.ExpandEnv(s.Password))
+	}
+	return call, nil
+}
+
+// webdavMultistatus is the part of a PROPFIND answer the file list uses.
+type webdavMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ContentLength int64  `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+				ContentType   string `xml:"getcontenttype"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// webdavBackend serves a site from a WebDAV folder. Downloads share
+// downloadFile's cache handling, as fetchFile reads from the share.
+type webdavBackend struct {
+	share WebDAVShare
+}
+
+// list lists the files in the share's folder, leaving out subfolders.
+func (b webdavBackend) list(siteName string) ([]FileInfo, error) {
+	call, err := b.share.newCall(opMetadata, "PROPFIND", b.share.URL, strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	defer call.close()
+	call.req.Header.Set("Depth", "1")
+	call.req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, err := call.do()
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %v", call.req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, call.fail(resp, "failed to list the share")
+	}
+	var result webdavMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	var files []FileInfo
+	for _, r := range result.Responses {
+		href, err := url.PathUnescape(r.Href)
+		if err != nil {
+			continue
+		}
+		for _, stat := range r.Propstat {
+			prop := stat.Prop
+			if !strings.Contains(stat.Status, " 200 ") || prop.ResourceType.Collection != nil {
+				continue // the folder itself, subfolders, or properties it lacks
+			}
+			modified, _ := http.ParseTime(prop.LastModified)
+			files = append(files, FileInfo{
+				ID:          remoteFileID(siteName, href),
+				FileName:    path.Base(href),
+				ContentType: prop.ContentType,
+				Size:        prop.ContentLength,
+				ModifiedAt:  modified,
+			})
+		}
+	}
+	return files, nil
+}
+
+func (b webdavBackend) fetch(siteName string, fileID int, fileName string, w io.Writer) (n int64, err error) {
+	endpoint := b.share.fileURL(fileName)
+	if href, ok := remoteKey(siteName, fileID); ok {
+		endpoint = b.share.fileURL(path.Base(href))
+	}
+	call, err := b.share.newCall(opTransfer, "GET", endpoint, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer call.close()
+
+	stat := newTransferStat(directionDownload, endpoint, siteName, fileName, 0)
+	call.live = stat.live
+	defer func() { stat.finish(n, err) }()
+
+	resp, err := call.do()
+	if err != nil {
+		return 0, fmt.Errorf("error downloading file: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, call.fail(resp, "failed to download file")
+	}
+	stat.live.setTotal(resp.ContentLength)
+	n, err = io.Copy(w, call.watch(resp.Body))
+	if err != nil {
+		return n, fmt.Errorf("error downloading file: %v", err)
+	}
+	if resp.ContentLength >= 0 && n != resp.ContentLength {
+		return n, fmt.Errorf("error downloading file: got %d of %d bytes", n, resp.ContentLength)
+	}
+	return n, nil
+}
+
+func (b webdavBackend) createSite(siteName string, password secret, enableTOTP bool, ttl time.Duration) tea.Cmd {
+	return func() tea.Msg {
+		return fmt.Errorf("%s is a WebDAV share; create its folder on the server rather than with cshare", siteName)
+	}
+}
+
+func (b webdavBackend) openSite(siteName string, password, totpCode secret) tea.Cmd {
+	return func() tea.Msg {
+		files, err := b.list(siteName)
+		if err != nil {
+			return err
+		}
+		return siteLoadedMsg{files: files}
+	}
+}
+
+// upload puts the file in the share and checks the size the server lists
+// for it, as WebDAV has no standard way to ask for a checksum.
+func (b webdavBackend) upload(siteName string, password secret, localPath, name string) (result uploadedMsg, err error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return result, fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return result, fmt.Errorf("error opening file: %v", err)
+	}
+
+	endpoint := b.share.fileURL(name)
+	call, err := b.share.newCall(opTransfer, "PUT", endpoint, file)
+	if err != nil {
+		return result, err
+	}
+	defer call.close()
+	call.req.ContentLength = info.Size()
+	call.req.Header.Set("Content-Type", detectContentType(localPath))
+
+	stat := newTransferStat(directionUpload, endpoint, siteName, name, 0)
+	call.live = stat.live
+	call.live.setTotal(info.Size())
+	resp, err := call.do()
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+			err = call.fail(resp, "failed to upload file")
+		}
+	}
+	stat.finish(info.Size(), err)
+	if err != nil {
+		return result, fmt.Errorf("error uploading file: %v", err)
+	}
+
+	files, err := b.list(siteName)
+	if err != nil {
+		return result, fmt.Errorf("file uploaded but error refreshing list: %v", err)
+	}
+	result = uploadedMsg{files: files, fileID: -1}
+	fileID, ok := findUploadedFile(files, name)
+	if !ok {
+		result.status = notice("File uploaded, but it could not be verified: not found in the site listing")
+		return result, nil
+	}
+	result.fileID = fileID
+	for _, f := range files {
+		if f.ID == fileID && f.Size != info.Size() {
+			result.status = notice(fmt.Sprintf("Upload size mismatch: the server has %s of %s", formatBytes(f.Size), formatBytes(info.Size())))
+			return result, nil
+		}
+	}
+	result.verified = true
+	result.status = success("File uploaded and its size checked!")
+	return result, nil
+}
+
+func (b webdavBackend) download(siteName string, fileID int, fileName, dest string) tea.Cmd {
+	return downloadFile(siteName, fileID, fileName, dest)
+}
+
+func (b webdavBackend) deleteFile(siteName string, fileID int, fileName string) tea.Cmd {
+	return func() tea.Msg {
+		endpoint := b.share.fileURL(fileName)
+		if href, ok := remoteKey(siteName, fileID); ok {
+			endpoint = b.share.fileURL(path.Base(href))
+		}
+		call, err := b.share.newCall(opMetadata, "DELETE", endpoint, nil)
+		if err != nil {
+			return err
+		}
+		defer call.close()
+		resp, err := call.do()
+		if err != nil {
+			return fmt.Errorf("failed to delete %s: %v", fileName, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+			return call.fail(resp, "failed to delete "+fileName)
+		}
+		return fileDeletedMsg{fileID: fileID, fileName: fileName}
+	}
+}
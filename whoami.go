@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// tokenInfo is what cshare can tell about a token without asking the
+// server. Tokens in JWT form carry their expiry and scopes; anything else
+// is opaque and only known to be there.
+type tokenInfo struct {
+	present bool
+	expires time.Time
+	scopes  []string
+}
+
+// inspectToken reads the expiry and scopes from a JWT's claims. The
+// signature isn't checked: this is for display, the server decides.
+func inspectToken(token secret) tokenInfo {
+	if token == "" {
+		return tokenInfo{}
+	}
+	info := tokenInfo{present: true}
+	parts := strings.Split(token.reveal(), ".")
+	if len(parts) != 3 {
+		return info
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return info
+	}
+	var claims struct {
+		Exp    int64    `json:"exp"`
+		Scope  string   `json:"scope"`
+		Scopes []string `json:"scopes"`
+	}
+	if json.Unmarshal(payload, &claims) != nil {
+		return info
+	}
+	if claims.Exp > 0 {
+		info.expires = time.Unix(claims.Exp, 0)
+	}
+	info.scopes = append(strings.Fields(claims.Scope), claims.Scopes...)
+	return info
+}
+
+// describe renders the token's state for the session panel.
+func (t tokenInfo) describe() string {
+	if !t.present {
+		return "none"
+	}
+	var parts []string
+	switch remaining := time.Until(t.expires); {
+	case t.expires.IsZero():
+		parts = append(parts, "expiry unknown")
+	case remaining <= 0:
+		parts = append(parts, "expired "+t.expires.Local().Format("2006-01-02 15:04"))
+	default:
+		parts = append(parts, fmt.Sprintf("expires in %s (%s)", formatRemaining(remaining), t.expires.Local().Format("2006-01-02 15:04")))
+	}
+	if len(t.scopes) > 0 {
+		parts = append(parts, "scopes "+strings.Join(t.scopes, ", "))
+	} else {
+		parts = append(parts, "scopes unknown")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sessionInfo is which servers and credentials requests would use.
+type sessionInfo struct {
+	site         string
+	siteExpires  time.Time
+	siteToken    tokenInfo
+	account      string
+	accountToken tokenInfo
+}
+
+// currentSession collects the credentials in use with site open. The
+// tokens come from the environment, which main loads .env into.
+func currentSession(site string, siteExpires time.Time) sessionInfo {
+	info := sessionInfo{
+		site:         site,
+		siteExpires:  siteExpires,
+		siteToken:    inspectToken(secret(os.Getenv("auth_token"))),
+		accountToken: inspectToken(secret(accountToken())),
+	}
+	if !accountLocked.Load() {
+		info.account = os.Getenv("account_name")
+	}
+	return info
+}
+
+// lines renders the session as "label: value" lines.
+func (s sessionInfo) lines() []string {
+	site := "none open"
+	if s.site != "" {
+		site = s.site
+		if !s.siteExpires.IsZero() {
+			site += ", expires " + s.siteExpires.Local().Format("2006-01-02 15:04")
+		}
+	}
+	account := "not signed in"
+	if s.account != "" {
+		account = s.account
+	}
+	lines := []string{
+		"Server:        " + serverURL,
+		"Login server:  " + loginServerURL,
+		"Site:          " + site,
+		"Site token:    " + s.siteToken.describe(),
+		"Account:       " + account,
+		"Account token: " + s.accountToken.describe(),
+		"",
+	}
+	switch {
+	case s.siteToken.present && s.accountToken.present:
+		lines = append(lines, "Site requests send the site token, and every request also carries the account token.")
+	case s.siteToken.present:
+		lines = append(lines, "Site requests send the site token.")
+	case s.accountToken.present:
+		lines = append(lines, "Requests carry the account token; sites are opened with it or their password.")
+	default:
+		lines = append(lines, "No credentials: sites are opened with their password.")
+	}
+	return lines
+}
+
+// openSessionPanel shows the session panel on top of the current screen.
+func openSessionPanel(m *Model) {
+	m.sessionReturn = m.state
+	m.goTo(stateSession)
+}
+
+// handleSessionInput handles input on the session panel.
+func handleSessionInput(m *Model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", keyBindings["session"]:
+		m.goTo(m.sessionReturn)
+	}
+	return m, nil
+}
+
+// renderSessionPanel renders the session panel's lines. A site name
+// that is still being typed doesn't count as open.
+func renderSessionPanel(m Model) string {
+	var info sessionInfo
+	if inSite(m.sessionReturn) {
+		info = currentSession(m.siteName, m.expiresAt)
+	} else {
+		info = currentSession("", time.Time{})
+	}
+	return strings.Join(info.lines(), "\n")
+}
+
+// runWhoamiCommand implements `cshare whoami`. Outside the TUI no site is
+// open, so the site of the last session stands in for it.
+func runWhoamiCommand(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: cshare whoami")
+	}
+	var site string
+	if saved, ok := loadSession(); ok && saved.Site != "" {
+		site = saved.Site + " (last session)"
+	}
+	for _, line := range currentSession(site, time.Time{}).lines() {
+		fmt.Println(line)
+	}
+	return nil
+}